@@ -18,58 +18,36 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+const url = "http://localhost:8881/api/v1/test-dubbo/user"
+
 func TestPost(t *testing.T) {
-	url := "http://localhost:8881/api/v1/test-dubbo/user"
 	data := "{\"id\":\"0003\",\"code\":3,\"name\":\"dubbogo\",\"age\":99}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "{\"age\":99,\"code\":3,\"iD\":\"0003\",\"name\":\"dubbogo\"}", string(s))
+	resp := testkit.Post(t, url, data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "{\"age\":99,\"code\":3,\"iD\":\"0003\",\"name\":\"dubbogo\"}", resp.String())
 }
 
 func TestPut1(t *testing.T) {
-	url := "http://localhost:8881/api/v1/test-dubbo/user"
 	data := "{\"id\":\"0003\",\"code\":3,\"name\":\"dubbogo\",\"age\":77}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("PUT", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Put(t, url, data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
 
 func TestPut2(t *testing.T) {
-	url := "http://localhost:8881/api/v1/test-dubbo/user2"
 	data := "{\"name\":\"dubbogo\",\"user\":{\"id\":\"0003\",\"code\":3,\"name\":\"dubbogo\",\"age\":88}}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("PUT", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Put(t, url+"2", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
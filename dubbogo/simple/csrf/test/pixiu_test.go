@@ -18,65 +18,36 @@
 package csrf
 
 import (
-	"io"
 	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
-	"github.com/stretchr/testify/assert"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 var token string
 
 func GetToken(t *testing.T) bool {
 	return t.Run("token", func(t *testing.T) {
-		urlStr := "http://localhost:1314/login?key=pixiu&secret=pixiu888"
-		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := http.NewRequest("GET", urlStr, nil)
-		assert.NoError(t, err)
-		resp, err := client.Do(req)
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusOK, resp.StatusCode)
-		assert.NotNil(t, resp)
-		s, _ := io.ReadAll(resp.Body)
-		token = string(s)
+		resp := testkit.Get(t, "http://localhost:1314/login?key=pixiu&secret=pixiu888")
+		testkit.AssertResponse(t, resp, http.StatusOK)
+		token = resp.String()
 	})
 }
 
 func TestCsrfHeader(t *testing.T) {
 	if GetToken(t) && token != "" {
-		urlStr := "http://localhost:8888/user/"
-		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := http.NewRequest("GET", urlStr, nil)
-		assert.NoError(t, err)
-		req.Header.Set("csrfSalt", "pixiu")
-		req.Header.Set("pixiu", token)
-		resp, err := client.Do(req)
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusOK, resp.StatusCode)
-		assert.NotNil(t, resp)
-		s, _ := io.ReadAll(resp.Body)
-		t.Log(string(s))
-		assert.True(t, strings.Contains(string(s), "success"))
+		resp := testkit.Get(t, "http://localhost:8888/user/", testkit.Header("csrfSalt", "pixiu"), testkit.Header("pixiu", token))
+		t.Log(resp.String())
+		testkit.AssertResponse(t, resp, http.StatusOK, "success")
 	}
 }
 
 func TestCsrfQuery(t *testing.T) {
 	if GetToken(t) && token != "" {
-		urlStr := "http://localhost:8888/user?pixiu=" + token
-		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := http.NewRequest("GET", urlStr, nil)
-		assert.NoError(t, err)
-		req.Header.Set("csrfSalt", "pixiu")
-		resp, err := client.Do(req)
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusOK, resp.StatusCode)
-		assert.NotNil(t, resp)
-		s, _ := io.ReadAll(resp.Body)
-		t.Log(string(s))
-		assert.True(t, strings.Contains(string(s), "success"))
+		resp := testkit.Get(t, "http://localhost:8888/user?pixiu="+token, testkit.Header("csrfSalt", "pixiu"))
+		t.Log(resp.String())
+		testkit.AssertResponse(t, resp, http.StatusOK, "success")
 	}
 }
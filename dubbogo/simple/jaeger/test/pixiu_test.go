@@ -18,46 +18,37 @@
 package test
 
 import (
-	"context"
-	"fmt"
 	"io"
-	"net/http"
 	"sort"
-	"strings"
 	"testing"
 	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 import (
 	"github.com/dubbo-go-pixiu/samples/dubbogo/simple/jaeger/grpc/api_v2"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
-func GetTracesFromJaeger(t *testing.T) []*api_v2.Span {
-	conn, err := grpc.NewClient("localhost:16685", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	require.NoError(t, err)
-	defer conn.Close()
+func GetTracesFromJaeger(t *testing.T) ([]*api_v2.Span, error) {
+	client := api_v2.NewQueryServiceClient(testkit.DialGRPC(t, "localhost:16685"))
 
 	queryParams := &api_v2.TraceQueryParameters{
 		ServiceName:  "dubbo-go-pixiu",
 		StartTimeMin: timestamppb.New(time.Now().Add(time.Duration(-5) * time.Hour)),
 		StartTimeMax: timestamppb.Now(),
 	}
-	client := api_v2.NewQueryServiceClient(conn)
-
-	serverStream, err := client.FindTraces(context.Background(), &api_v2.FindTracesRequest{
+	serverStream, err := client.FindTraces(testkit.GRPCContext(t), &api_v2.FindTracesRequest{
 		Query: queryParams,
 	})
-	require.NoError(t, err)
+	if err != nil {
+		return nil, err
+	}
 
 	spans := make([]*api_v2.Span, 0)
 	for i := 0; i < 1; i++ {
@@ -65,39 +56,36 @@ func GetTracesFromJaeger(t *testing.T) []*api_v2.Span {
 		if err == io.EOF {
 			break
 		}
-		require.NoError(t, err)
+		if err != nil {
+			return nil, err
+		}
 		spans = append(spans, spansResponseChunk.Spans...)
 	}
-	return spans
+	return spans, nil
 }
 
 func TestPost(t *testing.T) {
-	url := "http://localhost:8881/api/v1/test-dubbo/user"
 	data := "{\"id\":\"0003\",\"code\":4,\"name\":\"dubbogo2\",\"age\":99}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "{\"age\":99,\"code\":4,\"iD\":\"0003\",\"name\":\"dubbogo2\"}", string(s))
+	resp := testkit.Post(t, "http://localhost:8881/api/v1/test-dubbo/user", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "{\"age\":99,\"code\":4,\"iD\":\"0003\",\"name\":\"dubbogo2\"}", resp.String())
 }
 
 func TestFindTraces(t *testing.T) {
-	time.Sleep(5 * time.Second)
-
 	operations := []string{"DUBBOGO CLIENT", "HTTP-POST"}
-	spans := GetTracesFromJaeger(t)
-	spans = spans[len(spans)-2:] // lsat 2 spans
-	sort.Slice(spans, func(i, j int) bool {
-		return spans[i].OperationName < spans[j].OperationName
-	})
-	assert.Len(t, spans, len(operations))
-	for i := 0; i < len(operations); i++ {
-		fmt.Println(operations[i], spans[i].OperationName)
-		assert.Equal(t, operations[i], spans[i].OperationName)
-	}
+
+	// the spans are reported in batches, so they show up in jaeger a few seconds later
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		spans, err := GetTracesFromJaeger(t)
+		if !assert.NoError(c, err) || !assert.GreaterOrEqual(c, len(spans), len(operations)) {
+			return
+		}
+		spans = spans[len(spans)-len(operations):] // last 2 spans
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].OperationName < spans[j].OperationName
+		})
+		for i := 0; i < len(operations); i++ {
+			assert.Equal(c, operations[i], spans[i].OperationName)
+		}
+	}, 30*time.Second)
 }
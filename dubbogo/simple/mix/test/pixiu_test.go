@@ -18,56 +18,32 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
 func TestGet(t *testing.T) {
-	url := "http://localhost:8882/api/v1/test-dubbo/user/tc?age=99"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Get(t, "http://localhost:8882/api/v1/test-dubbo/user/tc?age=99")
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
 
 func TestPut1(t *testing.T) {
-	url := "http://localhost:8882/api/v1/test-dubbo/user/tc"
 	data := "{\"id\":\"0001\",\"code\":1,\"name\":\"tc\",\"age\":66}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("PUT", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Put(t, "http://localhost:8882/api/v1/test-dubbo/user/tc", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
 
 func TestPut2(t *testing.T) {
-	url := "http://localhost:8882/api/v1/test-dubbo/user?name=tc"
 	data := "{\"id\":\"0001\",\"code\":1,\"name\":\"tc\",\"age\":55}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("PUT", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Put(t, "http://localhost:8882/api/v1/test-dubbo/user?name=tc", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
@@ -18,32 +18,16 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
-	"github.com/stretchr/testify/assert"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 func TestPost1(t *testing.T) {
 	url := "http://localhost:8881/BDTService/com.dubbogo.pixiu.UserService/GetUserByName"
-	data := "[\"tc\"]"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	req.Header.Set("x-dubbo-http1.1-dubbo-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-protocol", "dubbo")
-	req.Header.Set("x-dubbo-service-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-group", "test")
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Post(t, url, "[\"tc\"]", testkit.JSON(), testkit.DubboService("dubbo", "1.0.0", "test"),
+		testkit.Header("x-dubbo-http1.1-dubbo-version", "1.0.0"))
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
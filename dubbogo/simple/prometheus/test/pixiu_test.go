@@ -29,8 +29,10 @@ import (
 
 import (
 	"github.com/dubbogo/gost/log/logger"
+)
 
-	"github.com/stretchr/testify/assert"
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 type _testMetric struct {
@@ -84,28 +86,18 @@ func TestLocal(t *testing.T) {
 	verify(t, "http://localhost:8888/health", http.StatusOK)
 	waitForMetric(t, metricServer, "pixiu_requests_total")
 
-	s := verify(t, "http://localhost:8888/user", http.StatusOK)
-	assert.True(t, strings.Contains(s, "user"))
+	verify(t, "http://localhost:8888/user", http.StatusOK, "user")
 	waitForMetric(t, metricServer, "pixiu_requests_total")
 
-	s = verify(t, "http://localhost:8888/user/pixiu", http.StatusOK)
-	assert.True(t, strings.Contains(s, "pixiu"))
+	verify(t, "http://localhost:8888/user/pixiu", http.StatusOK, "pixiu")
 	waitForMetric(t, metricServer, "pixiu_requests_total")
 
-	s = verify(t, "http://localhost:8888/prefix", http.StatusOK)
-	assert.True(t, strings.Contains(s, "prefix"))
+	verify(t, "http://localhost:8888/prefix", http.StatusOK, "prefix")
 	waitForMetric(t, metricServer, "pixiu_requests_total")
 }
 
-func verify(t *testing.T, url string, status int) string {
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.Equal(t, status, resp.StatusCode)
-	assert.NotNil(t, resp)
-	s, _ := io.ReadAll(resp.Body)
-	t.Log(string(s))
-	return string(s)
+func verify(t *testing.T, url string, status int, contains ...string) {
+	resp := testkit.Get(t, url)
+	t.Log(resp.String())
+	testkit.AssertResponse(t, resp, status, contains...)
 }
@@ -18,58 +18,34 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+const url = "http://localhost:8883/api/v1/test-dubbo/UserService/com.dubbogo.pixiu.UserService?group=test&version=1.0.0&method="
+
 func TestPost1(t *testing.T) {
-	url := "http://localhost:8883/api/v1/test-dubbo/UserService/com.dubbogo.pixiu.UserService?group=test&version=1.0.0&method=GetUserByName"
 	data := "{\"types\":\"string\",\"values\":\"tc\"}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Post(t, url+"GetUserByName", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
 
 func TestPost2(t *testing.T) {
-	url := "http://localhost:8883/api/v1/test-dubbo/UserService/com.dubbogo.pixiu.UserService?group=test&version=1.0.0&method=UpdateUserByName"
 	data := "{\"types\":\"string,object\",\"values\":[\"tc\",{\"id\":\"0001\",\"code\":1,\"name\":\"tc\",\"age\":15}]}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Post(t, url+"UpdateUserByName", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
 
 func TestPost3(t *testing.T) {
-	url := "http://localhost:8883/api/v1/test-dubbo/UserService/com.dubbogo.pixiu.UserService?group=test&version=1.0.0&method=GetUserByCode"
 	data := "{\"types\":\"int\",\"values\":1}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Post(t, url+"GetUserByCode", data, testkit.JSON())
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
@@ -18,52 +18,24 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
-	"github.com/stretchr/testify/assert"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 func TestGet1(t *testing.T) {
-	url := "http://localhost:8884/api/v1/test-dubbo/userByName?name=tc"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Get(t, "http://localhost:8884/api/v1/test-dubbo/userByName?name=tc")
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
 
 func TestGet2(t *testing.T) {
-	url := "http://localhost:8884/api/v1/test-dubbo/userByCode?code=1"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Get(t, "http://localhost:8884/api/v1/test-dubbo/userByCode?code=1")
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
 
 func TestGet3(t *testing.T) {
-	url := "http://localhost:8884/api/v1/test-dubbo/userByNameAndAge?name=tc&age=99"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Get(t, "http://localhost:8884/api/v1/test-dubbo/userByNameAndAge?name=tc&age=99")
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
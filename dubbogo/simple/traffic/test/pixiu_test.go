@@ -18,96 +18,39 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
-	"github.com/stretchr/testify/assert"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 func TestCanaryGET(t *testing.T) {
-	url := "http://localhost:8888/user"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("canary-by-header", "v1")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v1"`))
+	resp := testkit.Get(t, "http://localhost:8888/user", testkit.Header("canary-by-header", "v1"))
+	testkit.AssertResponse(t, resp, 200, `"server": "v1"`)
 }
 
 func TestCanaryGET1(t *testing.T) {
-	url := "http://localhost:8888/user"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v2"`))
+	resp := testkit.Get(t, "http://localhost:8888/user")
+	testkit.AssertResponse(t, resp, 200, `"server": "v2"`)
 }
 
 func TestCanaryGET2(t *testing.T) {
-	url := "http://localhost:8888/user"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("canary-by-header", "v3")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v3"`))
+	resp := testkit.Get(t, "http://localhost:8888/user", testkit.Header("canary-by-header", "v3"))
+	testkit.AssertResponse(t, resp, 200, `"server": "v3"`)
 }
 
 func TestHeaderGET1(t *testing.T) {
-	url := "http://localhost:8888/user"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("X-A", "t1")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v1"`))
+	resp := testkit.Get(t, "http://localhost:8888/user", testkit.Header("X-A", "t1"))
+	testkit.AssertResponse(t, resp, 200, `"server": "v1"`)
 }
 
 func TestHeaderGET2(t *testing.T) {
-	url := "http://localhost:8888"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("X-C", "t1")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v2"`))
+	resp := testkit.Get(t, "http://localhost:8888", testkit.Header("X-C", "t1"))
+	testkit.AssertResponse(t, resp, 200, `"server": "v2"`)
 }
 
 func TestHeaderGET3(t *testing.T) {
-	url := "http://localhost:8888"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("REG", "tt")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), `"server": "v3"`))
+	resp := testkit.Get(t, "http://localhost:8888", testkit.Header("REG", "tt"))
+	testkit.AssertResponse(t, resp, 200, `"server": "v3"`)
 }
@@ -18,30 +18,16 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
-	"github.com/stretchr/testify/assert"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 func TestPost1(t *testing.T) {
 	url := "http://localhost:8881/BDTService/org.apache.dubbogo.samples.api.Greeter/SayHello"
-	data := "{\"name\":\"test\"}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("tri-req-id", "test-req-id-1")
-	req.Header.Add("tri-req-id", "test-req-id-2")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "Hello test"))
+	resp := testkit.Post(t, url, "{\"name\":\"test\"}", testkit.JSON(),
+		testkit.Header("tri-req-id", "test-req-id-1"), testkit.Header("tri-req-id", "test-req-id-2"))
+	testkit.AssertResponse(t, resp, 200, "Hello test")
 }
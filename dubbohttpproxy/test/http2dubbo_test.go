@@ -18,71 +18,40 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+const url = "http://localhost:8888/UserService/com.dubbogo.pixiu.UserService/"
+
+var dubbo = []testkit.Option{
+	testkit.JSON(),
+	testkit.DubboService("dubbo", "1.0.0", "test"),
+	testkit.Header("x-dubbo-http1.1-dubbo-version", "1.0.0"),
+}
+
 func TestPost1(t *testing.T) {
-	url := "http://localhost:8888/UserService/com.dubbogo.pixiu.UserService/GetUserByName"
 	data := "{\"types\":\"string\",\"values\":\"tc\"}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	req.Header.Set("x-dubbo-http1.1-dubbo-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-protocol", "dubbo")
-	req.Header.Set("x-dubbo-service-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-group", "test")
-
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Post(t, url+"GetUserByName", data, dubbo...)
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
 
 func TestPost2(t *testing.T) {
-	url := "http://localhost:8888/UserService/com.dubbogo.pixiu.UserService/UpdateUserByName"
 	data := "{\"types\":\"string,object\",\"values\":[\"tc\",{\"id\":\"0001\",\"code\":1,\"name\":\"tc\",\"age\":15}]}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	req.Header.Set("x-dubbo-http1.1-dubbo-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-protocol", "dubbo")
-	req.Header.Set("x-dubbo-service-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-group", "test")
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, "true", string(s))
+	resp := testkit.Post(t, url+"UpdateUserByName", data, dubbo...)
+	testkit.AssertResponse(t, resp, 200)
+	assert.Equal(t, "true", resp.String())
 }
 
 func TestPost3(t *testing.T) {
-	url := "http://localhost:8888/UserService/com.dubbogo.pixiu.UserService/GetUserByCode"
 	data := "{\"types\":\"int\",\"values\":1}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	req.Header.Set("x-dubbo-http1.1-dubbo-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-protocol", "dubbo")
-	req.Header.Set("x-dubbo-service-version", "1.0.0")
-	req.Header.Set("x-dubbo-service-group", "test")
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
+	resp := testkit.Post(t, url+"GetUserByCode", data, dubbo...)
+	testkit.AssertResponse(t, resp, 200, "0001")
 }
@@ -18,58 +18,24 @@
 package test
 
 import (
-	"context"
 	"testing"
 )
 
 import (
-	"dubbo.apache.org/dubbo-go/v3/config"
-	"dubbo.apache.org/dubbo-go/v3/config/generic" //nolint
-	_ "dubbo.apache.org/dubbo-go/v3/imports"
-	"dubbo.apache.org/dubbo-go/v3/protocol/dubbo"
-
-	hessian "github.com/apache/dubbo-go-hessian2"
-
-	"github.com/dubbogo/gost/log/logger"
+	"github.com/stretchr/testify/assert"
 )
 
-const appName = "dubbo.io"
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/dubbotest"
+)
 
 func TestDubbo2Triple(t *testing.T) {
-	tripleRefConf := newDubboRefConf("com.dubbogo.pixiu.TripleUserService", dubbo.DUBBO)
-	resp, err := tripleRefConf.GetRPCService().(*generic.GenericService).Invoke(
-		context.TODO(),
-		"GetUserByName",
-		[]string{"java.lang.String"},
-		[]hessian.Object{"tc"},
-	)
-
-	if err != nil {
-		panic(err)
-	}
-	logger.Infof("GetUserByCode string) res: %+v", resp)
-}
-
-func newDubboRefConf(iface, protocol string) config.ReferenceConfig {
-
-	refConf := config.ReferenceConfig{
-		InterfaceName: iface,
-		Cluster:       "failover",
-		RegistryIDs:   []string{"zk"},
-		Protocol:      protocol,
-		Generic:       "true",
-		Group:         "test",
-		Version:       "1.0.0",
-		URL:           "dubbo://127.0.0.1:8888/" + iface,
-	}
-
-	rootConfig := config.NewRootConfigBuilder().
-		Build()
-	if err := config.Load(config.WithRootConfig(rootConfig)); err != nil {
-		panic(err)
-	}
-	_ = refConf.Init(rootConfig)
-	refConf.GenericLoad(appName)
-
-	return refConf
+	svc := dubbotest.NewGenericService(t, dubbotest.Reference{
+		Interface: "com.dubbogo.pixiu.TripleUserService",
+		Addr:      "127.0.0.1:8888",
+		Protocol:  dubbotest.ProtocolDubbo,
+	})
+	resp := dubbotest.Invoke(t, svc, "GetUserByName", []string{"java.lang.String"}, "tc")
+	assert.NotNil(t, resp)
+	t.Logf("GetUserByName res: %+v", resp)
 }
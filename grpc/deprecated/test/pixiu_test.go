@@ -18,22 +18,18 @@
 package test
 
 import (
-	"context"
 	"flag"
-	"log"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"github.com/stretchr/testify/require"
 )
 
 import (
 	pb "github.com/dubbo-go-pixiu/samples/http/grpc/proto"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 var (
@@ -41,19 +37,9 @@ var (
 )
 
 func TestGet(t *testing.T) {
-	flag.Parse()
-	// Set up a connection to the server.
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-	}
-	defer conn.Close()
-	c := pb.NewUserProviderClient(conn)
+	c := pb.NewUserProviderClient(testkit.DialGRPC(t, *addr))
 
-	// Contact the server and print out its response.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	r, err := c.GetUser(ctx, &pb.GetUserRequest{UserId: 1})
-	assert.NoError(t, err)
+	r, err := c.GetUser(testkit.GRPCContext(t), &pb.GetUserRequest{UserId: 1})
+	require.NoError(t, err)
 	assert.Equal(t, "user(s) query successfully", r.Message)
 }
@@ -27,13 +27,11 @@ import (
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 import (
 	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 const (
@@ -41,20 +39,12 @@ const (
 	pixiuAddr = "localhost:8881"
 )
 
-func getClient(t *testing.T) (pb.EchoServiceClient, func()) {
-	conn, err := grpc.NewClient(pixiuAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	require.NoError(t, err, "Failed to connect to Pixiu gateway")
-
-	cleanup := func() {
-		conn.Close()
-	}
-
-	return pb.NewEchoServiceClient(conn), cleanup
+func getClient(t *testing.T) pb.EchoServiceClient {
+	return pb.NewEchoServiceClient(testkit.DialGRPC(t, pixiuAddr))
 }
 
 func TestUnaryEcho(t *testing.T) {
-	client, cleanup := getClient(t)
-	defer cleanup()
+	client := getClient(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -77,8 +67,7 @@ func TestUnaryEcho(t *testing.T) {
 }
 
 func TestStreamEcho(t *testing.T) {
-	client, cleanup := getClient(t)
-	defer cleanup()
+	client := getClient(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -112,8 +101,7 @@ func TestStreamEcho(t *testing.T) {
 }
 
 func TestClientStreamEcho(t *testing.T) {
-	client, cleanup := getClient(t)
-	defer cleanup()
+	client := getClient(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -143,8 +131,7 @@ func TestClientStreamEcho(t *testing.T) {
 }
 
 func TestBidirectionalEcho(t *testing.T) {
-	client, cleanup := getClient(t)
-	defer cleanup()
+	client := getClient(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -183,8 +170,7 @@ func TestBidirectionalEcho(t *testing.T) {
 }
 
 func TestEchoWithMetadata(t *testing.T) {
-	client, cleanup := getClient(t)
-	defer cleanup()
+	client := getClient(t)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
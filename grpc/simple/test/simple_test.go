@@ -18,23 +18,19 @@
 package test
 
 import (
-	"context"
 	"io"
 	"math/rand/v2"
 	"sync"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 import (
 	pb "github.com/dubbo-go-pixiu/samples/grpc/simple/routeguide"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 const (
@@ -42,12 +38,7 @@ const (
 )
 
 func TestRouteGuide(t *testing.T) {
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	conn, err := grpc.NewClient(serverAddr, opts...)
-	assert.NoError(t, err, "fail to dial")
-	defer conn.Close()
-	client := pb.NewRouteGuideClient(conn)
+	client := pb.NewRouteGuideClient(testkit.DialGRPC(t, serverAddr))
 
 	t.Run("unary", func(t *testing.T) {
 		// Looking for a valid feature
@@ -76,8 +67,7 @@ func TestRouteGuide(t *testing.T) {
 // printFeature gets the feature for the given point.
 func printFeature(t *testing.T, client pb.RouteGuideClient, point *pb.Point) {
 	t.Logf("Getting feature for point (%d, %d)", point.Latitude, point.Longitude)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := testkit.GRPCContext(t)
 	feature, err := client.GetFeature(ctx, point)
 	assert.NoError(t, err, "client.GetFeature should not fail for a valid feature")
 	assert.Equal(t, "Berkshire Valley Management Area Trail, Jefferson, NJ, USA", feature.Name)
@@ -87,8 +77,7 @@ func printFeature(t *testing.T, client pb.RouteGuideClient, point *pb.Point) {
 // printMissingFeature gets a feature that doesn't exist.
 func printMissingFeature(t *testing.T, client pb.RouteGuideClient, point *pb.Point) {
 	t.Logf("Getting feature for point (%d, %d)", point.Latitude, point.Longitude)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := testkit.GRPCContext(t)
 	feature, err := client.GetFeature(ctx, point)
 	assert.NoError(t, err, "client.GetFeature should not fail for a missing feature")
 	assert.Empty(t, feature.Name, "Feature name should be empty for a missing feature")
@@ -98,8 +87,7 @@ func printMissingFeature(t *testing.T, client pb.RouteGuideClient, point *pb.Poi
 // printFeatures lists all the features within the given bounding Rectangle.
 func printFeatures(t *testing.T, client pb.RouteGuideClient, rect *pb.Rectangle) {
 	t.Logf("Looking for features within %v", rect)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := testkit.GRPCContext(t)
 	stream, err := client.ListFeatures(ctx, rect)
 	assert.NoError(t, err, "client.ListFeatures should not fail")
 
@@ -126,8 +114,7 @@ func runRecordRoute(t *testing.T, client pb.RouteGuideClient) {
 		points = append(points, randomPoint())
 	}
 	t.Logf("Traversing %d points.", len(points))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := testkit.GRPCContext(t)
 	stream, err := client.RecordRoute(ctx)
 	assert.NoError(t, err, "client.RecordRoute should not fail")
 
@@ -152,8 +139,7 @@ func runRouteChat(t *testing.T, client pb.RouteGuideClient) {
 		{Location: &pb.Point{Latitude: baseLatitude, Longitude: 2}, Message: "Fifth message"},
 		{Location: &pb.Point{Latitude: baseLatitude, Longitude: 3}, Message: "Sixth message"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := testkit.GRPCContext(t)
 	stream, err := client.RouteChat(ctx)
 	assert.NoError(t, err, "client.RouteChat should not fail")
 
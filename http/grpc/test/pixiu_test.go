@@ -18,13 +18,8 @@
 package test
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
 	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
@@ -33,6 +28,7 @@ import (
 
 import (
 	"github.com/dubbo-go-pixiu/samples/http/grpc/proto"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 const (
@@ -41,17 +37,10 @@ const (
 )
 
 func TestGet(t *testing.T) {
-	c := http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest(http.MethodGet, url, bytes.NewReader([]byte{}))
-	assert.NoError(t, err)
-	resp, err := c.Do(req)
-	assert.NoError(t, err)
-	body, err := io.ReadAll(resp.Body)
-	assert.NoError(t, err)
-	assert.NotNil(t, body)
+	resp := testkit.Get(t, url)
+	testkit.AssertResponse(t, resp, http.StatusOK)
 	var r proto.GetUserResponse
-	err = json.Unmarshal(body, &r)
-	assert.NoError(t, err)
+	testkit.DecodeJSON(t, resp, &r)
 	assert.Equal(t, "user(s) query successfully", r.Message)
 	assert.Equal(t, 2, len(r.Users))
 	assert.Equal(t, int32(1), r.Users[0].UserId)
@@ -61,17 +50,10 @@ func TestGet(t *testing.T) {
 }
 
 func TestPost(t *testing.T) {
-	c := http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(data))
-	assert.NoError(t, err)
-	resp, err := c.Do(req)
-	assert.NoError(t, err)
-	body, err := io.ReadAll(resp.Body)
-	assert.NoError(t, err)
-	assert.NotNil(t, body)
+	resp := testkit.Post(t, url, data)
+	testkit.AssertResponse(t, resp, http.StatusOK)
 	var r proto.GetUserResponse
-	err = json.Unmarshal(body, &r)
-	assert.NoError(t, err)
+	testkit.DecodeJSON(t, resp, &r)
 	assert.Equal(t, "user(s) query successfully", r.Message)
 	assert.Equal(t, 1, len(r.Users))
 	assert.Equal(t, int32(1), r.Users[0].UserId)
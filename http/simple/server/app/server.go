@@ -18,80 +18,15 @@
 package main
 
 import (
-	"encoding/json"
-	"io"
 	"log"
-	"math/rand"
 	"net/http"
-	"strings"
 )
 
 import (
-	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
+	"github.com/dubbo-go-pixiu/samples/http/simple/server/backend"
 )
 
 func main() {
-	http.HandleFunc("/user/", user)
 	log.Println("Starting sample server ...")
-	log.Fatal(http.ListenAndServe(":1314", nil))
-}
-
-func user(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case constant.Post:
-		byts, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.Write([]byte(err.Error()))
-		}
-		var user User
-		err = json.Unmarshal(byts, &user)
-		if err != nil {
-			w.Write([]byte(err.Error()))
-		}
-		_, ok := cache.Get(user.Name)
-		if ok {
-			w.Header().Set(constant.HeaderKeyContextType, constant.HeaderValueJsonUtf8)
-			w.Write([]byte("{\"message\":\"data is exist\"}"))
-			return
-		}
-		user.ID = randSeq(5)
-		if cache.Add(&user) {
-			b, _ := json.Marshal(&user)
-			w.Header().Set(constant.HeaderKeyContextType, constant.HeaderValueJsonUtf8)
-			w.Write(b)
-			return
-		}
-		w.Write(nil)
-	case constant.Get:
-		subPath := strings.TrimPrefix(r.URL.Path, "/user/")
-		userName := strings.Split(subPath, "/")[0]
-		var u *User
-		var b bool
-		if len(userName) != 0 {
-			log.Printf("paths: %v", userName)
-			u, b = cache.Get(userName)
-		} else {
-			q := r.URL.Query()
-			u, b = cache.Get(q.Get("name"))
-		}
-		// w.WriteHeader(200)
-		if b {
-			b, _ := json.Marshal(u)
-			w.Header().Set(constant.HeaderKeyContextType, constant.HeaderValueJsonUtf8)
-			w.Write(b)
-			return
-		}
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(nil)
-	}
-}
-
-var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-
-func randSeq(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
+	log.Fatal(http.ListenAndServe(":1314", backend.NewHandler()))
 }
@@ -18,11 +18,7 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
@@ -31,37 +27,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+// pixiuURL is the gateway, hermetic_test.go moves it when the sample runs in process
+var pixiuURL = "http://localhost:8888"
+
 func TestPost(t *testing.T) {
-	url := "http://localhost:8888/user/"
 	data := "{\"id\":\"0003\",\"code\":3,\"name\":\"dubbogo\",\"age\":99}"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", url, strings.NewReader(data))
-	assert.NoError(t, err)
-	req.Header.Add("Origin", "api.dubbo.com")
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "dubbogo"))
-	ao := resp.Header.Get(constant.HeaderKeyAccessControlAllowOrigin)
-	assert.Equal(t, "api.dubbo.com", ao)
+	resp := testkit.Post(t, pixiuURL+"/user/", data, testkit.Header("Origin", "api.dubbo.com"), testkit.JSON())
+	testkit.AssertResponse(t, resp, 200, "dubbogo")
+	assert.Equal(t, "api.dubbo.com", resp.Header.Get(constant.HeaderKeyAccessControlAllowOrigin))
 }
 
 func TestGET1(t *testing.T) {
-	url := "http://localhost:8888/user/tc"
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("Origin", "api.dubbo.com")
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 200, resp.StatusCode)
-	s, _ := io.ReadAll(resp.Body)
-	assert.True(t, strings.Contains(string(s), "0001"))
-	ao := resp.Header.Get(constant.HeaderKeyAccessControlAllowOrigin)
-	assert.Equal(t, "api.dubbo.com", ao)
+	resp := testkit.Get(t, pixiuURL+"/user/tc", testkit.Header("Origin", "api.dubbo.com"), testkit.JSON())
+	testkit.AssertResponse(t, resp, 200)
+	testkit.AssertGoldenJSON(t, "user_tc", resp.Body)
+	assert.Equal(t, "api.dubbo.com", resp.Header.Get(constant.HeaderKeyAccessControlAllowOrigin))
 }
@@ -17,35 +17,9 @@
 #
 
 if [ -z "$1" ]; then
-  echo "Provide test directory please, like : ./integrate_test.sh $(pwd)/samples/simple/server ."
-  exit
+  echo "Provide test directory please, like : ./integrate_test.sh http/simple ."
+  exit 1
 fi
 
-P_DIR=$(pwd)/$1
-PIXIU_DIR=$(pwd)
-
-# prepare
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile prepare
-
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile docker-up
-sleep 2
-# test
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile docker-health-check
-
-# start server
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile start
-sleep 2
-# start pixiu
-make CGO=on PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile buildPixiu
-sleep 2
-# start integration
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile integration
-result=$?
-# stop server
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile clean
-
-make PROJECT_DIR=$P_DIR PIXIU_DIR=$PIXIU_DIR PROJECT_NAME=$(basename $P_DIR) BASE_DIR=$P_DIR/dist -f igt/Makefile docker-down
-
-sleep 1
-
-exit $((result))
+# the sample is described by its sample.yaml, see tools/igt
+go run ./tools/igt "$@"
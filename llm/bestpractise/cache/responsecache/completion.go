@@ -0,0 +1,228 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+// completion is what the cache keeps of a chat completion, a stream is kept as the completion its
+// chunks add up to
+type completion struct {
+	ID      string          `json:"id"`
+	Object  string          `json:"object"`
+	Created int64           `json:"created"`
+	Model   string          `json:"model"`
+	Choices []*choice       `json:"choices"`
+	Usage   json.RawMessage `json:"usage,omitempty"`
+
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+}
+
+type choice struct {
+	Index        int      `json:"index"`
+	Message      *message `json:"message,omitempty"`
+	Delta        *delta   `json:"delta,omitempty"`
+	FinishReason *string  `json:"finish_reason"`
+}
+
+type message struct {
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []toolCall `json:"tool_calls,omitempty"`
+}
+
+type delta struct {
+	Role             string     `json:"role,omitempty"`
+	Content          string     `json:"content,omitempty"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	// Index is only sent in the chunks of a stream
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// tokens returns the prompt and completion tokens of the usage
+func (c *completion) tokens() (int64, int64) {
+	var u struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	}
+	_ = json.Unmarshal(c.Usage, &u)
+	return u.PromptTokens, u.CompletionTokens
+}
+
+// parseCompletion reads a json completion or a server sent event stream, a stream is only
+// complete with its [DONE] or the finish reason of every choice
+func parseCompletion(body []byte) (*completion, bool) {
+	if llmfilter.IsJSON(body) {
+		var c completion
+		if json.Unmarshal(body, &c) != nil || len(c.Choices) == 0 {
+			return nil, false
+		}
+		for _, ch := range c.Choices {
+			if ch.Message == nil {
+				return nil, false
+			}
+		}
+		return &c, true
+	}
+
+	c := &completion{}
+	done, broken := false, false
+	llmfilter.Events(body, func(data []byte) bool {
+		if string(data) == "[DONE]" {
+			done = true
+			return true
+		}
+		var chunk completion
+		if json.Unmarshal(data, &chunk) != nil {
+			broken = true
+			return false
+		}
+		c.add(&chunk)
+		return true
+	})
+	if broken || len(c.Choices) == 0 {
+		return nil, false
+	}
+	for _, ch := range c.Choices {
+		if ch.FinishReason == nil && !done {
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+// add merges a chunk of a stream into the completion
+func (c *completion) add(chunk *completion) {
+	if c.ID == "" {
+		c.ID, c.Created, c.Model, c.SystemFingerprint = chunk.ID, chunk.Created, chunk.Model, chunk.SystemFingerprint
+	}
+	if len(chunk.Usage) > 0 && string(chunk.Usage) != "null" {
+		c.Usage = chunk.Usage
+	}
+	for _, part := range chunk.Choices {
+		ch := c.choice(part.Index)
+		if part.FinishReason != nil {
+			ch.FinishReason = part.FinishReason
+		}
+		if part.Delta == nil {
+			continue
+		}
+		msg := ch.Message
+		if part.Delta.Role != "" {
+			msg.Role = part.Delta.Role
+		}
+		msg.Content += part.Delta.Content
+		msg.ReasoningContent += part.Delta.ReasoningContent
+		for _, call := range part.Delta.ToolCalls {
+			i := len(msg.ToolCalls)
+			if call.Index != nil {
+				i = *call.Index
+			}
+			for len(msg.ToolCalls) <= i {
+				msg.ToolCalls = append(msg.ToolCalls, toolCall{})
+			}
+			merged := &msg.ToolCalls[i]
+			if call.ID != "" {
+				merged.ID, merged.Type = call.ID, call.Type
+			}
+			if call.Function.Name != "" {
+				merged.Function.Name = call.Function.Name
+			}
+			merged.Function.Arguments += call.Function.Arguments
+		}
+	}
+}
+
+func (c *completion) choice(index int) *choice {
+	for _, ch := range c.Choices {
+		if ch.Index == index {
+			return ch
+		}
+	}
+	ch := &choice{Index: index, Message: &message{Role: "assistant"}}
+	c.Choices = append(c.Choices, ch)
+	return ch
+}
+
+// json returns the completion as the response of a request without stream
+func (c *completion) json() []byte {
+	reply := *c
+	reply.Object = "chat.completion"
+	data, _ := json.Marshal(reply)
+	return data
+}
+
+// stream replays the completion as the chunks of a stream, a chunk per word like a provider, with
+// the usage in the last chunk when the request asks for it
+func (c *completion) stream(includeUsage bool) []byte {
+	var out bytes.Buffer
+	send := func(index int, d *delta, finish *string, usage json.RawMessage) {
+		chunk := completion{ID: c.ID, Object: "chat.completion.chunk", Created: c.Created, Model: c.Model,
+			Choices: []*choice{{Index: index, Delta: d, FinishReason: finish}}, Usage: usage, SystemFingerprint: c.SystemFingerprint}
+		data, _ := json.Marshal(chunk)
+		out.WriteString("data: ")
+		out.Write(data)
+		out.WriteString("\n\n")
+	}
+	for i, ch := range c.Choices {
+		msg := ch.Message
+		send(ch.Index, &delta{Role: msg.Role}, nil, nil)
+		if msg.ReasoningContent != "" {
+			send(ch.Index, &delta{ReasoningContent: msg.ReasoningContent}, nil, nil)
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]toolCall, len(msg.ToolCalls))
+			for j := range msg.ToolCalls {
+				index := j
+				calls[j] = msg.ToolCalls[j]
+				calls[j].Index = &index
+			}
+			send(ch.Index, &delta{ToolCalls: calls}, nil, nil)
+		}
+		for _, word := range strings.SplitAfter(msg.Content, " ") {
+			if word != "" {
+				send(ch.Index, &delta{Content: word}, nil, nil)
+			}
+		}
+		var usage json.RawMessage
+		if includeUsage && i == len(c.Choices)-1 {
+			usage = c.Usage
+		}
+		send(ch.Index, &delta{}, ch.FinishReason, usage)
+	}
+	out.WriteString("data: [DONE]\n\n")
+	return out.Bytes()
+}
@@ -0,0 +1,78 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStream(t *testing.T) {
+	stream := `data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"role":"assistant"},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"current_time","arguments":"{\"timezone\":"}}]},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":" \"UTC\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":9,"completion_tokens":5,"total_tokens":14}}
+
+data: [DONE]
+
+`
+	c, ok := parseCompletion([]byte(stream))
+	require.True(t, ok)
+	require.Len(t, c.Choices, 1)
+	msg := c.Choices[0].Message
+	assert.Equal(t, "assistant", msg.Role)
+	require.Len(t, msg.ToolCalls, 1)
+	assert.Equal(t, "call_1", msg.ToolCalls[0].ID)
+	assert.Equal(t, "current_time", msg.ToolCalls[0].Function.Name)
+	assert.Equal(t, `{"timezone": "UTC"}`, msg.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", *c.Choices[0].FinishReason)
+
+	// the replay adds up to the same completion
+	replayed, ok := parseCompletion(c.stream(true))
+	require.True(t, ok)
+	assert.Equal(t, c, replayed)
+
+	replayed, ok = parseCompletion(c.stream(false))
+	require.True(t, ok)
+	assert.Empty(t, replayed.Usage, "the usage is only replayed when the request asks for it")
+}
+
+func TestParseIncomplete(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{name: "cut stream", body: "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"1+1\"},\"finish_reason\":null}]}\n\n"},
+		{name: "error", body: `{"error":{"message":"rate limited"}}`},
+		{name: "chunk", body: `{"id":"c1","choices":[{"index":0,"delta":{"content":"1+1"}}]}`},
+		{name: "empty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := parseCompletion([]byte(tc.body))
+			assert.False(t, ok)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package responsecache is the dgp.filter.llm.responsecache http filter. It keeps the replies of
+// the chat completions by model and normalized messages, and serves the repeated requests from the
+// cache instead of the provider, as json or replayed as a stream.
+package responsecache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+const (
+	// Kind is the kind of the filter
+	Kind = "dgp.filter.llm.responsecache"
+
+	// HeaderCache tells the client whether the reply is a HIT, a MISS or a BYPASS of the cache
+	HeaderCache = "X-Pixiu-Cache"
+
+	defaultTTL        = 10 * time.Minute
+	defaultMaxEntries = 1000
+)
+
+func init() {
+	filter.RegisterHttpFilter(&Plugin{})
+}
+
+type (
+	// Plugin is the response cache plugin
+	Plugin struct {
+	}
+
+	// FilterFactory holds the cache of the filters
+	FilterFactory struct {
+		cfg     *Config
+		ttl     time.Duration
+		store   *store
+		metrics *metrics
+		// now is replaced by the tests
+		now func() time.Time
+	}
+
+	// Filter serves a request from the cache, or keeps its reply
+	Filter struct {
+		factory *FilterFactory
+		request *request
+		writer  *llmfilter.Writer
+	}
+
+	// Config is the config of the filter
+	Config struct {
+		// TTL of a reply, 10m when empty
+		TTL string `yaml:"ttl" json:"ttl" mapstructure:"ttl"`
+		// MaxEntries is the most replies kept, the least recently used one goes first. 1000 when 0
+		MaxEntries int `yaml:"max_entries" json:"max_entries" mapstructure:"max_entries"`
+		// Models whose replies are cached, a trailing * matches a prefix. Every model when empty
+		Models []string `yaml:"models" json:"models" mapstructure:"models"`
+		// IgnoreCase makes the messages that only differ in case share a reply
+		IgnoreCase bool `yaml:"ignore_case" json:"ignore_case" mapstructure:"ignore_case"`
+		// Shared makes the callers share the replies, a caller only gets its own by default. The caller
+		// is told by its Authorization header.
+		Shared bool `yaml:"shared" json:"shared" mapstructure:"shared"`
+	}
+)
+
+func (p *Plugin) Kind() string {
+	return Kind
+}
+
+func (p *Plugin) CreateFilterFactory() (filter.HttpFilterFactory, error) {
+	return &FilterFactory{cfg: &Config{}, now: time.Now}, nil
+}
+
+func (factory *FilterFactory) Config() interface{} {
+	return factory.cfg
+}
+
+func (factory *FilterFactory) Apply() error {
+	factory.ttl = defaultTTL
+	if factory.cfg.TTL != "" {
+		ttl, err := time.ParseDuration(factory.cfg.TTL)
+		if err != nil {
+			return fmt.Errorf("%s: ttl: %w", Kind, err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("%s: ttl %s is not positive", Kind, factory.cfg.TTL)
+		}
+		factory.ttl = ttl
+	}
+	maxEntries := factory.cfg.MaxEntries
+	if maxEntries < 0 {
+		return fmt.Errorf("%s: max_entries %d is negative", Kind, maxEntries)
+	}
+	if maxEntries == 0 {
+		maxEntries = defaultMaxEntries
+	}
+	for _, model := range factory.cfg.Models {
+		if model == "" {
+			return fmt.Errorf("%s: a model is empty", Kind)
+		}
+	}
+	factory.store = newStore(maxEntries)
+	var err error
+	if factory.metrics, err = registerMetrics(factory.store); err != nil {
+		return fmt.Errorf("%s: register the metrics: %w", Kind, err)
+	}
+	return nil
+}
+
+func (factory *FilterFactory) PrepareFilterChain(ctx *contexthttp.HttpContext, chain filter.FilterChain) error {
+	f := &Filter{factory: factory}
+	chain.AppendDecodeFilters(f)
+	chain.AppendEncodeFilters(f)
+	return nil
+}
+
+// caches tells whether the replies of the model are cached
+func (factory *FilterFactory) caches(model string) bool {
+	if len(factory.cfg.Models) == 0 {
+		return true
+	}
+	for _, pattern := range factory.cfg.Models {
+		if llmfilter.MatchModel(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode answers a request from the cache. The reply is handed to pixiu like the response of an
+// upstream, so the filters after the cache are skipped and the encode filters still see it: a
+// dgp.filter.llm.tokenquota before the cache checks the key and the budget of a hit and counts its
+// tokens, one after it never sees a hit. The cache goes before dgp.filter.llm.keyvault, which puts
+// the key of the provider in place of the one of the caller.
+func (f *Filter) Decode(c *contexthttp.HttpContext) filter.FilterStatus {
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return filter.Continue
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		logger.Warnf("[dubbo-go-pixiu] %s: read body: %v", Kind, err)
+		return filter.Continue
+	}
+	caller := ""
+	if !f.factory.cfg.Shared {
+		caller = c.Request.Header.Get("Authorization")
+	}
+	r, ok := parseRequest(body, f.factory.cfg.IgnoreCase, caller)
+	if !ok || !f.factory.caches(r.model) {
+		return filter.Continue
+	}
+
+	noStore, noCache, maxAge := cacheControl(c.Request.Header.Get("Cache-Control"))
+	if noStore || noCache {
+		c.AddHeader(HeaderCache, "BYPASS")
+		f.factory.metrics.request(c.Ctx, r.model, resultBypass)
+		if !noStore {
+			f.keep(c, r)
+		}
+		return filter.Continue
+	}
+
+	now := f.factory.now()
+	if e, ok := f.factory.store.get(r.key, now, maxAge); ok {
+		f.factory.metrics.hit(c.Ctx, r.model, e.completion)
+		logger.Debugf("[dubbo-go-pixiu] %s: reply of %s served from the cache", Kind, r.model)
+		header := http.Header{}
+		header.Set(HeaderCache, "HIT")
+		header.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+		var reply []byte
+		if r.stream {
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("Cache-Control", "no-cache")
+			reply = e.completion.stream(r.includeUsage)
+		} else {
+			header.Set("Content-Type", "application/json")
+			reply = e.completion.json()
+		}
+		c.SourceResp = &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(reply))}
+		return filter.Stop
+	}
+	c.AddHeader(HeaderCache, "MISS")
+	f.factory.metrics.request(c.Ctx, r.model, resultMiss)
+	f.keep(c, r)
+	return filter.Continue
+}
+
+// keep records the reply of the request to cache it
+func (f *Filter) keep(c *contexthttp.HttpContext, r *request) {
+	f.request = r
+	f.writer = llmfilter.NewWriter(c.Writer)
+	c.Writer = f.writer
+}
+
+// Encode caches a complete reply, written to the client by the proxy itself, like a stream, or
+// returned to pixiu
+func (f *Filter) Encode(c *contexthttp.HttpContext) filter.FilterStatus {
+	if f.request == nil {
+		return filter.Continue
+	}
+	var (
+		status int
+		body   []byte
+	)
+	switch {
+	case f.writer.Overflow:
+		return filter.Continue
+	case f.writer.Wrote:
+		status, body = f.writer.Status, f.writer.Body.Bytes()
+	case c.TargetResp != nil && !c.LocalReply():
+		status, body = c.GetStatusCode(), c.TargetResp.Data
+	}
+	if status != http.StatusOK {
+		return filter.Continue
+	}
+	reply, ok := parseCompletion(body)
+	if !ok {
+		logger.Debugf("[dubbo-go-pixiu] %s: the reply of %s is not a complete chat completion", Kind, f.request.model)
+		return filter.Continue
+	}
+	f.factory.store.put(f.request.key, reply, f.factory.now(), f.factory.ttl)
+	return filter.Continue
+}
+
+// cacheControl reads the directives of the Cache-Control header of a request: no-store bypasses the
+// cache, no-cache asks the provider and caches its reply, max-age=N takes the replies of the last N
+// seconds only. maxAge is negative without max-age.
+func cacheControl(value string) (noStore, noCache bool, maxAge time.Duration) {
+	maxAge = -1
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil && seconds >= 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return noStore, noCache, maxAge
+}
@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/client"
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	"github.com/apache/dubbo-go-pixiu/pkg/context/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reply = `{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"deepseek-chat",` +
+	`"choices":[{"index":0,"message":{"role":"assistant","content":"1+1 equals 2."},"finish_reason":"stop"}],` +
+	`"usage":{"prompt_tokens":4,"completion_tokens":3,"total_tokens":7}}`
+
+type gateway struct {
+	factory  *FilterFactory
+	now      time.Time
+	upstream int
+}
+
+func newGateway(t *testing.T, cfg *Config) *gateway {
+	g := &gateway{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	g.factory = &FilterFactory{cfg: cfg, now: func() time.Time { return g.now }}
+	require.NoError(t, g.factory.Apply())
+	return g
+}
+
+// send passes a request through the filter, the upstream answers with reply when the cache does
+// not, and returns the header of the cache and the body sent to the client
+func (g *gateway) send(t *testing.T, body string, header http.Header) (string, string) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8888/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	for name := range header {
+		req.Header.Set(name, header.Get(name))
+	}
+	c := mock.GetMockHTTPContext(req)
+	recorder := httptest.NewRecorder()
+	c.Writer = recorder
+	f := &Filter{factory: g.factory}
+
+	if f.Decode(c) == filter.Stop {
+		resp := c.SourceResp.(*http.Response)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get(HeaderCache), string(data)
+	}
+	g.upstream++
+	c.StatusCode(http.StatusOK)
+	c.TargetResp = &client.Response{Data: []byte(reply)}
+	f.Encode(c)
+	return recorder.Header().Get(HeaderCache), reply
+}
+
+func TestHitAndMiss(t *testing.T) {
+	g := newGateway(t, &Config{})
+
+	result, _ := g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+	assert.Equal(t, "MISS", result)
+	result, body := g.send(t, `{"messages":[{"role":"user","content":"  1+1=?\n"}],"model":"deepseek-chat"}`, nil)
+	assert.Equal(t, "HIT", result, "the spacing and the order of the fields do not matter")
+	assert.JSONEq(t, reply, body)
+	assert.Equal(t, 1, g.upstream)
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{name: "model", body: `{"model":"deepseek-reasoner","messages":[{"role":"user","content":"1+1=?"}]}`},
+		{name: "stop", body: `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}],"stop":["\n"]}`},
+		{name: "temperature", body: `{"model":"deepseek-chat","temperature":0.7,"messages":[{"role":"user","content":"1+1=?"}]}`},
+		{name: "system", body: `{"model":"deepseek-chat","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"1+1=?"}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, _ := g.send(t, tc.body, nil)
+			assert.Equal(t, "MISS", result)
+		})
+	}
+}
+
+func TestIgnoreCase(t *testing.T) {
+	g := newGateway(t, &Config{IgnoreCase: true})
+	g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"Hello there"}]}`, nil)
+	result, _ := g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":[{"type":"text","text":"HELLO   there"}]}]}`, nil)
+	assert.Equal(t, "MISS", result, "a string and its parts are not the same messages")
+	result, _ = g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"HELLO   there"}]}`, nil)
+	assert.Equal(t, "HIT", result)
+}
+
+func TestShared(t *testing.T) {
+	body := `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`
+	alice := http.Header{"Authorization": {"Bearer sk-alice"}}
+	bob := http.Header{"Authorization": {"Bearer sk-bob"}}
+
+	g := newGateway(t, &Config{})
+	g.send(t, body, alice)
+	result, _ := g.send(t, body, bob)
+	assert.Equal(t, "MISS", result, "a caller does not get the replies of another")
+	result, _ = g.send(t, body, alice)
+	assert.Equal(t, "HIT", result)
+
+	g = newGateway(t, &Config{Shared: true})
+	g.send(t, body, alice)
+	result, _ = g.send(t, body, bob)
+	assert.Equal(t, "HIT", result)
+}
+
+func TestStreamReplay(t *testing.T) {
+	g := newGateway(t, &Config{})
+	g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+
+	result, body := g.send(t, `{"model":"deepseek-chat","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+	assert.Equal(t, "HIT", result, "the stream of a request shares the reply of its json")
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+	replayed, ok := parseCompletion([]byte(body))
+	require.True(t, ok)
+	assert.Equal(t, "1+1 equals 2.", replayed.Choices[0].Message.Content)
+	assert.Equal(t, "stop", *replayed.Choices[0].FinishReason)
+	prompt, completion := replayed.tokens()
+	assert.Equal(t, int64(4), prompt)
+	assert.Equal(t, int64(3), completion)
+}
+
+func TestCacheControl(t *testing.T) {
+	g := newGateway(t, &Config{TTL: "1m"})
+	body := `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`
+
+	result, _ := g.send(t, body, http.Header{"Cache-Control": {"no-store"}})
+	assert.Equal(t, "BYPASS", result)
+	result, _ = g.send(t, body, nil)
+	assert.Equal(t, "MISS", result, "no-store does not cache the reply")
+
+	result, _ = g.send(t, body, http.Header{"Cache-Control": {"no-cache"}})
+	assert.Equal(t, "BYPASS", result)
+	assert.Equal(t, 3, g.upstream)
+
+	g.now = g.now.Add(30 * time.Second)
+	result, _ = g.send(t, body, http.Header{"Cache-Control": {"max-age=10"}})
+	assert.Equal(t, "MISS", result, "the reply is older than max-age")
+	result, _ = g.send(t, body, http.Header{"Cache-Control": {"max-age=10"}})
+	assert.Equal(t, "HIT", result)
+
+	g.now = g.now.Add(time.Minute)
+	result, _ = g.send(t, body, nil)
+	assert.Equal(t, "MISS", result, "the ttl is over")
+}
+
+func TestModels(t *testing.T) {
+	g := newGateway(t, &Config{Models: []string{"deepseek-*"}})
+	for i := 0; i < 2; i++ {
+		result, _ := g.send(t, `{"model":"qwen2.5","messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+		assert.Empty(t, result)
+	}
+	g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+	result, _ := g.send(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`, nil)
+	assert.Equal(t, "HIT", result)
+	assert.Equal(t, 3, g.upstream)
+}
+
+func TestMaxEntries(t *testing.T) {
+	g := newGateway(t, &Config{MaxEntries: 2})
+	question := func(q string) string {
+		return `{"model":"deepseek-chat","messages":[{"role":"user","content":"` + q + `"}]}`
+	}
+	g.send(t, question("a"), nil)
+	g.send(t, question("b"), nil)
+	g.send(t, question("a"), nil)
+	g.send(t, question("c"), nil)
+
+	// b is the least recently used
+	result, _ := g.send(t, question("a"), nil)
+	assert.Equal(t, "HIT", result)
+	result, _ = g.send(t, question("b"), nil)
+	assert.Equal(t, "MISS", result)
+	assert.Equal(t, 2, g.factory.store.len())
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{name: "ttl", cfg: &Config{TTL: "ten minutes"}, err: "ttl"},
+		{name: "zero ttl", cfg: &Config{TTL: "0s"}, err: "not positive"},
+		{name: "max entries", cfg: &Config{MaxEntries: -1}, err: "negative"},
+		{name: "empty model", cfg: &Config{Models: []string{""}}, err: "empty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&FilterFactory{cfg: tc.cfg, now: time.Now}).Apply()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
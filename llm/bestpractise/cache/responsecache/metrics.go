@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"context"
+)
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+const (
+	resultHit    = "hit"
+	resultMiss   = "miss"
+	resultBypass = "bypass"
+)
+
+// metrics of the cache, exported on the prometheus port of pixiu. The exporter of pixiu adds no
+// suffix, the counters are named with _total like the other llm metrics.
+type metrics struct {
+	requests syncint64.Counter
+	saved    syncint64.Counter
+}
+
+func registerMetrics(s *store) (*metrics, error) {
+	meter := global.MeterProvider().Meter("pixiu")
+	m := &metrics{}
+	var err error
+	if m.requests, err = meter.SyncInt64().Counter("pixiu_llm_cache_requests_total",
+		instrument.WithDescription("chat requests by model and result, hit, miss or bypass")); err != nil {
+		return nil, err
+	}
+	if m.saved, err = meter.SyncInt64().Counter("pixiu_llm_cache_saved_tokens_total",
+		instrument.WithDescription("tokens of the replies served from the cache, by model and type prompt or completion")); err != nil {
+		return nil, err
+	}
+	entries, err := meter.AsyncInt64().Gauge("pixiu_llm_cache_entries",
+		instrument.WithDescription("replies in the cache"))
+	if err != nil {
+		return nil, err
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{entries}, func(ctx context.Context) {
+		entries.Observe(ctx, int64(s.len()))
+	})
+	return m, err
+}
+
+func (m *metrics) request(ctx context.Context, model, result string) {
+	m.requests.Add(ctx, 1, attribute.String("model", model), attribute.String("result", result))
+}
+
+func (m *metrics) hit(ctx context.Context, model string, c *completion) {
+	m.request(ctx, model, resultHit)
+	prompt, completion := c.tokens()
+	m.saved.Add(ctx, prompt, attribute.String("model", model), attribute.String("type", "prompt"))
+	m.saved.Add(ctx, completion, attribute.String("model", model), attribute.String("type", "completion"))
+}
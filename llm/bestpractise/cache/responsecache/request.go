@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// request is what the cache needs of a chat request
+type request struct {
+	model        string
+	stream       bool
+	includeUsage bool
+	// key is the hash of the request without what does not change the reply, like stream
+	key string
+}
+
+// ignoredFields do not change the reply of a request
+var ignoredFields = []string{"stream", "stream_options", "user", "metadata"}
+
+// parseRequest keys a chat request on its model, its normalized messages and the parameters of
+// the completion, so requests that only differ in the spacing of the messages share an entry. The
+// requests of another caller do not, unless caller is empty.
+func parseRequest(body []byte, ignoreCase bool, caller string) (*request, bool) {
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return nil, false
+	}
+	r := &request{}
+	r.model, _ = fields["model"].(string)
+	messages, _ := fields["messages"].([]interface{})
+	if r.model == "" || len(messages) == 0 {
+		return nil, false
+	}
+	r.stream, _ = fields["stream"].(bool)
+	if options, ok := fields["stream_options"].(map[string]interface{}); ok {
+		r.includeUsage, _ = options["include_usage"].(bool)
+	}
+
+	for _, name := range ignoredFields {
+		delete(fields, name)
+	}
+	for _, m := range messages {
+		msg, ok := m.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		switch content := msg["content"].(type) {
+		case string:
+			msg["content"] = normalize(content, ignoreCase)
+		case []interface{}:
+			for _, p := range content {
+				if part, ok := p.(map[string]interface{}); ok {
+					if text, ok := part["text"].(string); ok {
+						part["text"] = normalize(text, ignoreCase)
+					}
+				}
+			}
+		}
+	}
+	// the keys of a map are sorted, so the same fields in another order give the same key
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	h := sha256.New()
+	h.Write(canonical)
+	if caller != "" {
+		// a zero byte is in no json, so no body ends like the caller of another
+		h.Write([]byte{0})
+		h.Write([]byte(caller))
+	}
+	r.key = hex.EncodeToString(h.Sum(nil))
+	return r, true
+}
+
+// normalize collapses the spaces of a text, and folds its case when asked to
+func normalize(text string, ignoreCase bool) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if ignoreCase {
+		text = strings.ToLower(text)
+	}
+	return text
+}
@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest(t *testing.T) {
+	r, ok := parseRequest([]byte(`{"model":"deepseek-chat","stream":true,"stream_options":{"include_usage":true},"user":"alice","messages":[{"role":"user","content":"1+1=?"}]}`), false, "")
+	require.True(t, ok)
+	assert.Equal(t, "deepseek-chat", r.model)
+	assert.True(t, r.stream)
+	assert.True(t, r.includeUsage)
+
+	plain, ok := parseRequest([]byte(`{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`), false, "")
+	require.True(t, ok)
+	assert.Equal(t, plain.key, r.key, "stream and user do not change the reply")
+	alice, ok := parseRequest([]byte(`{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`), false, "Bearer sk-alice")
+	require.True(t, ok)
+	assert.NotEqual(t, plain.key, alice.key, "the caller does")
+
+	for _, body := range []string{`{"model":"deepseek-chat"}`, `{"messages":[{"role":"user","content":"1+1=?"}]}`, `{"model":"deepseek-chat","messages":["1+1=?"]}`, `not json`} {
+		_, ok := parseRequest([]byte(body), false, "")
+		assert.False(t, ok, body)
+	}
+}
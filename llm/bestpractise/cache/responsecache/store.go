@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// store keeps the completions by key, the least recently used one goes when it is full
+type store struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type entry struct {
+	key        string
+	completion *completion
+	stored     time.Time
+	expires    time.Time
+}
+
+func newStore(maxEntries int) *store {
+	return &store{maxEntries: maxEntries, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// get returns the entry of key stored at most maxAge ago, any age when maxAge is negative
+func (s *store) get(key string, now time.Time, maxAge time.Duration) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !now.Before(e.expires) {
+		s.remove(el)
+		return nil, false
+	}
+	if maxAge >= 0 && now.Sub(e.stored) > maxAge {
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return e, true
+}
+
+func (s *store) put(key string, c *completion, now time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &entry{key: key, completion: c, stored: now, expires: now.Add(ttl)}
+	if el, ok := s.entries[key]; ok {
+		el.Value = e
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.order.PushFront(e)
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *store) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*entry).key)
+}
+
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
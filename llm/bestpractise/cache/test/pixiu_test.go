@@ -0,0 +1,146 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const (
+	pixiuURL   = "http://localhost:8888"
+	metricsURL = "http://localhost:2222/"
+)
+
+var mock = llmmock.Client{URL: "http://localhost:8090"}
+
+// newQuestion returns a question no other test asks, the mock echoes it
+func newQuestion(t *testing.T) string {
+	return fmt.Sprintf("%s at %d", t.Name(), time.Now().UnixNano())
+}
+
+// ask sends a question through pixiu and returns the reply and the X-Pixiu-Cache header
+func ask(t *testing.T, model, question string, opts ...option.RequestOption) (string, string) {
+	t.Helper()
+	var resp *http.Response
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question(model, question), append(opts, option.WithResponseInto(&resp))...)
+	require.NoError(t, err)
+	return completion.Choices[0].Message.Content, resp.Header.Get("X-Pixiu-Cache")
+}
+
+func upstreamRequests(t *testing.T) int {
+	stats, err := mock.Stats()
+	require.NoError(t, err)
+	return stats.Requests["/chat/completions"]
+}
+
+func TestRepeatedQuestionIsServedFromTheCache(t *testing.T) {
+	llmmock.Reset(t, mock)
+	question := newQuestion(t)
+
+	reply, result := ask(t, "deepseek-chat", question)
+	assert.Equal(t, "MISS", result)
+	for i := 0; i < 3; i++ {
+		cached, result := ask(t, "deepseek-chat", "  "+question+"\n")
+		assert.Equal(t, "HIT", result, "the spacing of the question does not matter")
+		assert.Equal(t, reply, cached)
+	}
+	assert.Equal(t, 1, upstreamRequests(t), "only the first request reaches the provider")
+
+	_, result = ask(t, "deepseek-reasoner", question)
+	assert.Equal(t, "MISS", result, "the model is part of the key")
+}
+
+func TestStreamReplay(t *testing.T) {
+	llmmock.Reset(t, mock)
+	question := newQuestion(t)
+	reply, _ := ask(t, "deepseek-chat", question)
+
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	req := llmmock.Question("deepseek-chat", question)
+	req.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := client.Chat.Completions.NewStreaming(context.Background(), req)
+	acc := openai.ChatCompletionAccumulator{}
+	chunks := 0
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+		chunks++
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, reply, acc.Choices[0].Message.Content)
+	assert.Greater(t, chunks, 2, "the reply is replayed as a stream")
+	assert.NotZero(t, acc.Usage.CompletionTokens)
+	assert.Equal(t, 1, upstreamRequests(t))
+}
+
+func TestCacheBypass(t *testing.T) {
+	llmmock.Reset(t, mock)
+	question := newQuestion(t)
+	ask(t, "deepseek-chat", question)
+
+	_, result := ask(t, "deepseek-chat", question, option.WithHeader("Cache-Control", "no-store"))
+	assert.Equal(t, "BYPASS", result)
+	_, result = ask(t, "deepseek-chat", question, option.WithHeader("Cache-Control", "no-cache"))
+	assert.Equal(t, "BYPASS", result)
+	_, result = ask(t, "deepseek-chat", question, option.WithHeader("Cache-Control", "max-age=0"))
+	assert.Equal(t, "MISS", result, "the reply of no-cache is older than 0s")
+	assert.Equal(t, 4, upstreamRequests(t))
+
+	_, result = ask(t, "deepseek-chat", question)
+	assert.Equal(t, "HIT", result)
+}
+
+func TestHitRatioMetrics(t *testing.T) {
+	count := func(t require.TestingT, result string) float64 {
+		return testkit.ScrapeMetric(t, metricsURL, "pixiu_llm_cache_requests_total",
+			map[string]string{"model": "deepseek-chat", "result": result})
+	}
+	hits, misses := count(t, "hit"), count(t, "miss")
+	saved := testkit.ScrapeMetric(t, metricsURL, "pixiu_llm_cache_saved_tokens_total", map[string]string{"model": "deepseek-chat"})
+
+	question := newQuestion(t)
+	for i := 0; i < 4; i++ {
+		ask(t, "deepseek-chat", question)
+	}
+
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		assert.Equal(c, hits+3, count(c, "hit"))
+		assert.Equal(c, misses+1, count(c, "miss"))
+		assert.Greater(c, testkit.ScrapeMetric(c, metricsURL, "pixiu_llm_cache_saved_tokens_total", map[string]string{"model": "deepseek-chat"}), saved)
+		assert.Positive(c, testkit.ScrapeMetric(c, metricsURL, "pixiu_llm_cache_entries", nil))
+	}, 10*time.Second)
+}
@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+)
+
+// maxToolRounds stops a model that keeps calling tools
+const maxToolRounds = 5
+
+// session is a conversation, each question is sent with the history of the previous ones
+type session struct {
+	client    openai.Client
+	model     string
+	system    string
+	maxTokens int
+	stream    bool
+	tools     toolbox
+	history   []openai.ChatCompletionMessageParamUnion
+
+	// out gets the replies, log the tool calls
+	out io.Writer
+	log io.Writer
+}
+
+// turn is the answer to a question, with the usage and the latency of every request it took
+type turn struct {
+	content string
+	usage   openai.CompletionUsage
+	latency time.Duration
+	// ttft is the time to the first token of a stream
+	ttft     time.Duration
+	requests int
+	calls    int
+}
+
+func newSession(client openai.Client, cfg config, out, log io.Writer) *session {
+	s := &session{client: client, model: cfg.model, system: cfg.system, maxTokens: cfg.maxTokens, stream: cfg.stream, out: out, log: log}
+	if cfg.tools {
+		s.tools = localTools
+	}
+	return s
+}
+
+// params returns the request of the conversation so far
+func (s *session) params() openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{Model: s.model}
+	if s.system != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(s.system))
+	}
+	params.Messages = append(params.Messages, s.history...)
+	if s.maxTokens > 0 {
+		params.MaxTokens = openai.Int(int64(s.maxTokens))
+	}
+	if len(s.tools) > 0 {
+		params.Tools = s.tools.params()
+	}
+	if s.stream {
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	}
+	return params
+}
+
+// ask sends the question and answers the tool calls of the model until it replies. The question
+// is dropped from the history when it fails, so it can be asked again.
+func (s *session) ask(ctx context.Context, question string) (turn, error) {
+	var t turn
+	asked := len(s.history)
+	s.history = append(s.history, openai.UserMessage(question))
+	for round := 0; round < maxToolRounds; round++ {
+		msg, err := s.complete(ctx, &t)
+		if err != nil {
+			s.history = s.history[:asked]
+			return t, err
+		}
+		s.history = append(s.history, msg.ToParam())
+		if len(msg.ToolCalls) == 0 {
+			t.content = msg.Content
+			return t, nil
+		}
+		for _, call := range msg.ToolCalls {
+			result := s.tools.call(call.Function.Name, call.Function.Arguments)
+			fmt.Fprintf(s.log, "[tool] %s(%s) = %s\n", call.Function.Name, call.Function.Arguments, result)
+			s.history = append(s.history, openai.ToolMessage(result, call.ID))
+			t.calls++
+		}
+	}
+	s.history = s.history[:asked]
+	return t, fmt.Errorf("no reply after %d rounds of tool calls", maxToolRounds)
+}
+
+// complete sends one request and writes the reply to out as it comes
+func (s *session) complete(ctx context.Context, t *turn) (openai.ChatCompletionMessage, error) {
+	start := time.Now()
+	t.requests++
+	defer func() { t.latency += time.Since(start) }()
+
+	if !s.stream {
+		completion, err := s.client.Chat.Completions.New(ctx, s.params())
+		if err != nil {
+			return openai.ChatCompletionMessage{}, err
+		}
+		addUsage(&t.usage, completion.Usage)
+		if len(completion.Choices) == 0 {
+			return openai.ChatCompletionMessage{}, errors.New("the reply has no choices")
+		}
+		msg := completion.Choices[0].Message
+		if msg.Content != "" {
+			fmt.Fprintln(s.out, msg.Content)
+		}
+		return msg, nil
+	}
+
+	stream := s.client.Chat.Completions.NewStreaming(ctx, s.params())
+	defer stream.Close()
+	acc := openai.ChatCompletionAccumulator{}
+	printed := false
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if t.ttft == 0 {
+				t.ttft = time.Since(start)
+			}
+			fmt.Fprint(s.out, chunk.Choices[0].Delta.Content)
+			printed = true
+		}
+	}
+	if printed {
+		fmt.Fprintln(s.out)
+	}
+	if err := stream.Err(); err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	addUsage(&t.usage, acc.Usage)
+	if len(acc.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, errors.New("the stream has no choices")
+	}
+	return acc.Choices[0].Message, nil
+}
+
+func (s *session) reset() {
+	s.history = nil
+}
+
+func addUsage(sum *openai.CompletionUsage, u openai.CompletionUsage) {
+	sum.PromptTokens += u.PromptTokens
+	sum.CompletionTokens += u.CompletionTokens
+	sum.TotalTokens += u.TotalTokens
+}
+
+func (t turn) print(w io.Writer) {
+	line := fmt.Sprintf("[usage] prompt %d, completion %d, total %d tokens, latency %s",
+		t.usage.PromptTokens, t.usage.CompletionTokens, t.usage.TotalTokens, t.latency.Round(time.Millisecond))
+	if t.requests > 1 {
+		line += fmt.Sprintf(" over %d requests", t.requests)
+	}
+	if t.ttft > 0 {
+		line += fmt.Sprintf(", first token after %s", t.ttft.Round(time.Millisecond))
+	}
+	if t.calls > 0 {
+		line += fmt.Sprintf(", %d tool calls", t.calls)
+	}
+	fmt.Fprintln(w, line)
+}
+
+// repl reads a question per line until /exit or the end of the input. /reset drops the history.
+func repl(ctx context.Context, s *session, in io.Reader, out, log io.Writer) error {
+	fmt.Fprintln(out, "chat with", s.model, "- /reset drops the history, /exit quits")
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		switch line := strings.TrimSpace(scanner.Text()); line {
+		case "":
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			s.reset()
+			fmt.Fprintln(out, "history dropped")
+		default:
+			t, err := s.ask(ctx, line)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				fmt.Fprintln(log, "error:", err)
+				continue
+			}
+			t.print(log)
+		}
+	}
+}
@@ -6,7 +6,7 @@
  * (the "License"); you may not use this file except in compliance with
  * the License.  You may obtain a copy of the License at
  *
- * http://www.apache.org/licenses/LICENSE-2.0
+ *     http://www.apache.org/licenses/LICENSE-2.0
  *
  * Unless required by applicable law or agreed to in writing, software
  * distributed under the License is distributed on an "AS IS" BASIS,
@@ -15,12 +15,20 @@
  * limitations under the License.
  */
 
+// The client of the llm gateway: one question, an interactive chat with history, function calling
+// round trips, and a load mode that measures the throughput and the time to first token through pixiu.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"strings"
 )
 
 import (
@@ -30,43 +38,133 @@ import (
 	"github.com/openai/openai-go/option"
 )
 
-func main() {
-	err := godotenv.Load("go-client/.env")
-	if err != nil {
-		fmt.Println("Error loading .env file")
-		return
-	}
+const defaultEnvFile = ".env"
 
-	client := openai.NewClient(
-		option.WithAPIKey(os.Getenv("API_KEY")),
-		option.WithBaseURL("http://localhost:8888/"),
-	)
+// config is set by the flags
+type config struct {
+	url        string
+	apiKey     string
+	model      string
+	system     string
+	prompt     string
+	promptFile string
+	maxTokens  int
+	stream     bool
+	chat       bool
+	tools      bool
+	noCache    bool
 
-	question := "1+1=?"
+	// load is the number of requests of the load mode, 0 asks the prompt once
+	load        int
+	concurrency int
+}
+
+func parseFlags(args []string, output io.Writer) (config, error) {
+	cfg := config{}
+	flags := flag.NewFlagSet("go-client", flag.ContinueOnError)
+	flags.SetOutput(output)
+	envFile := flags.String("env", defaultEnvFile, "file with the API_KEY, ignored when the default one is missing")
+	flags.StringVar(&cfg.url, "url", "http://localhost:8888/", "base url of pixiu")
+	flags.StringVar(&cfg.apiKey, "api-key", "", "api key of the llm, API_KEY of the environment when empty")
+	flags.StringVar(&cfg.model, "model", "deepseek-chat", "model asked for")
+	flags.StringVar(&cfg.system, "system", "", "system message sent before the conversation")
+	flags.StringVar(&cfg.prompt, "prompt", "1+1=?", "question, the arguments are used when there are some")
+	flags.StringVar(&cfg.promptFile, "prompt-file", "", "file the question is read from, - reads stdin")
+	flags.IntVar(&cfg.maxTokens, "max-tokens", 0, "maximum tokens of a reply, no limit when 0")
+	flags.BoolVar(&cfg.stream, "stream", true, "stream the replies")
+	flags.BoolVar(&cfg.chat, "chat", false, "interactive chat, the history is sent with each question")
+	flags.BoolVar(&cfg.tools, "tools", false, "offer the local tools to the model and answer its calls")
+	flags.BoolVar(&cfg.noCache, "no-cache", false, "ask the provider, not the response cache of pixiu")
+	flags.IntVar(&cfg.load, "load", 0, "send the prompt that many times and report the throughput and latencies")
+	flags.IntVar(&cfg.concurrency, "concurrency", 8, "requests in flight in the load mode")
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
 
-	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(question),
-		},
-		Model: "deepseek-chat",
-	})
+	if err := godotenv.Load(*envFile); err != nil && (*envFile != defaultEnvFile || !errors.Is(err, fs.ErrNotExist)) {
+		return cfg, fmt.Errorf("load %s: %w", *envFile, err)
+	}
+	if cfg.apiKey == "" {
+		cfg.apiKey = os.Getenv("API_KEY")
+	}
+	if flags.NArg() > 0 {
+		cfg.prompt = strings.Join(flags.Args(), " ")
+	}
+	if cfg.promptFile != "" {
+		var content []byte
+		var err error
+		if cfg.promptFile == "-" {
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(cfg.promptFile)
+		}
+		if err != nil {
+			return cfg, err
+		}
+		cfg.prompt = strings.TrimSpace(string(content))
+	}
+	if cfg.load > 0 && cfg.concurrency <= 0 {
+		return cfg, errors.New("-concurrency must be positive")
+	}
+	if cfg.load > 0 && cfg.chat {
+		return cfg, errors.New("-load and -chat cannot be combined")
+	}
+	return cfg, nil
+}
 
-	// optionally, an accumulator helper can be used
-	acc := openai.ChatCompletionAccumulator{}
+func newClient(cfg config) openai.Client {
+	opts := []option.RequestOption{
+		option.WithBaseURL(cfg.url),
+		option.WithAPIKey(cfg.apiKey),
+		// pixiu retries by the policy of the cluster, a retry of the client would hide it
+		option.WithMaxRetries(0),
+	}
+	if cfg.noCache {
+		opts = append(opts, option.WithHeader("Cache-Control", "no-store"))
+	}
+	return openai.NewClient(opts...)
+}
 
-	for stream.Next() {
-		chunk := stream.Current()
-		acc.AddChunk(chunk)
+func run(ctx context.Context, cfg config, in io.Reader, out, log io.Writer) error {
+	client := newClient(cfg)
+	s := newSession(client, cfg, out, log)
 
-		if len(chunk.Choices) > 0 {
-			fmt.Print(chunk.Choices[0].Delta.Content)
+	switch {
+	case cfg.load > 0:
+		params := s.params()
+		params.Messages = append(params.Messages, openai.UserMessage(cfg.prompt))
+		report := runLoad(ctx, client, params, cfg.load, cfg.concurrency, cfg.stream)
+		report.print(out)
+		if report.failed == report.requests {
+			return errors.New("every request failed")
+		}
+		return nil
+	case cfg.chat:
+		return repl(ctx, s, in, out, log)
+	default:
+		t, err := s.ask(ctx, cfg.prompt)
+		if err != nil {
+			return err
 		}
+		t.print(log)
+		return nil
 	}
+}
 
-	if stream.Err() != nil {
-		panic(stream.Err())
+func main() {
+	cfg, err := parseFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	// After the stream is finished, acc can be used like a ChatCompletion
-	_ = acc.Choices[0].Message.Content
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err = run(ctx, cfg, os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
 }
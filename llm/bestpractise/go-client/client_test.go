@@ -0,0 +1,250 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+var testScript = llmmock.Script{Rules: []llmmock.Rule{
+	{Match: "1+1", Reply: "1+1 equals 2."},
+	{Match: "time", Tool: "current_time", Arguments: `{"timezone":"Asia/Shanghai"}`, Reply: "It is {result} in Shanghai."},
+}}
+
+// newTestSession returns a session with pixiu replaced by the mock llm
+func newTestSession(t *testing.T, cfg config) (*session, *llmmock.Server, *bytes.Buffer, *bytes.Buffer) {
+	mock := llmmock.New(llmmock.Options{Script: testScript})
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	cfg.url = srv.URL + "/"
+	if cfg.model == "" {
+		cfg.model = "deepseek-chat"
+	}
+	out, log := &bytes.Buffer{}, &bytes.Buffer{}
+	return newSession(newClient(cfg), cfg, out, log), mock, out, log
+}
+
+func TestAsk(t *testing.T) {
+	s, _, out, _ := newTestSession(t, config{})
+
+	first, err := s.ask(context.Background(), "what is 1+1 ?")
+	require.NoError(t, err)
+	assert.Equal(t, "1+1 equals 2.", first.content)
+	assert.Equal(t, "1+1 equals 2.\n", out.String())
+	assert.Equal(t, int64(3), first.usage.CompletionTokens)
+	assert.Equal(t, 1, first.requests)
+	assert.Positive(t, first.latency)
+	assert.Zero(t, first.ttft)
+
+	// the history of the first question is sent with the second one
+	second, err := s.ask(context.Background(), "and 1+1 again ?")
+	require.NoError(t, err)
+	assert.Len(t, s.history, 4)
+	assert.Greater(t, second.usage.PromptTokens, first.usage.PromptTokens+first.usage.CompletionTokens)
+}
+
+func TestAskStream(t *testing.T) {
+	s, _, out, _ := newTestSession(t, config{stream: true, system: "You are a calculator."})
+
+	tr, err := s.ask(context.Background(), "what is 1+1 ?")
+	require.NoError(t, err)
+	assert.Equal(t, "1+1 equals 2.", tr.content)
+	assert.Equal(t, "1+1 equals 2.\n", out.String())
+	// 4 words of the system message and 4 of the question
+	assert.Equal(t, int64(8), tr.usage.PromptTokens)
+	assert.Equal(t, int64(3), tr.usage.CompletionTokens)
+	assert.Positive(t, tr.ttft)
+	assert.LessOrEqual(t, tr.ttft, tr.latency)
+}
+
+func TestAskWithTools(t *testing.T) {
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	for _, stream := range []bool{false, true} {
+		s, mock, out, log := newTestSession(t, config{tools: true, stream: stream})
+		tr, err := s.ask(context.Background(), "what time is it ?")
+		require.NoError(t, err)
+		assert.Equal(t, "It is 2025-01-01T08:00:00+08:00 in Shanghai.", tr.content)
+		assert.Equal(t, "It is 2025-01-01T08:00:00+08:00 in Shanghai.\n", out.String())
+		assert.Equal(t, `[tool] current_time({"timezone":"Asia/Shanghai"}) = 2025-01-01T08:00:00+08:00`+"\n", log.String())
+		assert.Equal(t, 1, tr.calls)
+		assert.Equal(t, 2, tr.requests)
+		// the question, the call, its result and the reply
+		assert.Len(t, s.history, 4)
+		assert.Equal(t, 2, mock.Stats().Requests["/chat/completions"])
+	}
+}
+
+func TestAskFailureDropsQuestion(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		s, mock, _, _ := newTestSession(t, config{stream: stream})
+		mock.Inject(llmmock.Fault{Status: http.StatusBadGateway, Count: 1})
+
+		_, err := s.ask(context.Background(), "what is 1+1 ?")
+		var apiErr *openai.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Empty(t, s.history)
+
+		_, err = s.ask(context.Background(), "what is 1+1 ?")
+		require.NoError(t, err)
+		assert.Len(t, s.history, 2)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	s, mock, out, log := newTestSession(t, config{})
+	mock.Inject(llmmock.Fault{Status: http.StatusTooManyRequests, Count: 1})
+	in := strings.NewReader("1+1 ?\n1+1 ?\n\nhello\n/reset\nhello again\n/exit\nnot asked\n")
+
+	require.NoError(t, repl(context.Background(), s, in, out, log))
+	assert.Contains(t, log.String(), "error: ")
+	assert.Contains(t, out.String(), "1+1 equals 2.")
+	assert.Contains(t, out.String(), "mock reply to: hello\n")
+	assert.Contains(t, out.String(), "history dropped")
+	assert.NotContains(t, out.String(), "not asked")
+	assert.Equal(t, 3, strings.Count(log.String(), "[usage]"))
+	// the history was dropped before the last question
+	assert.Len(t, s.history, 2)
+	assert.Equal(t, 4, mock.Stats().Requests["/chat/completions"])
+}
+
+func TestLoad(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		s, mock, _, _ := newTestSession(t, config{stream: stream})
+		mock.Inject(llmmock.Fault{Status: http.StatusServiceUnavailable, Count: 2})
+		params := s.params()
+		params.Messages = append(params.Messages, openai.UserMessage("what is 1+1 ?"))
+
+		report := runLoad(context.Background(), s.client, params, 20, 4, stream)
+		assert.Equal(t, 20, report.requests)
+		assert.Equal(t, 2, report.failed)
+		assert.Len(t, report.latencies, 18)
+		assert.Equal(t, int64(18*3), report.completionTokens)
+		if stream {
+			assert.Len(t, report.ttfts, 18)
+		} else {
+			assert.Empty(t, report.ttfts)
+		}
+		assert.Len(t, report.errors, 1)
+
+		var out bytes.Buffer
+		report.print(&out)
+		assert.Contains(t, out.String(), "requests  20 in ")
+		assert.Contains(t, out.String(), "2x ")
+		assert.Equal(t, stream, strings.Contains(out.String(), "ttft"))
+	}
+}
+
+func TestTools(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tool      string
+		arguments string
+		result    string
+	}{
+		{name: "add", tool: "calculate", arguments: `{"a":1,"b":1,"op":"+"}`, result: "2"},
+		{name: "divide", tool: "calculate", arguments: `{"a":1,"b":4,"op":"/"}`, result: "0.25"},
+		{name: "division by zero", tool: "calculate", arguments: `{"a":1,"b":0,"op":"/"}`, result: "error: division by zero"},
+		{name: "unknown timezone", tool: "current_time", arguments: `{"timezone":"Mars/Base"}`, result: "error: unknown time zone Mars/Base"},
+		{name: "invalid arguments", tool: "calculate", arguments: `1+1`, result: "error: the arguments are not a json object"},
+		{name: "unknown tool", tool: "search", arguments: `{}`, result: "error: unknown tool search"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, strings.HasPrefix(localTools.call(tc.tool, tc.arguments), tc.result))
+		})
+	}
+
+	params := localTools.params()
+	require.Len(t, params, 2)
+	assert.Equal(t, "calculate", params[0].Function.Name)
+	assert.Equal(t, "current_time", params[1].Function.Name)
+}
+
+func TestNoCache(t *testing.T) {
+	mock := llmmock.New(llmmock.Options{Script: testScript})
+	var cacheControl []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheControl = append(cacheControl, r.Header.Get("Cache-Control"))
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	for _, noCache := range []bool{false, true} {
+		client := newClient(config{url: srv.URL + "/", noCache: noCache})
+		_, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+			Model:    "deepseek-chat",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("1+1=?")},
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"", "no-store"}, cacheControl)
+}
+
+func TestParseFlags(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	// the env file does not override a variable that is set
+	t.Setenv("API_KEY", "")
+	require.NoError(t, os.Unsetenv("API_KEY"))
+
+	cfg, err := parseFlags(nil, &bytes.Buffer{})
+	require.NoError(t, err, "the default env file may be missing")
+	assert.Equal(t, "1+1=?", cfg.prompt)
+	assert.True(t, cfg.stream)
+
+	_, err = parseFlags([]string{"-env", "missing.env"}, &bytes.Buffer{})
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("API_KEY=sk-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "question.txt"), []byte("what time is it ?\n"), 0o600))
+	cfg, err = parseFlags([]string{"-prompt-file", "question.txt", "-stream=false"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, "sk-file", cfg.apiKey)
+	assert.Equal(t, "what time is it ?", cfg.prompt)
+	assert.False(t, cfg.stream)
+
+	cfg, err = parseFlags([]string{"-api-key", "sk-flag", "how", "are", "you"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, "sk-flag", cfg.apiKey)
+	assert.Equal(t, "how are you", cfg.prompt)
+
+	_, err = parseFlags([]string{"-load", "10", "-chat"}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/bestpractise/internal/chatload"
+)
+
+// loadReport is the outcome of the load mode
+type loadReport struct {
+	requests         int
+	failed           int
+	duration         time.Duration
+	completionTokens int64
+	latencies        []time.Duration
+	// ttfts are only measured for streams
+	ttfts  []time.Duration
+	errors map[string]int
+}
+
+// runLoad sends the request that many times, concurrency at once, and measures each of them
+func runLoad(ctx context.Context, client openai.Client, params openai.ChatCompletionNewParams, requests, concurrency int, stream bool) loadReport {
+	report := loadReport{requests: requests, errors: map[string]int{}}
+	var mu sync.Mutex
+	start := time.Now()
+	sent := chatload.Run(ctx, concurrency, requests, 0, func(int) {
+		r := chatload.Measure(ctx, client, params, stream)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Err != nil {
+			report.failed++
+			report.errors[r.Err.Error()]++
+			return
+		}
+		report.latencies = append(report.latencies, r.Latency)
+		if stream {
+			report.ttfts = append(report.ttfts, r.TTFT)
+		}
+		report.completionTokens += r.CompletionTokens
+	})
+	if sent < requests && ctx.Err() != nil {
+		// the requests that are not sent count as failed
+		report.failed += requests - sent
+		report.errors[ctx.Err().Error()] += requests - sent
+	}
+	report.duration = time.Since(start)
+	return report
+}
+
+func (r loadReport) print(w io.Writer) {
+	fmt.Fprintf(w, "requests  %d in %s, %d failed\n", r.requests, r.duration.Round(time.Millisecond), r.failed)
+	seconds := r.duration.Seconds()
+	if seconds > 0 {
+		fmt.Fprintf(w, "rate      %.1f requests/s, %.1f completion tokens/s\n",
+			float64(r.requests-r.failed)/seconds, float64(r.completionTokens)/seconds)
+	}
+	printPercentiles(w, "latency", r.latencies)
+	printPercentiles(w, "ttft", r.ttfts)
+
+	messages := make([]string, 0, len(r.errors))
+	for msg := range r.errors {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool { return r.errors[messages[i]] > r.errors[messages[j]] })
+	for _, msg := range messages {
+		fmt.Fprintf(w, "error     %dx %s\n", r.errors[msg], msg)
+	}
+}
+
+func printPercentiles(w io.Writer, name string, durations []time.Duration) {
+	if p := chatload.Percentiles(durations); p != "" {
+		fmt.Fprintf(w, "%-9s %s\n", name, p)
+	}
+}
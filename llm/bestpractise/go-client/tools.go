@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	_ "time/tzdata"
+)
+
+import (
+	"github.com/openai/openai-go"
+)
+
+// tool is a function the model may call, its result is sent back as the tool message
+type tool struct {
+	definition openai.FunctionDefinitionParam
+	call       func(arguments map[string]interface{}) (string, error)
+}
+
+type toolbox map[string]tool
+
+// now is replaced by the tests
+var now = time.Now
+
+var localTools = toolbox{
+	"current_time": {
+		definition: openai.FunctionDefinitionParam{
+			Name:        "current_time",
+			Description: openai.String("Returns the current date and time in a timezone"),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"timezone": map[string]interface{}{"type": "string", "description": "IANA timezone, like Asia/Shanghai"},
+				},
+				"required": []string{"timezone"},
+			},
+		},
+		call: currentTime,
+	},
+	"calculate": {
+		definition: openai.FunctionDefinitionParam{
+			Name:        "calculate",
+			Description: openai.String("Applies an arithmetic operator to two numbers"),
+			Parameters: openai.FunctionParameters{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"a":  map[string]interface{}{"type": "number"},
+					"b":  map[string]interface{}{"type": "number"},
+					"op": map[string]interface{}{"type": "string", "enum": []string{"+", "-", "*", "/"}},
+				},
+				"required": []string{"a", "b", "op"},
+			},
+		},
+		call: calculate,
+	},
+}
+
+func (tb toolbox) params() []openai.ChatCompletionToolParam {
+	names := make([]string, 0, len(tb))
+	for name := range tb {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]openai.ChatCompletionToolParam, len(names))
+	for i, name := range names {
+		params[i] = openai.ChatCompletionToolParam{Function: tb[name].definition}
+	}
+	return params
+}
+
+// call runs a tool, the errors are returned to the model as the result so it can correct itself
+func (tb toolbox) call(name, arguments string) string {
+	t, ok := tb[name]
+	if !ok {
+		return "error: unknown tool " + name
+	}
+	args := map[string]interface{}{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "error: the arguments are not a json object: " + err.Error()
+		}
+	}
+	result, err := t.call(args)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return result
+}
+
+func currentTime(args map[string]interface{}) (string, error) {
+	name, _ := args["timezone"].(string)
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return "", err
+	}
+	return now().In(location).Format(time.RFC3339), nil
+}
+
+func calculate(args map[string]interface{}) (string, error) {
+	a, okA := args["a"].(float64)
+	b, okB := args["b"].(float64)
+	if !okA || !okB {
+		return "", fmt.Errorf("a and b must be numbers")
+	}
+	var result float64
+	switch op, _ := args["op"].(string); op {
+	case "+":
+		result = a + b
+	case "-":
+		result = a - b
+	case "*":
+		result = a * b
+	case "/":
+		if b == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		result = a / b
+	default:
+		return "", fmt.Errorf("unknown operator %q", op)
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64), nil
+}
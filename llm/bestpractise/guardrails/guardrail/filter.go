@@ -0,0 +1,342 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package guardrail is the dgp.filter.llm.guardrail http filter. It checks the chat completions
+// on their way to the provider and back: it redacts the pii of the prompts, blocks the prompts of
+// its deny lists, caps max_tokens, and scans the replies, streams chunk by chunk.
+package guardrail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+const (
+	// Kind is the kind of the filter
+	Kind = "dgp.filter.llm.guardrail"
+)
+
+// defaultRoles are the messages checked when the config names none, the replies of the model in
+// the history are not
+var defaultRoles = []string{"system", "user", "tool"}
+
+func init() {
+	filter.RegisterHttpFilter(&Plugin{})
+}
+
+type (
+	// Plugin is the guardrail plugin
+	Plugin struct {
+	}
+
+	// FilterFactory holds the compiled rules
+	FilterFactory struct {
+		cfg          *Config
+		roles        map[string]bool
+		redactions   []*redaction
+		deny         []*denyRule
+		responseDeny []*denyRule
+		responseHold int
+		metrics      *metrics
+	}
+
+	// Filter guards a request and its reply
+	Filter struct {
+		factory *FilterFactory
+		guard   *responseGuard
+		writer  *llmfilter.Writer
+	}
+
+	// Config is the config of the filter
+	Config struct {
+		// Roles of the messages that are checked, system, user and tool when empty
+		Roles []string `yaml:"roles" json:"roles" mapstructure:"roles"`
+		// Redact replaces the pii of the prompts before they are sent
+		Redact []*Redaction `yaml:"redact" json:"redact" mapstructure:"redact"`
+		// Deny blocks the requests whose prompts match, with a 400
+		Deny []*DenyRule `yaml:"deny" json:"deny" mapstructure:"deny"`
+		// MaxTokens caps the max_tokens of a request, and sets it when a request has none. No cap when 0
+		MaxTokens int `yaml:"max_tokens" json:"max_tokens" mapstructure:"max_tokens"`
+		// Response are the checks of the replies
+		Response ResponseConfig `yaml:"response" json:"response" mapstructure:"response"`
+	}
+
+	// ResponseConfig checks the replies of the provider
+	ResponseConfig struct {
+		// Deny ends a reply that matches with the content_filter finish reason
+		Deny []*DenyRule `yaml:"deny" json:"deny" mapstructure:"deny"`
+		// Hold is the end of a stream held back, in bytes, so no part of a denied text is sent. The
+		// longest match of the deny rules when 0, or 256 when one has no longest match
+		Hold int `yaml:"hold" json:"hold" mapstructure:"hold"`
+	}
+)
+
+func (p *Plugin) Kind() string {
+	return Kind
+}
+
+func (p *Plugin) CreateFilterFactory() (filter.HttpFilterFactory, error) {
+	return &FilterFactory{cfg: &Config{}}, nil
+}
+
+func (factory *FilterFactory) Config() interface{} {
+	return factory.cfg
+}
+
+func (factory *FilterFactory) Apply() error {
+	roles := factory.cfg.Roles
+	if len(roles) == 0 {
+		roles = defaultRoles
+	}
+	factory.roles = map[string]bool{}
+	for _, role := range roles {
+		factory.roles[role] = true
+	}
+	if factory.cfg.MaxTokens < 0 {
+		return fmt.Errorf("%s: max_tokens %d is negative", Kind, factory.cfg.MaxTokens)
+	}
+	var err error
+	if factory.redactions, err = compileRedactions(factory.cfg.Redact); err != nil {
+		return fmt.Errorf("%s: %w", Kind, err)
+	}
+	if factory.deny, err = compileDenyRules(factory.cfg.Deny); err != nil {
+		return fmt.Errorf("%s: %w", Kind, err)
+	}
+	if factory.responseDeny, err = compileDenyRules(factory.cfg.Response.Deny); err != nil {
+		return fmt.Errorf("%s: response: %w", Kind, err)
+	}
+	switch hold := factory.cfg.Response.Hold; {
+	case hold < 0 || hold > window:
+		return fmt.Errorf("%s: response: hold %d is not between 0 and %d", Kind, hold, window)
+	case hold == 0:
+		factory.responseHold = longestMatch(factory.responseDeny)
+	default:
+		factory.responseHold = hold
+	}
+	if factory.metrics, err = registerMetrics(); err != nil {
+		return fmt.Errorf("%s: register the metrics: %w", Kind, err)
+	}
+	return nil
+}
+
+func (factory *FilterFactory) PrepareFilterChain(ctx *contexthttp.HttpContext, chain filter.FilterChain) error {
+	f := &Filter{factory: factory}
+	chain.AppendDecodeFilters(f)
+	chain.AppendEncodeFilters(f)
+	return nil
+}
+
+// Decode checks the prompts before the provider gets them. The deny lists see the prompts as the
+// client sent them, the provider gets them redacted.
+func (f *Filter) Decode(c *contexthttp.HttpContext) filter.FilterStatus {
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return filter.Continue
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	if err != nil {
+		logger.Warnf("[dubbo-go-pixiu] %s: read body: %v", Kind, err)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return filter.Continue
+	}
+	defer func() {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+	}()
+
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return filter.Continue
+	}
+	messages, _ := fields["messages"].([]interface{})
+	if len(messages) == 0 {
+		return filter.Continue
+	}
+
+	texts := f.texts(messages)
+	for _, t := range texts {
+		if r := denied(f.factory.deny, t.value); r != nil {
+			f.factory.metrics.record(c.Ctx, r.name, actionBlock)
+			logger.Infof("[dubbo-go-pixiu] %s: request blocked by the %s rule", Kind, r.name)
+			sendError(c, r.message)
+			return filter.Stop
+		}
+	}
+
+	changed := false
+	for _, t := range texts {
+		redacted, matched := redact(f.factory.redactions, t.value)
+		for _, name := range matched {
+			f.factory.metrics.record(c.Ctx, name, actionRedact)
+		}
+		if len(matched) > 0 {
+			t.set(redacted)
+			changed = true
+		}
+	}
+	if f.capTokens(fields) {
+		f.factory.metrics.record(c.Ctx, "max_tokens", actionCap)
+		changed = true
+	}
+	if changed {
+		if rewritten, err := json.Marshal(fields); err == nil {
+			body = rewritten
+		}
+	}
+
+	if len(f.factory.responseDeny) > 0 {
+		// a stream is passed on line by line, a json reply is held until the filter encodes it
+		f.guard = newResponseGuard(f.factory.responseDeny, f.factory.responseHold)
+		f.writer = llmfilter.NewWriter(c.Writer)
+		f.writer.Line, f.writer.Hold = f.guard.line, true
+		c.Writer = f.writer
+	}
+	return filter.Continue
+}
+
+// text of a checked message, set puts a redacted one back in the request
+type text struct {
+	value string
+	set   func(string)
+}
+
+// texts returns the texts of the checked messages, a string content or the text of its parts
+func (f *Filter) texts(messages []interface{}) []*text {
+	var texts []*text
+	for _, m := range messages {
+		msg, _ := m.(map[string]interface{})
+		if role, _ := msg["role"].(string); !f.factory.roles[role] {
+			continue
+		}
+		switch content := msg["content"].(type) {
+		case string:
+			texts = append(texts, &text{value: content, set: func(s string) { msg["content"] = s }})
+		case []interface{}:
+			for _, p := range content {
+				part, _ := p.(map[string]interface{})
+				if value, ok := part["text"].(string); ok {
+					texts = append(texts, &text{value: value, set: func(s string) { part["text"] = s }})
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// capTokens lowers max_tokens and max_completion_tokens to the cap, and sets max_tokens when the
+// request has neither
+func (f *Filter) capTokens(fields map[string]interface{}) bool {
+	limit := f.factory.cfg.MaxTokens
+	if limit == 0 {
+		return false
+	}
+	capped, found := false, false
+	for _, name := range []string{"max_tokens", "max_completion_tokens"} {
+		value, ok := fields[name].(float64)
+		if !ok {
+			continue
+		}
+		found = true
+		if value > float64(limit) {
+			fields[name] = limit
+			capped = true
+		}
+	}
+	if !found {
+		fields["max_tokens"] = limit
+		capped = true
+	}
+	return capped
+}
+
+// Encode scans the reply, written to the client by the proxy itself, like a stream, or returned to pixiu
+func (f *Filter) Encode(c *contexthttp.HttpContext) filter.FilterStatus {
+	w := f.writer
+	if w == nil {
+		return filter.Continue
+	}
+	// pixiu writes its response after the encode filters, the guard has seen it by then
+	c.Writer = w.ResponseWriter
+
+	switch {
+	case w.Wrote && w.Stream:
+		w.Finish()
+		if rest := f.guard.flush(); len(rest) > 0 {
+			_, _ = w.ResponseWriter.Write(rest)
+		}
+		f.blocked(c, f.guard.blocked)
+	case w.Wrote:
+		body := w.Body.Bytes()
+		if w.Status == http.StatusOK {
+			var r *denyRule
+			if body, r = guardCompletion(f.guard.rules, body); r != nil {
+				w.Header().Del("Content-Length")
+				f.blocked(c, r)
+			}
+		}
+		w.ResponseWriter.WriteHeader(w.Status)
+		_, _ = w.ResponseWriter.Write(body)
+	case c.TargetResp != nil && !c.LocalReply() && c.GetStatusCode() == http.StatusOK:
+		data := c.TargetResp.Data
+		if llmfilter.IsStream(c.Writer.Header()) {
+			data = append(llmfilter.RewriteLines(data, f.guard.line), f.guard.flush()...)
+			f.blocked(c, f.guard.blocked)
+		} else {
+			var r *denyRule
+			data, r = guardCompletion(f.guard.rules, data)
+			f.blocked(c, r)
+		}
+		if len(data) != len(c.TargetResp.Data) {
+			c.Writer.Header().Del("Content-Length")
+		}
+		c.TargetResp.Data = data
+	}
+	return filter.Continue
+}
+
+func (f *Filter) blocked(c *contexthttp.HttpContext, r *denyRule) {
+	if r != nil {
+		f.factory.metrics.record(c.Ctx, r.name, actionFilter)
+		logger.Infof("[dubbo-go-pixiu] %s: reply filtered by the %s rule", Kind, r.name)
+	}
+}
+
+// sendError replies with the error body of the OpenAI api
+func sendError(c *contexthttp.HttpContext, message string) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "invalid_request_error",
+			"param":   "messages",
+			"code":    "content_policy_violation",
+		},
+	})
+	c.SendLocalReply(http.StatusBadRequest, body)
+}
@@ -0,0 +1,302 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package guardrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/client"
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/context/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFactory(t *testing.T, cfg *Config) *FilterFactory {
+	factory := &FilterFactory{cfg: cfg}
+	require.NoError(t, factory.Apply())
+	return factory
+}
+
+func newContext(t *testing.T, body string) (*contexthttp.HttpContext, *httptest.ResponseRecorder) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8888/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	c := mock.GetMockHTTPContext(req)
+	recorder := httptest.NewRecorder()
+	c.Writer = recorder
+	return c, recorder
+}
+
+// decode runs the filter on a request and returns the request the provider gets
+func decode(t *testing.T, factory *FilterFactory, body string) (filter.FilterStatus, map[string]interface{}, *httptest.ResponseRecorder) {
+	c, recorder := newContext(t, body)
+	status := (&Filter{factory: factory}).Decode(c)
+	data, err := io.ReadAll(c.Request.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), c.Request.ContentLength)
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &sent))
+	return status, sent, recorder
+}
+
+func content(sent map[string]interface{}, i int) interface{} {
+	return sent["messages"].([]interface{})[i].(map[string]interface{})["content"]
+}
+
+func TestRedact(t *testing.T) {
+	factory := newFactory(t, &Config{Redact: []*Redaction{{Name: "email"}, {Name: "phone"}}})
+	status, sent, _ := decode(t, factory, `{"model":"deepseek-chat","temperature":0.2,"messages":[`+
+		`{"role":"system","content":"the user is bob@example.com"},`+
+		`{"role":"assistant","content":"write to bob@example.com"},`+
+		`{"role":"user","content":[{"type":"text","text":"call me at 555-123-4567"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`)
+	assert.Equal(t, filter.Continue, status)
+	assert.Equal(t, "the user is [REDACTED:email]", content(sent, 0))
+	assert.Equal(t, "write to bob@example.com", content(sent, 1), "the replies of the model are not checked")
+	parts := content(sent, 2).([]interface{})
+	assert.Equal(t, "call me at [REDACTED:phone]", parts[0].(map[string]interface{})["text"])
+	assert.Equal(t, "image_url", parts[1].(map[string]interface{})["type"])
+	assert.Equal(t, 0.2, sent["temperature"])
+}
+
+func TestDeny(t *testing.T) {
+	factory := newFactory(t, &Config{
+		Redact: []*Redaction{{Name: "email"}},
+		Deny:   []*DenyRule{{Name: "secrets", Words: []string{"password"}, Message: "no secrets please"}},
+	})
+
+	status, _, recorder := decode(t, factory, `{"model":"deepseek-chat","messages":[{"role":"user","content":"the PASSWORD of bob@example.com?"}]}`)
+	assert.Equal(t, filter.Stop, status)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	var body map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "no secrets please", body["error"]["message"])
+	assert.Equal(t, "content_policy_violation", body["error"]["code"])
+
+	status, _, _ = decode(t, factory, `{"model":"deepseek-chat","messages":[{"role":"assistant","content":"a password"},{"role":"user","content":"why?"}]}`)
+	assert.Equal(t, filter.Continue, status, "the replies of the model are not checked")
+
+	factory = newFactory(t, &Config{Roles: []string{"user", "assistant"}, Deny: factory.cfg.Deny})
+	status, _, _ = decode(t, factory, `{"model":"deepseek-chat","messages":[{"role":"assistant","content":"a password"},{"role":"user","content":"why?"}]}`)
+	assert.Equal(t, filter.Stop, status)
+}
+
+func TestCapTokens(t *testing.T) {
+	factory := newFactory(t, &Config{MaxTokens: 64})
+
+	testCases := []struct {
+		name string
+		body string
+		want map[string]interface{}
+	}{
+		{name: "over", body: `{"max_tokens":4096}`, want: map[string]interface{}{"max_tokens": 64.0}},
+		{name: "under", body: `{"max_tokens":16}`, want: map[string]interface{}{"max_tokens": 16.0}},
+		{name: "none", body: `{}`, want: map[string]interface{}{"max_tokens": 64.0}},
+		{name: "completion tokens", body: `{"max_completion_tokens":100}`, want: map[string]interface{}{"max_completion_tokens": 64.0}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := strings.Replace(tc.body, "{", `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}],`, 1)
+			body = strings.Replace(body, ",}", "}", 1)
+			_, sent, _ := decode(t, factory, body)
+			for name, value := range tc.want {
+				assert.Equal(t, value, sent[name])
+			}
+		})
+	}
+}
+
+const stream = `data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"role":"assistant"},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":"the code name is Blue"},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":"bird, keep it"},"finish_reason":null}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+
+var responseDeny = []*DenyRule{{Name: "codename", Words: []string{"bluebird"}}}
+
+// assertFiltered checks that the stream ends with the content_filter finish reason, and that the
+// chunk held back with the start of the denied word is not sent
+func assertFiltered(t *testing.T, out string) {
+	assert.Contains(t, out, `"role":"assistant"`)
+	assert.NotContains(t, out, "Blue")
+	assert.NotContains(t, out, "bird")
+	assert.Contains(t, out, `"finish_reason":"content_filter"`)
+	assert.True(t, strings.HasSuffix(out, "data: [DONE]\n\n"))
+	assert.Equal(t, 1, strings.Count(out, "[DONE]"))
+}
+
+func TestStreamWrittenByTheProxy(t *testing.T) {
+	factory := newFactory(t, &Config{Response: ResponseConfig{Deny: responseDeny}})
+	c, recorder := newContext(t, `{"model":"deepseek-chat","stream":true,"messages":[{"role":"user","content":"1+1=?"}]}`)
+	f := &Filter{factory: factory}
+	require.Equal(t, filter.Continue, f.Decode(c))
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.WriteHeader(http.StatusOK)
+	// the chunks of the proxy do not end with the events
+	for i := 0; i < len(stream); i += 50 {
+		_, err := c.Writer.Write([]byte(stream[i:min(i+50, len(stream))]))
+		require.NoError(t, err)
+	}
+	f.Encode(c)
+	assertFiltered(t, recorder.Body.String())
+}
+
+func TestStreamReturnedToPixiu(t *testing.T) {
+	factory := newFactory(t, &Config{Response: ResponseConfig{Deny: responseDeny}})
+	c, _ := newContext(t, `{"model":"deepseek-chat","stream":true,"messages":[{"role":"user","content":"1+1=?"}]}`)
+	f := &Filter{factory: factory}
+	require.Equal(t, filter.Continue, f.Decode(c))
+
+	c.AddHeader("Content-Type", "text/event-stream")
+	c.StatusCode(http.StatusOK)
+	c.TargetResp = &client.Response{Data: []byte(stream)}
+	f.Encode(c)
+	assertFiltered(t, string(c.TargetResp.Data))
+
+	// a stream no rule denies is passed on as it is
+	c, _ = newContext(t, `{"model":"deepseek-chat","stream":true,"messages":[{"role":"user","content":"1+1=?"}]}`)
+	f = &Filter{factory: newFactory(t, &Config{Response: ResponseConfig{Deny: []*DenyRule{{Name: "other", Words: []string{"redbird"}}}}})}
+	require.Equal(t, filter.Continue, f.Decode(c))
+	c.AddHeader("Content-Type", "text/event-stream")
+	c.StatusCode(http.StatusOK)
+	c.TargetResp = &client.Response{Data: []byte(stream)}
+	f.Encode(c)
+	assert.Equal(t, stream, string(c.TargetResp.Data))
+}
+
+func TestJSONReply(t *testing.T) {
+	factory := newFactory(t, &Config{Response: ResponseConfig{Deny: responseDeny}})
+	reply := func(text string) string {
+		return fmt.Sprintf(`{"id":"c1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,"message":{"role":"assistant","content":%q},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":6,"total_tokens":9}}`, text)
+	}
+
+	testCases := []struct {
+		name    string
+		text    string
+		content string
+		finish  string
+	}{
+		{name: "denied", text: "the code name is Bluebird", content: "", finish: "content_filter"},
+		{name: "allowed", text: "1+1 equals 2.", content: "1+1 equals 2.", finish: "stop"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newContext(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`)
+			f := &Filter{factory: factory}
+			require.Equal(t, filter.Continue, f.Decode(c))
+			c.StatusCode(http.StatusOK)
+			c.TargetResp = &client.Response{Data: []byte(reply(tc.text))}
+			f.Encode(c)
+
+			var got struct {
+				Choices []struct {
+					Message      struct{ Content string }
+					FinishReason string `json:"finish_reason"`
+				}
+				Usage map[string]int
+			}
+			require.NoError(t, json.Unmarshal(c.TargetResp.Data, &got))
+			assert.Equal(t, tc.content, got.Choices[0].Message.Content)
+			assert.Equal(t, tc.finish, got.Choices[0].FinishReason)
+			assert.Equal(t, 9, got.Usage["total_tokens"])
+		})
+	}
+}
+
+func TestJSONWrittenByTheProxy(t *testing.T) {
+	factory := newFactory(t, &Config{Response: ResponseConfig{Deny: responseDeny}})
+	c, recorder := newContext(t, `{"model":"deepseek-chat","messages":[{"role":"user","content":"1+1=?"}]}`)
+	f := &Filter{factory: factory}
+	require.Equal(t, filter.Continue, f.Decode(c))
+
+	body := `{"choices":[{"index":0,"message":{"role":"assistant","content":"it is BLUEBIRD"},"finish_reason":"stop"}]}`
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	_, err := c.Writer.Write([]byte(body))
+	require.NoError(t, err)
+	assert.Zero(t, recorder.Body.Len(), "the json reply is kept until it is scanned")
+
+	f.Encode(c)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Content-Length"))
+	assert.Contains(t, recorder.Body.String(), `"finish_reason":"content_filter"`)
+	assert.NotContains(t, recorder.Body.String(), "BLUEBIRD")
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{name: "max tokens", cfg: &Config{MaxTokens: -1}, err: "negative"},
+		{name: "redaction", cfg: &Config{Redact: []*Redaction{{Name: "passport"}}}, err: "passport"},
+		{name: "deny", cfg: &Config{Deny: []*DenyRule{{Name: "empty"}}}, err: "empty"},
+		{name: "response deny", cfg: &Config{Response: ResponseConfig{Deny: []*DenyRule{{Name: "bad", Patterns: []string{"("}}}}}, err: "response"},
+		{name: "hold", cfg: &Config{Response: ResponseConfig{Hold: -1}}, err: "hold -1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&FilterFactory{cfg: tc.cfg}).Apply()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestStreamHold(t *testing.T) {
+	rules, err := compileDenyRules(responseDeny)
+	require.NoError(t, err)
+	g := newResponseGuard(rules, longestMatch(rules))
+	line := func(content string) []byte {
+		return []byte(fmt.Sprintf(`data: {"choices":[{"index":0,"delta":{"content":%q}}]}`+"\n", content))
+	}
+
+	// a chunk goes once the text after it is as long as the longest match
+	assert.Empty(t, g.line(line("the code")))
+	assert.Empty(t, g.line([]byte("\n")))
+	assert.Empty(t, g.line(line(" name")))
+	assert.Equal(t, string(line("the code"))+"\n", string(g.line(line(" is"))))
+	assert.Equal(t, string(line(" name")), string(g.line(line(" Robin"))))
+	// the end of the stream passes on what is held
+	assert.Equal(t, string(line(" is"))+string(line(" Robin"))+"data: [DONE]\n", string(g.line([]byte("data: [DONE]\n"))))
+	assert.Nil(t, g.blocked)
+
+	// the end of a long text is cut at the start of a rune
+	g = newResponseGuard(rules, 0)
+	g.line(line("a" + strings.Repeat("é", window/2) + "b"))
+	assert.True(t, utf8.ValidString(g.texts[0]))
+	assert.Equal(t, window-1, len(g.texts[0]))
+}
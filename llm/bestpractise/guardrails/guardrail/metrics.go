@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package guardrail
+
+import (
+	"context"
+)
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+const (
+	actionRedact = "redact"
+	actionBlock  = "block"
+	actionCap    = "cap"
+	actionFilter = "filter"
+)
+
+// metrics of the guardrail, exported on the prometheus port of pixiu. The exporter of pixiu adds no
+// suffix, the counter is named with _total like the other llm metrics.
+type metrics struct {
+	actions syncint64.Counter
+}
+
+func registerMetrics() (*metrics, error) {
+	actions, err := global.MeterProvider().Meter("pixiu").SyncInt64().Counter("pixiu_llm_guardrail_actions_total",
+		instrument.WithDescription("actions of the guardrail by rule and action, redact, block, cap or filter"))
+	if err != nil {
+		return nil, err
+	}
+	return &metrics{actions: actions}, nil
+}
+
+func (m *metrics) record(ctx context.Context, rule, action string) {
+	m.actions.Add(ctx, 1, attribute.String("rule", rule), attribute.String("action", action))
+}
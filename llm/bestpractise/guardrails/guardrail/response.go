@@ -0,0 +1,195 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package guardrail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+const (
+	// window is the end of a reply the deny rules of the responses are matched against, so a stream
+	// costs the same for each chunk however long it gets
+	window = 4096
+	// unboundedHold is the tail of a stream held back when a deny rule has no longest match, like
+	// one with + or *
+	unboundedHold = 256
+)
+
+// responseGuard scans the replies of the provider. A stream is scanned chunk by chunk: the chunks
+// are held back until the text after them is hold bytes long, so a denied text is never sent in
+// part. The chunk that completes it is dropped with the held ones, and the stream ends with the
+// content_filter finish reason.
+type responseGuard struct {
+	rules []*denyRule
+	hold  int
+	// texts are the ends of the contents of the choices so far, and sizes their whole lengths
+	texts   map[int]string
+	sizes   map[int]int
+	held    []heldLine
+	blocked *denyRule
+}
+
+// heldLine is a line of a stream not passed on yet, with the length of the choices it adds to
+type heldLine struct {
+	line  []byte
+	sizes map[int]int
+}
+
+type chunk struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func newResponseGuard(rules []*denyRule, hold int) *responseGuard {
+	return &responseGuard{rules: rules, hold: hold, texts: map[int]string{}, sizes: map[int]int{}}
+}
+
+// line takes the next line of a stream and returns what is passed on in its place, the held lines
+// that may go now
+func (g *responseGuard) line(line []byte) []byte {
+	if g.blocked != nil {
+		return nil
+	}
+	var out bytes.Buffer
+	sizes, blocked := g.scan(line, &out)
+	if blocked {
+		g.held = nil
+		return out.Bytes()
+	}
+	g.held = append(g.held, heldLine{line: append([]byte(nil), line...), sizes: sizes})
+	if data, ok := llmfilter.EventData(line); ok && string(data) == "[DONE]" {
+		return g.flush()
+	}
+	for len(g.held) > 0 && g.passed(g.held[0]) {
+		out.Write(g.held[0].line)
+		g.held = g.held[1:]
+	}
+	return out.Bytes()
+}
+
+// passed tells whether the text after a held line is long enough for the line to go
+func (g *responseGuard) passed(h heldLine) bool {
+	for index, size := range h.sizes {
+		if g.sizes[index]-size < g.hold {
+			return false
+		}
+	}
+	return true
+}
+
+// flush returns the held lines, at the end of a stream
+func (g *responseGuard) flush() []byte {
+	var out []byte
+	for _, h := range g.held {
+		out = append(out, h.line...)
+	}
+	g.held = nil
+	return out
+}
+
+// scan adds the chunk of an event line to the texts, and ends the stream when one is denied. It
+// returns the length of the choices the chunk adds to.
+func (g *responseGuard) scan(line []byte, out *bytes.Buffer) (map[int]int, bool) {
+	data, ok := llmfilter.EventData(line)
+	if !ok {
+		return nil, false
+	}
+	var c chunk
+	if json.Unmarshal(data, &c) != nil {
+		return nil, false
+	}
+	sizes := map[int]int{}
+	for _, choice := range c.Choices {
+		delta := choice.Delta.ReasoningContent + choice.Delta.Content
+		g.sizes[choice.Index] += len(delta)
+		sizes[choice.Index] = g.sizes[choice.Index]
+		text := g.texts[choice.Index] + delta
+		if len(text) > window {
+			// the end is cut at the start of a rune, so a match never starts in the middle of one
+			cut := len(text) - window
+			for cut < len(text) && !utf8.RuneStart(text[cut]) {
+				cut++
+			}
+			text = text[cut:]
+		}
+		g.texts[choice.Index] = text
+		if r := denied(g.rules, text); r != nil {
+			g.blocked = r
+			stop, _ := json.Marshal(map[string]interface{}{
+				"id":      c.ID,
+				"object":  "chat.completion.chunk",
+				"created": c.Created,
+				"model":   c.Model,
+				"choices": []map[string]interface{}{{"index": choice.Index, "delta": map[string]interface{}{}, "finish_reason": "content_filter"}},
+			})
+			fmt.Fprintf(out, "data: %s\n\ndata: [DONE]\n\n", stop)
+			return nil, true
+		}
+	}
+	return sizes, false
+}
+
+// guardCompletion empties the choices of a json completion a rule denies, with the content_filter
+// finish reason. It returns the completion unchanged when no rule matches.
+func guardCompletion(rules []*denyRule, body []byte) ([]byte, *denyRule) {
+	var completion map[string]interface{}
+	if json.Unmarshal(body, &completion) != nil {
+		return body, nil
+	}
+	choices, _ := completion["choices"].([]interface{})
+	var blocked *denyRule
+	for _, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		msg, _ := choice["message"].(map[string]interface{})
+		if msg == nil {
+			continue
+		}
+		content, _ := msg["content"].(string)
+		reasoning, _ := msg["reasoning_content"].(string)
+		if r := denied(rules, reasoning+content); r != nil {
+			blocked = r
+			msg["content"] = ""
+			delete(msg, "reasoning_content")
+			delete(msg, "tool_calls")
+			choice["finish_reason"] = "content_filter"
+		}
+	}
+	if blocked == nil {
+		return body, nil
+	}
+	guarded, err := json.Marshal(completion)
+	if err != nil {
+		return body, nil
+	}
+	return guarded, blocked
+}
@@ -0,0 +1,229 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// builtinPatterns are the pii a redaction finds by its name alone. The redactions run in the order
+// of the config, credit_card goes before phone, which may match a part of a card number.
+var builtinPatterns = map[string]string{
+	"email":       `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"credit_card": `\b\d{4}[ -]?(?:\d{4}[ -]?){2}\d{1,7}\b|\b\d{4}[ -]?\d{6}[ -]?\d{5}\b`,
+	"phone":       `\+\d{1,3}[ -]?\d[\d -]{6,}\d|\b\d{3}[-. ]\d{3}[-. ]\d{4}\b`,
+	"ipv4":        `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`,
+}
+
+type (
+	// Redaction replaces the matches of a pattern in the prompts
+	Redaction struct {
+		Name string `yaml:"name" json:"name" mapstructure:"name"`
+		// Pattern is a regular expression, the builtin pattern of the name when empty: email,
+		// credit_card, phone or ipv4
+		Pattern string `yaml:"pattern" json:"pattern" mapstructure:"pattern"`
+		// Replacement of a match, [REDACTED:<name>] when empty
+		Replacement string `yaml:"replacement" json:"replacement" mapstructure:"replacement"`
+	}
+
+	// DenyRule blocks the texts that contain one of its words or match one of its patterns
+	DenyRule struct {
+		Name string `yaml:"name" json:"name" mapstructure:"name"`
+		// Words are matched whole and in any case
+		Words []string `yaml:"words" json:"words" mapstructure:"words"`
+		// Patterns are regular expressions, (?i) ignores the case
+		Patterns []string `yaml:"patterns" json:"patterns" mapstructure:"patterns"`
+		// Message sent back when a request is blocked
+		Message string `yaml:"message" json:"message" mapstructure:"message"`
+	}
+)
+
+type redaction struct {
+	name        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+type denyRule struct {
+	name    string
+	pattern *regexp.Regexp
+	message string
+}
+
+func compileRedactions(redactions []*Redaction) ([]*redaction, error) {
+	compiled := make([]*redaction, 0, len(redactions))
+	for i, r := range redactions {
+		if r.Name == "" {
+			return nil, fmt.Errorf("redaction %d has no name", i)
+		}
+		pattern := r.Pattern
+		if pattern == "" {
+			builtin, ok := builtinPatterns[r.Name]
+			if !ok {
+				return nil, fmt.Errorf("redaction %s has no pattern and is not one of email, credit_card, phone or ipv4", r.Name)
+			}
+			pattern = builtin
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction %s: %w", r.Name, err)
+		}
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = "[REDACTED:" + r.Name + "]"
+		}
+		compiled = append(compiled, &redaction{name: r.Name, pattern: re, replacement: replacement})
+	}
+	return compiled, nil
+}
+
+// compileDenyRules joins the words and the patterns of a rule into one expression
+func compileDenyRules(rules []*DenyRule) ([]*denyRule, error) {
+	compiled := make([]*denyRule, 0, len(rules))
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("deny rule %d has no name", i)
+		}
+		var alternatives []string
+		for _, word := range r.Words {
+			if strings.TrimSpace(word) == "" {
+				return nil, fmt.Errorf("deny rule %s has an empty word", r.Name)
+			}
+			alternatives = append(alternatives, `(?i:\b`+regexp.QuoteMeta(strings.TrimSpace(word))+`\b)`)
+		}
+		for _, pattern := range r.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("deny rule %s: %w", r.Name, err)
+			}
+			alternatives = append(alternatives, "(?:"+pattern+")")
+		}
+		if len(alternatives) == 0 {
+			return nil, fmt.Errorf("deny rule %s has no words and no patterns", r.Name)
+		}
+		message := r.Message
+		if message == "" {
+			message = fmt.Sprintf("the content is blocked by the %s rule of the gateway", r.Name)
+		}
+		compiled = append(compiled, &denyRule{name: r.Name, pattern: regexp.MustCompile(strings.Join(alternatives, "|")), message: message})
+	}
+	return compiled, nil
+}
+
+// denied returns the first rule the text matches
+func denied(rules []*denyRule, text string) *denyRule {
+	for _, r := range rules {
+		if r.pattern.MatchString(text) {
+			return r
+		}
+	}
+	return nil
+}
+
+// longestMatch returns the most bytes a match of the rules spans, unboundedHold when one has no bound
+func longestMatch(rules []*denyRule) int {
+	longest := 0
+	for _, r := range rules {
+		re, err := syntax.Parse(r.pattern.String(), syntax.Perl)
+		if err != nil {
+			return unboundedHold
+		}
+		n, ok := maxLen(re)
+		if !ok || n > window {
+			return unboundedHold
+		}
+		longest = max(longest, n)
+	}
+	return longest
+}
+
+// maxLen returns the most bytes a match of re spans, false when it has no bound
+func maxLen(re *syntax.Regexp) (int, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		n := 0
+		for _, r := range re.Rune {
+			n += runeLen(r, re.Flags&syntax.FoldCase != 0)
+		}
+		return n, true
+	case syntax.OpCharClass:
+		// the ranges are sorted, the last one ends with the widest rune
+		if len(re.Rune) == 0 {
+			return 0, true
+		}
+		return runeLen(re.Rune[len(re.Rune)-1], false), true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return utf8.UTFMax, true
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxLen(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		return 0, false
+	case syntax.OpRepeat:
+		n, ok := maxLen(re.Sub[0])
+		if re.Max < 0 {
+			return 0, false
+		}
+		return n * re.Max, ok
+	case syntax.OpConcat, syntax.OpAlternate:
+		total := 0
+		for _, sub := range re.Sub {
+			n, ok := maxLen(sub)
+			if !ok {
+				return 0, false
+			}
+			if re.Op == syntax.OpConcat {
+				total += n
+			} else {
+				total = max(total, n)
+			}
+		}
+		return total, true
+	}
+	// the empty width assertions, like \b and ^
+	return 0, true
+}
+
+// runeLen is the length of r in utf-8, the longest of its cases when fold is set
+func runeLen(r rune, fold bool) int {
+	n := utf8.RuneLen(r)
+	if n < 0 {
+		n = utf8.UTFMax
+	}
+	if fold {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			n = max(n, utf8.RuneLen(f))
+		}
+	}
+	return n
+}
+
+// redact replaces the pii of the text and returns the names of the redactions that matched
+func redact(redactions []*redaction, text string) (string, []string) {
+	var matched []string
+	for _, r := range redactions {
+		if r.pattern.MatchString(text) {
+			text = r.pattern.ReplaceAllLiteralString(text, r.replacement)
+			matched = append(matched, r.name)
+		}
+	}
+	return text, matched
+}
@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chatload sends chat completions, concurrency at once, and measures them. The load mode
+// of the go-client and the loadtest share it.
+package chatload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Result is the measurement of a request
+type Result struct {
+	Latency time.Duration
+	// TTFT is the time to the first token of a stream, 0 for a json reply
+	TTFT             time.Duration
+	PromptTokens     int64
+	CompletionTokens int64
+	Err              error
+}
+
+// Measure sends a request and returns its latency, the time to its first token and its usage. The
+// usage of a stream is the sum of the one of its chunks.
+func Measure(ctx context.Context, client openai.Client, params openai.ChatCompletionNewParams, stream bool, opts ...option.RequestOption) Result {
+	start := time.Now()
+	if !stream {
+		completion, err := client.Chat.Completions.New(ctx, params, opts...)
+		if err != nil {
+			return Result{Err: err}
+		}
+		return Result{
+			Latency:          time.Since(start),
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+		}
+	}
+
+	s := client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	defer s.Close()
+	var r Result
+	for s.Next() {
+		chunk := s.Current()
+		if r.TTFT == 0 && len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			r.TTFT = time.Since(start)
+		}
+		r.PromptTokens += chunk.Usage.PromptTokens
+		r.CompletionTokens += chunk.Usage.CompletionTokens
+	}
+	if err := s.Err(); err != nil {
+		return Result{Err: err}
+	}
+	r.Latency = time.Since(start)
+	return r
+}
+
+// Run calls send with the requests 0, 1, ... concurrency at once, until n are sent, without end
+// when n is 0, or until stop is done. A rate above 0 starts that many requests a second, else they
+// go as fast as the concurrency allows. The requests in flight are waited for, and the ones sent
+// are returned.
+func Run(stop context.Context, concurrency, n int, rate float64, send func(i int)) int {
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for k := 0; k < concurrency; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				send(i)
+			}
+		}()
+	}
+	sent := 0
+loop:
+	for ; (n == 0 || sent < n) && stop.Err() == nil; sent++ {
+		if tick != nil && sent > 0 {
+			select {
+			case <-tick:
+			case <-stop.Done():
+				break loop
+			}
+		}
+		select {
+		case jobs <- sent:
+		case <-stop.Done():
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return sent
+}
+
+// Percentiles returns the p50, p90, p99 and max of the durations, nothing without any
+func Percentiles(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return ""
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return fmt.Sprintf("p50 %s, p90 %s, p99 %s, max %s",
+		Percentile(sorted, 50).Round(time.Millisecond), Percentile(sorted, 90).Round(time.Millisecond),
+		Percentile(sorted, 99).Round(time.Millisecond), sorted[len(sorted)-1].Round(time.Millisecond))
+}
+
+// Percentile returns the nearest rank percentile p of the sorted durations
+func Percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chatload
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	var calls, inFlight, most atomic.Int32
+	sent := Run(context.Background(), 3, 10, 0, func(i int) {
+		calls.Add(1)
+		n := inFlight.Add(1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		inFlight.Add(-1)
+	})
+	assert.Equal(t, 10, sent)
+	assert.EqualValues(t, 10, calls.Load())
+	assert.LessOrEqual(t, most.Load(), int32(3))
+
+	// without a count the requests go until stop is done
+	stop, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sent = Run(stop, 2, 0, 20, func(int) {})
+	assert.GreaterOrEqual(t, sent, 1)
+	assert.LessOrEqual(t, sent, 4)
+
+	assert.Zero(t, Run(stop, 2, 5, 0, func(int) {}), "nothing is sent once stop is done")
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 5*time.Millisecond, Percentile(sorted, 50))
+	assert.Equal(t, 9*time.Millisecond, Percentile(sorted, 90))
+	assert.Equal(t, 10*time.Millisecond, Percentile(sorted, 99))
+	assert.Equal(t, time.Millisecond, Percentile(sorted[:1], 50))
+
+	assert.Equal(t, "p50 5ms, p90 10ms, p99 10ms, max 10ms", Percentiles([]time.Duration{10 * time.Millisecond, 5 * time.Millisecond}))
+	assert.Empty(t, Percentiles(nil))
+}
@@ -0,0 +1,419 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+import (
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus/common/expfmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	statusOK   = "ok"
+	statusWarn = "warn"
+	statusFail = "FAIL"
+	statusSkip = "skip"
+)
+
+// bounds are the metrics that count a part of another one, the ratio panels read them as
+// percentages, so the part is never more than the whole
+var bounds = []struct {
+	part, whole string
+}{
+	{part: "pixiu_llm_upstream_requests_success_total", whole: "pixiu_llm_upstream_requests_total"},
+	{part: "pixiu_llm_upstream_requests_failure_total", whole: "pixiu_llm_upstream_requests_total"},
+	{part: "pixiu_llm_streaming_requests_total", whole: "pixiu_llm_upstream_requests_total"},
+	{part: "pixiu_llm_prompt_tokens_total", whole: "pixiu_llm_total_tokens_total"},
+	{part: "pixiu_llm_completion_tokens_total", whole: "pixiu_llm_total_tokens_total"},
+}
+
+// filterMetrics are the prefixes of the metrics of the llm filters a chain may leave out
+var filterMetrics = []struct {
+	prefix, kind string
+}{
+	{prefix: "pixiu_llm_consumer_", kind: "dgp.filter.llm.tokenquota"},
+	{prefix: "pixiu_llm_cache_", kind: "dgp.filter.llm.responsecache"},
+	{prefix: "pixiu_llm_guardrail_", kind: "dgp.filter.llm.guardrail"},
+}
+
+// series is a sample of the exposition of pixiu
+type series struct {
+	labels map[string]string
+	value  float64
+}
+
+// metricUse is what the queries of the dashboard need of a metric
+type metricUse struct {
+	metric  string
+	sources []string
+	// labels by name, with the sources that need them
+	labels map[string][]string
+	values map[string][]string
+}
+
+// result is the outcome of the checks of a metric
+type result struct {
+	metric   string
+	status   string
+	series   int
+	problems []string
+	sources  []string
+	// unchecked is the filter missing from the chain of the conf that the metric belongs to
+	unchecked string
+}
+
+// scrape reads the metrics of pixiu by sample name. The buckets, sums and counts of the histograms
+// and the summaries are series of their own, like prometheus stores them.
+func scrape(url string) (map[string][]series, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scrape %s: %s", url, resp.Status)
+	}
+	return parseExposition(resp.Body)
+}
+
+func parseExposition(r io.Reader) (map[string][]series, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+	samples := map[string][]series{}
+	add := func(name string, m *dto.Metric, value float64, extra ...string) {
+		labels := map[string]string{}
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			labels[extra[i]] = extra[i+1]
+		}
+		samples[name] = append(samples[name], series{labels: labels, value: value})
+	}
+	for name, family := range families {
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m, m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				add(name+"_sum", m, h.GetSampleSum())
+				add(name+"_count", m, float64(h.GetSampleCount()))
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m, float64(b.GetCumulativeCount()), "le", fmt.Sprint(b.GetUpperBound()))
+				}
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				add(name+"_sum", m, s.GetSampleSum())
+				add(name+"_count", m, float64(s.GetSampleCount()))
+				for _, q := range s.GetQuantile() {
+					add(name, m, q.GetValue(), "quantile", fmt.Sprint(q.GetQuantile()))
+				}
+			default:
+				add(name, m, m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return samples, nil
+}
+
+// metricUses gathers the metrics of the queries in the order the dashboard reads them
+func metricUses(queries []query) []*metricUse {
+	var ordered []*metricUse
+	byMetric := map[string]*metricUse{}
+	for _, q := range queries {
+		for _, sel := range selectors(q.expr) {
+			u := byMetric[sel.metric]
+			if u == nil {
+				u = &metricUse{metric: sel.metric, labels: map[string][]string{}, values: map[string][]string{}}
+				byMetric[sel.metric] = u
+				ordered = append(ordered, u)
+			}
+			u.sources = appendLabel(u.sources, q.source)
+			for _, label := range sel.labels {
+				u.labels[label] = appendLabel(u.labels[label], q.source)
+			}
+			for label, value := range sel.values {
+				key := label + "=" + fmt.Sprintf("%q", value)
+				u.values[key] = appendLabel(u.values[key], q.source)
+			}
+		}
+	}
+	return ordered
+}
+
+// isCounter tells whether the samples of a metric only go up
+func isCounter(metric string) bool {
+	for _, suffix := range []string{"_total", "_count", "_sum", "_bucket"} {
+		if strings.HasSuffix(metric, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMetrics checks that each metric of the dashboard has series with the labels its queries
+// filter and group by, and values a panel can show
+func checkMetrics(cfg checkConfig, used []*metricUse, samples map[string][]series) []result {
+	results := make([]result, 0, len(used))
+	for _, u := range used {
+		r := result{metric: u.metric, status: statusOK, sources: u.sources}
+		if kind := missingFilter(cfg.filters, u.metric); kind != "" {
+			// a warning, not a skip, so the panels the run leaves empty are not missed
+			r.status, r.unchecked = statusWarn, kind
+			r.problems = append(r.problems, fmt.Sprintf("not checked, %s is not in the chain of %s", kind, cfg.conf))
+			results = append(results, r)
+			continue
+		}
+		if cfg.ignore != nil && cfg.ignore.MatchString(u.metric) {
+			r.status = statusSkip
+			results = append(results, r)
+			continue
+		}
+		optional := cfg.optional != nil && cfg.optional.MatchString(u.metric)
+		fail := func(format string, args ...interface{}) {
+			r.problems = append(r.problems, fmt.Sprintf(format, args...))
+			if optional && r.status == statusOK {
+				r.status = statusWarn
+			} else if !optional {
+				r.status = statusFail
+			}
+		}
+
+		all := samples[u.metric]
+		r.series = len(all)
+		if len(all) == 0 {
+			fail("no series, the panels stay empty")
+			results = append(results, r)
+			continue
+		}
+
+		positive := false
+		for _, s := range all {
+			switch {
+			case math.IsNaN(s.value) || math.IsInf(s.value, 0):
+				fail("a series is %v", s.value)
+			case isCounter(u.metric) && s.value < 0:
+				fail("the counter is negative: %v", s.value)
+			}
+			if s.value > 0 {
+				positive = true
+			}
+		}
+		if isCounter(u.metric) && !positive {
+			fail("every series is 0, the load did not reach it")
+		}
+
+		for _, label := range sortedKeys(u.labels) {
+			if !hasLabel(all, label, nil) {
+				fail("no series has the label %s, used by %s", label, strings.Join(u.labels[label], ", "))
+			}
+		}
+		for _, matcher := range sortedKeys(u.values) {
+			label, value, _ := strings.Cut(matcher, "=")
+			want := strings.Trim(value, `"`)
+			if !hasLabel(all, label, &want) {
+				fail("no series has %s, used by %s", matcher, strings.Join(u.values[matcher], ", "))
+			}
+		}
+
+		for _, b := range bounds {
+			whole, ok := samples[b.whole]
+			if b.part != u.metric || !ok {
+				continue
+			}
+			if part, total := sum(all), sum(whole); part > total {
+				fail("%v is more than the %v of %s", part, total, b.whole)
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// missingFilter returns the filter of a metric when it is not one of the filters of the chain
+func missingFilter(filters map[string]bool, metric string) string {
+	if filters == nil {
+		return ""
+	}
+	for _, f := range filterMetrics {
+		if strings.HasPrefix(metric, f.prefix) && !filters[f.kind] {
+			return f.kind
+		}
+	}
+	return ""
+}
+
+// chainFilters reads the names of the http filters of a pixiu config
+func chainFilters(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	names := map[string]bool{}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			filters, _ := n["http_filters"].([]interface{})
+			for _, f := range filters {
+				if m, ok := f.(map[string]interface{}); ok {
+					if name, ok := m["name"].(string); ok {
+						names[name] = true
+					}
+				}
+			}
+			for _, v := range n {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range n {
+				walk(v)
+			}
+		}
+	}
+	walk(doc)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no http filter in %s", path)
+	}
+	return names, nil
+}
+
+func hasLabel(all []series, label string, value *string) bool {
+	for _, s := range all {
+		if v, ok := s.labels[label]; ok && (value == nil || v == *value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sum(all []series) float64 {
+	total := 0.0
+	for _, s := range all {
+		total += s.value
+	}
+	return total
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// check scrapes pixiu and checks the metrics of the dashboard, it fails when a panel would miss
+// its data
+func check(cfg checkConfig, out io.Writer) error {
+	queries, err := loadDashboard(cfg.dashboard)
+	if err != nil {
+		return err
+	}
+	used := metricUses(queries)
+	if len(used) == 0 {
+		return fmt.Errorf("no metric in the queries of %s", cfg.dashboard)
+	}
+	if cfg.conf != "" {
+		if cfg.filters, err = chainFilters(cfg.conf); err != nil {
+			return err
+		}
+	}
+	samples, err := scrape(cfg.metrics)
+	if err != nil {
+		return err
+	}
+
+	results := checkMetrics(cfg, used, samples)
+	failed := printResults(out, results)
+	printUnchecked(out, cfg.conf, results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d metrics of %s failed", failed, len(results), cfg.dashboard)
+	}
+	return nil
+}
+
+// printResults prints a line per metric with its panels, and the problems below it
+func printResults(w io.Writer, results []result) int {
+	width, failed := 0, 0
+	for _, r := range results {
+		width = max(width, len(r.metric))
+	}
+	for _, r := range results {
+		detail := fmt.Sprintf("%d series", r.series)
+		if r.status == statusSkip {
+			detail = "ignored"
+		} else if r.unchecked != "" {
+			detail = "unchecked"
+		}
+		fmt.Fprintf(w, "%-4s  %-*s  %-9s  %s\n", r.status, width, r.metric, detail, strings.Join(r.sources, ", "))
+		for _, problem := range r.problems {
+			fmt.Fprintf(w, "      - %s\n", problem)
+		}
+		if r.status == statusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// printUnchecked warns of the metrics of the filters missing from the chain of the conf, with
+// the filters a config needs to check them all
+func printUnchecked(w io.Writer, conf string, results []result) {
+	unchecked := 0
+	var kinds []string
+	for _, r := range results {
+		if r.unchecked == "" {
+			continue
+		}
+		unchecked++
+		if !slices.Contains(kinds, r.unchecked) {
+			kinds = append(kinds, r.unchecked)
+		}
+	}
+	if unchecked > 0 {
+		fmt.Fprintf(w, "%s: %d of %d metrics are not checked, check again against a pixiu whose chain has %s\n",
+			statusWarn, unchecked, len(results), strings.Join(kinds, ", "))
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = `# HELP requests_total requests to the upstreams
+# TYPE requests_total counter
+requests_total{cluster_name="chat",endpoint_address="127.0.0.1:8090"} 12
+# TYPE requests_success_total counter
+requests_success_total{cluster_name="chat",endpoint_address="127.0.0.1:8090"} 14
+# TYPE tokens_total counter
+tokens_total{model="deepseek-chat",type="prompt"} 40
+tokens_total{model="deepseek-chat",type="completion"} 0
+# TYPE idle_total counter
+idle_total{model="deepseek-chat"} 0
+# TYPE remaining gauge
+remaining{consumer="alice"} -3
+# TYPE duration histogram
+duration_bucket{cluster_name="chat",le="100"} 3
+duration_bucket{cluster_name="chat",le="+Inf"} 12
+duration_sum{cluster_name="chat"} 840
+duration_count{cluster_name="chat"} 12
+`
+
+func TestParseExposition(t *testing.T) {
+	samples, err := parseExposition(strings.NewReader(exposition))
+	require.NoError(t, err)
+
+	assert.Equal(t, []series{{labels: map[string]string{"cluster_name": "chat", "endpoint_address": "127.0.0.1:8090"}, value: 12}}, samples["requests_total"])
+	assert.Len(t, samples["tokens_total"], 2)
+	assert.Equal(t, 840.0, samples["duration_sum"][0].value)
+	assert.Equal(t, 12.0, samples["duration_count"][0].value)
+	assert.Len(t, samples["duration_bucket"], 2)
+	assert.Equal(t, -3.0, samples["remaining"][0].value)
+}
+
+func TestCheckMetrics(t *testing.T) {
+	samples, err := parseExposition(strings.NewReader(exposition))
+	require.NoError(t, err)
+	queries := []query{
+		{source: "QPS", expr: `sum(rate(requests_total{cluster_name=~"$cluster_name"}[5m])) by (endpoint_address)`},
+		{source: "Success", expr: `sum(rate(requests_success_total[5m])) / sum(rate(requests_total[5m]))`},
+		{source: "Tokens", expr: `sum(rate(tokens_total{type="prompt"}[1m])) by (model) + sum(rate(tokens_total{type="cached"}[1m]))`},
+		{source: "Errors", expr: `sum(rate(requests_failure_total[5m])) by (status_code)`},
+		{source: "Duration", expr: `sum(rate(duration_sum[5m])) by (consumer) / sum(rate(duration_count[5m]))`},
+		{source: "Idle", expr: `sum(idle_total)`},
+		{source: "Quota", expr: `min(remaining) by (consumer)`},
+		{source: "Spend", expr: `sum(increase(cost_total[1h]))`},
+		{source: "$model", expr: `label_values(tokens_total, model)`},
+	}
+	cfg := checkConfig{
+		optional: regexp.MustCompile("failure"),
+		ignore:   regexp.MustCompile("^cost_"),
+	}
+	bounds = append(bounds, struct{ part, whole string }{part: "requests_success_total", whole: "requests_total"})
+	defer func() { bounds = bounds[:len(bounds)-1] }()
+
+	byMetric := map[string]result{}
+	for _, r := range checkMetrics(cfg, metricUses(queries), samples) {
+		byMetric[r.metric] = r
+	}
+
+	testCases := []struct {
+		metric  string
+		status  string
+		problem string
+	}{
+		{metric: "requests_total", status: statusOK},
+		{metric: "requests_success_total", status: statusFail, problem: "14 is more than the 12 of requests_total"},
+		{metric: "tokens_total", status: statusFail, problem: `no series has type="cached", used by Tokens`},
+		{metric: "requests_failure_total", status: statusWarn, problem: "no series"},
+		{metric: "duration_sum", status: statusFail, problem: "no series has the label consumer"},
+		{metric: "duration_count", status: statusOK},
+		{metric: "idle_total", status: statusFail, problem: "every series is 0"},
+		// a gauge may go below 0
+		{metric: "remaining", status: statusOK},
+		{metric: "cost_total", status: statusSkip},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.metric, func(t *testing.T) {
+			r, ok := byMetric[tc.metric]
+			require.True(t, ok)
+			assert.Equal(t, tc.status, r.status, "%v", r.problems)
+			if tc.problem == "" {
+				assert.Empty(t, r.problems)
+				return
+			}
+			require.NotEmpty(t, r.problems)
+			assert.Contains(t, strings.Join(r.problems, "\n"), tc.problem)
+		})
+	}
+	assert.Equal(t, []string{"Tokens", "$model"}, byMetric["tokens_total"].sources)
+	assert.Len(t, byMetric, len(testCases))
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, exposition)
+	}))
+	defer server.Close()
+
+	dashboard := filepath.Join(t.TempDir(), "grafana.json")
+	write := func(exprs ...string) {
+		targets := make([]string, 0, len(exprs))
+		for _, expr := range exprs {
+			targets = append(targets, fmt.Sprintf(`{"expr": %q}`, expr))
+		}
+		content := fmt.Sprintf(`{"panels": [{"type": "row", "panels": [{"title": "QPS", "targets": [%s]}]}]}`, strings.Join(targets, ","))
+		require.NoError(t, os.WriteFile(dashboard, []byte(content), 0o644))
+	}
+	cfg := checkConfig{dashboard: dashboard, metrics: server.URL}
+
+	write(`sum(rate(requests_total[5m])) by (cluster_name)`)
+	var out bytes.Buffer
+	require.NoError(t, check(cfg, &out))
+	assert.Equal(t, "ok    requests_total  1 series   QPS\n", out.String())
+
+	write(`sum(rate(requests_total[5m]))`, `sum(rate(missing_total[5m]))`)
+	out.Reset()
+	err := check(cfg, &out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 2 metrics")
+	assert.Contains(t, out.String(), "FAIL  missing_total   0 series   QPS\n      - no series, the panels stay empty\n")
+}
+
+func TestChainFilters(t *testing.T) {
+	filters, err := chainFilters("../cache/pixiu/conf.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"dgp.filter.llm.responsecache": true, "dgp.filter.llm.proxy": true, "dgp.filter.llm.tokenizer": true}, filters)
+	assert.False(t, filters["dgp.filter.llm.tokenquota"])
+
+	conf := filepath.Join(t.TempDir(), "conf.yaml")
+	require.NoError(t, os.WriteFile(conf, []byte("static_resources:\n  listeners: []\n"), 0o644))
+	_, err = chainFilters(conf)
+	assert.ErrorContains(t, err, "no http filter")
+
+	queries := []query{
+		{source: "Hit Ratio", expr: `sum(rate(pixiu_llm_cache_requests_total[5m]))`},
+		{source: "Spend", expr: `sum(increase(pixiu_llm_consumer_cost_total[1h]))`},
+	}
+	cfg := checkConfig{conf: "conf.yaml", filters: filters}
+	results := checkMetrics(cfg, metricUses(queries), map[string][]series{})
+	require.Len(t, results, 2)
+	assert.Equal(t, statusFail, results[0].status, "the cache is in the chain")
+	assert.Equal(t, statusWarn, results[1].status, "a metric left out by the chain warns")
+	assert.Equal(t, "dgp.filter.llm.tokenquota", results[1].unchecked)
+	assert.Equal(t, []string{"not checked, dgp.filter.llm.tokenquota is not in the chain of conf.yaml"}, results[1].problems)
+
+	var out strings.Builder
+	printUnchecked(&out, cfg.conf, results)
+	assert.Equal(t, "warn: 1 of 2 metrics are not checked, check again against a pixiu whose chain has dgp.filter.llm.tokenquota\n", out.String())
+}
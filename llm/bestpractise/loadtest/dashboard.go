@@ -0,0 +1,333 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// query is a prometheus query of the dashboard, the expr of a panel target or the query of a
+// template variable
+type query struct {
+	// source is the title of the panel or the name of the variable
+	source string
+	expr   string
+}
+
+// selector is a metric read by a query, with what the query needs of its series
+type selector struct {
+	metric string
+	// labels are the label names the query filters or groups the series by
+	labels []string
+	// values are the fixed label values of the = matchers
+	values map[string]string
+}
+
+type (
+	dashboard struct {
+		Panels     []panel `json:"panels"`
+		Templating struct {
+			List []variable `json:"list"`
+		} `json:"templating"`
+	}
+
+	panel struct {
+		Title   string `json:"title"`
+		Targets []struct {
+			Expr string `json:"expr"`
+			Hide bool   `json:"hide"`
+		} `json:"targets"`
+		// Panels are the ones of a collapsed row
+		Panels []panel `json:"panels"`
+	}
+
+	variable struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		// Query is a string, or an object with the query in newer dashboards
+		Query json.RawMessage `json:"query"`
+	}
+)
+
+// loadDashboard returns the queries of the panels and of the template variables of a dashboard
+func loadDashboard(path string) ([]query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var d dashboard
+	if err = json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var queries []query
+	var walk func(panels []panel)
+	walk = func(panels []panel) {
+		for _, p := range panels {
+			for _, t := range p.Targets {
+				if t.Expr != "" && !t.Hide {
+					queries = append(queries, query{source: p.Title, expr: t.Expr})
+				}
+			}
+			walk(p.Panels)
+		}
+	}
+	walk(d.Panels)
+
+	for _, v := range d.Templating.List {
+		if v.Type != "query" || len(v.Query) == 0 {
+			continue
+		}
+		var expr string
+		if err = json.Unmarshal(v.Query, &expr); err != nil {
+			var object struct {
+				Query string `json:"query"`
+			}
+			if err = json.Unmarshal(v.Query, &object); err != nil {
+				return nil, fmt.Errorf("parse the query of the variable %s: %w", v.Name, err)
+			}
+			expr = object.Query
+		}
+		if expr != "" {
+			queries = append(queries, query{source: "$" + v.Name, expr: expr})
+		}
+	}
+	return queries, nil
+}
+
+var (
+	labelValuesPattern = regexp.MustCompile(`^\s*label_values\((.*),\s*(\w+)\s*\)\s*$`)
+	matcherPattern     = regexp.MustCompile(`(\w+)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"`)
+
+	// keywords of promql that are not followed by a parenthesis like the functions
+	keywords = map[string]bool{
+		"and": true, "or": true, "unless": true, "bool": true, "offset": true,
+		"group_left": true, "group_right": true, "inf": true, "nan": true,
+	}
+)
+
+// selectors returns the metrics of a query. The by and without clauses of an aggregation apply to
+// the metrics it aggregates, label_values to the metric of its selector.
+func selectors(expr string) []selector {
+	if m := labelValuesPattern.FindStringSubmatch(expr); m != nil {
+		sels := selectors(m[1])
+		for i := range sels {
+			sels[i].labels = appendLabel(sels[i].labels, m[2])
+		}
+		return sels
+	}
+
+	closing := matchParens(expr)
+	var (
+		sels      []selector
+		positions []int
+		groups    []grouping
+	)
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '[':
+			// a range, like [5m] or [$__rate_interval]
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return sels
+			}
+			i += end + 1
+		case c == '"' || c == '\'' || c == '`':
+			i = skipString(expr, i)
+		case c == '$':
+			// a variable of grafana, like $__interval
+			for i++; i < len(expr) && isIdentChar(expr[i]); i++ {
+			}
+		case isIdentStart(c) && (i == 0 || !isIdentChar(expr[i-1])):
+			start := i
+			for i < len(expr) && isIdentChar(expr[i]) {
+				i++
+			}
+			name := expr[start:i]
+			next := skipSpace(expr, i)
+			switch {
+			case name == "by" || name == "without" || name == "on" || name == "ignoring":
+				if next >= len(expr) || expr[next] != '(' || closing[next] < 0 {
+					continue
+				}
+				labels := splitList(expr[next+1 : closing[next]])
+				i = closing[next] + 1
+				if name != "by" {
+					// without drops labels, on and ignoring match the sides of a binary operator
+					continue
+				}
+				// sum(...) by (labels), or sum by (labels) (...)
+				if before := lastNonSpace(expr, start); before >= 0 && expr[before] == ')' {
+					if open := openingOf(closing, before); open >= 0 {
+						groups = append(groups, grouping{from: open, to: before, labels: labels})
+					}
+				}
+				if after := skipSpace(expr, i); after < len(expr) && expr[after] == '(' && closing[after] >= 0 {
+					groups = append(groups, grouping{from: after, to: closing[after], labels: labels})
+				}
+			case next < len(expr) && expr[next] == '(':
+				// a function or an aggregation
+			case startsWord(expr[next:], "by") || startsWord(expr[next:], "without"):
+				// an aggregation with its clause first, like sum by (model) (...)
+			case keywords[strings.ToLower(name)]:
+			default:
+				sel := selector{metric: name, values: map[string]string{}}
+				if next < len(expr) && expr[next] == '{' {
+					end := next + 1
+					for end < len(expr) && expr[end] != '}' {
+						if expr[end] == '"' {
+							end = skipString(expr, end)
+							continue
+						}
+						end++
+					}
+					sel.labels, sel.values = parseMatchers(expr[next+1 : min(end, len(expr))])
+					i = end + 1
+				}
+				sels = append(sels, sel)
+				positions = append(positions, start)
+			}
+		default:
+			i++
+		}
+	}
+	for _, g := range groups {
+		for i, pos := range positions {
+			if pos > g.from && pos < g.to {
+				for _, label := range g.labels {
+					sels[i].labels = appendLabel(sels[i].labels, label)
+				}
+			}
+		}
+	}
+	return sels
+}
+
+// grouping is a by clause, its labels apply to the metrics between from and to
+type grouping struct {
+	from, to int
+	labels   []string
+}
+
+// parseMatchers returns the labels a matcher needs and the fixed values of the = matchers. The
+// negative matchers also match the series without the label.
+func parseMatchers(matchers string) ([]string, map[string]string) {
+	var labels []string
+	values := map[string]string{}
+	for _, m := range matcherPattern.FindAllStringSubmatch(matchers, -1) {
+		name, op, value := m[1], m[2], m[3]
+		if op == "!=" || op == "!~" || name == "__name__" {
+			continue
+		}
+		labels = appendLabel(labels, name)
+		if op == "=" && !strings.Contains(value, "$") {
+			values[name] = value
+		}
+	}
+	return labels, values
+}
+
+func appendLabel(labels []string, label string) []string {
+	for _, l := range labels {
+		if l == label {
+			return labels
+		}
+	}
+	return append(labels, label)
+}
+
+// matchParens returns the position of the closing parenthesis of each opening one, -1 for the
+// other characters and the unbalanced ones
+func matchParens(expr string) []int {
+	closing := make([]int, len(expr))
+	var open []int
+	for i := 0; i < len(expr); i++ {
+		closing[i] = -1
+	}
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '"', '\'', '`':
+			i = skipString(expr, i) - 1
+		case '(':
+			open = append(open, i)
+		case ')':
+			if len(open) > 0 {
+				closing[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return closing
+}
+
+func openingOf(closing []int, end int) int {
+	for i := end - 1; i >= 0; i-- {
+		if closing[i] == end {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipString returns the position after the quoted string starting at i
+func skipString(expr string, i int) int {
+	quote := expr[i]
+	for i++; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(expr)
+}
+
+func skipSpace(expr string, i int) int {
+	for i < len(expr) && (expr[i] == ' ' || expr[i] == '\t' || expr[i] == '\n' || expr[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+func lastNonSpace(expr string, end int) int {
+	for i := end - 1; i >= 0; i-- {
+		if expr[i] != ' ' && expr[i] != '\t' && expr[i] != '\n' && expr[i] != '\r' {
+			return i
+		}
+	}
+	return -1
+}
+
+// startsWord tells whether s starts with the word
+func startsWord(s, word string) bool {
+	return strings.HasPrefix(s, word) && (len(s) == len(word) || !isIdentChar(s[len(word)]))
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
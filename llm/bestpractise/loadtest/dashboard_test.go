@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectors(t *testing.T) {
+	testCases := []struct {
+		name string
+		expr string
+		want []selector
+	}{
+		{
+			name: "matchers",
+			expr: `sum(rate(pixiu_llm_cache_requests_total{model=~"$model", result="hit"}[5m]))`,
+			want: []selector{{metric: "pixiu_llm_cache_requests_total", labels: []string{"model", "result"}, values: map[string]string{"result": "hit"}}},
+		},
+		{
+			name: "by after the aggregation",
+			expr: "sum(rate(a_total[1m])) by (cluster_name) / sum(rate(b_total{x!=\"y\"}[$__rate_interval])) by (endpoint_address, cluster_name) * 100",
+			want: []selector{
+				{metric: "a_total", labels: []string{"cluster_name"}, values: map[string]string{}},
+				{metric: "b_total", labels: []string{"endpoint_address", "cluster_name"}, values: map[string]string{}},
+			},
+		},
+		{
+			name: "by before the aggregation",
+			expr: `sum by (model) (increase(cost_total[1h])) + on (model) gauge`,
+			want: []selector{
+				{metric: "cost_total", labels: []string{"model"}, values: map[string]string{}},
+				{metric: "gauge", values: map[string]string{}},
+			},
+		},
+		{
+			name: "label_values",
+			expr: `label_values(pixiu_llm_prompt_tokens_total{cluster_name=~"$cluster_name"}, model)`,
+			want: []selector{{metric: "pixiu_llm_prompt_tokens_total", labels: []string{"cluster_name", "model"}, values: map[string]string{}}},
+		},
+		{
+			name: "keywords and numbers",
+			expr: `up offset 5m > bool 1e3 and on() vector(1)`,
+			want: []selector{{metric: "up", values: map[string]string{}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, selectors(tc.expr))
+		})
+	}
+}
+
+func TestLoadDashboardOfTheSample(t *testing.T) {
+	queries, err := loadDashboard("../grafana.json")
+	require.NoError(t, err)
+
+	sources := map[string]bool{}
+	for _, q := range queries {
+		sources[q.source] = true
+	}
+	assert.True(t, sources["Total QPS"])
+	assert.True(t, sources["Cache Hit Ratio"])
+	assert.True(t, sources["$cluster_name"], "the template variables are checked too")
+
+	used := map[string]*metricUse{}
+	for _, u := range metricUses(queries) {
+		used[u.metric] = u
+	}
+	for _, metric := range []string{
+		"pixiu_llm_upstream_requests_total",
+		"pixiu_llm_upstream_requests_success_total",
+		"pixiu_llm_prompt_tokens_total",
+		"pixiu_llm_consumer_quota_remaining_tokens",
+		"pixiu_llm_cache_saved_tokens_total",
+	} {
+		assert.Contains(t, used, metric)
+	}
+	assert.NotContains(t, used, "sum")
+	assert.NotContains(t, used, "cluster_name")
+
+	requests := used["pixiu_llm_upstream_requests_total"]
+	assert.Contains(t, requests.labels, "endpoint_address")
+	assert.Contains(t, requests.labels["cluster_name"], "$cluster_name", "label_values needs the label")
+	assert.Contains(t, used["pixiu_llm_consumer_tokens_total"].values, `type="prompt"`)
+
+	// the ttft panels read the first token, and a duration is read in a single unit
+	ttft := used["pixiu_llm_time_to_first_token_milliseconds_sum_total"]
+	require.NotNil(t, ttft)
+	assert.Equal(t, []string{"Average TTFT", "Average TTFT Trend"}, ttft.sources)
+	for metric := range used {
+		assert.NotContains(t, metric, "time_to_last_token")
+		if ms, ok := strings.CutSuffix(metric, "_milliseconds_sum_total"); ok {
+			assert.NotContains(t, used, ms+"_microseconds_sum_total", "%s is read in two units", ms)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/bestpractise/internal/chatload"
+)
+
+const (
+	modeJSON   = "json"
+	modeStream = "stream"
+
+	// goldenRatio spreads the streams evenly without a period the prompts, models or keys could share
+	goldenRatio = 0.6180339887498949
+)
+
+// loadConfig is set by the flags of the run command
+type loadConfig struct {
+	url         string
+	apiKeys     []string
+	models      []string
+	prompts     []string
+	maxTokens   int
+	concurrency int
+	duration    time.Duration
+	requests    int
+	rate        float64
+	streamRatio float64
+}
+
+// job is a request of the load
+type job struct {
+	model  string
+	prompt string
+	apiKey string
+	stream bool
+}
+
+// job returns the i-th request: the prompts are asked in turn to each model, the api keys take
+// turns after each round of them, and the streams are spread evenly by the stream ratio
+func (cfg loadConfig) job(i int) job {
+	round := len(cfg.prompts) * len(cfg.models)
+	return job{
+		model:  cfg.models[i/len(cfg.prompts)%len(cfg.models)],
+		prompt: cfg.prompts[i%len(cfg.prompts)],
+		apiKey: cfg.apiKeys[i/round%len(cfg.apiKeys)],
+		stream: math.Mod(float64(i)*goldenRatio, 1) < cfg.streamRatio,
+	}
+}
+
+// modeReport measures the requests of a mode, json or stream
+type modeReport struct {
+	requests         int
+	failed           int
+	promptTokens     int64
+	completionTokens int64
+	latencies        []time.Duration
+	// ttfts are only measured for streams
+	ttfts []time.Duration
+}
+
+func (r *modeReport) add(other *modeReport) {
+	r.requests += other.requests
+	r.failed += other.failed
+	r.promptTokens += other.promptTokens
+	r.completionTokens += other.completionTokens
+	r.latencies = append(r.latencies, other.latencies...)
+	r.ttfts = append(r.ttfts, other.ttfts...)
+}
+
+// loadReport is the outcome of the run command
+type loadReport struct {
+	duration time.Duration
+	modes    map[string]*modeReport
+	errors   map[string]int
+}
+
+func (r loadReport) total() *modeReport {
+	total := &modeReport{}
+	for _, mode := range r.modes {
+		total.add(mode)
+	}
+	return total
+}
+
+// runLoad sends the requests until the duration is over or the requests are sent, concurrency at
+// once. The requests in flight at the end are waited for.
+func runLoad(ctx context.Context, cfg loadConfig) loadReport {
+	report := loadReport{
+		modes:  map[string]*modeReport{modeJSON: {}, modeStream: {}},
+		errors: map[string]int{},
+	}
+	client := openai.NewClient(
+		option.WithBaseURL(cfg.url),
+		// pixiu retries by the policy of the cluster, a retry of the client would hide it
+		option.WithMaxRetries(0),
+	)
+
+	sending := ctx
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		sending, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+	var mu sync.Mutex
+	start := time.Now()
+	chatload.Run(sending, cfg.concurrency, cfg.requests, cfg.rate, func(i int) {
+		j := cfg.job(i)
+		m := measure(ctx, client, cfg, j)
+		mu.Lock()
+		defer mu.Unlock()
+		mode := report.modes[modeJSON]
+		if j.stream {
+			mode = report.modes[modeStream]
+		}
+		mode.add(&m.modeReport)
+		if m.err != nil {
+			report.errors[errorMessage(m.err)]++
+		}
+	})
+	report.duration = time.Since(start)
+	return report
+}
+
+// measurement is the report of a single request
+type measurement struct {
+	modeReport
+	err error
+}
+
+// measure sends a request and returns its latency, the time to its first token and its usage
+func measure(ctx context.Context, client openai.Client, cfg loadConfig, j job) measurement {
+	params := openai.ChatCompletionNewParams{
+		Model:    j.model,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(j.prompt)},
+	}
+	if cfg.maxTokens > 0 {
+		params.MaxTokens = openai.Int(int64(cfg.maxTokens))
+	}
+	if j.stream {
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	}
+	r := chatload.Measure(ctx, client, params, j.stream, option.WithAPIKey(j.apiKey))
+	if r.Err != nil {
+		return measurement{modeReport: modeReport{requests: 1, failed: 1}, err: r.Err}
+	}
+	m := measurement{modeReport: modeReport{
+		requests:         1,
+		promptTokens:     r.PromptTokens,
+		completionTokens: r.CompletionTokens,
+		latencies:        []time.Duration{r.Latency},
+	}}
+	if r.TTFT > 0 {
+		m.ttfts = []time.Duration{r.TTFT}
+	}
+	return m
+}
+
+// errorMessage shortens the errors of the api to their status and message, so the same failure of
+// many requests is counted once
+func errorMessage(err error) string {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return err.Error()
+	}
+	message := apiErr.Message
+	if message == "" {
+		message = http.StatusText(apiErr.StatusCode)
+	}
+	return fmt.Sprintf("%d %s", apiErr.StatusCode, message)
+}
+
+func (r loadReport) print(w io.Writer) {
+	total := r.total()
+	seconds := r.duration.Seconds()
+	fmt.Fprintf(w, "requests  %d in %s, %d failed", total.requests, r.duration.Round(time.Millisecond), total.failed)
+	if seconds > 0 {
+		fmt.Fprintf(w, ", %.1f requests/s", float64(total.requests-total.failed)/seconds)
+	}
+	fmt.Fprintln(w)
+	for _, name := range []string{modeJSON, modeStream} {
+		mode := r.modes[name]
+		if mode.requests == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-9s %d requests, %d failed", name, mode.requests, mode.failed)
+		if seconds > 0 {
+			fmt.Fprintf(w, ", %.1f completion tokens/s", float64(mode.completionTokens)/seconds)
+		}
+		fmt.Fprintln(w)
+		printPercentiles(w, "latency", mode.latencies)
+		printPercentiles(w, "ttft", mode.ttfts)
+	}
+	fmt.Fprintf(w, "tokens    %d prompt, %d completion\n", total.promptTokens, total.completionTokens)
+
+	messages := make([]string, 0, len(r.errors))
+	for msg := range r.errors {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if r.errors[messages[i]] != r.errors[messages[j]] {
+			return r.errors[messages[i]] > r.errors[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	for _, msg := range messages {
+		fmt.Fprintf(w, "error     %dx %s\n", r.errors[msg], msg)
+	}
+}
+
+func printPercentiles(w io.Writer, name string, durations []time.Duration) {
+	if p := chatload.Percentiles(durations); p != "" {
+		fmt.Fprintf(w, "  %-7s %s\n", name, p)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+// newTestConfig returns a load against the mock llm in place of pixiu, with the api keys it got
+func newTestConfig(t *testing.T, options llmmock.Options) (loadConfig, *llmmock.Server, func() map[string]int) {
+	mock := llmmock.New(options)
+	var mu sync.Mutex
+	keys := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]++
+		mu.Unlock()
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	cfg := loadConfig{
+		url:         srv.URL + "/",
+		apiKeys:     []string{"sk-mock"},
+		models:      []string{"deepseek-chat"},
+		prompts:     defaultPrompts,
+		concurrency: 4,
+		requests:    20,
+		streamRatio: 0.5,
+	}
+	return cfg, mock, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return keys
+	}
+}
+
+func TestJob(t *testing.T) {
+	cfg := loadConfig{
+		apiKeys:     []string{"sk-alice", "sk-bob"},
+		models:      []string{"deepseek-chat", "deepseek-reasoner"},
+		prompts:     []string{"a", "b", "c"},
+		streamRatio: 0.25,
+	}
+	var jobs []job
+	for i := 0; i < 8; i++ {
+		jobs = append(jobs, cfg.job(i))
+	}
+	assert.Equal(t, []job{
+		{model: "deepseek-chat", prompt: "a", apiKey: "sk-alice", stream: true},
+		{model: "deepseek-chat", prompt: "b", apiKey: "sk-alice"},
+		{model: "deepseek-chat", prompt: "c", apiKey: "sk-alice", stream: true},
+		{model: "deepseek-reasoner", prompt: "a", apiKey: "sk-alice"},
+		{model: "deepseek-reasoner", prompt: "b", apiKey: "sk-alice"},
+		{model: "deepseek-reasoner", prompt: "c", apiKey: "sk-alice", stream: true},
+		{model: "deepseek-chat", prompt: "a", apiKey: "sk-bob"},
+		{model: "deepseek-chat", prompt: "b", apiKey: "sk-bob"},
+	}, jobs)
+
+	for _, ratio := range []float64{0, 0.3, 0.5, 1} {
+		cfg.streamRatio = ratio
+		// the streams of each api key, each prompt is streamed as often as the others
+		streams := map[string]int{}
+		for i := 0; i < 1200; i++ {
+			if j := cfg.job(i); j.stream {
+				streams[j.apiKey]++
+				streams[j.prompt]++
+			}
+		}
+		for _, name := range []string{"sk-alice", "sk-bob"} {
+			assert.InDelta(t, ratio*600, streams[name], 3, "%s at %v", name, ratio)
+		}
+		for _, name := range []string{"a", "b", "c"} {
+			assert.InDelta(t, ratio*400, streams[name], 3, "%s at %v", name, ratio)
+		}
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	cfg, mock, keys := newTestConfig(t, llmmock.Options{})
+	cfg.apiKeys = []string{"sk-alice", "sk-bob"}
+
+	report := runLoad(context.Background(), cfg)
+	total := report.total()
+	assert.Equal(t, 20, total.requests)
+	assert.Zero(t, total.failed)
+	assert.Empty(t, report.errors)
+	assert.Equal(t, 10, report.modes[modeJSON].requests)
+	assert.Equal(t, 10, report.modes[modeStream].requests)
+	assert.Len(t, report.modes[modeStream].ttfts, report.modes[modeStream].requests)
+	assert.Empty(t, report.modes[modeJSON].ttfts)
+	assert.Len(t, total.latencies, 20)
+
+	stats := mock.Stats()
+	assert.Equal(t, 20, stats.Requests["/chat/completions"])
+	assert.EqualValues(t, stats.PromptTokens, total.promptTokens, "the usage of the streams is counted too")
+	assert.EqualValues(t, stats.CompletionTokens, total.completionTokens)
+	// the keys take turns after each round of the six prompts
+	assert.Equal(t, map[string]int{"sk-alice": 12, "sk-bob": 8}, keys())
+
+	var out bytes.Buffer
+	report.print(&out)
+	assert.Contains(t, out.String(), "requests  20 in ")
+	assert.Contains(t, out.String(), "json      10 requests, 0 failed")
+	assert.Contains(t, out.String(), "stream    10 requests, 0 failed")
+	assert.Contains(t, out.String(), "  ttft    p50 ")
+}
+
+func TestRunLoadFailures(t *testing.T) {
+	cfg, mock, _ := newTestConfig(t, llmmock.Options{})
+	mock.Inject(llmmock.Fault{Status: http.StatusTooManyRequests, Count: 5})
+
+	report := runLoad(context.Background(), cfg)
+	total := report.total()
+	assert.Equal(t, 20, total.requests)
+	assert.Equal(t, 5, total.failed)
+	assert.Len(t, total.latencies, 15, "the failed requests are not measured")
+	require.Len(t, report.errors, 1)
+	for msg, count := range report.errors {
+		assert.True(t, strings.HasPrefix(msg, "429 "), msg)
+		assert.Equal(t, 5, count)
+	}
+}
+
+func TestRunLoadDuration(t *testing.T) {
+	cfg, _, _ := newTestConfig(t, llmmock.Options{Latency: 20 * time.Millisecond})
+	cfg.requests, cfg.duration, cfg.concurrency, cfg.rate = 0, 300*time.Millisecond, 2, 20
+
+	start := time.Now()
+	report := runLoad(context.Background(), cfg)
+	assert.Less(t, time.Since(start), time.Second)
+	total := report.total()
+	assert.Zero(t, total.failed, "the requests in flight are waited for")
+	// a request every 50ms for 300ms
+	assert.GreaterOrEqual(t, total.requests, 4)
+	assert.LessOrEqual(t, total.requests, 8)
+}
+
+func TestRun(t *testing.T) {
+	cfg, _, _ := newTestConfig(t, llmmock.Options{APIKey: "sk-of-the-provider"})
+	var out, log bytes.Buffer
+	err := run(context.Background(), []string{"run", "-url", cfg.url, "-requests", "4", "-api-key", "sk-wrong"}, &out, &log)
+	require.Error(t, err)
+	assert.Equal(t, "every request failed", err.Error())
+	assert.Contains(t, out.String(), "error     4x 401 ")
+
+	err = run(context.Background(), []string{"bench"}, &out, &log)
+	require.Error(t, err)
+	assert.Contains(t, log.String(), "usage: loadtest")
+}
+
+func TestParseRunFlags(t *testing.T) {
+	prompts := filepath.Join(t.TempDir(), "prompts.txt")
+	require.NoError(t, os.WriteFile(prompts, []byte("# asked in turn\nHello\n\n1+1=?\n"), 0o644))
+	t.Setenv("API_KEY", "sk-env")
+
+	cfg, err := parseRunFlags([]string{"-model", "deepseek-chat, deepseek-reasoner", "-prompt-file", prompts, "-stream-ratio", "1"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deepseek-chat", "deepseek-reasoner"}, cfg.models)
+	assert.Equal(t, []string{"Hello", "1+1=?"}, cfg.prompts)
+	assert.Equal(t, []string{"sk-env"}, cfg.apiKeys)
+	assert.Equal(t, 30*time.Second, cfg.duration)
+
+	testCases := []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"-concurrency", "0"}, err: "-concurrency"},
+		{args: []string{"-stream-ratio", "1.5"}, err: "-stream-ratio"},
+		{args: []string{"-duration", "0"}, err: "limit the load"},
+		{args: []string{"-model", " , "}, err: "-model"},
+		{args: []string{"-prompt-file", filepath.Join(t.TempDir(), "missing.txt")}, err: "missing.txt"},
+	}
+	for _, tc := range testCases {
+		_, err := parseRunFlags(tc.args, io.Discard)
+		require.Error(t, err, tc.args)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
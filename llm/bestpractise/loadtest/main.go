@@ -0,0 +1,230 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// loadtest drives the llm gateway with a mix of streamed and plain chats, and checks that the
+// metrics of pixiu fill every panel of the grafana dashboard of the sample.
+//
+//	go run ./loadtest run -duration 1m -concurrency 16 -stream-ratio 0.5
+//	go run ./loadtest check -dashboard grafana.json -conf pixiu/conf.yaml -metrics http://localhost:2222/
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const usage = `usage: loadtest <command> [flags]
+
+commands:
+  run     send chats through pixiu and report the throughput and latencies
+  check   scrape the metrics of pixiu and check the queries of the grafana dashboard
+
+run "loadtest <command> -h" for the flags of a command
+`
+
+// defaultPrompts are asked in turn without -prompt-file, the first ones are answered by the script
+// of the mock llm
+var defaultPrompts = []string{
+	"1+1=?",
+	"Hello",
+	"What is an api gateway?",
+	"Explain the difference between a stream and a plain reply in one sentence.",
+	"Write a haiku about load balancing.",
+	"Name three uses of a response cache.",
+}
+
+// checkConfig is set by the flags of the check command
+type checkConfig struct {
+	dashboard string
+	metrics   string
+	// conf is the config of pixiu, the metrics of the llm filters missing in its chain are not checked and warn
+	conf    string
+	filters map[string]bool
+	// ignore matches the metrics that are not checked
+	ignore *regexp.Regexp
+	// optional matches the metrics that may have no series, like the failures of a healthy run
+	optional *regexp.Regexp
+}
+
+func parseRunFlags(args []string, output io.Writer) (loadConfig, error) {
+	cfg := loadConfig{}
+	flags := flag.NewFlagSet("loadtest run", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.url, "url", "http://localhost:8888/", "base url of pixiu")
+	apiKeys := flags.String("api-key", "", "api keys, comma separated, the requests take turns. API_KEY of the environment when empty")
+	models := flags.String("model", "deepseek-chat", "models, comma separated, every prompt is asked to each of them")
+	promptFile := flags.String("prompt-file", "", "file with a prompt per line, the builtin prompts when empty")
+	flags.IntVar(&cfg.maxTokens, "max-tokens", 0, "maximum tokens of a reply, no limit when 0")
+	flags.IntVar(&cfg.concurrency, "concurrency", 8, "requests in flight")
+	flags.DurationVar(&cfg.duration, "duration", 30*time.Second, "time new requests are sent for, no limit when 0")
+	flags.IntVar(&cfg.requests, "requests", 0, "requests sent at most, no limit when 0")
+	flags.Float64Var(&cfg.rate, "rate", 0, "requests started per second, as fast as the concurrency allows when 0")
+	flags.Float64Var(&cfg.streamRatio, "stream-ratio", 0.5, "share of the requests that are streamed, from 0 to 1")
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *apiKeys == "" {
+		*apiKeys = os.Getenv("API_KEY")
+	}
+	cfg.apiKeys = splitList(*apiKeys)
+	if len(cfg.apiKeys) == 0 {
+		// pixiu sends the key of its cluster, the client still needs one
+		cfg.apiKeys = []string{"sk-loadtest"}
+	}
+	if cfg.models = splitList(*models); len(cfg.models) == 0 {
+		return cfg, errors.New("-model is empty")
+	}
+	cfg.prompts = defaultPrompts
+	if *promptFile != "" {
+		prompts, err := readPrompts(*promptFile)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.prompts = prompts
+	}
+
+	switch {
+	case cfg.concurrency <= 0:
+		return cfg, errors.New("-concurrency must be positive")
+	case cfg.streamRatio < 0 || cfg.streamRatio > 1:
+		return cfg, errors.New("-stream-ratio must be between 0 and 1")
+	case cfg.rate < 0 || cfg.requests < 0 || cfg.duration < 0 || cfg.maxTokens < 0:
+		return cfg, errors.New("-rate, -requests, -duration and -max-tokens cannot be negative")
+	case cfg.duration == 0 && cfg.requests == 0:
+		return cfg, errors.New("-duration or -requests must limit the load")
+	}
+	return cfg, nil
+}
+
+func parseCheckFlags(args []string, output io.Writer) (checkConfig, error) {
+	cfg := checkConfig{}
+	flags := flag.NewFlagSet("loadtest check", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.dashboard, "dashboard", "grafana.json", "grafana dashboard whose queries are checked")
+	flags.StringVar(&cfg.metrics, "metrics", "http://localhost:2222/", "prometheus endpoint of pixiu")
+	flags.StringVar(&cfg.conf, "conf", "pixiu/conf.yaml", "config of pixiu, the metrics of the llm filters missing in its chain are not checked and warn, none when empty")
+	ignore := flags.String("ignore", "", "regexp of the metrics that are not checked")
+	optional := flags.String("optional", "failure|rejected", "regexp of the metrics that may have no series, their panels stay empty in a healthy run")
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	var err error
+	if *ignore != "" {
+		if cfg.ignore, err = regexp.Compile(*ignore); err != nil {
+			return cfg, fmt.Errorf("-ignore: %w", err)
+		}
+	}
+	if *optional != "" {
+		if cfg.optional, err = regexp.Compile(*optional); err != nil {
+			return cfg, fmt.Errorf("-optional: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma separated flag and drops the empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readPrompts reads a prompt per line, the empty lines and the ones starting with # are skipped
+func readPrompts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prompts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			prompts = append(prompts, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("no prompt in %s", path)
+	}
+	return prompts, nil
+}
+
+func run(ctx context.Context, args []string, out, log io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(log, usage)
+		return errors.New("no command given")
+	}
+	switch args[0] {
+	case "run":
+		cfg, err := parseRunFlags(args[1:], log)
+		if err != nil {
+			return err
+		}
+		report := runLoad(ctx, cfg)
+		report.print(out)
+		if total := report.total(); total.requests > 0 && total.failed == total.requests {
+			return errors.New("every request failed")
+		}
+		return nil
+	case "check":
+		cfg, err := parseCheckFlags(args[1:], log)
+		if err != nil {
+			return err
+		}
+		return check(cfg, out)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(out, usage)
+		return nil
+	default:
+		fmt.Fprint(log, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmfilter
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchModel(t *testing.T) {
+	testCases := []struct {
+		pattern, model string
+		match          bool
+	}{
+		{pattern: "deepseek-chat", model: "deepseek-chat", match: true},
+		{pattern: "deepseek-chat", model: "deepseek-chat-v2"},
+		{pattern: "deepseek-*", model: "deepseek-reasoner", match: true},
+		{pattern: "deepseek-*", model: "qwen2.5"},
+		{pattern: "*", model: "qwen2.5", match: true},
+		{pattern: "", model: "qwen2.5"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.match, MatchModel(tc.pattern, tc.model), "%s %s", tc.pattern, tc.model)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package llmfilter holds what the llm filters of the samples share: the writer that sees what a
+// proxy writes to the client itself, the reading of server sent events and the model patterns.
+package llmfilter
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// MaxBody is the most of a reply a filter keeps to read it
+const MaxBody = 4 << 20
+
+// IsStream tells whether the header is the one of a server sent event stream
+func IsStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+// EventData returns the data of a data: line of a stream, like a json chunk or [DONE]
+func EventData(line []byte) ([]byte, bool) {
+	data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !ok {
+		return nil, false
+	}
+	data = bytes.TrimSpace(data)
+	return data, len(data) > 0
+}
+
+// Events calls fn with the data of each event of a stream, until fn returns false
+func Events(body []byte, fn func(data []byte) bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64<<10), MaxBody)
+	for scanner.Scan() {
+		if data, ok := EventData(scanner.Bytes()); ok && !fn(data) {
+			return
+		}
+	}
+}
+
+// IsJSON tells whether a reply is a json object rather than a stream
+func IsJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
@@ -0,0 +1,40 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmfilter
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	body := "event: message\ndata: {\"a\":1}\n\n:keep-alive\ndata:{\"a\":2}\r\n\ndata: \n\ndata: [DONE]\n\ndata: {\"a\":3}\n"
+	var got []string
+	Events([]byte(body), func(data []byte) bool {
+		got = append(got, string(data))
+		return string(data) != "[DONE]"
+	})
+	assert.Equal(t, []string{`{"a":1}`, `{"a":2}`, "[DONE]"}, got)
+
+	assert.True(t, IsJSON([]byte("\n {\"a\":1}")))
+	assert.False(t, IsJSON([]byte("data: {\"a\":1}")))
+	assert.False(t, IsJSON(nil))
+}
@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmfilter
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Writer sees what a proxy writes to the client itself, like a stream, when a filter puts it in
+// place of the writer of the context. It records the reply, can rewrite a stream line by line and
+// can hold a reply that is not a stream until the filter encodes it.
+type Writer struct {
+	http.ResponseWriter
+	// Line is given each line of a stream with its new line, and returns what is passed on in its place
+	Line func(line []byte) []byte
+	// Hold keeps a reply that is not a stream from the client, the filter writes it itself
+	Hold bool
+
+	Status int
+	Stream bool
+	Wrote  bool
+	// Body is what was written, up to MaxBody unless held. Overflow is set past it.
+	Body     bytes.Buffer
+	Overflow bool
+
+	wroteHeader bool
+	// line is the part of a line of a stream not passed on yet
+	line []byte
+}
+
+// NewWriter puts a writer in place of w
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *Writer) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader, w.Status = true, status
+	w.Stream = IsStream(w.Header())
+	if w.Stream && w.Line != nil {
+		// the lines rewritten change the length
+		w.Header().Del("Content-Length")
+	}
+	if !w.held() {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.Wrote = true
+	switch {
+	case w.held():
+		return w.Body.Write(p)
+	case w.Body.Len()+len(p) > MaxBody:
+		w.Overflow = true
+		w.Body.Reset()
+	case !w.Overflow:
+		w.Body.Write(p)
+	}
+	if !w.Stream || w.Line == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	w.line = append(w.line, p...)
+	var out []byte
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		out = append(out, w.Line(w.line[:i+1])...)
+		w.line = w.line[i+1:]
+	}
+	if len(out) > 0 {
+		if _, err := w.ResponseWriter.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Finish passes on the end of a stream that has no new line, a filter calls it when it encodes
+func (w *Writer) Finish() {
+	if len(w.line) == 0 {
+		return
+	}
+	rest := w.Line(w.line)
+	w.line = nil
+	if len(rest) > 0 {
+		_, _ = w.ResponseWriter.Write(rest)
+	}
+}
+
+// Flush passes the chunks of a stream on at once
+func (w *Writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && (!w.Hold || w.Stream) {
+		f.Flush()
+	}
+}
+
+func (w *Writer) held() bool {
+	return w.Hold && !w.Stream
+}
+
+// RewriteLines gives each line of a stream to line and returns what it returns, like Writer does
+// with a stream returned to pixiu
+func RewriteLines(body []byte, line func(line []byte) []byte) []byte {
+	var out []byte
+	for len(body) > 0 {
+		i := bytes.IndexByte(body, '\n')
+		if i < 0 {
+			i = len(body) - 1
+		}
+		out = append(out, line(body[:i+1])...)
+		body = body[i+1:]
+	}
+	return out
+}
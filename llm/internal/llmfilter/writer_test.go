@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmfilter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func upper(line []byte) []byte {
+	return bytes.ToUpper(line)
+}
+
+func TestWriterStream(t *testing.T) {
+	client := httptest.NewRecorder()
+	w := NewWriter(client)
+	w.Line = upper
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Content-Length", "30")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("data: a\n\nda"))
+	_, _ = w.Write([]byte("ta: b\n\ndata: c"))
+	assert.Equal(t, "DATA: A\n\nDATA: B\n\n", client.Body.String())
+	w.Finish()
+
+	assert.Equal(t, "DATA: A\n\nDATA: B\n\nDATA: C", client.Body.String())
+	assert.Empty(t, client.Header().Get("Content-Length"))
+	assert.True(t, w.Wrote)
+	assert.True(t, w.Stream)
+	assert.Equal(t, "data: a\n\ndata: b\n\ndata: c", w.Body.String())
+}
+
+func TestWriterHold(t *testing.T) {
+	client := httptest.NewRecorder()
+	w := NewWriter(client)
+	w.Line, w.Hold = upper, true
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write([]byte(`{"error":{}}`))
+	w.Flush()
+
+	assert.False(t, client.Flushed)
+	assert.Empty(t, client.Body.String())
+	assert.Equal(t, http.StatusBadRequest, w.Status)
+	assert.Equal(t, `{"error":{}}`, w.Body.String())
+}
+
+func TestWriterOverflow(t *testing.T) {
+	client := httptest.NewRecorder()
+	w := NewWriter(client)
+	_, _ = w.Write(make([]byte, MaxBody))
+	assert.False(t, w.Overflow)
+	_, _ = w.Write([]byte("{}"))
+
+	assert.True(t, w.Overflow)
+	assert.Zero(t, w.Body.Len())
+	assert.Equal(t, MaxBody+2, client.Body.Len())
+	assert.Equal(t, http.StatusOK, w.Status)
+}
+
+func TestRewriteLines(t *testing.T) {
+	assert.Equal(t, "DATA: A\n\nDATA: B", string(RewriteLines([]byte("data: a\n\ndata: b"), upper)))
+	assert.Empty(t, RewriteLines(nil, upper))
+}
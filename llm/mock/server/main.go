@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address of the mock")
+	script := flag.String("script", "", "yaml script of the replies, the questions are echoed without it")
+	options := llmmock.Options{}
+	flag.DurationVar(&options.Latency, "latency", 0, "time waited before each reply")
+	flag.DurationVar(&options.ChunkDelay, "chunk-delay", 0, "time waited between the chunks of a stream")
+	flag.StringVar(&options.APIKey, "api-key", "", "bearer token the requests must carry, any when empty")
+	flag.IntVar(&options.Dimensions, "dimensions", 8, "dimensions of the embeddings")
+	flag.StringVar(&options.Name, "name", "", "name sent in the X-Mock-Server header and as system_fingerprint, to tell several mocks apart")
+	flag.Parse()
+
+	if *script != "" {
+		s, err := llmmock.LoadScript(*script)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Script = s
+	}
+	log.Printf("mock llm listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, llmmock.New(options)))
+}
@@ -0,0 +1,126 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const (
+	pixiuURL   = "http://localhost:8888"
+	metricsURL = "http://localhost:2222/"
+)
+
+var mock = llmmock.Client{URL: "http://localhost:8090"}
+
+func TestChatCompletion(t *testing.T) {
+	before, err := mock.Stats()
+	require.NoError(t, err)
+
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	require.NoError(t, err)
+	require.Len(t, completion.Choices, 1)
+	assert.Equal(t, "1+1 equals 2.", completion.Choices[0].Message.Content)
+	assert.Equal(t, int64(1), completion.Usage.PromptTokens)
+	assert.Equal(t, int64(3), completion.Usage.CompletionTokens)
+
+	after, err := mock.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, before.Requests["/chat/completions"]+1, after.Requests["/chat/completions"])
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	stream := client.Chat.Completions.NewStreaming(context.Background(), llmmock.Question("deepseek-chat", "Hello!"))
+	acc := openai.ChatCompletionAccumulator{}
+	chunks := 0
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+		chunks++
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "Hello! I am the mock llm of the pixiu samples, how can I help you today?", acc.Choices[0].Message.Content)
+	// pixiu passes the chunks on as they come, one per word
+	assert.Greater(t, chunks, 10)
+	assert.Equal(t, int64(16), acc.Usage.CompletionTokens)
+}
+
+func TestEmbeddings(t *testing.T) {
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	resp, err := client.Embeddings.New(context.Background(), openai.EmbeddingNewParams{
+		Model: "text-embedding-3-small",
+		Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String("dubbo go pixiu")},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, llmmock.Embed("text-embedding-3-small", "dubbo go pixiu", 8), resp.Data[0].Embedding)
+	assert.Equal(t, int64(3), resp.Usage.PromptTokens)
+}
+
+func TestUpstreamError(t *testing.T) {
+	require.NoError(t, mock.Reset())
+	require.NoError(t, mock.Inject(llmmock.Fault{Status: http.StatusInternalServerError, Path: "/chat/completions"}))
+
+	// the cluster does not retry, the error of the llm reaches the client
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	_, err := client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	var apiErr *openai.Error
+	require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+	assert.GreaterOrEqual(t, apiErr.StatusCode, http.StatusInternalServerError)
+
+	stats, err := mock.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Failed["/chat/completions"])
+}
+
+func TestTokenizerMetrics(t *testing.T) {
+	chat := map[string]string{"cluster_name": "chat"}
+	prompt := testkit.ScrapeMetric(t, metricsURL, "pixiu_llm_prompt_tokens_total", chat)
+	completionTokens := testkit.ScrapeMetric(t, metricsURL, "pixiu_llm_completion_tokens_total", chat)
+
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "Hello! what is 1+1 ?"))
+	require.NoError(t, err)
+
+	// the tokenizer counts the usage the llm reported
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		assert.Equal(c, prompt+float64(completion.Usage.PromptTokens),
+			testkit.ScrapeMetric(c, metricsURL, "pixiu_llm_prompt_tokens_total", chat))
+		assert.Equal(c, completionTokens+float64(completion.Usage.CompletionTokens),
+			testkit.ScrapeMetric(c, metricsURL, "pixiu_llm_completion_tokens_total", chat))
+	}, 10*time.Second)
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the endpoints file
+type Config struct {
+	Nacos     NacosConfig     `yaml:"nacos"`
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+	Secrets   SecretsConfig   `yaml:"secrets"`
+	Endpoints []Endpoint      `yaml:"endpoints"`
+
+	// vault resolves the api key references, set by Validate
+	vault *vault
+}
+
+// NacosConfig is the nacos the endpoints are registered in, NACOS_HOST overrides the host of the address
+type NacosConfig struct {
+	Address   string        `yaml:"address"`
+	Namespace string        `yaml:"namespace"`
+	Group     string        `yaml:"group"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+// HeartbeatConfig is how the endpoints with a probe are checked while the registry runs
+type HeartbeatConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// Threshold is the failed probes in a row that disable an instance
+	Threshold int `yaml:"threshold"`
+}
+
+// SecretsConfig is where the seal command resolves the secret:<name> references
+type SecretsConfig struct {
+	// File is a yaml map of the secrets by name
+	File string `yaml:"file"`
+	// EnvPrefix of the environment variables of the secrets, LLM_SECRET_ when empty
+	EnvPrefix string `yaml:"env_prefix"`
+	// KeyEnv is the environment variable of the vault key, LLM_VAULT_KEY when empty
+	KeyEnv string `yaml:"key_env"`
+	// AllowPlain accepts the api keys in llm-meta.api_keys, where anyone who lists the services reads them
+	AllowPlain bool `yaml:"allow_plain"`
+}
+
+// Endpoint is an llm endpoint, registered as an instance of a nacos service
+type Endpoint struct {
+	Service   string  `yaml:"service"`
+	IP        string  `yaml:"ip"`
+	Port      uint64  `yaml:"port"`
+	Weight    float64 `yaml:"weight"`
+	Ephemeral *bool   `yaml:"ephemeral"`
+	// Probe is an url of the endpoint, it is alive while the url answers with a status below 500
+	Probe    string            `yaml:"probe"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// LoadConfig reads the endpoints file, the ${VAR} in the metadata are replaced by the environment
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err = yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.setDefaults()
+	for i := range cfg.Endpoints {
+		for k, v := range cfg.Endpoints[i].Metadata {
+			cfg.Endpoints[i].Metadata[k] = os.ExpandEnv(v)
+		}
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Nacos.Address == "" {
+		c.Nacos.Address = "127.0.0.1:8848"
+	}
+	if c.Nacos.Group == "" {
+		c.Nacos.Group = "DEFAULT_GROUP"
+	}
+	if c.Nacos.Timeout <= 0 {
+		c.Nacos.Timeout = 5 * time.Second
+	}
+	if c.Heartbeat.Interval <= 0 {
+		c.Heartbeat.Interval = 5 * time.Second
+	}
+	if c.Heartbeat.Timeout <= 0 {
+		c.Heartbeat.Timeout = 2 * time.Second
+	}
+	if c.Heartbeat.Threshold <= 0 {
+		c.Heartbeat.Threshold = 3
+	}
+	for i := range c.Endpoints {
+		e := &c.Endpoints[i]
+		if e.Weight == 0 {
+			e.Weight = 10
+		}
+		if e.Ephemeral == nil {
+			ephemeral := true
+			e.Ephemeral = &ephemeral
+		}
+	}
+}
+
+// Validate checks every endpoint and returns all the problems at once
+func (c *Config) Validate() error {
+	var errs []error
+	v, err := newVault(c.Secrets)
+	if err != nil {
+		return err
+	}
+	c.vault = v
+	instances := map[string]bool{}
+	ids := map[string]string{}
+	for _, e := range c.Endpoints {
+		name := e.String()
+		if e.Service == "" || e.IP == "" || e.Port == 0 {
+			errs = append(errs, fmt.Errorf("%s: service, ip and port are required", name))
+		}
+		if e.Weight < 0 {
+			errs = append(errs, fmt.Errorf("%s: the weight must not be negative", name))
+		}
+		if instances[name] {
+			errs = append(errs, fmt.Errorf("%s: registered twice", name))
+		}
+		instances[name] = true
+		for _, err := range ValidateMetadata(e.Metadata) {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		if _, ok := e.Metadata[MetaAPIKeys]; ok && !c.Secrets.AllowPlain {
+			errs = append(errs, fmt.Errorf("%s: metadata %s publishes the keys in clear text, reference them in the clusters of %s or set secrets.allow_plain",
+				name, MetaAPIKeys, keyvaultFilter))
+		}
+		// the adapter keys the endpoints of a cluster by their id
+		key := e.Metadata[MetaCluster] + "/" + e.Metadata[MetaID]
+		if other, ok := ids[key]; ok && e.Metadata[MetaID] != "" {
+			errs = append(errs, fmt.Errorf("%s: id %s of cluster %s is used by %s too", name, e.Metadata[MetaID], e.Metadata[MetaCluster], other))
+		}
+		ids[key] = name
+	}
+	return errors.Join(errs...)
+}
+
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%s@%s:%d", e.Service, e.IP, e.Port)
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validMetadata() map[string]string {
+	return map[string]string{
+		MetaCluster:     "chat",
+		MetaID:          "1",
+		MetaAddress:     "api.deepseek.com",
+		MetaRetryPolicy: "CountBased",
+		MetaRetryConfig: `{"times": 3}`,
+		MetaFallback:    "false",
+		MetaAPIKeys:     "sk-one,sk-two",
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	assert.Empty(t, ValidateMetadata(validMetadata()))
+
+	testCases := []struct {
+		name   string
+		change func(map[string]string)
+		err    string
+	}{
+		{name: "missing cluster", change: func(m map[string]string) { delete(m, MetaCluster) }, err: "metadata cluster is required"},
+		{name: "address with scheme", change: func(m map[string]string) { m[MetaAddress] = "https://api.deepseek.com" }, err: "is not a host or host:port"},
+		{name: "address with bad port", change: func(m map[string]string) { m[MetaAddress] = "localhost:http" }, err: "invalid port"},
+		{name: "unknown policy", change: func(m map[string]string) { m[MetaRetryPolicy] = "Forever" }, err: "unknown retry policy"},
+		{name: "config not json", change: func(m map[string]string) { m[MetaRetryConfig] = "times=3" }, err: "not a json object"},
+		{name: "negative times", change: func(m map[string]string) { m[MetaRetryConfig] = `{"times": -1}` }, err: "times must be a positive integer"},
+		{name: "config missing", change: func(m map[string]string) { delete(m, MetaRetryConfig) }, err: "is required by the CountBased retry policy"},
+		{name: "fallback not bool", change: func(m map[string]string) { m[MetaFallback] = "maybe" }, err: "llm-meta.fallback"},
+		{name: "empty api key", change: func(m map[string]string) { m[MetaAPIKeys] = "" }, err: "empty api key"},
+		{name: "reference in api keys", change: func(m map[string]string) { m[MetaAPIKeys] = "secret:deepseek" }, err: "put the reference in the clusters of dgp.filter.llm.keyvault"},
+		{name: "key reference", change: func(m map[string]string) { m[MetaAPIKeyRef] = "secret:deepseek" }, err: "metadata api_key_ref is read by nothing"},
+		{name: "typo", change: func(m map[string]string) { m["llm-meta.retry_polcy.name"] = "NoRetry" }, err: "not an llm-meta key the adapter knows"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata := validMetadata()
+			tc.change(metadata)
+			errs := ValidateMetadata(metadata)
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0].Error(), tc.err)
+		})
+	}
+
+	// other keys are kept for the users of nacos
+	metadata := validMetadata()
+	metadata["owner"] = "ai-team"
+	delete(metadata, MetaRetryConfig)
+	metadata[MetaRetryPolicy] = "NoRetry"
+	assert.Empty(t, ValidateMetadata(metadata))
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("endpoints.yaml")
+	require.NoError(t, err, "the registry does not need the secrets of the keys")
+	assert.Equal(t, "test_llm_registry_group", cfg.Nacos.Group)
+	require.Len(t, cfg.Endpoints, 1)
+	e := cfg.Endpoints[0]
+	assert.Equal(t, "deepseek-service@192.168.1.11:8002", e.String())
+	assert.NotContains(t, e.Metadata, MetaAPIKeyRef)
+	assert.NotContains(t, e.Metadata, MetaAPIKeys)
+	assert.True(t, *e.Ephemeral)
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestPlainAPIKeys(t *testing.T) {
+	t.Setenv("API_KEY", "sk-from-env")
+	endpoints := `
+endpoints:
+  - service: chat
+    ip: 10.0.0.1
+    port: 8000
+    metadata: {cluster: chat, id: "1", address: "10.0.0.1:8000", llm-meta.api_keys: "${API_KEY}"}
+`
+	_, err := LoadConfig(writeConfig(t, endpoints))
+	assert.ErrorContains(t, err, "metadata llm-meta.api_keys publishes the keys in clear text, reference them in the clusters of dgp.filter.llm.keyvault or set secrets.allow_plain")
+
+	cfg, err := LoadConfig(writeConfig(t, "secrets: {allow_plain: true}\n"+endpoints))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-from-env", cfg.Endpoints[0].Metadata[MetaAPIKeys])
+
+	t.Setenv("API_KEY", "")
+	_, err = LoadConfig(writeConfig(t, "secrets: {allow_plain: true}\n"+endpoints))
+	assert.ErrorContains(t, err, "empty api key")
+}
+
+func TestSecretsConfig(t *testing.T) {
+	secrets := filepath.Join(t.TempDir(), "secrets.yaml")
+	require.NoError(t, os.WriteFile(secrets, []byte("deepseek: sk-from-file\n"), 0o600))
+	endpoints := `
+secrets: {file: ` + secrets + `}
+endpoints:
+  - service: chat
+    ip: 10.0.0.1
+    port: 8000
+    metadata: {cluster: chat, id: "1", address: "10.0.0.1:8000"}
+`
+	cfg, err := LoadConfig(writeConfig(t, endpoints))
+	require.NoError(t, err)
+	key, err := cfg.vault.resolve("secret:deepseek")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-from-file", key)
+
+	t.Setenv(defaultKeyEnv, "c2hvcnQ=")
+	_, err = LoadConfig(writeConfig(t, endpoints))
+	assert.ErrorContains(t, err, "LLM_VAULT_KEY is not the base64 of a 32 bytes key")
+}
+
+func TestValidateConfig(t *testing.T) {
+	path := writeConfig(t, `
+endpoints:
+  - service: chat
+    ip: 10.0.0.1
+    port: 8000
+    metadata: {cluster: chat, id: "1", address: "10.0.0.1:8000"}
+  - service: chat
+    ip: 10.0.0.1
+    port: 8000
+    metadata: {cluster: chat, id: "1", address: "10.0.0.1:8000"}
+  - service: chat
+    port: 8001
+    weight: -1
+    metadata: {cluster: chat, id: "2", address: "10.0.0.1:8001", llm-meta.fallback: "yes"}
+`)
+
+	_, err := LoadConfig(path)
+	require.Error(t, err)
+	lines := strings.Split(err.Error(), "\n")
+	assert.Len(t, lines, 5, err.Error())
+	assert.Contains(t, err.Error(), "chat@10.0.0.1:8000: registered twice")
+	assert.Contains(t, err.Error(), "id 1 of cluster chat is used by chat@10.0.0.1:8000 too")
+	assert.Contains(t, err.Error(), "chat@:8001: service, ip and port are required")
+	assert.Contains(t, err.Error(), "chat@:8001: the weight must not be negative")
+	assert.Contains(t, err.Error(), "chat@:8001: metadata llm-meta.fallback")
+}
+
+func TestMasked(t *testing.T) {
+	metadata := validMetadata()
+	assert.Equal(t, "sk-***,***", masked(map[string]string{MetaAPIKeys: "sk-abcdef, sk-1"})[MetaAPIKeys])
+	assert.Equal(t, "sk-one,sk-two", metadata[MetaAPIKeys], "the metadata is not changed")
+}
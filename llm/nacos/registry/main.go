@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// The registry of the llm endpoints of the nacos sample. It reads the endpoints from a yaml file,
+// checks their llm-meta metadata against what dgp.adapter.llmregistrycenter expects, and keeps the
+// instances of nacos in line with the file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+import (
+	"github.com/dubbogo/gost/log/logger"
+
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+const usage = `usage: registry [-config endpoints.yaml] [-prune] <command> [args]
+
+commands:
+  validate    check the endpoints file, nacos is not contacted
+  apply       register the missing endpoints and update the changed ones, -prune deregisters
+              the instances of the services that the file does not list
+  deregister  deregister every endpoint of the file
+  list        list the instances of the services of the file
+  run         apply, probe the endpoints at each heartbeat and deregister them on exit (default)
+  seal [ref]  print the sealed value of the key of a secret:<name> reference, or of the key read
+              from stdin, for the clusters of the dgp.filter.llm.keyvault config
+`
+
+// Create and return a Nacos client
+func createNacosClient(cfg NacosConfig) (naming_client.INamingClient, error) {
+	host, portText, err := net.SplitHostPort(cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("nacos address %q: %w", cfg.Address, err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nacos address %q: %w", cfg.Address, err)
+	}
+	if nacosHost := os.Getenv("NACOS_HOST"); nacosHost != "" {
+		host = nacosHost
+	}
+	serverConfigs := []constant.ServerConfig{
+		*constant.NewServerConfig(host, port),
+	}
+	clientConfig := *constant.NewClientConfig(
+		constant.WithNamespaceId(cfg.Namespace),
+		constant.WithTimeoutMs(uint64(cfg.Timeout.Milliseconds())),
+		constant.WithNotLoadCacheAtStart(true),
+		constant.WithLogDir("/tmp/nacos/log"),
+		constant.WithCacheDir("/tmp/nacos/cache"),
+		constant.WithLogLevel("info"),
+	)
+	return clients.NewNamingClient(
+		vo.NacosClientParam{
+			ClientConfig:  &clientConfig,
+			ServerConfigs: serverConfigs,
+		},
+	)
+}
+
+func main() {
+	configFile := flag.String("config", "endpoints.yaml", "yaml file of the llm endpoints")
+	prune := flag.Bool("prune", false, "apply deregisters the instances the file does not list")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	command := "run"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	cfg, err := LoadConfig(*configFile)
+	if err != nil {
+		logger.Fatalf("Invalid endpoints: %v", err)
+	}
+	switch command {
+	case "validate":
+		logger.Infof("%s: %d endpoints are valid", *configFile, len(cfg.Endpoints))
+		return
+	case "seal":
+		sealed, err := sealKey(cfg.vault, flag.Arg(1), os.Stdin)
+		if err != nil {
+			logger.Fatalf("Seal failed: %v", err)
+		}
+		fmt.Println(sealed)
+		return
+	}
+
+	client, err := createNacosClient(cfg.Nacos)
+	if err != nil {
+		logger.Fatalf("Failed to create nacos client: %v", err)
+	}
+	registry := NewRegistry(client, cfg)
+
+	switch command {
+	case "apply":
+		changes, err := registry.Apply(*prune)
+		logChanges(changes)
+		if err != nil {
+			logger.Fatalf("Apply failed: %v", err)
+		}
+	case "deregister":
+		changes, err := registry.Deregister()
+		logChanges(changes)
+		if err != nil {
+			logger.Fatalf("Deregister failed: %v", err)
+		}
+	case "list":
+		if err = registry.List(os.Stdout); err != nil {
+			logger.Fatalf("List failed: %v", err)
+		}
+	case "run":
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		logger.Info("Program running, press Ctrl+C to deregister the endpoints and exit")
+		if err = registry.Run(ctx); err != nil {
+			logger.Fatalf("Registry failed: %v", err)
+		}
+		logger.Info("The endpoints have been deregistered, exiting")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// sealKey seals the key of the reference, or the key read from stdin so that it stays out of the
+// shell history
+func sealKey(v *vault, ref string, stdin io.Reader) (string, error) {
+	var key string
+	if ref != "" {
+		if !strings.HasPrefix(ref, secretPrefix) {
+			return "", fmt.Errorf("%q is not a %s<name> reference, pass the key on stdin", maskKey(ref), secretPrefix)
+		}
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		key = resolved
+	} else {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	if key == "" {
+		return "", errors.New("the key is empty")
+	}
+	return v.seal(key)
+}
@@ -0,0 +1,319 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+)
+
+import (
+	"github.com/dubbogo/gost/log/logger"
+
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// naming is the part of the nacos naming client the registry uses
+type naming interface {
+	RegisterInstance(param vo.RegisterInstanceParam) (bool, error)
+	UpdateInstance(param vo.UpdateInstanceParam) (bool, error)
+	DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error)
+	SelectAllInstances(param vo.SelectAllInstancesParam) ([]model.Instance, error)
+}
+
+// Change is what the registry did to an instance
+type Change struct {
+	Action   string
+	Endpoint string
+}
+
+// Registry keeps the instances of nacos in line with the endpoints file
+type Registry struct {
+	client naming
+	cfg    *Config
+	// probe checks an endpoint, replaced by the tests
+	probe func(ctx context.Context, url string) error
+
+	// failures counts the failed probes in a row, an endpoint that reached the threshold is disabled
+	failures map[string]int
+	disabled map[string]bool
+}
+
+// NewRegistry returns a registry of the endpoints
+func NewRegistry(client naming, cfg *Config) *Registry {
+	r := &Registry{client: client, cfg: cfg, failures: map[string]int{}, disabled: map[string]bool{}}
+	httpClient := &http.Client{Timeout: cfg.Heartbeat.Timeout}
+	r.probe = func(ctx context.Context, url string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		// an endpoint that wants an api key answers 401, it is alive all the same
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+	return r
+}
+
+// services returns the services of the endpoints in the order of the file
+func (r *Registry) services() []string {
+	var services []string
+	seen := map[string]bool{}
+	for _, e := range r.cfg.Endpoints {
+		if !seen[e.Service] {
+			seen[e.Service] = true
+			services = append(services, e.Service)
+		}
+	}
+	return services
+}
+
+func (r *Registry) instances(service string) (map[string]model.Instance, error) {
+	list, err := r.client.SelectAllInstances(vo.SelectAllInstancesParam{ServiceName: service, GroupName: r.cfg.Nacos.Group})
+	if err != nil {
+		return nil, fmt.Errorf("list the instances of %s: %w", service, err)
+	}
+	instances := make(map[string]model.Instance, len(list))
+	for _, inst := range list {
+		instances[fmt.Sprintf("%s:%d", inst.Ip, inst.Port)] = inst
+	}
+	return instances, nil
+}
+
+// Apply registers the endpoints that are missing and updates the ones that changed. With prune,
+// the instances of the services that the file does not list any more are deregistered.
+func (r *Registry) Apply(prune bool) ([]Change, error) {
+	var changes []Change
+	for _, service := range r.services() {
+		existing, err := r.instances(service)
+		if err != nil {
+			return changes, err
+		}
+		for _, e := range r.cfg.Endpoints {
+			if e.Service != service {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", e.IP, e.Port)
+			inst, ok := existing[key]
+			delete(existing, key)
+			switch {
+			case !ok:
+				err = r.register(e)
+				changes = append(changes, Change{"register", e.String()})
+			case !r.matches(e, inst):
+				err = r.update(e)
+				changes = append(changes, Change{"update", e.String()})
+			default:
+				changes = append(changes, Change{"unchanged", e.String()})
+			}
+			if err != nil {
+				return changes, err
+			}
+		}
+		if !prune {
+			continue
+		}
+		stale := make([]string, 0, len(existing))
+		for key := range existing {
+			stale = append(stale, key)
+		}
+		sort.Strings(stale)
+		for _, key := range stale {
+			inst := existing[key]
+			if err = r.deregister(service, inst.Ip, inst.Port, inst.Ephemeral); err != nil {
+				return changes, err
+			}
+			changes = append(changes, Change{"deregister", fmt.Sprintf("%s@%s", service, key)})
+		}
+	}
+	return changes, nil
+}
+
+// Deregister removes the instances of every endpoint
+func (r *Registry) Deregister() ([]Change, error) {
+	var changes []Change
+	for _, e := range r.cfg.Endpoints {
+		if err := r.deregister(e.Service, e.IP, e.Port, *e.Ephemeral); err != nil {
+			return changes, err
+		}
+		changes = append(changes, Change{"deregister", e.String()})
+	}
+	return changes, nil
+}
+
+// List writes the instances of the services of the file, with the api keys masked
+func (r *Registry) List(w io.Writer) error {
+	for _, service := range r.services() {
+		instances, err := r.instances(service)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(instances))
+		for key := range instances {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(w, "%s (%d instances)\n", service, len(keys))
+		for _, key := range keys {
+			inst := instances[key]
+			fmt.Fprintf(w, "  %s weight=%g enabled=%t healthy=%t ephemeral=%t metadata=%v\n",
+				key, inst.Weight, inst.Enable, inst.Healthy, inst.Ephemeral, masked(inst.Metadata))
+		}
+	}
+	return nil
+}
+
+// Run applies the endpoints, probes them at each heartbeat until the context is done, and
+// deregisters them then
+func (r *Registry) Run(ctx context.Context) error {
+	changes, err := r.Apply(false)
+	logChanges(changes)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(r.cfg.Heartbeat.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			changes, err = r.Deregister()
+			logChanges(changes)
+			return err
+		case <-ticker.C:
+			r.heartbeat(ctx)
+		}
+	}
+}
+
+// heartbeat probes the endpoints. Nacos derives the health of an ephemeral instance from the beats
+// of this client, so an endpoint that failed too many probes is disabled instead, and enabled again
+// by its first successful probe.
+func (r *Registry) heartbeat(ctx context.Context) {
+	for _, e := range r.cfg.Endpoints {
+		if e.Probe == "" {
+			continue
+		}
+		name := e.String()
+		err := r.probe(ctx, e.Probe)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.failures[name]++
+			logger.Warnf("probe of %s failed %d times: %v", name, r.failures[name], err)
+			if r.failures[name] >= r.cfg.Heartbeat.Threshold && !r.disabled[name] {
+				r.setDisabled(e, true)
+			}
+			continue
+		}
+		r.failures[name] = 0
+		if r.disabled[name] {
+			r.setDisabled(e, false)
+		}
+	}
+}
+
+func (r *Registry) setDisabled(e Endpoint, disabled bool) {
+	name := e.String()
+	r.disabled[name] = disabled
+	if err := r.update(e); err != nil {
+		// the next heartbeat tries again
+		r.disabled[name] = !disabled
+		logger.Errorf("update %s: %v", name, err)
+		return
+	}
+	if disabled {
+		logger.Warnf("%s is disabled until its probe passes again", name)
+	} else {
+		logger.Infof("%s is enabled again", name)
+	}
+}
+
+func (r *Registry) matches(e Endpoint, inst model.Instance) bool {
+	return inst.Weight == e.Weight && inst.Enable == !r.disabled[e.String()] && inst.Ephemeral == *e.Ephemeral &&
+		reflect.DeepEqual(inst.Metadata, e.Metadata)
+}
+
+func (r *Registry) register(e Endpoint) error {
+	ok, err := r.client.RegisterInstance(vo.RegisterInstanceParam{
+		Ip:          e.IP,
+		Port:        e.Port,
+		ServiceName: e.Service,
+		GroupName:   r.cfg.Nacos.Group,
+		Weight:      e.Weight,
+		Enable:      !r.disabled[e.String()],
+		Healthy:     true,
+		Ephemeral:   *e.Ephemeral,
+		Metadata:    e.Metadata,
+	})
+	return result("register", e.String(), ok, err)
+}
+
+func (r *Registry) update(e Endpoint) error {
+	ok, err := r.client.UpdateInstance(vo.UpdateInstanceParam{
+		Ip:          e.IP,
+		Port:        e.Port,
+		ServiceName: e.Service,
+		GroupName:   r.cfg.Nacos.Group,
+		Weight:      e.Weight,
+		Enable:      !r.disabled[e.String()],
+		Ephemeral:   *e.Ephemeral,
+		Metadata:    e.Metadata,
+	})
+	return result("update", e.String(), ok, err)
+}
+
+func (r *Registry) deregister(service, ip string, port uint64, ephemeral bool) error {
+	ok, err := r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+		Ip:          ip,
+		Port:        port,
+		ServiceName: service,
+		GroupName:   r.cfg.Nacos.Group,
+		Ephemeral:   ephemeral,
+	})
+	return result("deregister", fmt.Sprintf("%s@%s:%d", service, ip, port), ok, err)
+}
+
+func result(action, name string, ok bool, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", action, name, err)
+	}
+	if !ok {
+		return fmt.Errorf("%s %s refused, please check the nacos logs", action, name)
+	}
+	return nil
+}
+
+func logChanges(changes []Change) {
+	for _, c := range changes {
+		logger.Infof("%-10s %s", c.Action, c.Endpoint)
+	}
+}
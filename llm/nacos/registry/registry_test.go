@@ -0,0 +1,259 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNaming keeps the instances in memory, by service and ip:port
+type fakeNaming struct {
+	instances map[string]map[string]model.Instance
+	calls     []string
+	fail      error
+}
+
+func newFakeNaming() *fakeNaming {
+	return &fakeNaming{instances: map[string]map[string]model.Instance{}}
+}
+
+func (f *fakeNaming) put(service string, inst model.Instance) {
+	if f.instances[service] == nil {
+		f.instances[service] = map[string]model.Instance{}
+	}
+	metadata := make(map[string]string, len(inst.Metadata))
+	for k, v := range inst.Metadata {
+		metadata[k] = v
+	}
+	inst.Metadata = metadata
+	f.instances[service][fmt.Sprintf("%s:%d", inst.Ip, inst.Port)] = inst
+}
+
+func (f *fakeNaming) RegisterInstance(p vo.RegisterInstanceParam) (bool, error) {
+	f.calls = append(f.calls, "register "+p.ServiceName)
+	if f.fail != nil {
+		return false, f.fail
+	}
+	f.put(p.ServiceName, model.Instance{Ip: p.Ip, Port: p.Port, Weight: p.Weight, Enable: p.Enable, Healthy: p.Healthy, Ephemeral: p.Ephemeral, Metadata: p.Metadata})
+	return true, nil
+}
+
+func (f *fakeNaming) UpdateInstance(p vo.UpdateInstanceParam) (bool, error) {
+	f.calls = append(f.calls, "update "+p.ServiceName)
+	if f.fail != nil {
+		return false, f.fail
+	}
+	f.put(p.ServiceName, model.Instance{Ip: p.Ip, Port: p.Port, Weight: p.Weight, Enable: p.Enable, Healthy: true, Ephemeral: p.Ephemeral, Metadata: p.Metadata})
+	return true, nil
+}
+
+func (f *fakeNaming) DeregisterInstance(p vo.DeregisterInstanceParam) (bool, error) {
+	f.calls = append(f.calls, "deregister "+p.ServiceName)
+	delete(f.instances[p.ServiceName], fmt.Sprintf("%s:%d", p.Ip, p.Port))
+	return true, nil
+}
+
+func (f *fakeNaming) SelectAllInstances(p vo.SelectAllInstancesParam) ([]model.Instance, error) {
+	var list []model.Instance
+	for _, inst := range f.instances[p.ServiceName] {
+		list = append(list, inst)
+	}
+	return list, nil
+}
+
+func testConfig() *Config {
+	cfg := &Config{Endpoints: []Endpoint{
+		{Service: "deepseek", IP: "10.0.0.1", Port: 8000, Probe: "http://10.0.0.1:8000/models", Metadata: map[string]string{MetaCluster: "chat", MetaID: "1", MetaAddress: "10.0.0.1:8000"}},
+		{Service: "deepseek", IP: "10.0.0.2", Port: 8000, Metadata: map[string]string{MetaCluster: "chat", MetaID: "2", MetaAddress: "10.0.0.2:8000"}},
+		{Service: "qwen", IP: "10.0.0.3", Port: 8000, Metadata: map[string]string{MetaCluster: "local", MetaID: "1", MetaAddress: "10.0.0.3:8000"}},
+	}}
+	cfg.setDefaults()
+	return cfg
+}
+
+func actions(changes []Change) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.Action+" "+c.Endpoint)
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	nacos := newFakeNaming()
+	cfg := testConfig()
+	r := NewRegistry(nacos, cfg)
+
+	changes, err := r.Apply(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"register deepseek@10.0.0.1:8000",
+		"register deepseek@10.0.0.2:8000",
+		"register qwen@10.0.0.3:8000",
+	}, actions(changes))
+	inst := nacos.instances["deepseek"]["10.0.0.1:8000"]
+	assert.True(t, inst.Enable)
+	assert.Equal(t, 10.0, inst.Weight)
+	assert.Equal(t, "1", inst.Metadata[MetaID])
+
+	// a second apply changes nothing, a changed weight or metadata is updated
+	cfg.Endpoints[1].Weight = 20
+	cfg.Endpoints[2].Metadata[MetaName] = "qwen2.5"
+	changes, err = r.Apply(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"unchanged deepseek@10.0.0.1:8000",
+		"update deepseek@10.0.0.2:8000",
+		"update qwen@10.0.0.3:8000",
+	}, actions(changes))
+	assert.Equal(t, 20.0, nacos.instances["deepseek"]["10.0.0.2:8000"].Weight)
+	assert.Equal(t, "qwen2.5", nacos.instances["qwen"]["10.0.0.3:8000"].Metadata[MetaName])
+
+	// the instances the file does not list are only deregistered with prune
+	nacos.put("deepseek", model.Instance{Ip: "10.0.0.9", Port: 8000, Weight: 1, Enable: true})
+	changes, err = r.Apply(false)
+	require.NoError(t, err)
+	assert.NotContains(t, actions(changes), "deregister deepseek@10.0.0.9:8000")
+	changes, err = r.Apply(true)
+	require.NoError(t, err)
+	assert.Contains(t, actions(changes), "deregister deepseek@10.0.0.9:8000")
+	assert.Len(t, nacos.instances["deepseek"], 2)
+}
+
+func TestApplyFailure(t *testing.T) {
+	nacos := newFakeNaming()
+	nacos.fail = errors.New("connection refused")
+	r := NewRegistry(nacos, testConfig())
+
+	changes, err := r.Apply(false)
+	assert.ErrorContains(t, err, "register deepseek@10.0.0.1:8000: connection refused")
+	assert.Len(t, changes, 1)
+	assert.Equal(t, []string{"register deepseek"}, nacos.calls, "apply stops at the first failure")
+}
+
+func TestDeregister(t *testing.T) {
+	nacos := newFakeNaming()
+	r := NewRegistry(nacos, testConfig())
+	_, err := r.Apply(false)
+	require.NoError(t, err)
+
+	changes, err := r.Deregister()
+	require.NoError(t, err)
+	assert.Len(t, changes, 3)
+	assert.Empty(t, nacos.instances["deepseek"])
+	assert.Empty(t, nacos.instances["qwen"])
+}
+
+func TestHeartbeat(t *testing.T) {
+	nacos := newFakeNaming()
+	cfg := testConfig()
+	cfg.Heartbeat.Threshold = 2
+	r := NewRegistry(nacos, cfg)
+	var down bool
+	probes := 0
+	r.probe = func(_ context.Context, url string) error {
+		assert.Equal(t, "http://10.0.0.1:8000/models", url, "only the endpoints with a probe are checked")
+		probes++
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	_, err := r.Apply(false)
+	require.NoError(t, err)
+	enabled := func() bool { return nacos.instances["deepseek"]["10.0.0.1:8000"].Enable }
+
+	down = true
+	r.heartbeat(context.Background())
+	assert.True(t, enabled(), "one failure is below the threshold")
+	r.heartbeat(context.Background())
+	assert.False(t, enabled())
+	r.heartbeat(context.Background())
+	assert.Equal(t, 1, countCalls(nacos.calls, "update deepseek"), "a disabled endpoint is updated once")
+
+	// an apply keeps the endpoint disabled
+	changes, err := r.Apply(false)
+	require.NoError(t, err)
+	assert.Equal(t, "unchanged deepseek@10.0.0.1:8000", actions(changes)[0])
+
+	down = false
+	r.heartbeat(context.Background())
+	assert.True(t, enabled())
+	assert.Equal(t, 4, probes)
+}
+
+func TestRun(t *testing.T) {
+	nacos := newFakeNaming()
+	cfg := testConfig()
+	cfg.Heartbeat.Interval = 10 * time.Millisecond
+	r := NewRegistry(nacos, cfg)
+	probed := make(chan struct{}, 1)
+	r.probe = func(context.Context, string) error {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	<-probed
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, nacos.instances["deepseek"], "the endpoints are deregistered on exit")
+}
+
+func TestList(t *testing.T) {
+	nacos := newFakeNaming()
+	cfg := testConfig()
+	cfg.Endpoints[2].Metadata[MetaAPIKeys] = "sk-secret-key"
+	r := NewRegistry(nacos, cfg)
+	_, err := r.Apply(false)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, r.List(&out))
+	assert.Contains(t, out.String(), "deepseek (2 instances)\n  10.0.0.1:8000 weight=10 enabled=true")
+	assert.Contains(t, out.String(), "llm-meta.api_keys:sk-***")
+	assert.NotContains(t, out.String(), "sk-secret-key")
+}
+
+func countCalls(calls []string, call string) int {
+	n := 0
+	for _, c := range calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
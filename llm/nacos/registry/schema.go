@@ -0,0 +1,167 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The instance metadata dgp.adapter.llmregistrycenter turns into an endpoint of a pixiu cluster
+const (
+	MetaCluster = "cluster"
+	MetaID      = "id"
+	MetaName    = "name"
+	MetaAddress = "address"
+	// MetaAPIKeyRef is refused: neither the adapter nor dgp.filter.llm.keyvault reads it, the
+	// reference of the api key of a cluster is set in the clusters of the keyvault config
+	MetaAPIKeyRef = "api_key_ref"
+
+	// LLMMetaPrefix starts the keys of the llm_meta of the endpoint
+	LLMMetaPrefix    = "llm-meta."
+	MetaRetryPolicy  = LLMMetaPrefix + "retry_policy.name"
+	MetaRetryConfig  = LLMMetaPrefix + "retry_policy.config"
+	MetaFallback     = LLMMetaPrefix + "fallback"
+	MetaAPIKeys      = LLMMetaPrefix + "api_keys"
+	retryCountBased  = "CountBased"
+	retryNoRetry     = "NoRetry"
+	retryExponential = "ExponentialBackoff"
+
+	// keyvaultFilter resolves the api key references in pixiu
+	keyvaultFilter = "dgp.filter.llm.keyvault"
+)
+
+// llmMetaSchema checks the value of each llm-meta key, the adapter ignores the keys it does not know,
+// so they are refused here instead of being silently lost
+var llmMetaSchema = map[string]func(string) error{
+	MetaRetryPolicy: func(v string) error {
+		switch v {
+		case retryNoRetry, retryCountBased, retryExponential:
+			return nil
+		}
+		return fmt.Errorf("unknown retry policy %q, want %s, %s or %s", v, retryNoRetry, retryCountBased, retryExponential)
+	},
+	MetaRetryConfig: func(v string) error {
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &config); err != nil {
+			return fmt.Errorf("not a json object: %v", err)
+		}
+		if times, ok := config["times"]; ok {
+			if n, isNumber := times.(float64); !isNumber || n < 0 || n != float64(int(n)) {
+				return fmt.Errorf("times must be a positive integer, not %v", times)
+			}
+		}
+		return nil
+	},
+	MetaFallback: func(v string) error {
+		_, err := strconv.ParseBool(v)
+		return err
+	},
+	MetaAPIKeys: func(v string) error {
+		for _, key := range strings.Split(v, ",") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return fmt.Errorf("empty api key, is the variable of the key set?")
+			}
+			if strings.HasPrefix(key, secretPrefix) || strings.HasPrefix(key, sealedPrefix) {
+				return fmt.Errorf("the adapter sends the api keys as they are, put the reference in the clusters of %s", keyvaultFilter)
+			}
+		}
+		return nil
+	},
+}
+
+// ValidateMetadata returns the problems of the metadata of an instance, sorted by key
+func ValidateMetadata(metadata map[string]string) []error {
+	var errs []error
+	for _, key := range []string{MetaCluster, MetaID, MetaAddress} {
+		if metadata[key] == "" {
+			errs = append(errs, fmt.Errorf("metadata %s is required", key))
+		}
+	}
+	if address := metadata[MetaAddress]; address != "" {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			host, port = address, ""
+		}
+		switch {
+		case host == "" || strings.ContainsAny(address, "/ "):
+			errs = append(errs, fmt.Errorf("metadata %s: %q is not a host or host:port", MetaAddress, address))
+		case port != "":
+			if _, err = strconv.ParseUint(port, 10, 16); err != nil {
+				errs = append(errs, fmt.Errorf("metadata %s: invalid port %q", MetaAddress, port))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, LLMMetaPrefix) {
+			continue
+		}
+		check, ok := llmMetaSchema[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("metadata %s is not an llm-meta key the adapter knows", key))
+			continue
+		}
+		if err := check(metadata[key]); err != nil {
+			errs = append(errs, fmt.Errorf("metadata %s: %w", key, err))
+		}
+	}
+
+	if _, ok := metadata[MetaAPIKeyRef]; ok {
+		errs = append(errs, fmt.Errorf("metadata %s is read by nothing, put the reference in the clusters of %s", MetaAPIKeyRef, keyvaultFilter))
+	}
+
+	policy := metadata[MetaRetryPolicy]
+	if _, configured := metadata[MetaRetryConfig]; (policy == retryCountBased || policy == retryExponential) && !configured {
+		errs = append(errs, fmt.Errorf("metadata %s is required by the %s retry policy", MetaRetryConfig, policy))
+	}
+	return errs
+}
+
+// masked returns the metadata with the api keys hidden, to be logged
+func masked(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	if keys, ok := out[MetaAPIKeys]; ok {
+		parts := strings.Split(keys, ",")
+		for i, key := range parts {
+			parts[i] = maskKey(strings.TrimSpace(key))
+		}
+		out[MetaAPIKeys] = strings.Join(parts, ",")
+	}
+	return out
+}
+
+func maskKey(key string) string {
+	if len(key) > 6 {
+		return key[:3] + "***"
+	}
+	return "***"
+}
@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// The references of dgp.filter.llm.keyvault of the llm/vault sample, which resolves them in pixiu.
+// A sealed value is the base64 of the nonce and the AES-256-GCM ciphertext of the key.
+const (
+	secretPrefix     = "secret:"
+	sealedPrefix     = "enc:v1:"
+	defaultEnvPrefix = "LLM_SECRET_"
+	defaultKeyEnv    = "LLM_VAULT_KEY"
+)
+
+// vault resolves the secret:<name> references from the secrets file and the environment, and
+// seals the keys with the vault key
+type vault struct {
+	secrets   map[string]string
+	envPrefix string
+	key       []byte
+}
+
+func newVault(cfg SecretsConfig) (*vault, error) {
+	v := &vault{secrets: map[string]string{}, envPrefix: cfg.EnvPrefix}
+	if v.envPrefix == "" {
+		v.envPrefix = defaultEnvPrefix
+	}
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("read secrets: %w", err)
+		}
+		if err = yaml.Unmarshal(data, &v.secrets); err != nil {
+			return nil, fmt.Errorf("parse secrets %s: %w", cfg.File, err)
+		}
+	}
+	keyEnv := cfg.KeyEnv
+	if keyEnv == "" {
+		keyEnv = defaultKeyEnv
+	}
+	if encoded := os.Getenv(keyEnv); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s is not the base64 of a 32 bytes key, openssl rand -base64 32 generates one", keyEnv)
+		}
+		v.key = key
+	}
+	return v, nil
+}
+
+// envName is the environment variable of a secret, deepseek-chat is LLM_SECRET_DEEPSEEK_CHAT
+func (v *vault) envName(name string) string {
+	return v.envPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name))
+}
+
+// resolve returns the key of a secret:<name> reference, the environment overrides the secrets file
+func (v *vault) resolve(ref string) (string, error) {
+	name := strings.TrimPrefix(ref, secretPrefix)
+	if value := os.Getenv(v.envName(name)); value != "" {
+		return value, nil
+	}
+	if value := v.secrets[name]; value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf("secret %s is neither in the secrets file nor in $%s", name, v.envName(name))
+}
+
+func (v *vault) aead() (cipher.AEAD, error) {
+	if v.key == nil {
+		return nil, errors.New("sealing needs the vault key")
+	}
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *vault) seal(value string) (string, error) {
+	aead, err := v.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func (v *vault) open(sealed string) (string, error) {
+	aead, err := v.aead()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("the sealed value is malformed")
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("the sealed value does not open with the vault key")
+	}
+	return string(value), nil
+}
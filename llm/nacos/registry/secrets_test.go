@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testVaultKey is the vault key of the tests and of the llm/vault sample
+const testVaultKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func testVault(t *testing.T) *vault {
+	t.Setenv(defaultKeyEnv, testVaultKey)
+	v, err := newVault(SecretsConfig{})
+	require.NoError(t, err)
+	return v
+}
+
+// TestOpenKeyvaultValue opens the sealed value of the llm/vault sample, so the format stays the one
+// dgp.filter.llm.keyvault opens
+func TestOpenKeyvaultValue(t *testing.T) {
+	v := testVault(t)
+	value, err := v.open("enc:v1:AAECAwQFBgcICQoLXo6WEbZ7uYHDuQglmkwoFd0CTo3MfIRc1H5L")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-mock-key", value)
+
+	sealed, err := v.seal("sk-mock-key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, sealedPrefix))
+	value, err = v.open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-mock-key", value)
+
+	_, err = v.open("enc:v1:MDEyMzQ1Njc4OWFiY2RlZmdoaWprbG1ub3BxcnN0dXY")
+	assert.EqualError(t, err, "the sealed value does not open with the vault key")
+}
+
+func TestSealKey(t *testing.T) {
+	v := testVault(t)
+	t.Setenv("LLM_SECRET_DEEPSEEK_CHAT", "sk-from-env")
+
+	sealed, err := sealKey(v, "secret:deepseek-chat", nil)
+	require.NoError(t, err)
+	value, err := v.open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-from-env", value)
+
+	sealed, err = sealKey(v, "", strings.NewReader("sk-from-stdin\n"))
+	require.NoError(t, err)
+	value, err = v.open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-from-stdin", value)
+
+	_, err = sealKey(v, "sk-on-the-command-line", nil)
+	assert.ErrorContains(t, err, `"sk-***" is not a secret:<name> reference, pass the key on stdin`)
+	_, err = sealKey(v, "", strings.NewReader(""))
+	assert.EqualError(t, err, "the key is empty")
+
+	_, err = sealKey(&vault{}, "", strings.NewReader("sk-from-stdin"))
+	assert.EqualError(t, err, "sealing needs the vault key")
+}
@@ -0,0 +1,163 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const (
+	pixiuURL   = "http://localhost:8888"
+	metricsURL = "http://localhost:2222/"
+)
+
+var deepseek = llmmock.Client{URL: "http://localhost:8090"}
+
+func apiError(t *testing.T, err error, status int) *openai.Error {
+	t.Helper()
+	var apiErr *openai.Error
+	require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+	assert.Equal(t, status, apiErr.StatusCode)
+	return apiErr
+}
+
+func scrape(t require.TestingT, name string, labels map[string]string) float64 {
+	return testkit.ScrapeMetric(t, metricsURL, name, labels)
+}
+
+func TestUnknownKey(t *testing.T) {
+	require.NoError(t, deepseek.Reset())
+	before := scrape(t, "pixiu_llm_consumer_rejected_requests_total", map[string]string{"reason": "unknown_key"})
+
+	client := llmmock.NewClient(pixiuURL, "sk-of-the-provider")
+	_, err := client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	apiErr := apiError(t, err, http.StatusUnauthorized)
+	assert.Equal(t, "invalid_api_key", apiErr.Code)
+
+	stats, err := deepseek.Stats()
+	require.NoError(t, err)
+	assert.Zero(t, stats.Requests["/chat/completions"], "pixiu rejects the request before the provider")
+	assert.Equal(t, before+1, scrape(t, "pixiu_llm_consumer_rejected_requests_total", map[string]string{"reason": "unknown_key"}))
+}
+
+func TestTokensAndCostPerModel(t *testing.T) {
+	models := []string{"deepseek-chat", "deepseek-reasoner"}
+	labels := func(model, kind string) map[string]string {
+		return map[string]string{"consumer": "alice", "model": model, "type": kind}
+	}
+	before := map[string]float64{}
+	for _, model := range models {
+		before[model+"/prompt"] = scrape(t, "pixiu_llm_consumer_tokens_total", labels(model, "prompt"))
+		before[model+"/completion"] = scrape(t, "pixiu_llm_consumer_tokens_total", labels(model, "completion"))
+		before[model+"/cost"] = scrape(t, "pixiu_llm_consumer_cost_total", map[string]string{"consumer": "alice", "model": model})
+	}
+
+	client := llmmock.NewClient(pixiuURL, "sk-alice")
+	used := map[string]openai.CompletionUsage{}
+	for _, model := range models {
+		completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question(model, "1+1=?"))
+		require.NoError(t, err, "the key vault sends the key of the provider")
+		assert.Equal(t, "1+1 equals 2.", completion.Choices[0].Message.Content)
+		used[model] = completion.Usage
+	}
+
+	// deepseek-chat has a price of its own, deepseek-reasoner the one of deepseek-*
+	prices := map[string][2]float64{"deepseek-chat": {0.27, 1.10}, "deepseek-reasoner": {0.55, 2.19}}
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		for _, model := range models {
+			u := used[model]
+			assert.Equal(c, before[model+"/prompt"]+float64(u.PromptTokens),
+				scrape(c, "pixiu_llm_consumer_tokens_total", labels(model, "prompt")))
+			assert.Equal(c, before[model+"/completion"]+float64(u.CompletionTokens),
+				scrape(c, "pixiu_llm_consumer_tokens_total", labels(model, "completion")))
+			cost := (float64(u.PromptTokens)*prices[model][0] + float64(u.CompletionTokens)*prices[model][1]) / 1e6
+			assert.InDelta(c, before[model+"/cost"]+cost,
+				scrape(c, "pixiu_llm_consumer_cost_total", map[string]string{"consumer": "alice", "model": model}), 1e-9)
+		}
+	}, 10*time.Second)
+}
+
+func TestStreamIsCounted(t *testing.T) {
+	labels := map[string]string{"consumer": "alice", "model": "deepseek-chat", "type": "completion"}
+	before := scrape(t, "pixiu_llm_consumer_tokens_total", labels)
+
+	client := llmmock.NewClient(pixiuURL, "sk-alice")
+	stream := client.Chat.Completions.NewStreaming(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "1+1 equals 2.", acc.Choices[0].Message.Content)
+	require.NotZero(t, acc.Usage.CompletionTokens)
+
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		assert.Equal(c, before+float64(acc.Usage.CompletionTokens), scrape(c, "pixiu_llm_consumer_tokens_total", labels))
+	}, 10*time.Second)
+}
+
+func TestDailyQuota(t *testing.T) {
+	rejected := map[string]string{"consumer": "bob", "reason": "quota"}
+	before := scrape(t, "pixiu_llm_consumer_rejected_requests_total", rejected)
+
+	// the two keys of bob share its budget of 20 tokens, a few requests use it up
+	var err error
+	for i := 0; i < 20 && err == nil; i++ {
+		key := []string{"sk-bob", "sk-bob-ci"}[i%2]
+		client := llmmock.NewClient(pixiuURL, key)
+		_, err = client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	}
+	require.Error(t, err, "bob goes over its daily tokens")
+
+	apiErr := apiError(t, err, http.StatusTooManyRequests)
+	assert.Equal(t, "insufficient_quota", apiErr.Type)
+	assert.Equal(t, "insufficient_quota", apiErr.Code)
+	assert.Contains(t, apiErr.Message, "daily tokens")
+	retryAfter, convErr := strconv.Atoi(apiErr.Response.Header.Get("Retry-After"))
+	require.NoError(t, convErr)
+	assert.True(t, retryAfter > 0 && retryAfter <= 24*60*60)
+
+	// the budget of alice is not the one of bob
+	alice := llmmock.NewClient(pixiuURL, "sk-alice")
+	_, err = alice.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	require.NoError(t, err)
+
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		assert.Equal(c, before+1, scrape(c, "pixiu_llm_consumer_rejected_requests_total", rejected))
+		assert.Zero(c, scrape(c, "pixiu_llm_consumer_quota_remaining_tokens", map[string]string{"consumer": "bob"}))
+	}, 10*time.Second)
+}
@@ -0,0 +1,376 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tokenquota is the dgp.filter.llm.tokenquota http filter. It knows the consumers by their
+// api key, counts the prompt and completion tokens of each one, rejects the requests of a consumer
+// whose daily budget is used up, and exports the tokens and their cost by model to prometheus.
+package tokenquota
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+	"github.com/dubbo-go-pixiu/samples/llm/vault/keyvault"
+)
+
+const (
+	// Kind is the kind of the filter
+	Kind = "dgp.filter.llm.tokenquota"
+)
+
+func init() {
+	filter.RegisterHttpFilter(&Plugin{})
+}
+
+type (
+	// Plugin is the token quota plugin
+	Plugin struct {
+	}
+
+	// FilterFactory holds the consumers and the tokens they used
+	FilterFactory struct {
+		cfg       *Config
+		consumers []*Consumer
+		// keys maps an api key to its consumer
+		keys    map[string]*Consumer
+		ledger  *ledger
+		metrics *metrics
+		// now is replaced by the tests
+		now func() time.Time
+	}
+
+	// Filter accounts the tokens of a request
+	Filter struct {
+		factory  *FilterFactory
+		consumer *Consumer
+		model    string
+		// hold is what the request holds of the budget of the consumer, out of its estimate
+		hold     *hold
+		estimate int64
+		writer   *llmfilter.Writer
+		// injected is set when the filter asked for the usage of a stream, not the client
+		injected  bool
+		dropBlank bool
+		// streamed is the usage read from a stream as it passes
+		streamed usage
+		found    bool
+	}
+
+	// Config is the config of the filter
+	Config struct {
+		// SecretsFile, EnvPrefix and KeyEnv resolve the api keys of the consumers like dgp.filter.llm.keyvault
+		SecretsFile string `yaml:"secrets_file" json:"secrets_file" mapstructure:"secrets_file"`
+		EnvPrefix   string `yaml:"env_prefix" json:"env_prefix" mapstructure:"env_prefix"`
+		KeyEnv      string `yaml:"key_env" json:"key_env" mapstructure:"key_env"`
+		// Timezone the days of the budgets start in, UTC when empty
+		Timezone  string      `yaml:"timezone" json:"timezone" mapstructure:"timezone"`
+		Consumers []*Consumer `yaml:"consumers" json:"consumers" mapstructure:"consumers"`
+		// Prices of the models, a trailing * matches a prefix
+		Prices map[string]*Price `yaml:"prices" json:"prices" mapstructure:"prices"`
+	}
+
+	// Consumer is a client of the gateway
+	Consumer struct {
+		Name string `yaml:"name" json:"name" mapstructure:"name"`
+		// Keys are the secret:<name> or enc:v1: references of the api keys of the consumer
+		Keys []string `yaml:"keys" json:"keys" mapstructure:"keys"`
+		// DailyTokens is the prompt and completion tokens the consumer may use a day, no limit when 0
+		DailyTokens int64 `yaml:"daily_tokens" json:"daily_tokens" mapstructure:"daily_tokens"`
+	}
+
+	// Price of a model per million tokens
+	Price struct {
+		Prompt     float64 `yaml:"prompt" json:"prompt" mapstructure:"prompt"`
+		Completion float64 `yaml:"completion" json:"completion" mapstructure:"completion"`
+	}
+)
+
+func (p *Plugin) Kind() string {
+	return Kind
+}
+
+func (p *Plugin) CreateFilterFactory() (filter.HttpFilterFactory, error) {
+	return &FilterFactory{cfg: &Config{}, now: time.Now}, nil
+}
+
+func (factory *FilterFactory) Config() interface{} {
+	return factory.cfg
+}
+
+func (factory *FilterFactory) Apply() error {
+	location := time.UTC
+	if factory.cfg.Timezone != "" {
+		loc, err := time.LoadLocation(factory.cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("%s: %w", Kind, err)
+		}
+		location = loc
+	}
+	vault, err := keyvault.NewVault(factory.cfg.SecretsFile, factory.cfg.EnvPrefix, factory.cfg.KeyEnv)
+	if err != nil {
+		return fmt.Errorf("%s: %w", Kind, err)
+	}
+	keys := map[string]*Consumer{}
+	names := map[string]bool{}
+	for i, consumer := range factory.cfg.Consumers {
+		if consumer.Name == "" {
+			return fmt.Errorf("%s: consumer %d has no name", Kind, i)
+		}
+		if names[consumer.Name] {
+			return fmt.Errorf("%s: consumer %s is configured twice", Kind, consumer.Name)
+		}
+		names[consumer.Name] = true
+		if len(consumer.Keys) == 0 {
+			return fmt.Errorf("%s: consumer %s has no keys", Kind, consumer.Name)
+		}
+		if consumer.DailyTokens < 0 {
+			return fmt.Errorf("%s: the daily tokens of consumer %s are negative", Kind, consumer.Name)
+		}
+		for _, ref := range consumer.Keys {
+			key, err := vault.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("%s: a key of consumer %s: %w", Kind, consumer.Name, err)
+			}
+			if other, ok := keys[key]; ok {
+				return fmt.Errorf("%s: consumers %s and %s share a key", Kind, other.Name, consumer.Name)
+			}
+			keys[key] = consumer
+		}
+	}
+	factory.consumers = factory.cfg.Consumers
+	factory.keys = keys
+	factory.ledger = newLedger(location)
+	if factory.metrics, err = registerMetrics(factory); err != nil {
+		return fmt.Errorf("%s: register the metrics: %w", Kind, err)
+	}
+	return nil
+}
+
+func (factory *FilterFactory) PrepareFilterChain(ctx *contexthttp.HttpContext, chain filter.FilterChain) error {
+	f := &Filter{factory: factory}
+	chain.AppendDecodeFilters(f)
+	chain.AppendEncodeFilters(f)
+	return nil
+}
+
+// price returns the price of a model, an exact match first
+func (factory *FilterFactory) price(model string) *Price {
+	if p, ok := factory.cfg.Prices[model]; ok {
+		return p
+	}
+	var found *Price
+	longest := -1
+	for pattern, p := range factory.cfg.Prices {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && llmfilter.MatchModel(pattern, model) && len(prefix) > longest {
+			found, longest = p, len(prefix)
+		}
+	}
+	return found
+}
+
+func (p *Price) cost(u usage) float64 {
+	return (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1e6
+}
+
+// Decode rejects the requests of unknown keys and of the consumers over their budget. It runs before
+// dgp.filter.llm.keyvault, which replaces the key of the consumer with the one of the provider.
+func (f *Filter) Decode(c *contexthttp.HttpContext) filter.FilterStatus {
+	key, _ := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+	consumer, ok := f.factory.keys[strings.TrimSpace(key)]
+	if !ok {
+		f.factory.metrics.reject(c.Ctx, "", "unknown_key")
+		sendError(c, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "incorrect api key provided")
+		return filter.Stop
+	}
+
+	f.estimate = f.readRequest(c)
+	if consumer.DailyTokens > 0 {
+		// the request holds an estimate of its tokens until its usage is read, so the requests in
+		// flight together can not go far over the budget
+		h, taken, reset, ok := f.factory.ledger.reserve(consumer.Name, f.estimate, consumer.DailyTokens, f.factory.now())
+		if !ok {
+			f.factory.metrics.reject(c.Ctx, consumer.Name, "quota")
+			c.AddHeader("Retry-After", strconv.Itoa(int(reset.Sub(f.factory.now()).Seconds())+1))
+			sendError(c, http.StatusTooManyRequests, "insufficient_quota", "insufficient_quota",
+				fmt.Sprintf("consumer %s used %d of its %d daily tokens, the quota resets at %s",
+					consumer.Name, taken, consumer.DailyTokens, reset.Format(time.RFC3339)))
+			return filter.Stop
+		}
+		f.hold = h
+		c.AddHeader("X-Ratelimit-Limit-Tokens", strconv.FormatInt(consumer.DailyTokens, 10))
+		c.AddHeader("X-Ratelimit-Remaining-Tokens", strconv.FormatInt(consumer.DailyTokens-taken, 10))
+	}
+	f.consumer = consumer
+	f.writer = llmfilter.NewWriter(c.Writer)
+	f.writer.Line = f.line
+	c.Writer = f.writer
+	return filter.Continue
+}
+
+// readRequest reads the model of the request and returns an estimate of its tokens, the prompt at
+// four bytes a token and its max_tokens. It asks for the usage of a stream, which the providers only
+// send with stream_options.include_usage.
+func (f *Filter) readRequest(c *contexthttp.HttpContext) int64 {
+	if c.Request.Body == nil {
+		return 0
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	defer func() {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+	}()
+	if err != nil {
+		logger.Warnf("[dubbo-go-pixiu] %s: read body: %v", Kind, err)
+		return 0
+	}
+	estimate := int64(len(body)) / 4
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return estimate
+	}
+	var (
+		stream    bool
+		maxTokens int64
+	)
+	_ = json.Unmarshal(fields["model"], &f.model)
+	_ = json.Unmarshal(fields["stream"], &stream)
+	if json.Unmarshal(fields["max_completion_tokens"], &maxTokens) != nil || maxTokens == 0 {
+		_ = json.Unmarshal(fields["max_tokens"], &maxTokens)
+	}
+	if _, ok := fields["stream_options"]; stream && !ok {
+		fields["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+		if rewritten, err := json.Marshal(fields); err == nil {
+			body = rewritten
+			f.injected = true
+		}
+	}
+	return estimate + max(maxTokens, 0)
+}
+
+// line reads the usage of a stream as it passes, it comes with the last chunk. The chunk is dropped
+// when the filter asked for it, the client did not.
+func (f *Filter) line(line []byte) []byte {
+	if f.dropBlank {
+		f.dropBlank = false
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil
+		}
+	}
+	data, ok := llmfilter.EventData(line)
+	if !ok || !f.streamed.merge(data) {
+		return line
+	}
+	f.found = true
+	if f.injected && usageOnly(data) {
+		// the blank line that ends the event goes with it
+		f.dropBlank = true
+		return nil
+	}
+	return line
+}
+
+// Encode counts the tokens of the response, streamed to the client or returned to pixiu, and lets
+// go of what the request held of the budget
+func (f *Filter) Encode(c *contexthttp.HttpContext) filter.FilterStatus {
+	if f.consumer == nil {
+		return filter.Continue
+	}
+	var (
+		u  usage
+		ok bool
+	)
+	switch {
+	case f.writer.Wrote && f.writer.Stream:
+		f.writer.Finish()
+		u, ok = f.streamed, f.found
+	case f.writer.Wrote:
+		u, ok = parseUsage(f.writer.Body.Bytes())
+	case c.TargetResp != nil && llmfilter.IsStream(c.Writer.Header()):
+		data := llmfilter.RewriteLines(c.TargetResp.Data, f.line)
+		if len(data) != len(c.TargetResp.Data) {
+			c.Writer.Header().Del("Content-Length")
+		}
+		c.TargetResp.Data = data
+		u, ok = f.streamed, f.found
+	case c.TargetResp != nil:
+		u, ok = parseUsage(c.TargetResp.Data)
+	}
+	if !ok {
+		f.settleUnknown(c)
+		return filter.Continue
+	}
+	if u.Model == "" {
+		u.Model = f.model
+	}
+	used := f.factory.ledger.settle(f.consumer.Name, f.hold, u.PromptTokens+u.CompletionTokens, f.factory.now())
+	f.factory.metrics.record(c.Ctx, f.consumer.Name, u, f.factory.price(u.Model))
+	logger.Debugf("[dubbo-go-pixiu] %s: consumer %s used %d prompt and %d completion tokens of %s, %d today",
+		Kind, f.consumer.Name, u.PromptTokens, u.CompletionTokens, u.Model, used)
+	return filter.Continue
+}
+
+// settleUnknown settles a reply without usage. No reply or an error of the provider uses no tokens,
+// any other reply, like a stream without the usage chunk or one too long to read, is charged the
+// estimate of the request.
+func (f *Filter) settleUnknown(c *contexthttp.HttpContext) {
+	status := c.GetStatusCode()
+	if f.writer.Wrote {
+		status = f.writer.Status
+	}
+	var tokens int64
+	switch {
+	case !f.writer.Wrote && c.TargetResp == nil, status >= http.StatusBadRequest:
+	case f.hold != nil:
+		tokens = f.hold.tokens
+	default:
+		tokens = f.estimate
+	}
+	used := f.factory.ledger.settle(f.consumer.Name, f.hold, tokens, f.factory.now())
+	logger.Debugf("[dubbo-go-pixiu] %s: no usage in the %d response for consumer %s, %d tokens charged, %d today",
+		Kind, status, f.consumer.Name, tokens, used)
+}
+
+// sendError replies with the error body of the OpenAI api
+func sendError(c *contexthttp.HttpContext, status int, errorType, code, message string) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errorType,
+			"param":   nil,
+			"code":    code,
+		},
+	})
+	c.SendLocalReply(status, body)
+}
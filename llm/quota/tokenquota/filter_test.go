@@ -0,0 +1,279 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenquota
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/client"
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/context/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFactory(t *testing.T, consumers ...*Consumer) *FilterFactory {
+	t.Setenv("LLM_SECRET_ALICE", "sk-alice")
+	t.Setenv("LLM_SECRET_BOB", "sk-bob")
+	factory := &FilterFactory{cfg: &Config{
+		Consumers: consumers,
+		Prices: map[string]*Price{
+			"deepseek-*":        {Prompt: 1, Completion: 2},
+			"deepseek-reasoner": {Prompt: 4, Completion: 16},
+		},
+	}, now: time.Now}
+	require.NoError(t, factory.Apply())
+	return factory
+}
+
+func newContext(t *testing.T, key, body string) (*contexthttp.HttpContext, *httptest.ResponseRecorder) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8888/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	c := mock.GetMockHTTPContext(req)
+	recorder := httptest.NewRecorder()
+	c.Writer = recorder
+	return c, recorder
+}
+
+// complete decodes a request of the key and encodes the response pixiu got for it
+func complete(t *testing.T, factory *FilterFactory, key, response string) filter.FilterStatus {
+	c, _ := newContext(t, key, `{"model":"deepseek-chat"}`)
+	f := &Filter{factory: factory}
+	status := f.Decode(c)
+	if status == filter.Continue {
+		c.TargetResp = &client.Response{Data: []byte(response)}
+		f.Encode(c)
+	}
+	return status
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv("LLM_SECRET_ALICE", "sk-alice")
+	testCases := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{name: "no name", cfg: &Config{Consumers: []*Consumer{{Keys: []string{"secret:alice"}}}}, err: "no name"},
+		{name: "no keys", cfg: &Config{Consumers: []*Consumer{{Name: "alice"}}}, err: "no keys"},
+		{name: "twice", cfg: &Config{Consumers: []*Consumer{
+			{Name: "alice", Keys: []string{"secret:alice"}},
+			{Name: "alice", Keys: []string{"secret:alice"}},
+		}}, err: "configured twice"},
+		{name: "shared key", cfg: &Config{Consumers: []*Consumer{
+			{Name: "alice", Keys: []string{"secret:alice"}},
+			{Name: "bob", Keys: []string{"secret:alice"}},
+		}}, err: "share a key"},
+		{name: "negative", cfg: &Config{Consumers: []*Consumer{{Name: "alice", Keys: []string{"secret:alice"}, DailyTokens: -1}}}, err: "negative"},
+		{name: "plain key", cfg: &Config{Consumers: []*Consumer{{Name: "alice", Keys: []string{"sk-alice"}}}}, err: "consumer alice"},
+		{name: "missing secret", cfg: &Config{Consumers: []*Consumer{{Name: "alice", Keys: []string{"secret:carol"}}}}, err: "consumer alice"},
+		{name: "timezone", cfg: &Config{Timezone: "Mars/Olympus"}, err: "Mars/Olympus"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			factory := &FilterFactory{cfg: tc.cfg, now: time.Now}
+			err := factory.Apply()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	factory := newFactory(t, &Consumer{Name: "alice", Keys: []string{"secret:alice"}})
+
+	for _, key := range []string{"", "sk-carol"} {
+		c, recorder := newContext(t, key, `{"model":"deepseek-chat"}`)
+		assert.Equal(t, filter.Stop, (&Filter{factory: factory}).Decode(c))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		var body map[string]map[string]interface{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.Equal(t, "invalid_api_key", body["error"]["code"])
+	}
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2026, 5, 1, 23, 0, 0, 0, time.UTC)
+	factory := newFactory(t,
+		&Consumer{Name: "alice", Keys: []string{"secret:alice"}, DailyTokens: 100},
+		&Consumer{Name: "bob", Keys: []string{"secret:bob"}},
+	)
+	factory.now = func() time.Time { return now }
+	response := `{"model":"deepseek-chat","usage":{"prompt_tokens":40,"completion_tokens":20}}`
+
+	assert.Equal(t, filter.Continue, complete(t, factory, "sk-alice", response))
+	assert.Equal(t, filter.Continue, complete(t, factory, "sk-alice", response))
+	used, _ := factory.ledger.used("alice", now)
+	assert.Equal(t, int64(120), used)
+
+	c, recorder := newContext(t, "sk-alice", `{"model":"deepseek-chat"}`)
+	assert.Equal(t, filter.Stop, (&Filter{factory: factory}).Decode(c))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "3601", recorder.Header().Get("Retry-After"))
+	var body map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "insufficient_quota", body["error"]["type"])
+	assert.Equal(t, "insufficient_quota", body["error"]["code"])
+	assert.Contains(t, body["error"]["message"], "120 of its 100")
+
+	// bob has no limit, and the day of alice ends
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, filter.Continue, complete(t, factory, "sk-bob", response))
+	}
+	now = now.Add(time.Hour)
+	c, recorder = newContext(t, "sk-alice", `{"model":"deepseek-chat"}`)
+	assert.Equal(t, filter.Continue, (&Filter{factory: factory}).Decode(c))
+	assert.Equal(t, "100", recorder.Header().Get("X-Ratelimit-Remaining-Tokens"))
+}
+
+func TestStreamOptions(t *testing.T) {
+	factory := newFactory(t, &Consumer{Name: "alice", Keys: []string{"secret:alice"}})
+
+	testCases := []struct {
+		name string
+		body string
+		sent map[string]interface{}
+	}{
+		{name: "stream", body: `{"model":"deepseek-chat","stream":true}`,
+			sent: map[string]interface{}{"model": "deepseek-chat", "stream": true, "stream_options": map[string]interface{}{"include_usage": true}}},
+		{name: "options of the client", body: `{"model":"deepseek-chat","stream":true,"stream_options":{"include_usage":false}}`,
+			sent: map[string]interface{}{"model": "deepseek-chat", "stream": true, "stream_options": map[string]interface{}{"include_usage": false}}},
+		{name: "no stream", body: `{"model":"deepseek-chat"}`,
+			sent: map[string]interface{}{"model": "deepseek-chat"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newContext(t, "sk-alice", tc.body)
+			f := &Filter{factory: factory}
+			assert.Equal(t, filter.Continue, f.Decode(c))
+			assert.Equal(t, "deepseek-chat", f.model)
+			data, err := io.ReadAll(c.Request.Body)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), c.Request.ContentLength)
+			var sent map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &sent))
+			assert.Equal(t, tc.sent, sent)
+		})
+	}
+}
+
+func TestStreamedUsage(t *testing.T) {
+	events := "data: {\"model\":\"deepseek-chat\",\"choices\":[]}\n\n"
+	usage := "data: {\"model\":\"deepseek-chat\",\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":5}}\n\n"
+	stream := events + usage + "data: [DONE]\n\n"
+	testCases := []struct {
+		name string
+		body string
+		// pixiu returns the stream instead of the proxy writing it to the client
+		returned bool
+		sent     string
+	}{
+		// the usage the filter asked for is not passed on
+		{name: "usage of the filter", body: `{"model":"deepseek-chat","stream":true}`, sent: events + "data: [DONE]\n\n"},
+		{name: "usage of the client", body: `{"model":"deepseek-chat","stream":true,"stream_options":{"include_usage":true}}`, sent: stream},
+		{name: "returned", body: `{"model":"deepseek-chat","stream":true}`, returned: true, sent: events + "data: [DONE]\n\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			factory := newFactory(t, &Consumer{Name: "alice", Keys: []string{"secret:alice"}, DailyTokens: 1000})
+			c, recorder := newContext(t, "sk-alice", tc.body)
+			f := &Filter{factory: factory}
+			require.Equal(t, filter.Continue, f.Decode(c))
+
+			c.Writer.Header().Set("Content-Type", "text/event-stream")
+			var sent string
+			if tc.returned {
+				c.TargetResp = &client.Response{Data: []byte(stream)}
+				f.Encode(c)
+				sent = string(c.TargetResp.Data)
+			} else {
+				// the proxy writes the stream to the client itself, in chunks that split the events
+				for _, chunk := range []string{stream[:30], stream[30:90], stream[90:]} {
+					_, err := c.Writer.Write([]byte(chunk))
+					require.NoError(t, err)
+				}
+				f.Encode(c)
+				sent = recorder.Body.String()
+			}
+
+			assert.Equal(t, tc.sent, sent)
+			used, _ := factory.ledger.used("alice", time.Now())
+			assert.Equal(t, int64(12), used)
+		})
+	}
+}
+
+func TestReserve(t *testing.T) {
+	factory := newFactory(t, &Consumer{Name: "alice", Keys: []string{"secret:alice"}, DailyTokens: 100})
+	decode := func(body string) (*Filter, *contexthttp.HttpContext, filter.FilterStatus) {
+		c, _ := newContext(t, "sk-alice", body)
+		f := &Filter{factory: factory}
+		return f, c, f.Decode(c)
+	}
+
+	// the first request in flight holds 10 prompt and 60 completion tokens, the second what is left
+	first, c1, status := decode(`{"model":"deepseek-chat","max_tokens":60}`)
+	require.Equal(t, filter.Continue, status)
+	assert.Equal(t, int64(70), first.hold.tokens)
+	second, c2, status := decode(`{"model":"deepseek-chat","max_tokens":60}`)
+	require.Equal(t, filter.Continue, status)
+	assert.Equal(t, int64(30), second.hold.tokens)
+	_, _, status = decode(`{"model":"deepseek-chat"}`)
+	assert.Equal(t, filter.Stop, status)
+
+	// the first one used less than it held, the second one failed
+	c1.TargetResp = &client.Response{Data: []byte(`{"usage":{"prompt_tokens":8,"completion_tokens":12}}`)}
+	first.Encode(c1)
+	c2.StatusCode(http.StatusServiceUnavailable)
+	c2.TargetResp = &client.Response{Data: []byte(`{"error":{"message":"overloaded"}}`)}
+	second.Encode(c2)
+	used, _ := factory.ledger.used("alice", time.Now())
+	assert.Equal(t, int64(20), used)
+
+	// a reply without usage is charged what the request held
+	third, c, status := decode(`{"model":"deepseek-chat","max_tokens":30}`)
+	assert.Equal(t, filter.Continue, status)
+	assert.Equal(t, "80", c.Writer.Header().Get("X-Ratelimit-Remaining-Tokens"))
+	c.StatusCode(http.StatusOK)
+	c.TargetResp = &client.Response{Data: []byte(`{"choices":[]}`)}
+	third.Encode(c)
+	used, _ = factory.ledger.used("alice", time.Now())
+	assert.Equal(t, int64(20+10+30), used)
+}
+
+func TestPrice(t *testing.T) {
+	factory := newFactory(t)
+
+	assert.Equal(t, float64(4), factory.price("deepseek-reasoner").Prompt)
+	assert.Equal(t, float64(1), factory.price("deepseek-chat").Prompt)
+	assert.Nil(t, factory.price("qwen2.5"))
+	assert.InDelta(t, 0.000005, factory.price("deepseek-chat").cost(usage{PromptTokens: 1, CompletionTokens: 2}), 1e-12)
+}
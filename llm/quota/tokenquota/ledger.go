@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenquota
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ledger counts the tokens each consumer used today, and the ones its requests in flight hold. A day
+// starts at midnight in the location.
+type ledger struct {
+	location *time.Location
+
+	mu   sync.Mutex
+	days map[string]*day
+}
+
+type day struct {
+	start time.Time
+	used  int64
+	held  int64
+}
+
+// hold is what a request in flight holds of the budget of a day
+type hold struct {
+	start  time.Time
+	tokens int64
+}
+
+func newLedger(location *time.Location) *ledger {
+	return &ledger{location: location, days: map[string]*day{}}
+}
+
+// startOfDay is the midnight before now
+func (l *ledger) startOfDay(now time.Time) time.Time {
+	y, m, d := now.In(l.location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, l.location)
+}
+
+// today returns the day of the consumer, a day that ended is started again
+func (l *ledger) today(consumer string, now time.Time) *day {
+	start := l.startOfDay(now)
+	d, ok := l.days[consumer]
+	if !ok || !d.start.Equal(start) {
+		d = &day{start: start}
+		l.days[consumer] = d
+	}
+	return d
+}
+
+// used returns the tokens the consumer used today, and when the count resets
+func (l *ledger) used(consumer string, now time.Time) (int64, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := l.today(consumer, now)
+	return d.used, d.start.AddDate(0, 0, 1)
+}
+
+// reserve holds an estimate of the tokens of a request, clipped to what is left of the limit, until
+// the request is settled. It fails when the tokens used and held reach the limit, and returns them
+// with when the count resets.
+func (l *ledger) reserve(consumer string, estimate, limit int64, now time.Time) (*hold, int64, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := l.today(consumer, now)
+	taken := d.used + d.held
+	if taken >= limit {
+		return nil, taken, d.start.AddDate(0, 0, 1), false
+	}
+	h := &hold{start: d.start, tokens: min(estimate, limit-taken)}
+	d.held += h.tokens
+	return h, taken, d.start.AddDate(0, 0, 1), true
+}
+
+// settle counts the tokens a request used and lets go of what it held, the hold of a day that ended
+// went with it. h is nil for the consumers without a limit.
+func (l *ledger) settle(consumer string, h *hold, tokens int64, now time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := l.today(consumer, now)
+	if h != nil && h.start.Equal(d.start) {
+		d.held -= h.tokens
+	}
+	d.used += tokens
+	return d.used
+}
+
+// consumers returns the consumers with a count, sorted
+func (l *ledger) consumers() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.days))
+	for name := range l.days {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
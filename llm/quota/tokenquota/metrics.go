@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenquota
+
+import (
+	"context"
+)
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// metrics of the consumers, exported on the prometheus port of pixiu. The exporter of pixiu adds no
+// suffix, the counters are named with _total like the other llm metrics.
+type metrics struct {
+	tokens   syncint64.Counter
+	cost     syncfloat64.Counter
+	rejected syncint64.Counter
+}
+
+func registerMetrics(factory *FilterFactory) (*metrics, error) {
+	meter := global.MeterProvider().Meter("pixiu")
+	m := &metrics{}
+	var err error
+	if m.tokens, err = meter.SyncInt64().Counter("pixiu_llm_consumer_tokens_total",
+		instrument.WithDescription("tokens used by a consumer, by model and type prompt or completion")); err != nil {
+		return nil, err
+	}
+	if m.cost, err = meter.SyncFloat64().Counter("pixiu_llm_consumer_cost_total",
+		instrument.WithDescription("cost of the tokens used by a consumer, by model, in the currency of the prices")); err != nil {
+		return nil, err
+	}
+	if m.rejected, err = meter.SyncInt64().Counter("pixiu_llm_consumer_rejected_requests_total",
+		instrument.WithDescription("requests rejected by reason, quota or unknown_key")); err != nil {
+		return nil, err
+	}
+	remaining, err := meter.AsyncInt64().Gauge("pixiu_llm_consumer_quota_remaining_tokens",
+		instrument.WithDescription("tokens a consumer may still use today"))
+	if err != nil {
+		return nil, err
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{remaining}, func(ctx context.Context) {
+		now := factory.now()
+		for _, consumer := range factory.consumers {
+			if consumer.DailyTokens > 0 {
+				used, _ := factory.ledger.used(consumer.Name, now)
+				remaining.Observe(ctx, max(consumer.DailyTokens-used, 0), attribute.String("consumer", consumer.Name))
+			}
+		}
+	})
+	return m, err
+}
+
+func (m *metrics) record(ctx context.Context, consumer string, u usage, price *Price) {
+	attrs := []attribute.KeyValue{attribute.String("consumer", consumer), attribute.String("model", u.Model)}
+	m.tokens.Add(ctx, u.PromptTokens, append(attrs, attribute.String("type", "prompt"))...)
+	m.tokens.Add(ctx, u.CompletionTokens, append(attrs, attribute.String("type", "completion"))...)
+	if price != nil {
+		m.cost.Add(ctx, price.cost(u), attrs...)
+	}
+}
+
+func (m *metrics) reject(ctx context.Context, consumer, reason string) {
+	m.rejected.Add(ctx, 1, attribute.String("consumer", consumer), attribute.String("reason", reason))
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenquota
+
+import (
+	"encoding/json"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+// usage is what a completion reports in its usage field
+type usage struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+type completion struct {
+	Model string `json:"model"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// merge takes the model and the usage of a completion or of a chunk, the usage of a stream comes
+// with its last chunk
+func (u *usage) merge(data []byte) bool {
+	var c completion
+	if json.Unmarshal(data, &c) != nil {
+		return false
+	}
+	if c.Model != "" {
+		u.Model = c.Model
+	}
+	if c.Usage == nil {
+		return false
+	}
+	u.PromptTokens = c.Usage.PromptTokens
+	u.CompletionTokens = c.Usage.CompletionTokens
+	return true
+}
+
+// parseUsage reads the usage of a json completion or of a server sent event stream
+func parseUsage(body []byte) (usage, bool) {
+	var u usage
+	if llmfilter.IsJSON(body) {
+		return u, u.merge(body)
+	}
+	found := false
+	llmfilter.Events(body, func(data []byte) bool {
+		if u.merge(data) {
+			found = true
+		}
+		return true
+	})
+	return u, found
+}
+
+// usageOnly tells whether a chunk of a stream only carries the usage, the one include_usage adds
+func usageOnly(data []byte) bool {
+	var c struct {
+		Choices []json.RawMessage `json:"choices"`
+		Usage   json.RawMessage   `json:"usage"`
+	}
+	return json.Unmarshal(data, &c) == nil && len(c.Choices) == 0 && len(c.Usage) > 0 && string(c.Usage) != "null"
+}
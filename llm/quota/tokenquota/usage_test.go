@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenquota
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUsage(t *testing.T) {
+	testCases := []struct {
+		name  string
+		body  string
+		usage usage
+		found bool
+	}{
+		{
+			name:  "json",
+			body:  `{"model":"deepseek-chat","usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`,
+			usage: usage{Model: "deepseek-chat", PromptTokens: 3, CompletionTokens: 4},
+			found: true,
+		},
+		{
+			name: "stream",
+			body: "data: {\"model\":\"deepseek-reasoner\",\"choices\":[]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":1}}\n\ndata: [DONE]\n\n",
+			usage: usage{Model: "deepseek-reasoner", PromptTokens: 9, CompletionTokens: 1},
+			found: true,
+		},
+		{
+			name:  "stream without usage",
+			body:  "data: {\"model\":\"deepseek-chat\",\"choices\":[]}\n\ndata: [DONE]\n\n",
+			usage: usage{Model: "deepseek-chat"},
+		},
+		{name: "error", body: `{"error":{"message":"bad request"}}`},
+		{name: "empty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, found := parseUsage([]byte(tc.body))
+			assert.Equal(t, tc.found, found)
+			assert.Equal(t, tc.usage, u)
+		})
+	}
+}
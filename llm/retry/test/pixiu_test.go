@@ -0,0 +1,178 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const (
+	pixiuURL = "http://localhost:8888"
+	// retryTimes is the times of the CountBased retry policy of both endpoints,
+	// an endpoint gets the first attempt and as many retries
+	retryTimes = 3
+	attempts   = retryTimes + 1
+	// down fails an endpoint for the rest of a test
+	down  = 100
+	reply = "1+1 equals 2."
+)
+
+var (
+	primary  = llmmock.Client{URL: "http://localhost:8090"}
+	fallback = llmmock.Client{URL: "http://localhost:8091"}
+)
+
+func inject(t *testing.T, f llmmock.Fault, endpoints ...llmmock.Client) {
+	t.Helper()
+	f.Path = "/chat/completions"
+	for _, e := range endpoints {
+		require.NoError(t, e.Inject(f))
+	}
+}
+
+// chatStats returns the chat completions an endpoint received, and the ones that failed
+func chatStats(t *testing.T, endpoint llmmock.Client) (int, int) {
+	t.Helper()
+	stats, err := endpoint.Stats()
+	require.NoError(t, err)
+	return stats.Requests["/chat/completions"], stats.Failed["/chat/completions"]
+}
+
+func ask(t *testing.T) (*openai.ChatCompletion, error) {
+	t.Helper()
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	return client.Chat.Completions.New(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+}
+
+func stream(t *testing.T) (string, error) {
+	t.Helper()
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	s := client.Chat.Completions.NewStreaming(context.Background(), llmmock.Question("deepseek-chat", "1+1=?"))
+	var content strings.Builder
+	for s.Next() {
+		if chunk := s.Current(); len(chunk.Choices) > 0 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	return content.String(), s.Err()
+}
+
+func TestTransientFailureIsRetried(t *testing.T) {
+	llmmock.Reset(t, primary, fallback)
+	// whichever endpoint the request goes to fails twice, and answers the third attempt
+	inject(t, llmmock.Fault{Status: http.StatusTooManyRequests, Count: 2}, primary, fallback)
+
+	completion, err := ask(t)
+	require.NoError(t, err)
+	assert.Equal(t, reply, completion.Choices[0].Message.Content)
+
+	primaryRequests, primaryFailed := chatStats(t, primary)
+	fallbackRequests, fallbackFailed := chatStats(t, fallback)
+	assert.Equal(t, 3, primaryRequests+fallbackRequests)
+	assert.Equal(t, 2, primaryFailed+fallbackFailed)
+}
+
+func TestExhaustedRetriesFallBack(t *testing.T) {
+	llmmock.Reset(t, primary, fallback)
+	inject(t, llmmock.Fault{Status: http.StatusInternalServerError, Count: down}, primary)
+
+	// round robin sends one request to each endpoint first, both end up answered by the fallback
+	for i := 0; i < 2; i++ {
+		completion, err := ask(t)
+		require.NoError(t, err)
+		assert.Equal(t, reply, completion.Choices[0].Message.Content)
+	}
+
+	primaryRequests, primaryFailed := chatStats(t, primary)
+	assert.Equal(t, attempts, primaryRequests)
+	assert.Equal(t, attempts, primaryFailed)
+	fallbackRequests, fallbackFailed := chatStats(t, fallback)
+	assert.Equal(t, 2, fallbackRequests)
+	assert.Zero(t, fallbackFailed)
+}
+
+func TestNoHealthyEndpoint(t *testing.T) {
+	llmmock.Reset(t, primary, fallback)
+	inject(t, llmmock.Fault{Status: http.StatusServiceUnavailable, Count: down}, primary, fallback)
+
+	for i := 0; i < 2; i++ {
+		_, err := ask(t)
+		var apiErr *openai.Error
+		require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+		assert.GreaterOrEqual(t, apiErr.StatusCode, http.StatusInternalServerError)
+	}
+
+	// the request that starts on the primary falls back and retries again, the other one ends on the fallback
+	primaryRequests, _ := chatStats(t, primary)
+	assert.Equal(t, attempts, primaryRequests)
+	fallbackRequests, _ := chatStats(t, fallback)
+	assert.Equal(t, 2*attempts, fallbackRequests)
+}
+
+func TestStreamIsRetriedBeforeItStarts(t *testing.T) {
+	llmmock.Reset(t, primary, fallback)
+	inject(t, llmmock.Fault{Status: http.StatusBadGateway, Count: 1}, primary, fallback)
+
+	content, err := stream(t)
+	require.NoError(t, err)
+	assert.Equal(t, reply, content)
+
+	primaryRequests, _ := chatStats(t, primary)
+	fallbackRequests, _ := chatStats(t, fallback)
+	assert.Equal(t, 2, primaryRequests+fallbackRequests)
+}
+
+func TestInterruptedStreamIsNotRetried(t *testing.T) {
+	llmmock.Reset(t, primary, fallback)
+	// the role and the first word get through, then the llm goes away
+	inject(t, llmmock.Fault{Interrupt: 2, Count: 1}, primary, fallback)
+
+	content, err := stream(t)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(reply, content), "%q is not the start of the reply", content)
+	assert.NotEqual(t, reply, content)
+
+	// the client already has a part of the answer, pixiu cannot send it a second one
+	primaryRequests, _ := chatStats(t, primary)
+	fallbackRequests, _ := chatStats(t, fallback)
+	assert.Equal(t, 1, primaryRequests+fallbackRequests)
+
+	// once the fault of the other endpoint is dropped too, the next request is answered in full
+	require.NoError(t, primary.Reset())
+	require.NoError(t, fallback.Reset())
+	content, err = stream(t)
+	require.NoError(t, err)
+	assert.Equal(t, reply, content)
+}
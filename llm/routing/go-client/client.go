@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// route is a model the clients ask for, and the upstreams that may serve it with the model name
+// each upstream answers with
+type route struct {
+	model     string
+	upstreams map[string]string
+	// weights of the upstreams, the requests are split by them
+	weights map[string]int
+}
+
+// routes mirror the rules of the model router in pixiu/conf.yaml
+var routes = []route{
+	{model: "deepseek-chat", upstreams: map[string]string{"deepseek": "deepseek-chat"}},
+	{model: "deepseek-reasoner", upstreams: map[string]string{"deepseek": "deepseek-reasoner"}},
+	{model: "qwen2.5", upstreams: map[string]string{"local": "qwen2.5"}},
+	{model: "qwen2.5-coder", upstreams: map[string]string{"local": "qwen2.5-coder"}},
+	{
+		model:     "chat",
+		upstreams: map[string]string{"deepseek": "deepseek-chat", "local": "qwen2.5"},
+		weights:   map[string]int{"deepseek": 80, "local": 20},
+	},
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8888/", "base url of pixiu")
+	requests := flag.Int("requests", 10, "requests sent per model")
+	flag.Parse()
+
+	client := openai.NewClient(
+		option.WithBaseURL(*url),
+		option.WithAPIKey(os.Getenv("API_KEY")),
+		option.WithMaxRetries(0),
+	)
+
+	failed := false
+	for _, r := range routes {
+		served, err := ask(context.Background(), client, r, *requests)
+		if err != nil {
+			fmt.Printf("%-18s FAIL %v\n", r.model, err)
+			failed = true
+			continue
+		}
+		if err = r.check(served, *requests); err != nil {
+			fmt.Printf("%-18s FAIL %v\n", r.model, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%-18s ok   %s\n", r.model, summary(served, r))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// ask sends the requests of a route and counts the upstreams that answered them. The mocks name
+// themselves in the system_fingerprint, the model field is the model the upstream was asked for.
+func ask(ctx context.Context, client openai.Client, r route, requests int) (map[string]int, error) {
+	served := map[string]int{}
+	for i := 0; i < requests; i++ {
+		completion, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model:    r.model,
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("1+1=?")},
+		})
+		if err != nil {
+			return nil, err
+		}
+		upstream := completion.SystemFingerprint
+		model, ok := r.upstreams[upstream]
+		if !ok {
+			return nil, fmt.Errorf("answered by %q", upstream)
+		}
+		if completion.Model != model {
+			return nil, fmt.Errorf("%s was asked for %s instead of %s", upstream, completion.Model, model)
+		}
+		served[upstream]++
+	}
+	return served, nil
+}
+
+// check compares the split with the weights, a weighted round robin is off by one request at most
+func (r route) check(served map[string]int, requests int) error {
+	total := 0
+	for _, w := range r.weights {
+		total += w
+	}
+	for upstream, w := range r.weights {
+		want := requests * w / total
+		if got := served[upstream]; got < want-1 || got > want+1 {
+			return fmt.Errorf("%s served %d of %d requests, want %d", upstream, got, requests, want)
+		}
+	}
+	return nil
+}
+
+func summary(served map[string]int, r route) string {
+	upstreams := make([]string, 0, len(served))
+	for upstream, n := range served {
+		upstreams = append(upstreams, fmt.Sprintf("%s %d (%s)", upstream, n, r.upstreams[upstream]))
+	}
+	sort.Strings(upstreams)
+	return strings.Join(upstreams, ", ")
+}
@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package modelrouter is the dgp.filter.llm.modelrouter http filter. It routes an OpenAI request to a
+// cluster by the model field of its body, which the pixiu routes cannot match, and splits a model
+// between the clusters of several providers by weight.
+package modelrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/internal/llmfilter"
+)
+
+const (
+	// Kind is the kind of the filter
+	Kind = "dgp.filter.llm.modelrouter"
+)
+
+func init() {
+	filter.RegisterHttpFilter(&Plugin{})
+}
+
+type (
+	// Plugin is the model router plugin
+	Plugin struct {
+	}
+
+	// FilterFactory holds the rules and the weights served so far
+	FilterFactory struct {
+		cfg   *Config
+		rules []*rule
+	}
+
+	// Filter routes a request
+	Filter struct {
+		rules []*rule
+	}
+
+	// Config is the config of the filter
+	Config struct {
+		Rules []*Rule `yaml:"rules" json:"rules" mapstructure:"rules"`
+	}
+
+	// Rule routes the requests of a model, the first rule that matches is used
+	Rule struct {
+		// Model is matched with the model field, a trailing * matches a prefix
+		Model   string    `yaml:"model" json:"model" mapstructure:"model"`
+		Targets []*Target `yaml:"targets" json:"targets" mapstructure:"targets"`
+	}
+
+	// Target is a cluster that serves a model
+	Target struct {
+		Cluster string `yaml:"cluster" json:"cluster" mapstructure:"cluster"`
+		// Weight among the targets of the rule, 1 when 0
+		Weight int `yaml:"weight" json:"weight" mapstructure:"weight"`
+		// Model replaces the model field, so an alias is served by the model name of each provider
+		Model string `yaml:"model" json:"model" mapstructure:"model"`
+	}
+
+	// rule spreads the requests with a smooth weighted round robin, 80 and 20 send exactly 8 of
+	// 10 requests to the first target, interleaved with the other 2
+	rule struct {
+		*Rule
+		total int
+
+		mu      sync.Mutex
+		current []int
+	}
+)
+
+func (p *Plugin) Kind() string {
+	return Kind
+}
+
+func (p *Plugin) CreateFilterFactory() (filter.HttpFilterFactory, error) {
+	return &FilterFactory{cfg: &Config{}}, nil
+}
+
+func (factory *FilterFactory) Config() interface{} {
+	return factory.cfg
+}
+
+func (factory *FilterFactory) Apply() error {
+	rules := make([]*rule, 0, len(factory.cfg.Rules))
+	for i, r := range factory.cfg.Rules {
+		if r.Model == "" {
+			return fmt.Errorf("%s: rule %d has no model", Kind, i)
+		}
+		if len(r.Targets) == 0 {
+			return fmt.Errorf("%s: the rule of model %s has no targets", Kind, r.Model)
+		}
+		total := 0
+		for _, t := range r.Targets {
+			if t.Cluster == "" {
+				return fmt.Errorf("%s: a target of model %s has no cluster", Kind, r.Model)
+			}
+			if t.Weight < 0 {
+				return fmt.Errorf("%s: the weight of cluster %s for model %s is negative", Kind, t.Cluster, r.Model)
+			}
+			if t.Weight == 0 {
+				t.Weight = 1
+			}
+			total += t.Weight
+		}
+		rules = append(rules, &rule{Rule: r, total: total, current: make([]int, len(r.Targets))})
+	}
+	factory.rules = rules
+	return nil
+}
+
+func (factory *FilterFactory) PrepareFilterChain(ctx *contexthttp.HttpContext, chain filter.FilterChain) error {
+	chain.AppendDecodeFilters(&Filter{rules: factory.rules})
+	return nil
+}
+
+// Decode sets the cluster of the request. A request without a json body, or whose model no rule
+// matches, keeps the cluster of its route.
+func (f *Filter) Decode(c *contexthttp.HttpContext) filter.FilterStatus {
+	if c.Request.Body == nil || c.GetRouteEntry() == nil {
+		return filter.Continue
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	if err != nil {
+		logger.Warnf("[dubbo-go-pixiu] %s: read body: %v", Kind, err)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return filter.Continue
+	}
+	defer func() {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+	}()
+
+	var fields map[string]json.RawMessage
+	var model string
+	if json.Unmarshal(body, &fields) != nil || json.Unmarshal(fields["model"], &model) != nil {
+		return filter.Continue
+	}
+	r := f.match(model)
+	if r == nil {
+		return filter.Continue
+	}
+	target := r.next()
+
+	// the route action is shared by every request of the route, so it is copied
+	route := *c.GetRouteEntry()
+	route.Cluster = target.Cluster
+	c.RouteEntry(&route)
+	logger.Debugf("[dubbo-go-pixiu] %s: model %s routed to cluster %s", Kind, model, target.Cluster)
+
+	if target.Model != "" && target.Model != model {
+		fields["model"], _ = json.Marshal(target.Model)
+		if rewritten, err := json.Marshal(fields); err == nil {
+			body = rewritten
+		}
+	}
+	return filter.Continue
+}
+
+func (f *Filter) match(model string) *rule {
+	for _, r := range f.rules {
+		if llmfilter.MatchModel(r.Model, model) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *rule) next() *Target {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best := 0
+	for i, t := range r.Targets {
+		r.current[i] += t.Weight
+		if r.current[i] > r.current[best] {
+			best = i
+		}
+	}
+	r.current[best] -= r.total
+	return r.Targets[best]
+}
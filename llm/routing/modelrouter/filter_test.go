@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package modelrouter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/context/mock"
+	"github.com/apache/dubbo-go-pixiu/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFilter(t *testing.T, rules ...*Rule) *Filter {
+	factory := &FilterFactory{cfg: &Config{Rules: rules}}
+	require.NoError(t, factory.Apply())
+	return &Filter{rules: factory.rules}
+}
+
+// route decodes a chat request for the model and returns the cluster and the body sent upstream
+func route(t *testing.T, f *Filter, body string) (string, map[string]interface{}) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8888/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	c := mock.GetMockHTTPContext(req)
+	shared := &model.RouteAction{Cluster: "default"}
+	c.RouteEntry(shared)
+
+	f.Decode(c)
+	assert.Equal(t, "default", shared.Cluster, "the route of the config must not change")
+	data, err := io.ReadAll(c.Request.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), c.Request.ContentLength)
+	var sent map[string]interface{}
+	_ = json.Unmarshal(data, &sent)
+	return c.GetRouteEntry().Cluster, sent
+}
+
+func TestDecode(t *testing.T) {
+	f := newFilter(t,
+		&Rule{Model: "deepseek-*", Targets: []*Target{{Cluster: "deepseek"}}},
+		&Rule{Model: "qwen2.5", Targets: []*Target{{Cluster: "local"}}},
+		&Rule{Model: "chat", Targets: []*Target{{Cluster: "local", Model: "qwen2.5"}}},
+	)
+
+	testCases := []struct {
+		name    string
+		body    string
+		cluster string
+		model   interface{}
+	}{
+		{name: "prefix", body: `{"model":"deepseek-reasoner","stream":true}`, cluster: "deepseek", model: "deepseek-reasoner"},
+		{name: "exact", body: `{"model":"qwen2.5"}`, cluster: "local", model: "qwen2.5"},
+		{name: "alias", body: `{"model":"chat","stream":true}`, cluster: "local", model: "qwen2.5"},
+		{name: "unknown model", body: `{"model":"gpt-4o"}`, cluster: "default", model: "gpt-4o"},
+		{name: "no model", body: `{"messages":[]}`, cluster: "default"},
+		{name: "not json", body: `model=chat`, cluster: "default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster, sent := route(t, f, tc.body)
+			assert.Equal(t, tc.cluster, cluster)
+			assert.Equal(t, tc.model, sent["model"])
+		})
+	}
+
+	_, sent := route(t, f, `{"model":"chat","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, true, sent["stream"], "the other fields are kept")
+	assert.Len(t, sent["messages"], 1)
+}
+
+func TestDecodeWithoutBody(t *testing.T) {
+	f := newFilter(t, &Rule{Model: "*", Targets: []*Target{{Cluster: "local"}}})
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8888/models", nil)
+	require.NoError(t, err)
+	c := mock.GetMockHTTPContext(req)
+	c.RouteEntry(&model.RouteAction{Cluster: "default"})
+
+	f.Decode(c)
+	assert.Equal(t, "default", c.GetRouteEntry().Cluster)
+}
+
+func TestWeightedSplit(t *testing.T) {
+	f := newFilter(t, &Rule{Model: "chat", Targets: []*Target{
+		{Cluster: "deepseek", Weight: 80, Model: "deepseek-chat"},
+		{Cluster: "local", Weight: 20, Model: "qwen2.5"},
+	}})
+
+	var clusters []string
+	counts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		cluster, sent := route(t, f, `{"model":"chat"}`)
+		clusters = append(clusters, cluster)
+		counts[cluster]++
+		assert.Equal(t, map[string]string{"deepseek": "deepseek-chat", "local": "qwen2.5"}[cluster], sent["model"])
+	}
+	assert.Equal(t, map[string]int{"deepseek": 8, "local": 2}, counts)
+	assert.NotEqual(t, "local", clusters[0], "the heavier target is served first")
+	assert.NotEqual(t, []string{"local", "local"}, clusters[8:], "the targets are interleaved")
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []*Rule
+		err   string
+	}{
+		{name: "no model", rules: []*Rule{{Targets: []*Target{{Cluster: "local"}}}}, err: "rule 0 has no model"},
+		{name: "no targets", rules: []*Rule{{Model: "chat"}}, err: "has no targets"},
+		{name: "no cluster", rules: []*Rule{{Model: "chat", Targets: []*Target{{Weight: 1}}}}, err: "has no cluster"},
+		{name: "negative weight", rules: []*Rule{{Model: "chat", Targets: []*Target{{Cluster: "local", Weight: -1}}}}, err: "negative"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			factory := &FilterFactory{cfg: &Config{Rules: tc.rules}}
+			assert.ErrorContains(t, factory.Apply(), tc.err)
+		})
+	}
+
+	factory := &FilterFactory{cfg: &Config{Rules: []*Rule{{Model: "chat", Targets: []*Target{{Cluster: "a"}, {Cluster: "b", Weight: 3}}}}}}
+	require.NoError(t, factory.Apply())
+	assert.Equal(t, 1, factory.cfg.Rules[0].Targets[0].Weight, "a missing weight is 1")
+	assert.Equal(t, 4, factory.rules[0].total)
+}
+
+func TestPlugin(t *testing.T) {
+	p := &Plugin{}
+	assert.Equal(t, Kind, p.Kind())
+	factory, err := p.CreateFilterFactory()
+	require.NoError(t, err)
+	assert.IsType(t, &Config{}, factory.Config())
+}
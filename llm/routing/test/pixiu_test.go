@@ -0,0 +1,158 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const (
+	pixiuURL   = "http://localhost:8888"
+	metricsURL = "http://localhost:2222/"
+)
+
+var (
+	deepseek = llmmock.Client{URL: "http://localhost:8090"}
+	local    = llmmock.Client{URL: "http://localhost:8091"}
+)
+
+func chatRequests(t *testing.T, upstream llmmock.Client) int {
+	t.Helper()
+	stats, err := upstream.Stats()
+	require.NoError(t, err)
+	return stats.Requests["/chat/completions"]
+}
+
+func TestRouteByModel(t *testing.T) {
+	testCases := []struct {
+		model    string
+		upstream string
+		reply    string
+	}{
+		{model: "deepseek-chat", upstream: "deepseek", reply: "1+1 equals 2."},
+		{model: "deepseek-reasoner", upstream: "deepseek", reply: "1+1 equals 2."},
+		{model: "qwen2.5", upstream: "local", reply: "1+1 equals 2, answered on premises."},
+		{model: "qwen2.5-coder", upstream: "local", reply: "1+1 equals 2, answered on premises."},
+	}
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	for _, tc := range testCases {
+		t.Run(tc.model, func(t *testing.T) {
+			llmmock.Reset(t, deepseek, local)
+			completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question(tc.model, "1+1=?"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.upstream, completion.SystemFingerprint)
+			assert.Equal(t, tc.model, completion.Model, "a model without alias is sent as is")
+			assert.Equal(t, tc.reply, completion.Choices[0].Message.Content)
+
+			want := map[string]int{"deepseek": 0, "local": 0}
+			want[tc.upstream] = 1
+			assert.Equal(t, want, map[string]int{"deepseek": chatRequests(t, deepseek), "local": chatRequests(t, local)})
+		})
+	}
+}
+
+func TestAliasIsSplitByWeight(t *testing.T) {
+	llmmock.Reset(t, deepseek, local)
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+
+	// 80 and 20 repeat every 5 requests, so any 10 requests in a row are split 8 to 2
+	served := map[string]int{}
+	for i := 0; i < 10; i++ {
+		completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question("chat", "1+1=?"))
+		require.NoError(t, err)
+		served[completion.SystemFingerprint]++
+		// each provider is asked for its own name of the model
+		assert.Equal(t, map[string]string{"deepseek": "deepseek-chat", "local": "qwen2.5"}[completion.SystemFingerprint], completion.Model)
+	}
+	assert.Equal(t, map[string]int{"deepseek": 8, "local": 2}, served)
+	assert.Equal(t, 8, chatRequests(t, deepseek))
+	assert.Equal(t, 2, chatRequests(t, local))
+}
+
+func TestStreamIsRouted(t *testing.T) {
+	llmmock.Reset(t, deepseek, local)
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	stream := client.Chat.Completions.NewStreaming(context.Background(), llmmock.Question("qwen2.5", "1+1=?"))
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		assert.Equal(t, "local", chunk.SystemFingerprint)
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "1+1 equals 2, answered on premises.", acc.Choices[0].Message.Content)
+	assert.Zero(t, chatRequests(t, deepseek))
+}
+
+func TestUnknownModelUsesRouteCluster(t *testing.T) {
+	llmmock.Reset(t, deepseek, local)
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	_, err := client.Chat.Completions.New(context.Background(), llmmock.Question("gpt-4o", "1+1=?"))
+
+	// no rule matches, the deepseek cluster of the route gets the request and does not know the model
+	var apiErr *openai.Error
+	require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, 1, chatRequests(t, deepseek))
+	assert.Zero(t, chatRequests(t, local))
+}
+
+func TestTokenizerMetricsPerCluster(t *testing.T) {
+	clusters := map[string]map[string]string{
+		"deepseek": {"cluster_name": "deepseek"},
+		"local":    {"cluster_name": "local"},
+	}
+	before := map[string]float64{}
+	for name, labels := range clusters {
+		before[name] = testkit.ScrapeMetric(t, metricsURL, "pixiu_llm_completion_tokens_total", labels)
+	}
+
+	client := llmmock.NewClient(pixiuURL, "sk-mock")
+	used := map[string]int64{}
+	for _, model := range []string{"deepseek-chat", "qwen2.5"} {
+		completion, err := client.Chat.Completions.New(context.Background(), llmmock.Question(model, "1+1=?"))
+		require.NoError(t, err)
+		used[completion.SystemFingerprint] += completion.Usage.CompletionTokens
+	}
+
+	// the usage is counted for the cluster the model router picked, not the one of the route
+	testkit.Eventually(t, func(c *assert.CollectT) {
+		for name, labels := range clusters {
+			assert.Equal(c, before[name]+float64(used[name]),
+				testkit.ScrapeMetric(c, metricsURL, "pixiu_llm_completion_tokens_total", labels))
+		}
+	}, 10*time.Second)
+}
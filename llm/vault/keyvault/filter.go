@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keyvault is the dgp.filter.llm.keyvault http filter. It sets the api key of the cluster
+// a request is routed to, resolved from a reference to a secret or from a sealed value, so that
+// neither the clients nor the registry hold the key of the provider.
+package keyvault
+
+import (
+	"fmt"
+	"sort"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/extension/filter"
+	contexthttp "github.com/apache/dubbo-go-pixiu/pkg/context/http"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+)
+
+const (
+	// Kind is the kind of the filter
+	Kind = "dgp.filter.llm.keyvault"
+)
+
+func init() {
+	filter.RegisterHttpFilter(&Plugin{})
+}
+
+type (
+	// Plugin is the key vault plugin
+	Plugin struct {
+	}
+
+	// FilterFactory holds the resolved authorization of each cluster
+	FilterFactory struct {
+		cfg            *Config
+		authorizations map[string]string
+	}
+
+	// Filter sets the api key of a request
+	Filter struct {
+		authorizations map[string]string
+	}
+
+	// Config is the config of the filter
+	Config struct {
+		// SecretsFile is a yaml map of the secrets by name
+		SecretsFile string `yaml:"secrets_file" json:"secrets_file" mapstructure:"secrets_file"`
+		// EnvPrefix of the environment variables of the secrets, LLM_SECRET_ when empty
+		EnvPrefix string `yaml:"env_prefix" json:"env_prefix" mapstructure:"env_prefix"`
+		// KeyEnv is the environment variable of the key of the sealed values, LLM_VAULT_KEY when empty
+		KeyEnv string `yaml:"key_env" json:"key_env" mapstructure:"key_env"`
+		// Clusters maps a cluster to the secret:<name> or enc:v1: reference of its api key. It is the only
+		// source of the keys, the metadata of the endpoints the registry publishes is not read.
+		Clusters map[string]string `yaml:"clusters" json:"clusters" mapstructure:"clusters"`
+	}
+)
+
+func (p *Plugin) Kind() string {
+	return Kind
+}
+
+func (p *Plugin) CreateFilterFactory() (filter.HttpFilterFactory, error) {
+	return &FilterFactory{cfg: &Config{}}, nil
+}
+
+func (factory *FilterFactory) Config() interface{} {
+	return factory.cfg
+}
+
+// Apply resolves every reference, so a missing secret stops pixiu instead of failing the requests
+func (factory *FilterFactory) Apply() error {
+	vault, err := NewVault(factory.cfg.SecretsFile, factory.cfg.EnvPrefix, factory.cfg.KeyEnv)
+	if err != nil {
+		return fmt.Errorf("%s: %w", Kind, err)
+	}
+	clusters := make([]string, 0, len(factory.cfg.Clusters))
+	for cluster := range factory.cfg.Clusters {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+
+	authorizations := make(map[string]string, len(clusters))
+	for _, cluster := range clusters {
+		key, err := vault.Resolve(factory.cfg.Clusters[cluster])
+		if err != nil {
+			return fmt.Errorf("%s: the api key of cluster %s: %w", Kind, cluster, err)
+		}
+		authorizations[cluster] = "Bearer " + key
+	}
+	factory.authorizations = authorizations
+	return nil
+}
+
+func (factory *FilterFactory) PrepareFilterChain(ctx *contexthttp.HttpContext, chain filter.FilterChain) error {
+	chain.AppendDecodeFilters(&Filter{authorizations: factory.authorizations})
+	return nil
+}
+
+// Decode replaces the authorization of a request to a cluster of the config, the other requests
+// are passed on unchanged. It runs after the filters that choose the cluster.
+func (f *Filter) Decode(c *contexthttp.HttpContext) filter.FilterStatus {
+	route := c.GetRouteEntry()
+	if route == nil {
+		return filter.Continue
+	}
+	if authorization, ok := f.authorizations[route.Cluster]; ok {
+		c.Request.Header.Set("Authorization", authorization)
+		logger.Debugf("[dubbo-go-pixiu] %s: api key of cluster %s set", Kind, route.Cluster)
+	}
+	return filter.Continue
+}
@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyvault
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/context/mock"
+	"github.com/apache/dubbo-go-pixiu/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorization decodes a request to the cluster and returns the authorization sent upstream
+func authorization(t *testing.T, f *Filter, cluster, client string) string {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8888/chat/completions", strings.NewReader(`{"model":"chat"}`))
+	require.NoError(t, err)
+	if client != "" {
+		req.Header.Set("Authorization", client)
+	}
+	c := mock.GetMockHTTPContext(req)
+	c.RouteEntry(&model.RouteAction{Cluster: cluster})
+
+	f.Decode(c)
+	return c.Request.Header.Get("Authorization")
+}
+
+func TestDecode(t *testing.T) {
+	t.Setenv(DefaultKeyEnv, testKey)
+	factory := &FilterFactory{cfg: &Config{
+		SecretsFile: writeSecrets(t, "mock: sk-from-file\n"),
+		Clusters: map[string]string{
+			"chat":   "secret:mock",
+			"sealed": "enc:v1:AAECAwQFBgcICQoLXo6WEbZ7uYHDuQglmkwoFd0CTo3MfIRc1H5L",
+		},
+	}}
+	require.NoError(t, factory.Apply())
+	f := &Filter{authorizations: factory.authorizations}
+
+	assert.Equal(t, "Bearer sk-from-file", authorization(t, f, "chat", ""))
+	assert.Equal(t, "Bearer sk-from-file", authorization(t, f, "chat", "Bearer sk-of-the-client"), "the key of the client is replaced")
+	assert.Equal(t, "Bearer sk-mock-key", authorization(t, f, "sealed", ""))
+	assert.Equal(t, "Bearer sk-of-the-client", authorization(t, f, "other", "Bearer sk-of-the-client"))
+	assert.Empty(t, authorization(t, f, "other", ""))
+}
+
+func TestApply(t *testing.T) {
+	factory := &FilterFactory{cfg: &Config{Clusters: map[string]string{"chat": "secret:missing"}}}
+	assert.EqualError(t, factory.Apply(), Kind+": the api key of cluster chat: secret missing is neither in the secrets file nor in $LLM_SECRET_MISSING")
+
+	factory = &FilterFactory{cfg: &Config{Clusters: map[string]string{"chat": "sk-plain"}}}
+	assert.ErrorContains(t, factory.Apply(), "is not a secret: or enc:v1: reference", "plain keys are refused")
+}
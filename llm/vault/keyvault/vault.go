@@ -0,0 +1,179 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyvault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SecretPrefix references a secret by name, secret:deepseek
+	SecretPrefix = "secret:"
+	// SealedPrefix is a value encrypted with the vault key, enc:v1:<base64 of the nonce and the ciphertext>
+	SealedPrefix = "enc:v1:"
+
+	// DefaultEnvPrefix is prepended to the upper cased name of a secret to look it up in the environment
+	DefaultEnvPrefix = "LLM_SECRET_"
+	// DefaultKeyEnv is the environment variable of the base64 AES-256 key of the sealed values
+	DefaultKeyEnv = "LLM_VAULT_KEY"
+)
+
+// Vault resolves the references to api keys, so the keys are neither written in the configs
+// nor published in a registry
+type Vault struct {
+	// secrets by name, read from the secrets file
+	secrets   map[string]string
+	envPrefix string
+	// key opens the sealed values, nil when the vault has none
+	key []byte
+}
+
+// NewVault reads the secrets file, which is a yaml map of the secrets by name, and the key of the
+// sealed values from the environment variable keyEnv. Both are optional.
+func NewVault(secretsFile, envPrefix, keyEnv string) (*Vault, error) {
+	v := &Vault{secrets: map[string]string{}, envPrefix: envPrefix}
+	if v.envPrefix == "" {
+		v.envPrefix = DefaultEnvPrefix
+	}
+	if secretsFile != "" {
+		data, err := os.ReadFile(secretsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secrets: %w", err)
+		}
+		if err = yaml.Unmarshal(data, &v.secrets); err != nil {
+			return nil, fmt.Errorf("parse secrets %s: %w", secretsFile, err)
+		}
+	}
+	if keyEnv == "" {
+		keyEnv = DefaultKeyEnv
+	}
+	if encoded := os.Getenv(keyEnv); encoded != "" {
+		key, err := ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyEnv, err)
+		}
+		v.key = key
+	}
+	return v, nil
+}
+
+// Resolve returns the api key a reference stands for. A secret is looked up in the environment
+// first, so a deployment can override the secrets file.
+func (v *Vault) Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretPrefix):
+		name := strings.TrimPrefix(ref, SecretPrefix)
+		if name == "" {
+			return "", errors.New("the secret has no name")
+		}
+		if value := os.Getenv(EnvName(v.envPrefix, name)); value != "" {
+			return value, nil
+		}
+		if value := v.secrets[name]; value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("secret %s is neither in the secrets file nor in $%s", name, EnvName(v.envPrefix, name))
+	case strings.HasPrefix(ref, SealedPrefix):
+		if v.key == nil {
+			return "", errors.New("a sealed value needs the vault key")
+		}
+		return Open(v.key, ref)
+	default:
+		return "", fmt.Errorf("%q is not a %s or %s reference", mask(ref), SecretPrefix, SealedPrefix)
+	}
+}
+
+// EnvName is the environment variable of a secret, deepseek-chat is LLM_SECRET_DEEPSEEK_CHAT
+func EnvName(prefix, name string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(name))
+}
+
+// ParseKey decodes a base64 AES-256 key, openssl rand -base64 32 generates one
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("the vault key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the vault key has %d bytes, want 32", len(key))
+	}
+	return key, nil
+}
+
+// Seal encrypts a value with AES-256-GCM
+func Seal(key []byte, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return SealedPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value
+func Open(key []byte, sealed string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(sealed, SealedPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("the sealed value is malformed")
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("the sealed value does not open with the vault key")
+	}
+	return string(value), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// mask keeps a plain api key out of the errors and logs
+func mask(value string) string {
+	if len(value) <= 3 {
+		return "***"
+	}
+	return value[:3] + "***"
+}
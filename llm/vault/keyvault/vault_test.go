@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyvault
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey is the key of the sealed values of the tests and of the llm/vault sample
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func writeSecrets(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv(DefaultKeyEnv, testKey)
+	t.Setenv("LLM_SECRET_DEEPSEEK_CHAT", "sk-from-env")
+	vault, err := NewVault(writeSecrets(t, "mock: sk-from-file\ndeepseek-chat: sk-overridden\n"), "", "")
+	require.NoError(t, err)
+
+	key, err := ParseKey(testKey)
+	require.NoError(t, err)
+	sealed, err := Seal(key, "sk-sealed")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, SealedPrefix))
+	assert.NotContains(t, sealed, "sk-sealed")
+
+	testCases := []struct {
+		ref   string
+		value string
+		err   string
+	}{
+		{ref: "secret:mock", value: "sk-from-file"},
+		{ref: "secret:deepseek-chat", value: "sk-from-env"},
+		{ref: sealed, value: "sk-sealed"},
+		{ref: "secret:qwen", err: "secret qwen is neither in the secrets file nor in $LLM_SECRET_QWEN"},
+		{ref: "secret:", err: "the secret has no name"},
+		{ref: "enc:v1:MDEyMzQ1Njc4OWFiY2RlZmdoaWprbG1ub3BxcnN0dXY", err: "does not open with the vault key"},
+		{ref: "enc:v1:***", err: "the sealed value is malformed"},
+		{ref: "sk-plain-key", err: `"sk-***" is not a secret: or enc:v1: reference`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			value, err := vault.Resolve(tc.ref)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.value, value)
+		})
+	}
+}
+
+// TestOpen opens a value sealed by the registry tool of llm/nacos, which implements the format on its own
+func TestOpen(t *testing.T) {
+	key, err := ParseKey(testKey)
+	require.NoError(t, err)
+	value, err := Open(key, "enc:v1:AAECAwQFBgcICQoLXo6WEbZ7uYHDuQglmkwoFd0CTo3MfIRc1H5L")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-mock-key", value)
+}
+
+func TestVaultKey(t *testing.T) {
+	_, err := NewVault("", "", "")
+	require.NoError(t, err, "the key is only needed by sealed values")
+
+	vault, err := NewVault("", "", "")
+	require.NoError(t, err)
+	_, err = vault.Resolve("enc:v1:AAAA")
+	assert.ErrorContains(t, err, "a sealed value needs the vault key")
+
+	t.Setenv("SHORT_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+	_, err = NewVault("", "", "SHORT_KEY")
+	assert.ErrorContains(t, err, "SHORT_KEY: the vault key has 5 bytes, want 32")
+
+	_, err = NewVault(filepath.Join(t.TempDir(), "missing.yaml"), "", "")
+	assert.ErrorContains(t, err, "read secrets")
+}
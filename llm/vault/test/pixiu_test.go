@@ -0,0 +1,103 @@
+//go:build integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+import (
+	"github.com/openai/openai-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/llmmock"
+)
+
+const pixiuURL = "http://localhost:8888"
+
+var (
+	file   = llmmock.Client{URL: "http://localhost:8090"}
+	sealed = llmmock.Client{URL: "http://localhost:8091"}
+)
+
+func chat(baseURL, apiKey, model string) (*openai.ChatCompletion, error) {
+	client := llmmock.NewClient(baseURL, apiKey)
+	return client.Chat.Completions.New(context.Background(), llmmock.Question(model, "1+1=?"))
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var apiErr *openai.Error
+	require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+	assert.Equal(t, status, apiErr.StatusCode)
+}
+
+func TestUpstreamsRequireTheirKey(t *testing.T) {
+	llmmock.Reset(t, file, sealed)
+	// the key of the clients is not the one of the providers
+	_, err := chat(file.URL, "sk-of-the-client", "deepseek-chat")
+	assertStatus(t, err, http.StatusUnauthorized)
+	_, err = chat(sealed.URL, "sk-of-the-client", "sealed-chat")
+	assertStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestGatewayResolvesTheReference(t *testing.T) {
+	testCases := []struct {
+		name     string
+		model    string
+		upstream string
+	}{
+		{name: "secrets file", model: "deepseek-chat", upstream: "file"},
+		{name: "sealed value", model: "sealed-chat", upstream: "sealed"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			llmmock.Reset(t, file, sealed)
+			completion, err := chat(pixiuURL, "sk-of-the-client", tc.model)
+			require.NoError(t, err, "pixiu replaces the key of the client with the resolved one")
+			assert.Equal(t, tc.upstream, completion.SystemFingerprint)
+			assert.Equal(t, "1+1 equals 2.", completion.Choices[0].Message.Content)
+		})
+	}
+}
+
+func TestStreamWithResolvedKey(t *testing.T) {
+	llmmock.Reset(t, file, sealed)
+	client := llmmock.NewClient(pixiuURL, "sk-of-the-client")
+	stream := client.Chat.Completions.NewStreaming(context.Background(), llmmock.Question("sealed-chat", "1+1=?"))
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "1+1 equals 2.", acc.Choices[0].Message.Content)
+
+	stats, err := sealed.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Requests["/chat/completions"])
+	assert.Zero(t, stats.Failed["/chat/completions"])
+}
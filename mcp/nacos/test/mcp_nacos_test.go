@@ -22,15 +22,22 @@
 package test
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"testing"
 	"time"
 )
 
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
 const (
 	// Test service addresses
 	pixiuURL    = "http://localhost:8888"
@@ -38,79 +45,20 @@ const (
 	backendURL  = "http://localhost:8081"
 )
 
-// JSONRPCRequest represents a minimal JSON-RPC 2.0 request envelope.
-type JSONRPCRequest struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      any    `json:"id"`
-	Method  string `json:"method"`
-	Params  any    `json:"params,omitempty"`
-}
+var client = testkit.NewJSONRPCClient(pixiuURL + mcpEndpoint)
 
-// JSONRPCResponse represents a minimal JSON-RPC 2.0 response envelope.
-type JSONRPCResponse struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      any    `json:"id"`
-	Result  any    `json:"result,omitempty"`
-	Error   any    `json:"error,omitempty"`
-}
-
-// ToolCallParams represents parameters for tools/call requests.
-type ToolCallParams struct {
-	Name      string         `json:"name"`
-	Arguments map[string]any `json:"arguments"`
+// checkServiceAvailable performs a quick HTTP GET and returns true if 200 OK.
+func checkServiceAvailable(url string) bool {
+	return testkit.WaitHTTP(url, 0, http.StatusOK) == nil
 }
 
-// sendJSONRPCRequest posts a JSON-RPC request to /mcp and returns the decoded
-// response. It fails the test on transport or protocol errors.
-func sendJSONRPCRequest(t *testing.T, method string, params any) *JSONRPCResponse {
-	req := JSONRPCRequest{
-		JSONRPC: "2.0",
-		ID:      1,
-		Method:  method,
-		Params:  params,
-	}
-
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		t.Fatalf("Failed to marshal request: %v", err)
-	}
-
-	resp, err := http.Post(pixiuURL+mcpEndpoint, "application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
-		t.Fatalf("Failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("Failed to read response: %v", err)
-	}
-
-	var jsonResp JSONRPCResponse
-	if err := json.Unmarshal(body, &jsonResp); err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
-	}
-
-	if jsonResp.Error != nil {
-		t.Fatalf("JSON-RPC error: %v", jsonResp.Error)
-	}
-
-	return &jsonResp
-}
+// callTool calls a tool and decodes the json text it returns
+func callTool(t *testing.T, name string, args map[string]any) map[string]any {
+	resp := client.Call(t, "tools/call", testkit.ToolCallParams{Name: name, Arguments: args})
 
-// checkServiceAvailable performs a quick HTTP GET and returns true if 200 OK.
-func checkServiceAvailable(url string) bool {
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+	var data map[string]any
+	require.NoError(t, json.Unmarshal([]byte(resp.ToolText(t)), &data), "tool %s returned no json", name)
+	return data
 }
 
 // TestServiceAvailability tests service availability
@@ -147,23 +95,12 @@ func TestMCPInitialize(t *testing.T) {
 		},
 	}
 
-	resp := sendJSONRPCRequest(t, "initialize", params)
-
-	// Validate response structure
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
+	result := client.Call(t, "initialize", params).Object(t)
 
 	// Check server info
 	serverInfo, ok := result["serverInfo"].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected serverInfo in result")
-	}
-
-	if name, ok := serverInfo["name"].(string); !ok || name == "" {
-		t.Fatalf("Expected server name")
-	}
+	require.True(t, ok, "Expected serverInfo in result")
+	assert.NotEmpty(t, serverInfo["name"], "Expected server name")
 
 	t.Logf("MCP server initialized successfully: %s", serverInfo["name"])
 }
@@ -176,38 +113,18 @@ func TestToolsList(t *testing.T) {
 
 	t.Log("Testing tools list...")
 
-	resp := sendJSONRPCRequest(t, "tools/list", nil)
-
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	tools, ok := result["tools"].([]any)
-	if !ok {
-		t.Fatalf("Expected tools array in result")
-	}
-
-	if len(tools) == 0 {
-		t.Fatalf("Expected at least one tool")
-	}
+	tools, ok := client.Call(t, "tools/list", nil).Object(t)["tools"].([]any)
+	require.True(t, ok, "Expected tools array in result")
+	require.NotEmpty(t, tools, "Expected at least one tool")
 
 	t.Logf("Found %d tools", len(tools))
 
 	// Validate tool structure
 	for i, tool := range tools {
 		toolObj, ok := tool.(map[string]any)
-		if !ok {
-			t.Fatalf("Tool %d is not an object", i)
-		}
-
-		if name, ok := toolObj["name"].(string); !ok || name == "" {
-			t.Fatalf("Tool %d missing name", i)
-		}
-
-		if desc, ok := toolObj["description"].(string); !ok || desc == "" {
-			t.Fatalf("Tool %d missing description", i)
-		}
+		require.True(t, ok, "Tool %d is not an object", i)
+		assert.NotEmpty(t, toolObj["name"], "Tool %d missing name", i)
+		assert.NotEmpty(t, toolObj["description"], "Tool %d missing description", i)
 
 		t.Logf("Tool %d: %s - %s", i+1, toolObj["name"], toolObj["description"])
 	}
@@ -221,50 +138,13 @@ func TestGetUser(t *testing.T) {
 
 	t.Log("Testing get user tool...")
 
-	params := ToolCallParams{
-		Name: "getUserById",
-		Arguments: map[string]any{
-			"id":              1,
-			"include_profile": true,
-		},
-	}
-
-	resp := sendJSONRPCRequest(t, "tools/call", params)
+	userData := callTool(t, "getUserById", map[string]any{
+		"id":              1,
+		"include_profile": true,
+	})
 
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	content, ok := result["content"].([]any)
-	if !ok || len(content) == 0 {
-		t.Fatalf("Expected content array in result")
-	}
-
-	contentItem, ok := content[0].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected content item to be an object")
-	}
-
-	text, ok := contentItem["text"].(string)
-	if !ok {
-		t.Fatalf("Expected text in content item")
-	}
-
-	// Parse returned user data
-	var userData map[string]any
-	if err := json.Unmarshal([]byte(text), &userData); err != nil {
-		t.Fatalf("Failed to parse user data: %v", err)
-	}
-
-	// Validate user data
-	if id, ok := userData["id"].(float64); !ok || id != 1 {
-		t.Fatalf("Expected user ID to be 1")
-	}
-
-	if name, ok := userData["name"].(string); !ok || name == "" {
-		t.Fatalf("Expected user name")
-	}
+	assert.Equal(t, float64(1), userData["id"], "Expected user ID to be 1")
+	assert.NotEmpty(t, userData["name"], "Expected user name")
 
 	t.Logf("Successfully got user: %s", userData["name"])
 }
@@ -277,53 +157,16 @@ func TestSearchUsers(t *testing.T) {
 
 	t.Log("Testing search users tool...")
 
-	params := ToolCallParams{
-		Name: "searchUsers",
-		Arguments: map[string]any{
-			"q":     "alice",
-			"page":  1,
-			"limit": 10,
-		},
-	}
-
-	resp := sendJSONRPCRequest(t, "tools/call", params)
-
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	content, ok := result["content"].([]any)
-	if !ok || len(content) == 0 {
-		t.Fatalf("Expected content array in result")
-	}
-
-	contentItem, ok := content[0].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected content item to be an object")
-	}
-
-	text, ok := contentItem["text"].(string)
-	if !ok {
-		t.Fatalf("Expected text in content item")
-	}
+	searchResult := callTool(t, "searchUsers", map[string]any{
+		"q":     "alice",
+		"page":  1,
+		"limit": 10,
+	})
 
-	// Parse search result
-	var searchResult map[string]any
-	if err := json.Unmarshal([]byte(text), &searchResult); err != nil {
-		t.Fatalf("Failed to parse search result: %v", err)
-	}
-
-	// Validate search result
 	users, ok := searchResult["users"].([]any)
-	if !ok {
-		t.Fatalf("Expected users array in search result")
-	}
-
+	require.True(t, ok, "Expected users array in search result")
 	total, ok := searchResult["total"].(float64)
-	if !ok {
-		t.Fatalf("Expected total in search result")
-	}
+	require.True(t, ok, "Expected total in search result")
 
 	t.Logf("Found %d users, total %.0f", len(users), total)
 }
@@ -337,54 +180,16 @@ func TestCreateUser(t *testing.T) {
 	t.Log("Testing create user tool...")
 
 	// Use timestamp to ensure unique email
-	timestamp := time.Now().Unix()
-	email := fmt.Sprintf("test%d@example.com", timestamp)
-
-	params := ToolCallParams{
-		Name: "createUser",
-		Arguments: map[string]any{
-			"name":  "Test User",
-			"email": email,
-			"age":   25,
-		},
-	}
+	email := fmt.Sprintf("test%d@example.com", time.Now().Unix())
 
-	resp := sendJSONRPCRequest(t, "tools/call", params)
+	userData := callTool(t, "createUser", map[string]any{
+		"name":  "Test User",
+		"email": email,
+		"age":   25,
+	})
 
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	content, ok := result["content"].([]any)
-	if !ok || len(content) == 0 {
-		t.Fatalf("Expected content array in result")
-	}
-
-	contentItem, ok := content[0].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected content item to be an object")
-	}
-
-	text, ok := contentItem["text"].(string)
-	if !ok {
-		t.Fatalf("Expected text in content item")
-	}
-
-	// Parse created user data
-	var userData map[string]any
-	if err := json.Unmarshal([]byte(text), &userData); err != nil {
-		t.Fatalf("Failed to parse user data: %v", err)
-	}
-
-	// Validate created user data
-	if name, ok := userData["name"].(string); !ok || name != "Test User" {
-		t.Fatalf("Expected user name to be 'Test User'")
-	}
-
-	if userEmail, ok := userData["email"].(string); !ok || userEmail != email {
-		t.Fatalf("Expected user email to be '%s'", email)
-	}
+	assert.Equal(t, "Test User", userData["name"])
+	assert.Equal(t, email, userData["email"])
 
 	t.Logf("Successfully created user: %s (%s)", userData["name"], userData["email"])
 }
@@ -397,43 +202,8 @@ func TestHealthCheck(t *testing.T) {
 
 	t.Log("Testing health check tool...")
 
-	params := ToolCallParams{
-		Name:      "healthCheck",
-		Arguments: map[string]any{},
-	}
-
-	resp := sendJSONRPCRequest(t, "tools/call", params)
-
-	result, ok := resp.Result.(map[string]any)
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	content, ok := result["content"].([]any)
-	if !ok || len(content) == 0 {
-		t.Fatalf("Expected content array in result")
-	}
-
-	contentItem, ok := content[0].(map[string]any)
-	if !ok {
-		t.Fatalf("Expected content item to be an object")
-	}
-
-	text, ok := contentItem["text"].(string)
-	if !ok {
-		t.Fatalf("Expected text in content item")
-	}
-
-	// Parse health check result
-	var healthData map[string]any
-	if err := json.Unmarshal([]byte(text), &healthData); err != nil {
-		t.Fatalf("Failed to parse health data: %v", err)
-	}
-
-	// Validate health check result
-	if status, ok := healthData["status"].(string); !ok || status != "healthy" {
-		t.Fatalf("Expected status to be 'healthy'")
-	}
+	healthData := callTool(t, "healthCheck", map[string]any{})
+	assert.Equal(t, "healthy", healthData["status"])
 
 	t.Logf("Health check passed: %s", healthData["status"])
 }
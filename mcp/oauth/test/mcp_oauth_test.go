@@ -20,12 +20,11 @@
 package test
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
@@ -33,6 +32,10 @@ import (
 	"time"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
 const (
 	pixiuBaseURL   = "http://localhost:8888"
 	mcpPath        = "/mcp"
@@ -42,61 +45,24 @@ const (
 	redirectURI    = "http://localhost:8081/callback"
 )
 
-// JSON-RPC request/response types
-type JSONRPCRequest struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      any    `json:"id"`
-	Method  string `json:"method"`
-	Params  any    `json:"params,omitempty"`
-}
-
-type JSONRPCResponse struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      any    `json:"id"`
-	Result  any    `json:"result,omitempty"`
-	Error   any    `json:"error,omitempty"`
-}
-
-type toolCallParams struct {
-	Name      string         `json:"name"`
-	Arguments map[string]any `json:"arguments"`
-}
-
 func TestMain(m *testing.M) {
-	if !waitForAllServices() {
-		panic("Services not available. Start: Authorization Server (9000), Backend API (8081), Pixiu Gateway (8888)")
+	if err := testkit.Setup(); err != nil {
+		panic(err)
 	}
-	m.Run()
-}
-
-func waitForAllServices() bool {
-	services := map[string]string{
-		"Backend": backendBaseURL + "/api/health",
-		"Auth":    authBaseURL + "/.well-known/oauth-authorization-server",
-		"Pixiu":   pixiuBaseURL + mcpPath,
-	}
-
-	for name, url := range services {
-		if !waitForService(name, url) {
-			return false
-		}
+	if err := waitForAllServices(); err != nil {
+		panic("Services not available. Start: Authorization Server (9000), Backend API (8081), Pixiu Gateway (8888): " + err.Error())
 	}
-	return true
+	m.Run()
 }
 
-func waitForService(name, url string) bool {
-	client := &http.Client{Timeout: 2 * time.Second}
-	for i := 0; i < 30; i++ {
-		resp, err := client.Get(url)
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == http.StatusOK || (name == "Pixiu" && resp.StatusCode == http.StatusUnauthorized) {
-				return true
-			}
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
-	return false
+func waitForAllServices() error {
+	const timeout = 15 * time.Second
+	return errors.Join(
+		testkit.WaitHTTP(backendBaseURL+"/api/health", timeout),
+		testkit.WaitHTTP(authBaseURL+"/.well-known/oauth-authorization-server", timeout),
+		// the gateway rejects the anonymous probe, which still proves it is up
+		testkit.WaitHTTP(pixiuBaseURL+mcpPath, timeout, http.StatusOK, http.StatusUnauthorized),
+	)
 }
 
 // getAccessToken implements OAuth2 Authorization Code with PKCE flow
@@ -130,22 +96,9 @@ func getAuthorizationCode(t *testing.T, codeChallenge string) string {
 	params.Set("code_challenge_method", "S256")
 	params.Set("resource", pixiuBaseURL+mcpPath)
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	resp, err := client.Get(authBaseURL + "/oauth/authorize?" + params.Encode())
-	if err != nil {
-		t.Fatalf("authorization request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
+	resp := testkit.Get(t, authBaseURL+"/oauth/authorize?"+params.Encode(), testkit.NoRedirect())
 	if resp.StatusCode != http.StatusFound {
-		body, _ := io.ReadAll(resp.Body)
-		t.Fatalf("expected redirect, got %d: %s", resp.StatusCode, string(body))
+		t.Fatalf("expected redirect, got %d: %s", resp.StatusCode, resp.String())
 	}
 
 	location := resp.Header.Get("Location")
@@ -171,30 +124,16 @@ func exchangeCodeForToken(t *testing.T, code, codeVerifier string) string {
 	form.Set("code_verifier", codeVerifier)
 	form.Set("resource", pixiuBaseURL+mcpPath)
 
-	req, err := http.NewRequest(http.MethodPost, authBaseURL+"/oauth/token", strings.NewReader(form.Encode()))
-	if err != nil {
-		t.Fatalf("failed to build token request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		t.Fatalf("token request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
+	resp := testkit.Post(t, authBaseURL+"/oauth/token", form.Encode(),
+		testkit.Header("Content-Type", "application/x-www-form-urlencoded"))
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		t.Fatalf("expected 200 from token endpoint, got %d: %s", resp.StatusCode, string(body))
+		t.Fatalf("expected 200 from token endpoint, got %d: %s", resp.StatusCode, resp.String())
 	}
 
 	var tokenResp struct {
 		AccessToken string `json:"access_token"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		t.Fatalf("failed to decode token response: %v", err)
-	}
+	testkit.DecodeJSON(t, resp, &tokenResp)
 
 	if tokenResp.AccessToken == "" {
 		t.Fatalf("access token is empty")
@@ -204,34 +143,10 @@ func exchangeCodeForToken(t *testing.T, code, codeVerifier string) string {
 
 func sendJSONRPC(t *testing.T, method string, params any, token string) (int, []byte) {
 	t.Helper()
-	reqObj := JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params}
-	reqBody, err := json.Marshal(reqObj)
-	if err != nil {
-		t.Fatalf("failed to marshal request: %v", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, pixiuBaseURL+mcpPath, bytes.NewBuffer(reqBody))
-	if err != nil {
-		t.Fatalf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-
-	client := &http.Client{Timeout: 8 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		t.Fatalf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("failed to read response: %v", err)
-	}
-	return resp.StatusCode, body
+	client := testkit.NewJSONRPCClient(pixiuBaseURL + mcpPath)
+	client.Token = token
+	resp := client.Send(t, method, params)
+	return resp.StatusCode, resp.Body
 }
 
 // TestUnauthorizedAccess verifies requests without tokens are rejected
@@ -256,7 +171,7 @@ func TestAuthorizedAccess(t *testing.T) {
 		t.Fatalf("tools/list failed: %d, %s", status, string(body))
 	}
 
-	var resp JSONRPCResponse
+	var resp testkit.JSONRPCResponse
 	if err := json.Unmarshal(body, &resp); err != nil {
 		t.Fatalf("failed to unmarshal response: %v", err)
 	}
@@ -275,7 +190,7 @@ func TestAuthorizedAccess(t *testing.T) {
 	t.Logf("Retrieved %d tools", len(tools))
 
 	// Test tool call
-	params := toolCallParams{
+	params := testkit.ToolCallParams{
 		Name:      "health_check",
 		Arguments: map[string]any{},
 	}
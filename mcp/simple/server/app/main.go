@@ -18,36 +18,24 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 )
 
-// Logger An example of a logger that implements `pkg/log/Logger`.  Logs to
-// stdout.  If Debug == false then Debugf() and Infof() won't output
-// anything.
-type Logger struct {
-	Debug bool
-}
-
-// Debugf Log to stdout only if Debug is true.
-func (logger Logger) Debugf(format string, args ...interface{}) {
-	if logger.Debug {
-		log.Printf(format+"\n", args...)
-	}
-}
-
-// Log to stdout only if Debug is true.
-func (logger Logger) Infof(format string, args ...interface{}) {
-	if logger.Debug {
-		log.Printf(format+"\n", args...)
-	}
-}
+import (
+	"github.com/dubbo-go-pixiu/samples/mcp/simple/server/backend"
+)
 
-// Warnf ErrorfLog to stdout always.
-func (logger Logger) Warnf(format string, args ...interface{}) {
-	log.Printf(format+"\n", args...)
-}
+func main() {
+	fmt.Println("🚀 Mock Backend Server starting on :8081")
+	fmt.Println("📚 Available endpoints:")
+	fmt.Println("  GET  /api/users/{id}        - Get user by ID")
+	fmt.Println("  GET  /api/users/search      - Search users")
+	fmt.Println("  POST /api/users             - Create user")
+	fmt.Println("  GET  /api/users/{id}/posts  - Get user posts")
+	fmt.Println("  GET  /api/health            - Health check")
+	fmt.Println("  GET  /                      - Root endpoint")
 
-// Errorf Log to stdout always.
-func (logger Logger) Errorf(format string, args ...interface{}) {
-	log.Printf(format+"\n", args...)
+	log.Fatal(http.ListenAndServe(":8081", backend.NewHandler()))
 }
@@ -15,7 +15,9 @@
  * limitations under the License.
  */
 
-package main
+// Package backend is the mock REST service the MCP tools of the sample call,
+// it is served by server/app and in process by the hermetic tests
+package backend
 
 import (
 	"encoding/json"
@@ -86,7 +88,8 @@ var posts = []Post{
 
 var nextUserID = 6
 
-func main() {
+// NewHandler returns the router of the mock backend
+func NewHandler() http.Handler {
 	r := mux.NewRouter()
 
 	// Add CORS middleware
@@ -108,16 +111,7 @@ func main() {
 	// Root path
 	r.HandleFunc("/", rootHandler).Methods("GET")
 
-	fmt.Println("🚀 Mock Backend Server starting on :8081")
-	fmt.Println("📚 Available endpoints:")
-	fmt.Println("  GET  /api/users/{id}        - Get user by ID")
-	fmt.Println("  GET  /api/users/search      - Search users")
-	fmt.Println("  POST /api/users             - Create user")
-	fmt.Println("  GET  /api/users/{id}/posts  - Get user posts")
-	fmt.Println("  GET  /api/health            - Health check")
-	fmt.Println("  GET  /                      - Root endpoint")
-
-	log.Fatal(http.ListenAndServe(":8081", r))
+	return r
 }
 
 // CORS middleware
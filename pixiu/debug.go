@@ -0,0 +1,347 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
+	"github.com/apache/dubbo-go-pixiu/pkg/config"
+	"github.com/apache/dubbo-go-pixiu/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/llm/quota/tokenquota"
+	"github.com/dubbo-go-pixiu/samples/llm/vault/keyvault"
+)
+
+const (
+	// defaultDebugAddr keeps the debug listener off the public interfaces unless asked otherwise
+	defaultDebugAddr = "127.0.0.1:6060"
+
+	envDebugEnable = "DUBBOGO_PIXIU_DEBUG"
+	envDebugAddr   = "DUBBOGO_PIXIU_DEBUG_ADDR"
+
+	// redacted replaces the secrets in the config dump
+	redacted = "[redacted]"
+)
+
+var (
+	// secretFields are redacted wherever they are in the config, like the passwords of the registries
+	secretFields = map[string]bool{"password": true, "access_key": true, "secret_key": true}
+
+	// filterSecrets are the paths of the keys in the config of a filter. The strings, the items of a list or
+	// the values of a map at the path are redacted, but for secret:<name> references, which only name a key.
+	filterSecrets = map[string][]string{
+		keyvault.Kind:              {"clusters"},
+		tokenquota.Kind:            {"consumers.keys"},
+		constant.HTTPAuthJwtFilter: {"providers.local_jwks.inline_string"},
+	}
+)
+
+var (
+	debugEnable bool
+	debugAddr   string
+)
+
+// addDebugFlags registers the debug listener flags on the root command, so they apply to every sub command
+func addDebugFlags(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().BoolVar(&debugEnable, "debug", os.Getenv(envDebugEnable) == "true", "start the debug listener with pprof, expvar, goroutine and config dump endpoints")
+	rootCmd.PersistentFlags().StringVar(&debugAddr, "debug-addr", envOrDefault(envDebugAddr, defaultDebugAddr), "debug listener address, `HOST:PORT`")
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if !debugEnable {
+			return nil
+		}
+		return startDebugServer(debugAddr)
+	}
+}
+
+// startDebugServer binds addr synchronously so a bad address fails the command, then serves in background
+func startDebugServer(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("debug listener on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           newDebugMux(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Warnf("[dubbopixiu go debug] listener stopped, err: %v", err)
+		}
+	}()
+	logger.Infof("[dubbopixiu go debug] listener start by : %s", ln.Addr().String())
+	return nil
+}
+
+// newDebugMux builds a dedicated mux. The profiles are served from runtime/pprof, net/http/pprof is
+// not imported since it registers itself on http.DefaultServeMux.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", handleProfile)
+	mux.HandleFunc("/debug/pprof/cmdline", handleCmdline)
+	mux.HandleFunc("/debug/pprof/profile", handleCPUProfile)
+	mux.HandleFunc("/debug/pprof/symbol", handleSymbol)
+	mux.HandleFunc("/debug/pprof/trace", handleTrace)
+
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", handleGoroutineDump)
+	mux.HandleFunc("/debug/config", handleConfigDump)
+
+	return mux
+}
+
+// handleProfile lists the profiles, or writes the one named by the path, like /debug/pprof/heap.
+// ?debug=1 writes it as text for the browser.
+func handleProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	if name == "" {
+		profiles := pprof.Profiles()
+		sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range profiles {
+			_, _ = fmt.Fprintf(w, "%d\t%s\n", p.Count(), p.Name())
+		}
+		_, _ = fmt.Fprint(w, "\nprofile, trace, cmdline and symbol are served too, add ?debug=1 to read a profile as text\n")
+		return
+	}
+
+	p := pprof.Lookup(name)
+	if p == nil {
+		http.Error(w, "unknown profile "+name, http.StatusNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.FormValue("debug"))
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	}
+	if err := p.WriteTo(w, debug); err != nil {
+		logger.Warnf("[dubbopixiu go debug] write profile %s failed, err: %v", name, err)
+	}
+}
+
+// handleCmdline writes the command line of pixiu, its arguments separated by NUL bytes
+func handleCmdline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprint(w, strings.Join(os.Args, "\x00"))
+}
+
+// handleSymbol looks up the functions of the program counters go tool pprof posts, or puts in the query,
+// joined by +, like net/http/pprof does
+func handleSymbol(w http.ResponseWriter, r *http.Request) {
+	pcs := r.URL.RawQuery
+	if r.Method == http.MethodPost {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		pcs = string(body)
+	}
+
+	// num_symbols only tells that symbols are served, a request without counters asks just that
+	var buf bytes.Buffer
+	buf.WriteString("num_symbols: 1\n")
+	for _, word := range strings.Split(pcs, "+") {
+		pc, err := strconv.ParseUint(word, 0, 64)
+		if err != nil {
+			continue
+		}
+		if fn := runtime.FuncForPC(uintptr(pc)); fn != nil {
+			_, _ = fmt.Fprintf(&buf, "%#x %s\n", pc, fn.Name())
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// profileSeconds reads ?seconds=, 30 by default like net/http/pprof
+func profileSeconds(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.FormValue("seconds"), 64)
+	if err != nil || seconds <= 0 {
+		seconds = 30
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// handleCPUProfile records the cpu for ?seconds=
+func handleCPUProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "cpu profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sleep(r, profileSeconds(r))
+	pprof.StopCPUProfile()
+}
+
+// handleTrace records an execution trace for ?seconds=
+func handleTrace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "trace: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sleep(r, profileSeconds(r))
+	trace.Stop()
+}
+
+// sleep waits for d, or until the client goes away
+func sleep(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+// handleGoroutineDump writes the stack of every goroutine as plain text
+func handleGoroutineDump(w http.ResponseWriter, r *http.Request) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "goroutines: %d\n\n", runtime.NumGoroutine())
+	_, _ = w.Write(buf)
+}
+
+// handleConfigDump writes the bootstrap config pixiu is currently running with, its secrets redacted
+func handleConfigDump(w http.ResponseWriter, r *http.Request) {
+	bs := config.GetBootstrap()
+	if bs == nil {
+		http.Error(w, "config not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	dump, err := redactConfig(bs)
+	if err != nil {
+		logger.Warnf("[dubbopixiu go debug] encode config failed, err: %v", err)
+		http.Error(w, "encode config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dump); err != nil {
+		logger.Warnf("[dubbopixiu go debug] encode config failed, err: %v", err)
+	}
+}
+
+// redactConfig returns the json tree of the config with secretFields and filterSecrets redacted
+func redactConfig(bs interface{}) (interface{}, error) {
+	raw, err := json.Marshal(bs)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err = json.Unmarshal(raw, &tree); err != nil {
+		return nil, err
+	}
+	redactTree(tree)
+	return tree, nil
+}
+
+// redactTree walks the tree, the filters are the objects with a name and a config
+func redactTree(node interface{}) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if secretFields[key] {
+				node[key] = redactPath(value, nil)
+				continue
+			}
+			redactTree(value)
+		}
+		if name, ok := node["name"].(string); ok {
+			for _, path := range filterSecrets[name] {
+				if cfg, ok := node["config"]; ok {
+					node["config"] = redactPath(cfg, strings.Split(path, "."))
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range node {
+			redactTree(item)
+		}
+	}
+}
+
+// redactPath redacts the strings at path below node, the lists on the way are walked item by item
+func redactPath(node interface{}, path []string) interface{} {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if len(path) == 0 {
+				node[key] = redactPath(value, nil)
+			} else if key == path[0] {
+				node[key] = redactPath(value, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, item := range node {
+			node[i] = redactPath(item, path)
+		}
+	case string:
+		if len(path) == 0 && node != "" && !strings.HasPrefix(node, "secret:") {
+			return redacted
+		}
+	}
+	return node
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
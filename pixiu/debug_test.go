@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugMux(t *testing.T) {
+	srv := httptest.NewServer(newDebugMux())
+	defer srv.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		status   int
+		contains string
+	}{
+		{name: "pprof index", path: "/debug/pprof/", status: http.StatusOK, contains: "goroutine"},
+		{name: "pprof profile", path: "/debug/pprof/goroutine?debug=1", status: http.StatusOK, contains: "goroutine profile:"},
+		{name: "unknown profile", path: "/debug/pprof/nothing", status: http.StatusNotFound, contains: "unknown profile"},
+		{name: "cpu profile", path: "/debug/pprof/profile?seconds=0.1", status: http.StatusOK},
+		{name: "symbol", path: "/debug/pprof/symbol", status: http.StatusOK, contains: "num_symbols: 1"},
+		{name: "expvar", path: "/debug/vars", status: http.StatusOK, contains: "memstats"},
+		{name: "goroutine dump", path: "/debug/goroutines", status: http.StatusOK, contains: "goroutines:"},
+		{name: "config not loaded", path: "/debug/config", status: http.StatusServiceUnavailable, contains: "config not loaded"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tc.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.True(t, strings.Contains(string(body), tc.contains))
+		})
+	}
+}
+
+func TestSymbol(t *testing.T) {
+	srv := httptest.NewServer(newDebugMux())
+	defer srv.Close()
+
+	pc := fmt.Sprintf("%#x", reflect.ValueOf(handleSymbol).Pointer())
+	resp, err := http.Post(srv.URL+"/debug/pprof/symbol", "text/plain", strings.NewReader(pc+"+nothing"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// the test binary names the main package by its import path
+	assert.Regexp(t, `^num_symbols: 1\n`+pc+` \S+\.handleSymbol\n$`, string(body))
+}
+
+func TestRedactConfig(t *testing.T) {
+	var bs interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "static_resources": {"listeners": [{"filter_chain": {"filters": [{"name": "dgp.filter.httpconnectionmanager", "config": {
+    "http_filters": [
+      {"name": "dgp.filter.llm.keyvault", "config": {"secrets_file": "secrets.yaml", "clusters": {"a": "secret:a", "b": "enc:v1:c2VhbGVk", "c": "sk-raw"}}},
+      {"name": "dgp.filter.llm.tokenquota", "config": {"consumers": [{"name": "alice", "keys": ["secret:alice", "sk-alice"], "daily_tokens": 10}]}},
+      {"name": "dgp.filter.http.auth.jwt", "config": {"providers": [{"name": "p", "issuer": "i", "local_jwks": {"inline_string": "{\"keys\":[]}"}}]}},
+      {"name": "dgp.filter.http.httpproxy", "config": {"clusters": {"a": "kept"}}}
+    ]}}]}}]},
+  "nacos": {"username": "nacos", "password": "hunter2", "secret_key": ""}
+}`), &bs))
+
+	dump, err := redactConfig(bs)
+	require.NoError(t, err)
+	raw, err := json.Marshal(dump)
+	require.NoError(t, err)
+	out := string(raw)
+
+	for _, secret := range []string{"enc:v1:c2VhbGVk", "sk-raw", "sk-alice", `{\"keys\":[]}`, "hunter2"} {
+		assert.NotContains(t, out, secret)
+	}
+	// references only name a key, the rest of the config stays readable
+	for _, kept := range []string{`"secret:a"`, `"secret:alice"`, `"secrets_file":"secrets.yaml"`, `"daily_tokens":10`, `"issuer":"i"`,
+		`"a":"kept"`, `"username":"nacos"`, `"secret_key":""`} {
+		assert.Contains(t, out, kept)
+	}
+	assert.Equal(t, 5, strings.Count(out, redacted))
+}
+
+func TestPprofOffTheDefaultMux(t *testing.T) {
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Empty(t, pattern)
+}
+
+func TestDebugFlags(t *testing.T) {
+	rootCmd := getRootCmd()
+
+	flag := rootCmd.PersistentFlags().Lookup("debug-addr")
+	require.NotNil(t, flag)
+	assert.Equal(t, defaultDebugAddr, flag.DefValue)
+
+	flag = rootCmd.PersistentFlags().Lookup("debug")
+	require.NotNil(t, flag)
+	assert.Equal(t, "false", flag.DefValue)
+}
+
+func TestStartDebugServerBadAddr(t *testing.T) {
+	assert.Error(t, startDebugServer("127.0.0.1:-1"))
+}
@@ -18,7 +18,6 @@
 package main
 
 import (
-	_ "net/http/pprof"
 	"strconv"
 	"time"
 )
@@ -30,6 +29,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+import (
+	// filters of the samples that pixiu does not ship
+	_ "github.com/dubbo-go-pixiu/samples/llm/bestpractise/cache/responsecache"
+	_ "github.com/dubbo-go-pixiu/samples/llm/bestpractise/guardrails/guardrail"
+	_ "github.com/dubbo-go-pixiu/samples/llm/quota/tokenquota"
+	_ "github.com/dubbo-go-pixiu/samples/llm/routing/modelrouter"
+	_ "github.com/dubbo-go-pixiu/samples/llm/vault/keyvault"
+)
+
 const (
 	// Version pixiu version
 	Version = "1.0.0"
@@ -54,6 +62,8 @@ func getRootCmd() *cobra.Command {
 		Version: Version,
 	}
 
+	addDebugFlags(rootCmd)
+
 	rootCmd.AddCommand(cmd.GatewayCmd)
 	rootCmd.AddCommand(cmd.SideCarCmd)
 
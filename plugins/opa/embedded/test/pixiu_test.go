@@ -18,75 +18,35 @@
 package test
 
 import (
-	"io"
-	"net"
 	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 )
 
-const embeddedGatewayBaseURL = "http://localhost:8888"
-
-func doEmbeddedRequest(t *testing.T, path string, headerVal *string) (int, string) {
-	t.Helper()
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", embeddedGatewayBaseURL+path, nil)
-	if err != nil {
-		t.Fatalf("failed to build request: %v", err)
-	}
-
-	if headerVal != nil {
-		req.Header.Set("Test_header", *headerVal)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		// Surface clearer guidance when gateway is not running
-		if ne, ok := err.(net.Error); ok && ne.Timeout() {
-			t.Fatalf("request timeout: ensure embedded Pixiu gateway and backend are running: %v", err)
-		}
-		t.Fatalf("request failed: ensure embedded Pixiu gateway and backend are running: %v", err)
-	}
-	if resp == nil {
-		t.Fatalf("response is nil")
-	}
-	defer resp.Body.Close()
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("failed to read response body: %v", err)
-	}
-	return resp.StatusCode, string(body)
-}
+const gatewayBaseURL = "http://localhost:8888"
 
 func TestEmbeddedUserServiceAllow(t *testing.T) {
-	header := "1"
-	status, body := doEmbeddedRequest(t, "/UserService", &header)
-
-	assert.Equal(t, http.StatusOK, status)
-	assert.True(t, strings.Contains(body, "pass"))
-	assert.True(t, strings.Contains(body, "UserService"))
+	resp := testkit.Get(t, gatewayBaseURL+"/UserService", testkit.Header("Test_header", "1"))
+	testkit.AssertResponse(t, resp, http.StatusOK, "pass", "UserService")
 }
 
 func TestEmbeddedUserServiceDeny(t *testing.T) {
-	status, body := doEmbeddedRequest(t, "/UserService", nil)
-
-	assert.Equal(t, http.StatusForbidden, status)
-	assert.False(t, strings.Contains(body, "pass"))
-	assert.False(t, strings.Contains(body, "UserService"))
+	resp := testkit.Get(t, gatewayBaseURL+"/UserService")
+	testkit.AssertResponse(t, resp, http.StatusForbidden)
+	assert.NotContains(t, resp.String(), "pass")
+	assert.NotContains(t, resp.String(), "UserService")
 }
 
 func TestEmbeddedOtherServiceDeny(t *testing.T) {
-	header := "1"
-	status, body := doEmbeddedRequest(t, "/OtherService", &header)
-
-	assert.Equal(t, http.StatusForbidden, status)
-	assert.False(t, strings.Contains(body, "pass"))
-	assert.False(t, strings.Contains(body, "OtherService"))
+	resp := testkit.Get(t, gatewayBaseURL+"/OtherService", testkit.Header("Test_header", "1"))
+	testkit.AssertResponse(t, resp, http.StatusForbidden)
+	assert.NotContains(t, resp.String(), "pass")
+	assert.NotContains(t, resp.String(), "OtherService")
 }
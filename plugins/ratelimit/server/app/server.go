@@ -23,21 +23,10 @@ import (
 )
 
 import (
-	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
+	"github.com/dubbo-go-pixiu/samples/plugins/ratelimit/server/backend"
 )
 
 func main() {
-	http.HandleFunc("/v1/", handle)
 	log.Println("Starting sample server ...")
-	log.Fatal(http.ListenAndServe(":1314", nil))
-}
-
-func handle(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case constant.Get:
-		// w.WriteHeader(200)
-		w.Header().Set(constant.HeaderKeyContextType, constant.HeaderValueJsonUtf8)
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("resp"))
-	}
+	log.Fatal(http.ListenAndServe(":1314", backend.NewHandler()))
 }
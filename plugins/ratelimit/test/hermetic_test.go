@@ -0,0 +1,49 @@
+//go:build !integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+import (
+	// pluginregistry leaves the ratelimit filter out
+	_ "github.com/apache/dubbo-go-pixiu/pkg/filter/sentinel/ratelimit"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/plugins/ratelimit/server/backend"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/hermetic"
+)
+
+// TestMain runs the sample in process under plain go test, go test -tags integration tests the running sample instead
+func TestMain(m *testing.M) {
+	backendPort := hermetic.Serve(backend.NewHandler())
+	gateway, err := hermetic.StartPixiu("../pixiu/conf.yaml", hermetic.Ports{1314: backendPort})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "start pixiu:", err)
+		os.Exit(1)
+	}
+	pixiuURL = gateway.URL(8888)
+
+	os.Exit(m.Run())
+}
@@ -18,11 +18,7 @@
 package test
 
 import (
-	"io"
-	"net/http"
-	"strings"
 	"testing"
-	"time"
 )
 
 import (
@@ -31,40 +27,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestRatelimit(t *testing.T) {
-	var (
-		cnt200 int
-		cnt429 int
-		url    = "http://localhost:8888/v1/"
-		client = &http.Client{Timeout: 5 * time.Second}
-		resp   *http.Response
-	)
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
 
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	req.Header.Add("Content-Type", "application/json")
+// pixiuURL is the gateway, hermetic_test.go moves it when the sample runs in process
+var pixiuURL = "http://localhost:8888"
 
-	for i := 0; i < 5; i++ {
-		resp, err = client.Do(req)
-		assert.NoError(t, err)
-		assert.NotNil(t, resp)
+func TestRatelimit(t *testing.T) {
+	var cnt200, cnt429 int
 
+	for i := 0; i < 5; i++ {
+		resp := testkit.Get(t, pixiuURL+"/v1/", testkit.JSON())
 		if resp.StatusCode == 200 {
-			assert.Equal(t, 200, resp.StatusCode)
-			s, _ := io.ReadAll(resp.Body)
-			assert.True(t, strings.Contains(string(s), "resp"))
+			testkit.AssertResponse(t, resp, 200, "resp")
 			cnt200++
-			logger.Info("status: ", 200)
 		} else {
-			assert.Equal(t, 429, resp.StatusCode)
+			testkit.AssertResponse(t, resp, 429)
+			testkit.AssertGolden(t, "blocked", resp.Body)
 			cnt429++
-			logger.Info("status: ", 429)
 		}
-
-		resp.Body.Close()
+		logger.Info("status: ", resp.StatusCode)
 	}
 
 	assert.Equal(t, 1, cnt200)
 	assert.Equal(t, 4, cnt429)
-
 }
@@ -26,10 +26,6 @@ import (
 )
 
 import (
-	dconfig "dubbo.apache.org/dubbo-go/v3/config"
-	"dubbo.apache.org/dubbo-go/v3/config/generic" //nolint
-	"dubbo.apache.org/dubbo-go/v3/protocol/dubbo"
-
 	hessian "github.com/apache/dubbo-go-hessian2"
 
 	"github.com/apache/dubbo-go-pixiu/pkg/config"
@@ -39,27 +35,10 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func newDubboRefConf(iface, protocol string) dconfig.ReferenceConfig {
-	refConf := dconfig.ReferenceConfig{
-		InterfaceName:  iface,
-		Cluster:        "failover",
-		RegistryIDs:    []string{"zk"},
-		Protocol:       protocol,
-		Generic:        "true",
-		URL:            "dubbo://127.0.0.1:8889/" + iface,
-		Group:          "test",
-		Version:        "1.0.0",
-		RequestTimeout: "10s",
-	}
-	rootConfig := dconfig.NewRootConfigBuilder().
-		Build()
-	if err := dconfig.Load(dconfig.WithRootConfig(rootConfig)); err != nil {
-		panic(err)
-	}
-	_ = refConf.Init(rootConfig)
-	refConf.GenericLoad("dubbo.io")
-	return refConf
-}
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/dubbotest"
+)
 
 func TestDubboListenShutdown(t *testing.T) {
 	count := int32(0)
@@ -67,14 +46,19 @@ func TestDubboListenShutdown(t *testing.T) {
 	// start pixiu listener
 	bootstrap := config.Load("../pixiu/conf.yaml")
 	go server.Start(bootstrap)
-	time.Sleep(3 * time.Second) // wait start already
+	testkit.WaitForGateway(t, "127.0.0.1:8889")
 
 	// start client
-	tripleRefConf := newDubboRefConf("com.dubbogo.pixiu.TripleUserService", dubbo.DUBBO)
+	svc := dubbotest.NewGenericService(t, dubbotest.Reference{
+		Interface:      "com.dubbogo.pixiu.TripleUserService",
+		Addr:           "127.0.0.1:8889",
+		Protocol:       dubbotest.ProtocolDubbo,
+		RequestTimeout: "10s",
+	})
 	req_wg := &sync.WaitGroup{}
 	req_wg.Add(2)
 	call_func := func() {
-		_, err := tripleRefConf.GetRPCService().(*generic.GenericService).Invoke(
+		_, err := svc.Invoke(
 			context.TODO(),
 			"TestByDubbo",
 			[]string{"java.lang.String"},
@@ -20,21 +20,13 @@ package main
 import (
 	"log"
 	"net/http"
-	"time"
 )
 
 import (
-	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
+	"github.com/dubbo-go-pixiu/samples/shutdown/http/server/backend"
 )
 
 func main() {
-	http.HandleFunc("/user/", testFunc)
 	log.Println("Starting sample server ...")
-	log.Fatal(http.ListenAndServe(":1314", nil))
-}
-
-func testFunc(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(3 * time.Second)
-	w.Header().Set(constant.HeaderKeyContextType, constant.HeaderValueJsonUtf8)
-	w.Write([]byte("{\"message\":\"receive\"}"))
+	log.Fatal(http.ListenAndServe(":1314", backend.NewHandler()))
 }
@@ -0,0 +1,52 @@
+//go:build !integration
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/shutdown/http/server/backend"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/hermetic"
+)
+
+// TestMain serves the backend in process and points a copy of the config at it under plain go test,
+// the test starts pixiu itself. go test -tags integration tests the running backend instead.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "shutdown-http")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	gateway, err := hermetic.RewriteConfig("../pixiu/conf.yaml", dir, hermetic.Ports{1314: hermetic.Serve(backend.NewHandler())})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rewrite config:", err)
+		os.Exit(1)
+	}
+	pixiuConfig = gateway.Config
+	pixiuPort = gateway.Port(8888)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
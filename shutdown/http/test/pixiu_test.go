@@ -18,6 +18,7 @@
 package test
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"sync"
@@ -34,16 +35,26 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+// the config and listener port of pixiu, hermetic_test.go moves them when the sample runs in process
+var (
+	pixiuConfig = "../pixiu/conf.yaml"
+	pixiuPort   = 8888
+)
+
 func TestHttpListenShutdown(t *testing.T) {
 	count := int32(0)
 	// start pixiu listener
-	bootstrap := config.Load("../pixiu/conf.yaml")
+	bootstrap := config.Load(pixiuConfig)
 	go server.Start(bootstrap)
-	time.Sleep(3 * time.Second) // wait start already
+	testkit.WaitForGateway(t, fmt.Sprintf("127.0.0.1:%d", pixiuPort))
 
 	// start client
-	url := "http://localhost:8888/user/"
-	client := &http.Client{Timeout: 10 * time.Second}
+	url := fmt.Sprintf("http://localhost:%d/user/", pixiuPort)
+	client := &http.Client{Transport: testkit.Transport, Timeout: 10 * time.Second}
 	req, err := http.NewRequest("POST", url, strings.NewReader(""))
 	req_wg := &sync.WaitGroup{}
 	req_wg.Add(3)
@@ -67,7 +78,7 @@ func TestHttpListenShutdown(t *testing.T) {
 		time.Sleep(1 * time.Second)
 		go send_fenc()
 	}()
-	server.GetServer().GetListenerManager().GetListenerService("0.0.0.0-8888-HTTP").ShutDown(wg)
+	server.GetServer().GetListenerManager().GetListenerService(fmt.Sprintf("0.0.0.0-%d-HTTP", pixiuPort)).ShutDown(wg)
 	req_wg.Wait()
 	assert.Equal(t, count, int32(2))
 }
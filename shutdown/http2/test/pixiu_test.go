@@ -31,13 +31,11 @@ import (
 	"github.com/apache/dubbo-go-pixiu/pkg/server"
 
 	"github.com/stretchr/testify/assert"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 import (
 	gproto "github.com/dubbo-go-pixiu/samples/grpc/deprecated/proto"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
 )
 
 func TestHttpListenShutdown(t *testing.T) {
@@ -45,13 +43,10 @@ func TestHttpListenShutdown(t *testing.T) {
 	// start pixiu listener
 	bootstrap := config.Load("../pixiu/conf.yaml")
 	go server.Start(bootstrap)
-	time.Sleep(3 * time.Second) // wait start already
+	testkit.WaitForGateway(t, "127.0.0.1:8881")
 
 	// start client
-	conn, err := grpc.NewClient("localhost:8881", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	assert.NoError(t, err)
-	defer conn.Close()
-	client := gproto.NewUserProviderClient(conn)
+	client := gproto.NewUserProviderClient(testkit.DialGRPC(t, "localhost:8881"))
 	call_wg := &sync.WaitGroup{}
 	call_wg.Add(3)
 	send_fenc := func() {
@@ -26,47 +26,21 @@ import (
 )
 
 import (
-	"dubbo.apache.org/dubbo-go/v3/common/constant"
-	dconfig "dubbo.apache.org/dubbo-go/v3/config"
-	"dubbo.apache.org/dubbo-go/v3/config/generic" //nolint
-
 	hessian "github.com/apache/dubbo-go-hessian2"
 
 	"github.com/apache/dubbo-go-pixiu/pkg/config"
 	_ "github.com/apache/dubbo-go-pixiu/pkg/pluginregistry"
 	"github.com/apache/dubbo-go-pixiu/pkg/server"
 
-	tpconst "github.com/dubbogo/triple/pkg/common/constant" //nolint
-
 	"github.com/stretchr/testify/assert"
 )
 
-var count int32
-
-func newTripleRefConf(iface, protocol string) dconfig.ReferenceConfig {
-
-	refConf := dconfig.ReferenceConfig{
-		InterfaceName:  iface,
-		Cluster:        "failover",
-		RegistryIDs:    []string{"zk"},
-		Protocol:       protocol,
-		Generic:        "true",
-		Group:          "test",
-		Version:        "1.0.0",
-		URL:            "tri://127.0.0.1:9999/" + iface + "?" + constant.SerializationKey + "=hessian2",
-		RequestTimeout: "10s",
-	}
-
-	rootConfig := dconfig.NewRootConfigBuilder().
-		Build()
-	if err := dconfig.Load(dconfig.WithRootConfig(rootConfig)); err != nil {
-		panic(err)
-	}
-	_ = refConf.Init(rootConfig)
-	refConf.GenericLoad("dubbo.io")
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/dubbotest"
+)
 
-	return refConf
-}
+var count int32
 
 func TestTripleListenShutdown(t *testing.T) {
 	count = 0
@@ -74,14 +48,19 @@ func TestTripleListenShutdown(t *testing.T) {
 	// start pixiu listener
 	bootstrap := config.Load("../pixiu/conf.yaml")
 	go server.Start(bootstrap)
-	time.Sleep(3 * time.Second) // wait start already
+	testkit.WaitForGateway(t, "127.0.0.1:9999")
 
 	// start client
-	tripleRefConf := newTripleRefConf("com.dubbogo.pixiu.TripleUserService", tpconst.TRIPLE)
+	svc := dubbotest.NewGenericService(t, dubbotest.Reference{
+		Interface:      "com.dubbogo.pixiu.TripleUserService",
+		Addr:           "127.0.0.1:9999",
+		Protocol:       dubbotest.ProtocolTriple,
+		RequestTimeout: "10s",
+	})
 	call_wg := &sync.WaitGroup{}
 	call_wg.Add(3)
 	call_func := func() {
-		rsp, err := tripleRefConf.GetRPCService().(*generic.GenericService).Invoke(
+		rsp, err := svc.Invoke(
 			context.TODO(),
 			"TestByTriple",
 			[]string{"java.lang.String"},
@@ -41,16 +41,12 @@ array=(
   "plugins/opa/server-mode"
 )
 
-for t in "${array[@]}"; do
-  echo "::group::> start: $t"
-  bash ./integrate_test.sh "$t"
-  result=$?
-  echo "::endgroup::"
-  if [ "$result" -ne 0 ]; then
-    echo "[ERROR] failed: $t (exit code: $result)"
-    exit "$result"
-  fi
-  echo "> ok: $t"
-done
+# samples sharing a port are never run at the same time, whatever IGT_PARALLEL is
+go run ./tools/igt -parallel "${IGT_PARALLEL:-1}" "${array[@]}"
+result=$?
+if [ "$result" -ne 0 ]; then
+  echo "[ERROR] integration tests failed (exit code: $result)"
+  exit "$result"
+fi
 
 echo "> all tests passed."
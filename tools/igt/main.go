@@ -0,0 +1,214 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// igt runs the integration tests of the samples described by a sample.yaml manifest.
+//
+//	go run ./tools/igt http/simple dubbogo/simple/body
+//	go run ./tools/igt -all -parallel 4
+//	go run ./tools/igt -up dubbogo/simple/benchmark
+//	PIXIU_SRC=pathto/dubbo-go-pixiu go run ./tools/igt llm/mock
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+)
+
+var (
+	rootDir      = flag.String("root", ".", "repository root, samples are resolved against it")
+	all          = flag.Bool("all", false, "run every sample with a "+sample.FileName+" under the root")
+	parallel     = flag.Int("parallel", 1, "number of samples running at the same time, samples sharing a port the runner can not move never overlap")
+	workDir      = flag.String("work", "", "directory for binaries, rendered configs and logs, a temporary one by default")
+	keep         = flag.Bool("keep", false, "keep the work directory even if every sample passed")
+	up           = flag.Bool("up", false, "start the samples on their own ports without testing them and keep them running until interrupted")
+	readyTimeout = flag.Duration("ready-timeout", 60*time.Second, "default timeout of readiness probes")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(log.Ltime)
+
+	if err := run(); err != nil {
+		log.Printf("[igt] %v", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	root, err := filepath.Abs(*rootDir)
+	if err != nil {
+		return err
+	}
+
+	dirs := flag.Args()
+	if *all {
+		if dirs, err = sample.Discover(root); err != nil {
+			return err
+		}
+	}
+	if len(dirs) == 0 {
+		flag.Usage()
+		return fmt.Errorf("no sample given")
+	}
+
+	manifests := make([]*sample.Manifest, 0, len(dirs))
+	for _, dir := range dirs {
+		m, err := sample.Load(filepath.Join(root, dir))
+		if err != nil {
+			return err
+		}
+		if err = m.Validate(); err != nil {
+			return err
+		}
+		if m.Name == "" {
+			m.Name = filepath.ToSlash(filepath.Clean(dir))
+		}
+		manifests = append(manifests, m)
+	}
+
+	work := *workDir
+	if work == "" {
+		if work, err = os.MkdirTemp("", "igt-"); err != nil {
+			return err
+		}
+	}
+	if work, err = filepath.Abs(work); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r := &runner{
+		root:         root,
+		work:         work,
+		readyTimeout: *readyTimeout,
+		up:           *up,
+	}
+	if err = r.prepare(ctx, manifests); err != nil {
+		return err
+	}
+
+	n := *parallel
+	if r.up {
+		n = len(manifests)
+	}
+	results := schedule(ctx, r, manifests, n)
+	failed := report(results)
+
+	if failed == 0 && !*keep && !r.up {
+		_ = os.RemoveAll(work)
+	} else {
+		log.Printf("[igt] binaries, configs and logs are kept in %s", work)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d samples failed", failed, len(results))
+	}
+	return nil
+}
+
+type result struct {
+	name     string
+	err      error
+	skipped  string
+	duration time.Duration
+}
+
+// schedule runs the samples with at most n at a time. The runner moves the ports of pixiu and of the processes
+// naming their port in args to free ones, a sample only locks the ports left, so two samples binding one of those
+// are never running together
+func schedule(ctx context.Context, r *runner, manifests []*sample.Manifest, n int) []result {
+	if n < 1 {
+		n = 1
+	}
+	slots := make(chan struct{}, n)
+	ports := newPortLocks()
+	results := make([]result, len(manifests))
+
+	var wg sync.WaitGroup
+	for i, m := range manifests {
+		wg.Add(1)
+		go func(i int, m *sample.Manifest) {
+			defer wg.Done()
+
+			if reason := r.skipReason(m); reason != "" {
+				results[i] = result{name: m.Name, skipped: reason}
+				return
+			}
+
+			locked := fixedPorts(m)
+			if r.up {
+				locked = m.Ports()
+			}
+			unlock := ports.lock(locked)
+			defer unlock()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			start := time.Now()
+			err := ctx.Err()
+			if err == nil {
+				err = r.run(ctx, m)
+			}
+			results[i] = result{name: m.Name, err: err, duration: time.Since(start)}
+		}(i, m)
+	}
+	wg.Wait()
+	return results
+}
+
+// skipReason tells why a sample can not be run here, empty when it can
+func (r *runner) skipReason(m *sample.Manifest) string {
+	if m.Test == "" && !r.up {
+		return "no test package"
+	}
+	if missing := m.Requires.Missing(); len(missing) > 0 {
+		return "missing " + strings.Join(missing, ", ")
+	}
+	return ""
+}
+
+func report(results []result) int {
+	failed := 0
+	log.Printf("[igt] summary:")
+	for _, res := range results {
+		switch {
+		case res.skipped != "":
+			log.Printf("  skip %-32s         %s", res.name, res.skipped)
+		case res.err != nil:
+			failed++
+			log.Printf("  FAIL %-32s %6.1fs", res.name, res.duration.Seconds())
+			log.Printf("       %v", res.err)
+		default:
+			log.Printf("  ok   %-32s %6.1fs", res.name, res.duration.Seconds())
+		}
+	}
+	return failed
+}
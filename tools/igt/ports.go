@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+// portLocks hands out one mutex per port
+type portLocks struct {
+	mu    sync.Mutex
+	locks map[int]*sync.Mutex
+}
+
+func newPortLocks() *portLocks {
+	return &portLocks{locks: map[int]*sync.Mutex{}}
+}
+
+// lock blocks until every port is free for the caller, ports are taken in ascending order to avoid deadlocks
+func (p *portLocks) lock(ports []int) func() {
+	sorted := uniquePorts(ports)
+
+	held := make([]*sync.Mutex, 0, len(sorted))
+	for _, port := range sorted {
+		l := p.get(port)
+		l.Lock()
+		held = append(held, l)
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+		}
+	}
+}
+
+func (p *portLocks) get(port int) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.locks[port]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[port] = l
+	}
+	return l
+}
+
+func uniquePorts(ports []int) []int {
+	seen := map[int]bool{}
+	out := make([]int, 0, len(ports))
+	for _, port := range ports {
+		if !seen[port] {
+			seen[port] = true
+			out = append(out, port)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// checkPortsFree fails when something already listens on one of the ports, usually a leftover process
+func checkPortsFree(ports []int) error {
+	for _, port := range uniquePorts(ports) {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 300*time.Millisecond)
+		if err == nil {
+			_ = conn.Close()
+			return fmt.Errorf("port %d is already in use, is a process of a previous run still alive?", port)
+		}
+	}
+	return nil
+}
+
+// fixedPorts returns the ports of a sample that stay where they are, samples sharing one of them still wait for each other
+func fixedPorts(m *sample.Manifest) []int {
+	return without(m.Ports(), m.MovablePorts())
+}
+
+func without(ports, drop []int) []int {
+	dropped := map[int]bool{}
+	for _, port := range drop {
+		dropped[port] = true
+	}
+	var kept []int
+	for _, port := range ports {
+		if !dropped[port] {
+			kept = append(kept, port)
+		}
+	}
+	return kept
+}
+
+// movePorts gives every port a free one, the listeners are held until all are picked so no port is handed out twice
+func movePorts(ports []int) (portmap.Ports, error) {
+	moved := portmap.Ports{}
+	var held []net.Listener
+	defer func() {
+		for _, ln := range held {
+			_ = ln.Close()
+		}
+	}()
+	for _, port := range uniquePorts(ports) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		held = append(held, ln)
+		moved[port] = ln.Addr().(*net.TCPAddr).Port
+	}
+	return moved, nil
+}
+
+// moveProbe returns a copy of the probe checking the moved port
+func moveProbe(p *sample.Probe, moved portmap.Ports) *sample.Probe {
+	if p == nil {
+		return nil
+	}
+	probe := *p
+	probe.TCP = moved.Replace(p.TCP)
+	probe.HTTP = moved.Replace(p.HTTP)
+	return &probe
+}
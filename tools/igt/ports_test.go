@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+)
+
+func TestUniquePorts(t *testing.T) {
+	assert.Equal(t, []int{1314, 2181, 8888}, uniquePorts([]int{8888, 2181, 1314, 2181}))
+}
+
+func TestPortLocksSerializeSharedPorts(t *testing.T) {
+	locks := newPortLocks()
+	unlock := locks.lock([]int{8888, 2181})
+
+	var acquired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		release := locks.lock([]int{2181, 1314})
+		acquired.Store(true)
+		release()
+	}()
+
+	// a sample with disjoint ports is not blocked
+	locks.lock([]int{9000})()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, acquired.Load())
+
+	unlock()
+	<-done
+	assert.True(t, acquired.Load())
+}
+
+func TestFixedPorts(t *testing.T) {
+	m := &sample.Manifest{
+		Services: []sample.Service{{Name: "zookeeper", Ports: []int{2181}}},
+		Processes: []sample.Process{
+			{Name: "server", Kind: sample.KindGo, Ports: []int{1314}},
+			{Name: "mock", Kind: sample.KindGo, Args: []string{"-addr", ":8090"}, Ports: []int{8090}},
+			{Name: "pixiu", Kind: sample.KindPixiu, Ports: []int{8888, 8889}},
+		},
+		PinnedPorts: []int{8889},
+	}
+	assert.Equal(t, []int{2181, 1314, 8889}, fixedPorts(m))
+}
+
+func TestMovePorts(t *testing.T) {
+	moved, err := movePorts([]int{8888, 8090, 8888})
+	require.NoError(t, err)
+	require.Len(t, moved, 2)
+	assert.NotEqual(t, moved[8888], moved[8090])
+
+	probe := moveProbe(&sample.Probe{HTTP: "http://127.0.0.1:8090/models"}, moved)
+	assert.Equal(t, fmt.Sprintf("http://127.0.0.1:%d/models", moved[8090]), probe.HTTP)
+	assert.Nil(t, moveProbe(nil, moved))
+}
+
+func TestCheckPortsFree(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	assert.Error(t, checkPortsFree([]int{port}))
+
+	require.NoError(t, ln.Close())
+	assert.NoError(t, checkPortsFree([]int{port}))
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+)
+
+const probeInterval = 200 * time.Millisecond
+
+// waitReady polls the probe until it passes, alive is checked between attempts so a crashed process fails fast
+func waitReady(ctx context.Context, p *sample.Probe, def time.Duration, alive func() error) error {
+	if p == nil {
+		return nil
+	}
+	timeout := time.Duration(p.Timeout)
+	if timeout <= 0 {
+		timeout = def
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	var last error
+	for {
+		if last = probeOnce(ctx, p); last == nil {
+			return nil
+		}
+		if alive != nil {
+			if err := alive(); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready after %s: %v", timeout, last)
+		case <-ticker.C:
+		}
+	}
+}
+
+func probeOnce(ctx context.Context, p *sample.Probe) error {
+	switch {
+	case p.TCP != "":
+		d := net.Dialer{Timeout: time.Second}
+		conn, err := d.DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.HTTP != "":
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("GET %s: %s", p.HTTP, resp.Status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("probe has neither tcp nor http")
+	}
+}
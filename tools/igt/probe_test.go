@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+)
+
+func TestWaitReady(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	err := waitReady(context.Background(), &sample.Probe{HTTP: srv.URL}, 5*time.Second, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+
+	tcp := &sample.Probe{TCP: srv.Listener.Addr().String()}
+	assert.NoError(t, waitReady(context.Background(), tcp, time.Second, nil))
+}
+
+func TestWaitReadyFailsFast(t *testing.T) {
+	probe := &sample.Probe{TCP: "127.0.0.1:1", Timeout: sample.Duration(time.Minute)}
+
+	start := time.Now()
+	err := waitReady(context.Background(), probe, time.Minute, func() error {
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	probe.Timeout = sample.Duration(300 * time.Millisecond)
+	assert.Error(t, waitReady(context.Background(), probe, time.Minute, nil))
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// stopGrace is how long a process may take to exit after SIGTERM before it is killed
+const stopGrace = 10 * time.Second
+
+// process is a started backend or gateway, its output goes to logPath
+type process struct {
+	name    string
+	logPath string
+	cmd     *exec.Cmd
+	logFile *os.File
+
+	done    chan struct{}
+	waitErr error
+	once    sync.Once
+}
+
+func startProcess(name, logPath, dir string, env []string, bin string, args ...string) (*process, error) {
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	setProcessGroup(cmd)
+
+	if err = cmd.Start(); err != nil {
+		_ = logFile.Close()
+		return nil, fmt.Errorf("start %s: %w", name, err)
+	}
+
+	p := &process{
+		name:    name,
+		logPath: logPath,
+		cmd:     cmd,
+		logFile: logFile,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		p.waitErr = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// alive returns an error once the process has exited
+func (p *process) alive() error {
+	select {
+	case <-p.done:
+		return fmt.Errorf("%s exited early: %v, see %s", p.name, p.waitErr, p.logPath)
+	default:
+		return nil
+	}
+}
+
+// stop terminates the whole process group, and kills it when it does not exit within stopGrace
+func (p *process) stop() {
+	p.once.Do(func() {
+		defer p.logFile.Close()
+
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		_ = terminateProcessGroup(p.cmd.Process)
+		select {
+		case <-p.done:
+		case <-time.After(stopGrace):
+			_ = killProcessGroup(p.cmd.Process)
+			<-p.done
+		}
+	})
+}
+
+// tailFile returns the last n lines of a log file
+func tailFile(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
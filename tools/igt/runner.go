@@ -0,0 +1,423 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/sample"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+// logTailLines is how many lines of each process log are printed when a sample fails
+const logTailLines = 50
+
+// outputMu keeps the output of samples running in parallel from interleaving
+var outputMu sync.Mutex
+
+type runner struct {
+	root         string
+	work         string
+	readyTimeout time.Duration
+	// up starts the samples without testing them, on their own ports, until the run is interrupted
+	up bool
+
+	pixiuBin         string
+	upstreamPixiuBin string
+	hostIP           string
+}
+
+// prepare builds the pixiu binaries once for all samples, the one of go.mod and, for the samples requiring it,
+// the one built against the upstream checkout
+func (r *runner) prepare(ctx context.Context, manifests []*sample.Manifest) error {
+	if err := os.MkdirAll(r.work, 0o755); err != nil {
+		return err
+	}
+	r.hostIP, _ = os.Hostname()
+
+	var release, upstream bool
+	for _, m := range manifests {
+		if r.skipReason(m) != "" {
+			continue
+		}
+		for _, p := range m.Processes {
+			if p.Kind == sample.KindPixiu {
+				upstream = upstream || m.Requires.UpstreamPixiu
+				release = release || !m.Requires.UpstreamPixiu
+			}
+		}
+	}
+
+	if release {
+		r.pixiuBin = filepath.Join(r.work, "bin", "dubbo-go-pixiu"+exeSuffix())
+		log.Printf("[igt] building pixiu into %s", r.pixiuBin)
+		if err := r.goBuild(ctx, r.pixiuBin, "./pixiu", os.Stdout); err != nil {
+			return err
+		}
+	}
+	if upstream {
+		src := os.Getenv(sample.UpstreamPixiuEnv)
+		modfile, err := r.upstreamModfile(src)
+		if err != nil {
+			return err
+		}
+		r.upstreamPixiuBin = filepath.Join(r.work, "bin", "dubbo-go-pixiu-upstream"+exeSuffix())
+		log.Printf("[igt] building pixiu against %s into %s", src, r.upstreamPixiuBin)
+		return r.goBuild(ctx, r.upstreamPixiuBin, "./pixiu", os.Stdout, "-modfile", modfile, "-mod", "mod")
+	}
+	return nil
+}
+
+// upstreamModfile writes a copy of go.mod that replaces pixiu with the checkout in src,
+// go build reads it with -modfile and keeps go.mod and go.sum of the repository untouched
+func (r *runner) upstreamModfile(src string) (string, error) {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	mod, err := os.ReadFile(filepath.Join(r.root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	sum, err := os.ReadFile(filepath.Join(r.root, "go.sum"))
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(r.work, "upstream")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	mod = append(mod, fmt.Sprintf("\nreplace github.com/apache/dubbo-go-pixiu => %s\n", src)...)
+	modfile := filepath.Join(dir, "go.mod")
+	if err = os.WriteFile(modfile, mod, 0o644); err != nil {
+		return "", err
+	}
+	return modfile, os.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o644)
+}
+
+// run starts everything the sample needs, runs its tests and tears it all down again. With up it waits
+// for the run to be interrupted instead of running the tests
+func (r *runner) run(ctx context.Context, m *sample.Manifest) (err error) {
+	dir := filepath.Join(r.work, strings.ReplaceAll(m.Name, "/", "_"))
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	out := &sampleLog{name: m.Name}
+	defer out.flush()
+
+	if m.Compose != "" {
+		if err = r.composeUp(ctx, m, out); err != nil {
+			return err
+		}
+		defer r.composeDown(m, out)
+	}
+	for _, s := range m.Services {
+		out.printf("waiting for service %s", s.Name)
+		if err = waitReady(ctx, s.Ready, r.readyTimeout, nil); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+
+	var ports []int
+	for _, p := range m.Processes {
+		ports = append(ports, p.Ports...)
+	}
+	if err = checkPortsFree(without(ports, r.movablePorts(m))); err != nil {
+		return err
+	}
+	moved, err := movePorts(r.movablePorts(m))
+	if err != nil {
+		return err
+	}
+	if len(moved) > 0 {
+		out.printf("moved ports %s", moved)
+	}
+
+	var started []*process
+	defer func() {
+		for i := len(started) - 1; i >= 0; i-- {
+			started[i].stop()
+		}
+		if err != nil {
+			for _, p := range started {
+				out.printf("last %d lines of %s:", logTailLines, p.logPath)
+				for _, line := range tailFile(p.logPath, logTailLines) {
+					out.printf("  | %s", line)
+				}
+			}
+		}
+	}()
+
+	for _, spec := range m.Processes {
+		p, err := r.startProcess(ctx, m, spec, dir, moved, out)
+		if err != nil {
+			return err
+		}
+		started = append(started, p)
+
+		out.printf("waiting for %s", spec.Name)
+		if err = waitReady(ctx, moveProbe(spec.Ready, moved), r.readyTimeout, p.alive); err != nil {
+			return fmt.Errorf("%s: %w", spec.Name, err)
+		}
+	}
+
+	if r.up {
+		out.printf("up, the logs are in %s, interrupt to stop it", dir)
+		out.flush()
+		<-ctx.Done()
+		return nil
+	}
+	return r.goTest(ctx, m, dir, moved, out)
+}
+
+// movablePorts are the ports of the sample the runner moves, none with up, the sample then answers
+// on the ports of its readme
+func (r *runner) movablePorts(m *sample.Manifest) []int {
+	if r.up {
+		return nil
+	}
+	return m.MovablePorts()
+}
+
+// startProcess starts one process of the sample, the ports in its args, env and pixiu configs are replaced by the moved ones
+func (r *runner) startProcess(ctx context.Context, m *sample.Manifest, spec sample.Process, dir string, moved portmap.Ports, out *sampleLog) (*process, error) {
+	vars := map[string]string{
+		"ROOT_DIR":   r.root,
+		"SAMPLE_DIR": m.Dir,
+		"WORK_DIR":   dir,
+	}
+
+	var (
+		bin  string
+		args []string
+	)
+	switch spec.Kind {
+	case sample.KindGo:
+		bin = filepath.Join(dir, spec.Name+exeSuffix())
+		pkg := "./" + filepath.ToSlash(filepath.Join(relPath(r.root, m.Dir), spec.Package))
+		out.printf("building %s", pkg)
+		if err := r.goBuild(ctx, bin, pkg, out); err != nil {
+			return nil, err
+		}
+	case sample.KindExec:
+		bin = expand(spec.Command[0], vars)
+		for _, a := range spec.Command[1:] {
+			args = append(args, expand(a, vars))
+		}
+	case sample.KindPixiu:
+		bin = r.pixiuBin
+		if m.Requires.UpstreamPixiu {
+			bin = r.upstreamPixiuBin
+		}
+		conf, err := r.renderConfig(m, spec.Config, filepath.Join(dir, spec.Name), moved)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "gateway", "start", "-c", conf)
+		if spec.APIConfig != "" {
+			apiConf, err := r.renderConfig(m, spec.APIConfig, filepath.Join(dir, spec.Name), moved)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-a", apiConf)
+		}
+	default:
+		return nil, fmt.Errorf("process %s: unknown kind %q", spec.Name, spec.Kind)
+	}
+	for _, a := range spec.Args {
+		args = append(args, moved.Replace(expand(a, vars)))
+	}
+
+	env := os.Environ()
+	for k, v := range spec.Env {
+		env = append(env, k+"="+moved.Replace(expand(v, vars)))
+	}
+
+	workDir := r.root
+	if spec.Dir != "" {
+		workDir = filepath.Join(m.Dir, spec.Dir)
+	}
+
+	out.printf("starting %s", spec.Name)
+	return startProcess(spec.Name, filepath.Join(dir, spec.Name+".log"), workDir, env, bin, args...)
+}
+
+// renderConfig replaces the $HOST_IP and $PROJECT_DIR placeholders the configs of the samples use,
+// moves the ports, and writes the result into dir
+func (r *runner) renderConfig(m *sample.Manifest, rel, dir string, moved portmap.Ports) (string, error) {
+	content, err := os.ReadFile(filepath.Join(m.Dir, rel))
+	if err != nil {
+		return "", err
+	}
+	rendered := []byte(strings.NewReplacer("$HOST_IP", r.hostIP, "$PROJECT_DIR", m.Dir).Replace(string(content)))
+	if len(moved) > 0 {
+		if rendered, err = moved.RewriteYAML(rendered); err != nil {
+			return "", fmt.Errorf("%s: %w", rel, err)
+		}
+	}
+
+	target := filepath.Join(dir, filepath.Base(rel))
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	return target, os.WriteFile(target, rendered, 0o644)
+}
+
+func (r *runner) goBuild(ctx context.Context, bin, pkg string, out io.Writer, flags ...string) error {
+	args := append([]string{"build", "-o", bin}, flags...)
+	cmd := r.command(ctx, "go", append(args, pkg)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go build %s: %w", pkg, err)
+	}
+	return nil
+}
+
+// goTest runs the tests of the sample, testkit reads the moved ports from the environment
+func (r *runner) goTest(ctx context.Context, m *sample.Manifest, dir string, moved portmap.Ports, out *sampleLog) error {
+	pkg := "./" + filepath.ToSlash(filepath.Join(relPath(r.root, m.Dir), m.Test)) + "/..."
+
+	logFile, err := os.Create(filepath.Join(dir, "test.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	out.printf("go test %s", pkg)
+	args := []string{"test", "-tags", "integration", "-count=1", "-v"}
+	if m.Run != "" {
+		args = append(args, "-run", m.Run)
+	}
+	cmd := r.command(ctx, "go", append(args, pkg)...)
+	cmd.Env = append(os.Environ(), portmap.EnvName+"="+moved.String())
+	cmd.Stdout = io.MultiWriter(logFile, out)
+	cmd.Stderr = cmd.Stdout
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("go test %s: %w", pkg, err)
+	}
+	return nil
+}
+
+func (r *runner) composeUp(ctx context.Context, m *sample.Manifest, out *sampleLog) error {
+	out.printf("docker compose up %s", m.Compose)
+	cmd := r.command(ctx, "docker", r.composeArgs(m, "up", "-d")...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker compose up: %w", err)
+	}
+	return nil
+}
+
+// composeDown does not use the run context, services must go away even when the run is interrupted
+func (r *runner) composeDown(m *sample.Manifest, out *sampleLog) {
+	out.printf("docker compose down %s", m.Compose)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	cmd := r.command(ctx, "docker", r.composeArgs(m, "down", "-v", "--remove-orphans")...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		out.printf("docker compose down failed: %v", err)
+	}
+}
+
+// composeArgs uses a project name per sample, compose files all live in a "docker" directory
+// and would otherwise share the same default project
+func (r *runner) composeArgs(m *sample.Manifest, args ...string) []string {
+	project := "igt-" + strings.NewReplacer("/", "-", "_", "-", ".", "-").Replace(strings.ToLower(m.Name))
+	return append([]string{"compose", "-f", filepath.Join(m.Dir, m.Compose), "-p", project}, args...)
+}
+
+// command runs in the repository root and takes its process group down when ctx is cancelled
+func (r *runner) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = r.root
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd.Process)
+	}
+	cmd.WaitDelay = stopGrace
+	return cmd
+}
+
+// sampleLog buffers the output of one sample and prints it in one piece
+type sampleLog struct {
+	name string
+	mu   sync.Mutex
+	buf  strings.Builder
+}
+
+func (l *sampleLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *sampleLog) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(l, "%s > %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (l *sampleLog) flush() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buf.Len() == 0 {
+		return
+	}
+	fmt.Printf("::group::> %s\n%s::endgroup::\n", l.name, l.buf.String())
+	l.buf.Reset()
+}
+
+func expand(s string, vars map[string]string) string {
+	return os.Expand(s, func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	})
+}
+
+func relPath(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return target
+	}
+	return rel
+}
+
+func exeSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
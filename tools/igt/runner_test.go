@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamModfile(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/samples\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.sum"), []byte("example.com/dep v1.0.0 h1:x=\n"), 0o644))
+	r := &runner{root: root, work: t.TempDir()}
+
+	src := t.TempDir()
+	modfile, err := r.upstreamModfile(src)
+	require.NoError(t, err)
+	mod, err := os.ReadFile(modfile)
+	require.NoError(t, err)
+	assert.Equal(t, "module example.com/samples\n\nreplace github.com/apache/dubbo-go-pixiu => "+src+"\n", string(mod))
+	sum, err := os.ReadFile(filepath.Join(filepath.Dir(modfile), "go.sum"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/dep v1.0.0 h1:x=\n", string(sum))
+
+	mod, err = os.ReadFile(filepath.Join(root, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module example.com/samples\n", string(mod), "go.mod of the repository is left as it is")
+}
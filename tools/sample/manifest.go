@@ -0,0 +1,248 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sample loads and validates the sample.yaml manifest that describes how a sample is started and tested.
+package sample
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+// FileName is the manifest file name expected in every sample directory
+const FileName = "sample.yaml"
+
+const (
+	// KindGo is a go main package built and started by the runner
+	KindGo = "go"
+	// KindPixiu is the pixiu gateway built from the pixiu directory of this repository
+	KindPixiu = "pixiu"
+	// KindExec is any other command, like a java service or a go module of its own
+	KindExec = "exec"
+)
+
+// UpstreamPixiuEnv names the dubbo-go-pixiu checkout the pixiu of a sample requiring UpstreamPixiu is built against
+const UpstreamPixiuEnv = "PIXIU_SRC"
+
+// Manifest describes one sample
+type Manifest struct {
+	// Name is shown in reports, the runner defaults it to the sample path relative to the repository root
+	Name string `yaml:"name"`
+	// Compose is the docker compose file starting the external services, relative to the sample
+	Compose   string       `yaml:"compose"`
+	Services  []Service    `yaml:"services"`
+	Processes []Process    `yaml:"processes"`
+	Requires  Requirements `yaml:"requires"`
+	// Test is the go test package, relative to the sample, samples without one are not run by the runner
+	Test string `yaml:"test"`
+	// Run is passed to go test -run when only some tests fit the started processes
+	Run string `yaml:"run"`
+	// TestPorts are bound by the test binary itself, like the shutdown samples starting pixiu in process
+	TestPorts []int `yaml:"test_ports"`
+	// PinnedPorts are process ports other programs know by number, like a pixiu port the dubbo client of a test dials,
+	// the runner never moves them
+	PinnedPorts []int `yaml:"pinned_ports"`
+
+	// Dir is the absolute sample directory, set by Load
+	Dir string `yaml:"-"`
+}
+
+// Service is an external dependency started by docker compose
+type Service struct {
+	Name  string `yaml:"name"`
+	Ports []int  `yaml:"ports"`
+	Ready *Probe `yaml:"ready"`
+}
+
+// Requirements are what the runner can not provide itself
+type Requirements struct {
+	// Env are environment variables that must be set, like the api key of an LLM provider
+	Env []string `yaml:"env"`
+	// Tools are executables that must be on the PATH, like mvn or kubectl
+	Tools []string `yaml:"tools"`
+	// Manual describes a step a person has to do, the runner always skips samples with one
+	Manual string `yaml:"manual"`
+	// UpstreamPixiu samples use filters the pixiu release in go.mod does not ship yet, like the llm ones,
+	// their pixiu is built against the checkout UpstreamPixiuEnv names
+	UpstreamPixiu bool `yaml:"upstream_pixiu"`
+}
+
+// Missing returns the unmet requirements, empty when the sample can be run
+func (r Requirements) Missing() []string {
+	var missing []string
+	if r.Manual != "" {
+		missing = append(missing, "manual setup: "+r.Manual)
+	}
+	for _, env := range r.Env {
+		if os.Getenv(env) == "" {
+			missing = append(missing, "environment variable "+env)
+		}
+	}
+	for _, tool := range r.Tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			missing = append(missing, "executable "+tool)
+		}
+	}
+	if r.UpstreamPixiu {
+		if src := os.Getenv(UpstreamPixiuEnv); src == "" {
+			missing = append(missing, "environment variable "+UpstreamPixiuEnv+" naming a dubbo-go-pixiu checkout")
+		} else if _, err := os.Stat(filepath.Join(src, "pkg", "filter", "llm")); err != nil {
+			missing = append(missing, "pkg/filter/llm in "+UpstreamPixiuEnv+" "+src)
+		}
+	}
+	return missing
+}
+
+// Process is a process started by the runner
+type Process struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Dir is the working directory relative to the sample, the repository root when empty
+	Dir string `yaml:"dir"`
+	// Package is the go package of a KindGo process, relative to the sample
+	Package string `yaml:"package"`
+	// Command is the command line of a KindExec process
+	Command []string `yaml:"command"`
+	// Config and APIConfig are the pixiu conf.yaml and api_config.yaml of a KindPixiu process, relative to the sample
+	Config    string            `yaml:"config"`
+	APIConfig string            `yaml:"api_config"`
+	Args      []string          `yaml:"args"`
+	Env       map[string]string `yaml:"env"`
+	Ports     []int             `yaml:"ports"`
+	Ready     *Probe            `yaml:"ready"`
+}
+
+// Probe tells when a process or service is ready, exactly one of TCP and HTTP is set
+type Probe struct {
+	// TCP is a host:port that must accept connections
+	TCP string `yaml:"tcp"`
+	// HTTP is an url that must answer a GET with a 2xx status
+	HTTP    string   `yaml:"http"`
+	Timeout Duration `yaml:"timeout"`
+}
+
+// Duration is a time.Duration read from strings like "30s"
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	v, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads the manifest of the sample in dir
+func Load(dir string) (*Manifest, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(abs, FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err = yaml.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, FileName), err)
+	}
+	m.Dir = abs
+	return m, nil
+}
+
+// Discover returns the directories under root containing a manifest, sorted
+func Discover(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == "dist") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == FileName {
+			rel, err := filepath.Rel(root, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs, err
+}
+
+// Ports returns every port the sample binds, services and tests included
+func (m *Manifest) Ports() []int {
+	ports := append([]int(nil), m.TestPorts...)
+	for _, s := range m.Services {
+		ports = append(ports, s.Ports...)
+	}
+	for _, p := range m.Processes {
+		ports = append(ports, p.Ports...)
+	}
+	return ports
+}
+
+// MovablePorts returns the process ports the runner can move to free ones: the ports of pixiu, whose config it rewrites,
+// and the ports named in the args or env of a process. The other ports are fixed in code or in configs the runner does not know.
+func (m *Manifest) MovablePorts() []int {
+	pinned := map[int]bool{}
+	for _, port := range m.PinnedPorts {
+		pinned[port] = true
+	}
+	var ports []int
+	for _, p := range m.Processes {
+		for _, port := range p.Ports {
+			if !pinned[port] && (p.Kind == KindPixiu || p.mentions(port)) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+func (p *Process) mentions(port int) bool {
+	for _, arg := range p.Args {
+		if portmap.Mentions(arg, port) {
+			return true
+		}
+	}
+	for _, v := range p.Env {
+		if portmap.Mentions(v, port) {
+			return true
+		}
+	}
+	return false
+}
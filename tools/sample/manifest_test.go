@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sample
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+compose: docker/docker-compose.yml
+services:
+  - name: zookeeper
+    ports: [2181]
+    ready:
+      tcp: 127.0.0.1:2181
+      timeout: 30s
+processes:
+  - name: server
+    kind: go
+    package: server/app
+    ports: [20000]
+  - name: pixiu
+    kind: pixiu
+    config: pixiu/conf.yaml
+    ports: [8881]
+    ready:
+      http: http://127.0.0.1:8881/health
+test: test
+`
+
+func writeManifest(t *testing.T, dir, content string) {
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, testManifest)
+
+	m, err := Load(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, dir, m.Dir)
+	assert.Equal(t, "docker/docker-compose.yml", m.Compose)
+	require.Len(t, m.Services, 1)
+	assert.Equal(t, Duration(30*time.Second), m.Services[0].Ready.Timeout)
+	require.Len(t, m.Processes, 2)
+	assert.Equal(t, KindGo, m.Processes[0].Kind)
+	assert.Nil(t, m.Processes[0].Ready)
+	assert.Equal(t, "http://127.0.0.1:8881/health", m.Processes[1].Ready.HTTP)
+	assert.Equal(t, []int{2181, 20000, 8881}, m.Ports())
+}
+
+func TestMovablePorts(t *testing.T) {
+	m := &Manifest{
+		Processes: []Process{
+			{Name: "server", Kind: KindGo, Ports: []int{1314}},
+			{Name: "mock", Kind: KindGo, Args: []string{"-addr", ":8090"}, Ports: []int{8090}},
+			{Name: "pixiu", Kind: KindPixiu, Ports: []int{8888, 8889}},
+		},
+		PinnedPorts: []int{8889},
+	}
+	assert.Equal(t, []int{8090, 8888}, m.MovablePorts())
+}
+
+func TestMissingUpstreamPixiu(t *testing.T) {
+	r := Requirements{UpstreamPixiu: true}
+
+	t.Setenv(UpstreamPixiuEnv, "")
+	assert.Equal(t, []string{"environment variable PIXIU_SRC naming a dubbo-go-pixiu checkout"}, r.Missing())
+
+	src := t.TempDir()
+	t.Setenv(UpstreamPixiuEnv, src)
+	assert.Equal(t, []string{"pkg/filter/llm in PIXIU_SRC " + src}, r.Missing())
+
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "pkg", "filter", "llm"), 0o755))
+	assert.Empty(t, r.Missing())
+}
+
+func TestLoadBadDuration(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "services:\n  - name: a\n    ready:\n      timeout: soon\n")
+
+	_, err := Load(dir)
+	assert.Error(t, err)
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeManifest(t, filepath.Join(root, "http", "simple"), testManifest)
+	writeManifest(t, filepath.Join(root, "dubbogo", "simple", "body"), testManifest)
+	writeManifest(t, filepath.Join(root, "http", "simple", "dist"), testManifest)
+
+	dirs, err := Discover(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("dubbogo", "simple", "body"), filepath.Join("http", "simple")}, dirs)
+}
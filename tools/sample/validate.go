@@ -0,0 +1,292 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// pixiuDefaultPort is the listener port pixiu uses when the config leaves it out
+const pixiuDefaultPort = 8881
+
+// Validate checks the manifest against itself and against the files of the sample,
+// every problem found is reported, not only the first one
+func (m *Manifest) Validate() error {
+	v := &validator{m: m, owners: map[int]string{}}
+
+	v.file("compose", m.Compose, false)
+	if m.Test != "" {
+		v.file("test", m.Test, true)
+	}
+	for _, port := range m.TestPorts {
+		v.own(port, "test")
+	}
+
+	names := map[string]bool{}
+	pixius := 0
+	for i, s := range m.Services {
+		where := fmt.Sprintf("services[%d] %s", i, s.Name)
+		if s.Name == "" {
+			v.errorf("services[%d]: name is required", i)
+		}
+		if names[s.Name] {
+			v.errorf("%s: duplicate name", where)
+		}
+		names[s.Name] = true
+		if m.Compose == "" && m.Requires.Manual == "" {
+			v.errorf("%s: no compose file starts it, and no manual step is required", where)
+		}
+		for _, port := range s.Ports {
+			v.own(port, where)
+		}
+		v.probe(where, s.Ready, s.Ports)
+	}
+
+	for i, p := range m.Processes {
+		where := fmt.Sprintf("processes[%d] %s", i, p.Name)
+		if p.Name == "" {
+			v.errorf("processes[%d]: name is required", i)
+		}
+		if names[p.Name] {
+			v.errorf("%s: duplicate name", where)
+		}
+		names[p.Name] = true
+		if p.Dir != "" {
+			v.file(where+" dir", p.Dir, true)
+		}
+
+		switch p.Kind {
+		case KindGo:
+			if p.Package == "" {
+				v.errorf("%s: package is required", where)
+			}
+			v.file(where+" package", p.Package, true)
+		case KindPixiu:
+			pixius++
+			v.file(where+" config", p.Config, false)
+			v.file(where+" api_config", p.APIConfig, false)
+		case KindExec:
+			if len(p.Command) == 0 {
+				v.errorf("%s: command is required", where)
+			}
+		default:
+			v.errorf("%s: kind must be one of %s, %s, %s, got %q", where, KindGo, KindPixiu, KindExec, p.Kind)
+		}
+
+		for _, port := range p.Ports {
+			v.own(port, where)
+		}
+		v.probe(where, p.Ready, p.Ports)
+	}
+
+	if m.Requires.UpstreamPixiu && pixius == 0 {
+		v.errorf("requires.upstream_pixiu: no process is of kind %s", KindPixiu)
+	}
+
+	for _, port := range m.PinnedPorts {
+		if owner, ok := v.owners[port]; !ok || !strings.HasPrefix(owner, "processes") {
+			v.errorf("pinned_ports: port %d is not bound by a process", port)
+		}
+	}
+
+	// the pixiu configs are checked last, every port owner is known by then
+	for i, p := range m.Processes {
+		if p.Kind == KindPixiu && p.Config != "" {
+			v.pixiuConfig(fmt.Sprintf("processes[%d] %s", i, p.Name), p)
+		}
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", filepath.Join(m.Dir, FileName), errors.Join(v.errs...))
+}
+
+type validator struct {
+	m      *Manifest
+	owners map[int]string
+	errs   []error
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+// file checks that a path relative to the sample exists, empty paths are optional
+func (v *validator) file(where, rel string, dir bool) {
+	if rel == "" {
+		return
+	}
+	info, err := os.Stat(filepath.Join(v.m.Dir, rel))
+	switch {
+	case err != nil:
+		v.errorf("%s: %s does not exist", where, rel)
+	case dir && !info.IsDir():
+		v.errorf("%s: %s is not a directory", where, rel)
+	case !dir && info.IsDir():
+		v.errorf("%s: %s is a directory", where, rel)
+	}
+}
+
+// own records who binds a port, a port bound twice in one sample is a mistake
+func (v *validator) own(port int, owner string) {
+	if port <= 0 || port > 65535 {
+		v.errorf("%s: invalid port %d", owner, port)
+		return
+	}
+	if other, ok := v.owners[port]; ok {
+		v.errorf("%s: port %d is already bound by %s", owner, port, other)
+		return
+	}
+	v.owners[port] = owner
+}
+
+// probe checks that exactly one check is set, and that it targets a port of its owner
+func (v *validator) probe(where string, p *Probe, ports []int) {
+	if p == nil {
+		return
+	}
+	var target string
+	switch {
+	case p.TCP != "" && p.HTTP != "":
+		v.errorf("%s: ready sets both tcp and http", where)
+		return
+	case p.TCP != "":
+		target = p.TCP
+	case p.HTTP != "":
+		u, err := url.Parse(p.HTTP)
+		if err != nil || u.Host == "" {
+			v.errorf("%s: ready http %q is not an absolute url", where, p.HTTP)
+			return
+		}
+		target = u.Host
+		if u.Port() == "" {
+			target = net.JoinHostPort(u.Hostname(), "80")
+		}
+	default:
+		v.errorf("%s: ready sets neither tcp nor http", where)
+		return
+	}
+
+	_, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		v.errorf("%s: ready target %q: %v", where, target, err)
+		return
+	}
+	port, _ := strconv.Atoi(portStr)
+	for _, own := range ports {
+		if own == port {
+			return
+		}
+	}
+	v.errorf("%s: ready probes port %d which is not in its ports", where, port)
+}
+
+// pixiuConf is the part of pixiu's conf.yaml the validator looks at
+type pixiuConf struct {
+	StaticResources struct {
+		Listeners []struct {
+			Name    string `yaml:"name"`
+			Address struct {
+				SocketAddress pixiuSocketAddress `yaml:"socket_address"`
+			} `yaml:"address"`
+		} `yaml:"listeners"`
+		Clusters []struct {
+			Name      string `yaml:"name"`
+			Endpoints []struct {
+				SocketAddress pixiuSocketAddress `yaml:"socket_address"`
+			} `yaml:"endpoints"`
+		} `yaml:"clusters"`
+	} `yaml:"static_resources"`
+	Metric struct {
+		Enable         bool `yaml:"enable"`
+		PrometheusPort int  `yaml:"prometheus_port"`
+	} `yaml:"metric"`
+}
+
+type pixiuSocketAddress struct {
+	Address string   `yaml:"address"`
+	Port    int      `yaml:"port"`
+	Domains []string `yaml:"domains"`
+}
+
+// pixiuConfig checks that the ports the gateway binds are declared on the process,
+// and that local cluster endpoints are served by something the manifest starts
+func (v *validator) pixiuConfig(where string, p Process) {
+	content, err := os.ReadFile(filepath.Join(v.m.Dir, p.Config))
+	if err != nil {
+		return
+	}
+	conf := &pixiuConf{}
+	if err = yaml.Unmarshal(content, conf); err != nil {
+		v.errorf("%s: %s: %v", where, p.Config, err)
+		return
+	}
+
+	declared := map[int]bool{}
+	for _, port := range p.Ports {
+		declared[port] = true
+	}
+	for _, port := range v.m.TestPorts {
+		declared[port] = true
+	}
+
+	for _, l := range conf.StaticResources.Listeners {
+		port := l.Address.SocketAddress.Port
+		if port == 0 {
+			port = pixiuDefaultPort
+			if len(l.Address.SocketAddress.Domains) > 0 {
+				// the certificates of a listener with domains are issued over https
+				port = 443
+			}
+		}
+		if !declared[port] {
+			v.errorf("%s: listener %q binds port %d which is not in its ports", where, l.Name, port)
+		}
+	}
+	if conf.Metric.Enable && conf.Metric.PrometheusPort != 0 && !declared[conf.Metric.PrometheusPort] {
+		v.errorf("%s: metric port %d is not in its ports", where, conf.Metric.PrometheusPort)
+	}
+
+	for _, c := range conf.StaticResources.Clusters {
+		for _, e := range c.Endpoints {
+			addr := e.SocketAddress
+			if addr.Port == 0 || !isLocal(addr.Address) {
+				continue
+			}
+			if _, ok := v.owners[addr.Port]; !ok {
+				v.errorf("%s: cluster %q sends to port %d which nothing in the manifest binds", where, c.Name, addr.Port)
+			}
+		}
+	}
+}
+
+func isLocal(host string) bool {
+	return host == "127.0.0.1" || host == "localhost" || host == "0.0.0.0"
+}
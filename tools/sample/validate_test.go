@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sample
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPixiuConf = `
+static_resources:
+  listeners:
+    - name: "net/http"
+      address:
+        socket_address:
+          address: "0.0.0.0"
+          port: 8888
+  clusters:
+    - name: "user"
+      endpoints:
+        - socket_address:
+            address: 127.0.0.1
+            port: 1314
+`
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "server", "app"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pixiu"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "test"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pixiu", "conf.yaml"), []byte(testPixiuConf), 0o644))
+
+	tests := []struct {
+		name     string
+		manifest string
+		errs     []string
+	}{
+		{
+			name: "valid",
+			manifest: `
+processes:
+  - {name: server, kind: go, package: server/app, ports: [1314], ready: {tcp: "127.0.0.1:1314"}}
+  - {name: pixiu, kind: pixiu, config: pixiu/conf.yaml, ports: [8888]}
+test: test
+`,
+		},
+		{
+			name: "invalid",
+			manifest: `
+services:
+  - {name: zookeeper, ports: [2181]}
+processes:
+  - {name: server, kind: go, package: server/missing, ports: [2181], ready: {tcp: "127.0.0.1:9999"}}
+  - {name: pixiu, kind: pixiu, config: pixiu/conf.yaml, ports: [8889]}
+  - {name: pixiu, kind: java}
+pinned_ports: [2181]
+`,
+			errs: []string{
+				"no compose file starts it",
+				"server/missing does not exist",
+				"ready probes port 9999",
+				"port 2181 is already bound by services[0] zookeeper",
+				"duplicate name",
+				"kind must be one of",
+				`listener "net/http" binds port 8888`,
+				`cluster "user" sends to port 1314`,
+				"pinned_ports: port 2181 is not bound by a process",
+			},
+		},
+		{
+			name: "upstream pixiu without pixiu",
+			manifest: `
+processes:
+  - {name: server, kind: go, package: server/app, ports: [1314]}
+requires:
+  upstream_pixiu: true
+test: test
+`,
+			errs: []string{"requires.upstream_pixiu: no process is of kind pixiu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeManifest(t, dir, tt.manifest)
+			m, err := Load(dir)
+			require.NoError(t, err)
+
+			err = m.Validate()
+			if len(tt.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, e := range tt.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+// TestRepositoryManifests keeps the manifests of the samples in this repository valid,
+// and makes sure no sample with a pixiu config is left without one
+func TestRepositoryManifests(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+
+	dirs, err := Discover(root)
+	require.NoError(t, err)
+	known := map[string]bool{}
+	for _, dir := range dirs {
+		known[dir] = true
+		m, err := Load(filepath.Join(root, dir))
+		require.NoError(t, err)
+		assert.NoError(t, m.Validate())
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || d.Name() == "dist" {
+			return filepath.SkipDir
+		}
+		if d.Name() != "pixiu" {
+			return nil
+		}
+		// the java samples have packages named pixiu too
+		if confs, _ := filepath.Glob(filepath.Join(path, "*.yaml")); len(confs) == 0 {
+			return filepath.SkipDir
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || dir == "." {
+			return err
+		}
+		assert.True(t, known[dir], "%s has a pixiu config but no %s", dir, FileName)
+		return filepath.SkipDir
+	})
+	require.NoError(t, err)
+}
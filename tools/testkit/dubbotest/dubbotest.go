@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dubbotest calls dubbo and triple services through pixiu with generic invocations.
+// It is kept apart from testkit, so the samples that only speak http do not link dubbo-go.
+package dubbotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+import (
+	"dubbo.apache.org/dubbo-go/v3/common/constant"
+	"dubbo.apache.org/dubbo-go/v3/config"
+	"dubbo.apache.org/dubbo-go/v3/config/generic" //nolint
+	_ "dubbo.apache.org/dubbo-go/v3/imports"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// ProtocolDubbo is the dubbo protocol
+	ProtocolDubbo = "dubbo"
+	// ProtocolTriple is the triple protocol
+	ProtocolTriple = "tri"
+
+	appName = "dubbo.io"
+)
+
+// Reference describes the service to call, Group and Version default to the ones of the samples
+type Reference struct {
+	Interface string
+	// Addr is the host:port pixiu listens on for the protocol
+	Addr           string
+	Protocol       string
+	Group          string
+	Version        string
+	RequestTimeout string
+}
+
+// URL is the direct url of the service, triple is called with hessian2
+func (r Reference) URL() string {
+	url := fmt.Sprintf("%s://%s/%s", r.Protocol, r.Addr, r.Interface)
+	if r.Protocol == ProtocolTriple {
+		url += "?" + constant.SerializationKey + "=hessian2"
+	}
+	return url
+}
+
+// NewGenericService loads a generic reference to the service
+func NewGenericService(t testing.TB, ref Reference) *generic.GenericService {
+	t.Helper()
+	if ref.Group == "" {
+		ref.Group = "test"
+	}
+	if ref.Version == "" {
+		ref.Version = "1.0.0"
+	}
+
+	refConf := config.ReferenceConfig{
+		InterfaceName:  ref.Interface,
+		Cluster:        "failover",
+		RegistryIDs:    []string{"zk"},
+		Protocol:       ref.Protocol,
+		Generic:        "true",
+		URL:            ref.URL(),
+		Group:          ref.Group,
+		Version:        ref.Version,
+		RequestTimeout: ref.RequestTimeout,
+	}
+
+	rootConfig := config.NewRootConfigBuilder().Build()
+	require.NoError(t, config.Load(config.WithRootConfig(rootConfig)))
+	require.NoError(t, refConf.Init(rootConfig))
+	refConf.GenericLoad(appName)
+
+	return refConf.GetRPCService().(*generic.GenericService)
+}
+
+// Invoke calls method with the java types and values of its arguments, the test stops on error
+func Invoke(t testing.TB, svc *generic.GenericService, method string, types []string, args ...hessian.Object) any {
+	t.Helper()
+	resp, err := svc.Invoke(context.Background(), method, types, args)
+	require.NoError(t, err, "invoke %s", method)
+	return resp
+}
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv rewrites the golden files with the actual responses when it is set to true
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// GoldenPath is the file a golden response is kept in, relative to the test package
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// AssertGolden compares got with testdata/<name>.golden byte for byte
+func AssertGolden(t testing.TB, name string, got []byte) bool {
+	t.Helper()
+	want := golden(t, name, got)
+	return assert.Equal(t, string(want), string(got))
+}
+
+// AssertGoldenJSON compares got with testdata/<name>.golden as json,
+// so neither key order nor indentation matter
+func AssertGoldenJSON(t testing.TB, name string, got []byte) bool {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		indented := &bytes.Buffer{}
+		require.NoError(t, json.Indent(indented, got, "", "  "))
+		got = append(indented.Bytes(), '\n')
+	}
+	want := golden(t, name, got)
+	return assert.JSONEq(t, string(want), string(got))
+}
+
+func golden(t testing.TB, name string, got []byte) []byte {
+	t.Helper()
+	path := GoldenPath(name)
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run with %s=true to create it", UpdateGoldenEnv)
+	return want
+}
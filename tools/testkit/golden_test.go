@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"os"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoldenJSON(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	t.Setenv(UpdateGoldenEnv, "true")
+	assert.True(t, AssertGoldenJSON(t, "user", []byte(`{"name":"tc","id":"0001"}`)))
+	content, err := os.ReadFile(GoldenPath("user"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"name\": \"tc\",\n  \"id\": \"0001\"\n}\n", string(content))
+
+	t.Setenv(UpdateGoldenEnv, "")
+	assert.True(t, AssertGoldenJSON(t, "user", []byte(`{"id":"0001","name":"tc"}`)))
+
+	mismatch := &failRecorder{TB: t}
+	assert.False(t, AssertGoldenJSON(mismatch, "user", []byte(`{"id":"0002","name":"tc"}`)))
+	assert.True(t, mismatch.failed)
+}
+
+// failRecorder notes an assertion failure instead of failing the test
+type failRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *failRecorder) Errorf(string, ...any) {
+	r.failed = true
+}
@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCTimeout bounds each context returned by GRPCContext
+const GRPCTimeout = 10 * time.Second
+
+// DialGRPC opens a plaintext grpc connection to addr, it is closed when the test ends
+func DialGRPC(t testing.TB, addr string, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(Addr(addr), opts...)
+	require.NoError(t, err, "dial %s", addr)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// GRPCContext returns a context for one call, canceled after GRPCTimeout or when the test ends
+func GRPCContext(t testing.TB) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), GRPCTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
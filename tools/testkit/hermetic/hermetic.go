@@ -0,0 +1,196 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package hermetic runs a sample in the test process: the backends are served on free ports,
+// and pixiu is started with a copy of the sample config rewritten to use them.
+// Tests import it from files built without the integration tag, so plain go test needs nothing running,
+// while tools/igt tests the processes it starts, moved to free ports the same way.
+package hermetic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/config"
+	_ "github.com/apache/dubbo-go-pixiu/pkg/pluginregistry"
+	"github.com/apache/dubbo-go-pixiu/pkg/server"
+
+	"gopkg.in/yaml.v3"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+// Ports maps a port of the sample config to the port used instead
+type Ports = portmap.Ports
+
+// Gateway is pixiu started from a rewritten config
+type Gateway struct {
+	// Config is the rewritten config file
+	Config string
+	Ports  Ports
+}
+
+// Port returns the port used in place of the port of the sample config
+func (g *Gateway) Port(port int) int {
+	return g.Ports.Port(port)
+}
+
+// Addr returns the local address of a listener of the sample config
+func (g *Gateway) Addr(port int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(g.Port(port)))
+}
+
+// URL returns the http url of a listener of the sample config
+func (g *Gateway) URL(port int) string {
+	return "http://" + g.Addr(port)
+}
+
+// Serve serves handler on a free local port for the rest of the test process, and returns the port
+func Serve(handler http.Handler) int {
+	srv := httptest.NewServer(handler)
+	return srv.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// FreePort returns a local port nothing listens on
+func FreePort() (int, error) {
+	return portmap.Free()
+}
+
+// RewriteConfig writes a copy of the pixiu config at path into dir. Every listener is moved to a free port,
+// and every other port found in ports is replaced, so clusters point at the backends started by the test.
+func RewriteConfig(path, dir string, ports Ports) (*Gateway, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{}
+	if err = yaml.Unmarshal(content, doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	gw := &Gateway{Ports: Ports{}}
+	for from, to := range ports {
+		gw.Ports[from] = to
+	}
+	for _, listener := range listenerPorts(doc) {
+		if _, ok := gw.Ports[listener]; ok {
+			continue
+		}
+		if gw.Ports[listener], err = FreePort(); err != nil {
+			return nil, err
+		}
+	}
+	gw.Ports.RewriteNode(doc)
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	gw.Config = filepath.Join(dir, filepath.Base(path))
+	if err = os.WriteFile(gw.Config, out, 0o644); err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
+
+var started sync.Once
+
+// StartPixiu rewrites the config at path, starts pixiu in this process and waits for its listeners.
+// Pixiu keeps its server in a global, so it can only be started once per test binary.
+func StartPixiu(path string, ports Ports) (*Gateway, error) {
+	err := errors.New("pixiu is already started in this process")
+	var gw *Gateway
+	started.Do(func() {
+		var dir string
+		if dir, err = os.MkdirTemp("", "pixiu-hermetic"); err != nil {
+			return
+		}
+		defer os.RemoveAll(dir)
+		if gw, err = RewriteConfig(path, dir, ports); err != nil {
+			return
+		}
+
+		bootstrap := config.Load(gw.Config)
+		if bootstrap == nil {
+			err = fmt.Errorf("load %s failed", path)
+			return
+		}
+		go server.Start(bootstrap)
+
+		err = nil
+		for _, listener := range bootstrap.StaticResources.Listeners {
+			addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(listener.Address.SocketAddress.Port)))
+			if err = testkit.WaitTCP(addr, testkit.DefaultReadyTimeout); err != nil {
+				return
+			}
+		}
+	})
+	return gw, err
+}
+
+// listenerPorts returns the ports of static_resources.listeners
+func listenerPorts(doc *yaml.Node) []int {
+	var ports []int
+	listeners := lookup(doc, "static_resources", "listeners")
+	if listeners == nil {
+		return nil
+	}
+	for _, l := range listeners.Content {
+		if port := lookup(l, "address", "socket_address", "port"); port != nil {
+			if p, err := strconv.Atoi(port.Value); err == nil {
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports
+}
+
+// lookup follows the keys down mappings, it returns nil when one is missing
+func lookup(node *yaml.Node, keys ...string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hermetic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const conf = `static_resources:
+  listeners:
+    - name: "net/http"
+      address:
+        socket_address:
+          address: "0.0.0.0"
+          port: 8888
+  clusters:
+    - name: "user"
+      endpoints:
+        - socket_address:
+            address: 127.0.0.1
+            port: 1314
+    - name: "other"
+      endpoints:
+        - socket_address:
+            address: 127.0.0.1
+            port: 2181
+  shutdown_config:
+    timeout: "60s"
+metric:
+  enable: true
+  prometheus_port: 2222
+tracing:
+  endpoint: "localhost:1314"
+`
+
+func TestRewriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conf.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(conf), 0o644))
+	out := filepath.Join(dir, "out")
+	require.NoError(t, os.Mkdir(out, 0o755))
+
+	gw, err := RewriteConfig(path, out, Ports{1314: 40000, 2222: 40001})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(out, "conf.yaml"), gw.Config)
+
+	listener := gw.Port(8888)
+	assert.NotEqual(t, 8888, listener)
+	assert.Equal(t, 2181, gw.Port(2181))
+	assert.Equal(t, "http://127.0.0.1:40000", gw.URL(1314))
+
+	content, err := os.ReadFile(gw.Config)
+	require.NoError(t, err)
+	rewritten := string(content)
+	assert.Contains(t, rewritten, fmt.Sprintf("port: %d", listener))
+	assert.Contains(t, rewritten, "port: 40000")
+	assert.Contains(t, rewritten, "port: 2181")
+	assert.Contains(t, rewritten, "prometheus_port: 40001")
+	assert.Contains(t, rewritten, "localhost:40000")
+	assert.Contains(t, rewritten, "timeout: \"60s\"")
+	assert.NotContains(t, rewritten, "1314")
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultTimeout is the timeout of a single request sent by the helpers
+const DefaultTimeout = 5 * time.Second
+
+// Option changes a request before it is sent
+type Option func(*request)
+
+type request struct {
+	*http.Request
+	timeout    time.Duration
+	noRedirect bool
+}
+
+// Header adds a header to the request
+func Header(key, value string) Option {
+	return func(req *request) {
+		req.Header.Add(key, value)
+	}
+}
+
+// Timeout replaces DefaultTimeout for the request
+func Timeout(timeout time.Duration) Option {
+	return func(req *request) {
+		req.timeout = timeout
+	}
+}
+
+// NoRedirect returns redirects as they are instead of following them
+func NoRedirect() Option {
+	return func(req *request) {
+		req.noRedirect = true
+	}
+}
+
+// JSON marks the request body as json
+func JSON() Option {
+	return Header("Content-Type", "application/json")
+}
+
+// Bearer sets the Authorization header to the bearer token
+func Bearer(token string) Option {
+	return func(req *request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// DubboService sets the headers pixiu uses to pick the dubbo service behind an http request
+func DubboService(protocol, version, group string) Option {
+	return func(req *request) {
+		req.Header.Set("x-dubbo-service-protocol", protocol)
+		req.Header.Set("x-dubbo-service-version", version)
+		req.Header.Set("x-dubbo-service-group", group)
+	}
+}
+
+// Response is a fully read http response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// String returns the body as a string
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// Do sends a request and reads the whole response, the test stops if the request cannot be sent
+func Do(t testing.TB, method, url, body string, opts ...Option) *Response {
+	t.Helper()
+	resp, err := do(method, url, body, opts...)
+	require.NoError(t, err, "%s %s", method, url)
+	return resp
+}
+
+// Get sends a GET request
+func Get(t testing.TB, url string, opts ...Option) *Response {
+	t.Helper()
+	return Do(t, http.MethodGet, url, "", opts...)
+}
+
+// Post sends a POST request
+func Post(t testing.TB, url, body string, opts ...Option) *Response {
+	t.Helper()
+	return Do(t, http.MethodPost, url, body, opts...)
+}
+
+// Put sends a PUT request
+func Put(t testing.TB, url, body string, opts ...Option) *Response {
+	t.Helper()
+	return Do(t, http.MethodPut, url, body, opts...)
+}
+
+func do(method, url, body string, opts ...Option) (*Response, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	httpReq, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{Request: httpReq, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	client := &http.Client{Transport: Transport, Timeout: req.timeout}
+	if req.noRedirect {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	resp, err := client.Do(req.Request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: content}, nil
+}
+
+// AssertResponse checks the status code, and that the body contains every one of the substrings
+func AssertResponse(t testing.TB, resp *Response, status int, contains ...string) bool {
+	t.Helper()
+	ok := assert.Equal(t, status, resp.StatusCode, "body: %s", resp.Body)
+	for _, s := range contains {
+		ok = assert.Contains(t, resp.String(), s) && ok
+	}
+	return ok
+}
+
+// DecodeJSON unmarshals the body into v, the test stops if it is not valid json
+func DecodeJSON(t testing.TB, resp *Response, v any) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(resp.Body, v), "body: %s", resp.Body)
+}
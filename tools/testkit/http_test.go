@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		_, _ = w.Write([]byte(r.Header.Get("Content-Type") + "|" + r.Header.Get("Authorization") + "|" +
+			r.Header.Get("x-dubbo-service-group") + "|" + string(body)))
+	}))
+	defer srv.Close()
+
+	resp := Post(t, srv.URL, "{}", JSON(), Bearer("token"), DubboService("dubbo", "1.0.0", "test"))
+	assert.True(t, AssertResponse(t, resp, http.StatusOK, "application/json|Bearer token|test|{}"))
+	assert.Equal(t, http.MethodPost, resp.Header.Get("X-Method"))
+
+	resp = Put(t, srv.URL, "")
+	assert.Equal(t, http.MethodPut, resp.Header.Get("X-Method"))
+}
+
+func TestNoRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("target"))
+	}))
+	defer srv.Close()
+
+	AssertResponse(t, Get(t, srv.URL), http.StatusOK, "target")
+	resp := Get(t, srv.URL, NoRedirect())
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/target", resp.Header.Get("Location"))
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	_, err := do(http.MethodGet, srv.URL, "", Timeout(50*time.Millisecond))
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/require"
+)
+
+// JSONRPCRequest is a JSON-RPC 2.0 request envelope
+type JSONRPCRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// JSONRPCResponse is a JSON-RPC 2.0 response envelope
+type JSONRPCResponse struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Result  any    `json:"result,omitempty"`
+	Error   any    `json:"error,omitempty"`
+}
+
+// ToolCallParams are the params of an MCP tools/call request
+type ToolCallParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// JSONRPCClient posts JSON-RPC requests to one endpoint
+type JSONRPCClient struct {
+	URL string
+	// Token is sent as a bearer token when it is not empty
+	Token string
+}
+
+// NewJSONRPCClient returns a client posting to url
+func NewJSONRPCClient(url string) *JSONRPCClient {
+	return &JSONRPCClient{URL: url}
+}
+
+// Send posts the request and returns the raw answer, whatever its status
+func (c *JSONRPCClient) Send(t testing.TB, method string, params any) *Response {
+	t.Helper()
+	body, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	require.NoError(t, err)
+
+	opts := []Option{JSON()}
+	if c.Token != "" {
+		opts = append(opts, Bearer(c.Token))
+	}
+	return Post(t, c.URL, string(body), opts...)
+}
+
+// Call posts the request, the test stops unless it gets a 200 without a JSON-RPC error
+func (c *JSONRPCClient) Call(t testing.TB, method string, params any) *JSONRPCResponse {
+	t.Helper()
+	resp := c.Send(t, method, params)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "body: %s", resp.Body)
+
+	rpcResp := &JSONRPCResponse{}
+	DecodeJSON(t, resp, rpcResp)
+	require.Nil(t, rpcResp.Error, "JSON-RPC error")
+	return rpcResp
+}
+
+// Object returns the result as a json object
+func (r *JSONRPCResponse) Object(t testing.TB) map[string]any {
+	t.Helper()
+	result, ok := r.Result.(map[string]any)
+	require.True(t, ok, "result is not an object: %v", r.Result)
+	return result
+}
+
+// ToolText returns the text of the first content item of an MCP tools/call result
+func (r *JSONRPCResponse) ToolText(t testing.TB) string {
+	t.Helper()
+	content, ok := r.Object(t)["content"].([]any)
+	require.True(t, ok && len(content) > 0, "no content in result")
+	item, ok := content[0].(map[string]any)
+	require.True(t, ok, "content item is not an object")
+	text, ok := item["text"].(string)
+	require.True(t, ok, "no text in content item")
+	return text
+}
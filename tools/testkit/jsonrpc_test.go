@@ -0,0 +1,58 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRPCClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req := &JSONRPCRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+		params := req.Params.(map[string]any)
+		_ = json.NewEncoder(w).Encode(JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result: map[string]any{
+				"content": []any{map[string]any{"type": "text", "text": params["name"]}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	client := NewJSONRPCClient(srv.URL)
+	assert.Equal(t, http.StatusUnauthorized, client.Send(t, "tools/call", nil).StatusCode)
+
+	client.Token = "secret"
+	resp := client.Call(t, "tools/call", ToolCallParams{Name: "health_check"})
+	assert.Equal(t, "2.0", resp.JSONRPC)
+	assert.Equal(t, "health_check", resp.ToolText(t))
+}
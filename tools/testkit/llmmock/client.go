@@ -0,0 +1,78 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+// Client drives a mock running in another process, like the one tools/igt starts for a sample
+type Client struct {
+	// URL of the mock, like http://127.0.0.1:8090
+	URL string
+}
+
+// Inject queues a fault on the mock
+func (c Client) Inject(f Fault) error {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, "/mock/faults", body, nil)
+}
+
+// Stats returns the stats of the mock
+func (c Client) Stats() (Stats, error) {
+	var stats Stats
+	err := c.do(http.MethodGet, "/mock/stats", nil, &stats)
+	return stats, err
+}
+
+// Reset drops the pending faults and the stats of the mock
+func (c Client) Reset() error {
+	if err := c.do(http.MethodDelete, "/mock/faults", nil, nil); err != nil {
+		return err
+	}
+	return c.do(http.MethodDelete, "/mock/stats", nil, nil)
+}
+
+func (c Client) do(method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: testkit.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,270 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package llmmock is an OpenAI compatible LLM backend with scripted replies, so the llm samples are
+// tested without an api key: /chat/completions with and without streaming, /embeddings and /models,
+// with token usage, latency, and errors injected through /mock/faults or the X-Mock-Status header.
+package llmmock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatusHeader makes the mock answer a request with the status it names, like 429 or 500
+const StatusHeader = "X-Mock-Status"
+
+// ServerHeader carries the name of the mock in its answers
+const ServerHeader = "X-Mock-Server"
+
+// Options configure the mock
+type Options struct {
+	Script Script
+	// Latency is waited before a request is answered
+	Latency time.Duration
+	// ChunkDelay is waited between the chunks of a stream
+	ChunkDelay time.Duration
+	// APIKey is the bearer token the requests must carry, any token is accepted when empty
+	APIKey string
+	// Dimensions of the embeddings, 8 when 0
+	Dimensions int
+	// Name is sent in the ServerHeader and as system_fingerprint of the completions, so a client
+	// behind a gateway sees which mock answered
+	Name string
+}
+
+// Fault makes the next Count requests fail with Status
+type Fault struct {
+	Status int `json:"status"`
+	Count  int `json:"count"`
+	// Path limits the fault to an api path, like /chat/completions, every path when empty
+	Path string `json:"path,omitempty"`
+	// RetryAfter is sent as Retry-After header, in seconds
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Interrupt cuts the connection after that many chunks of a stream instead of sending Status,
+	// the other requests are cut before they are answered
+	Interrupt int `json:"interrupt,omitempty"`
+}
+
+// interruptKey is the context key of the chunks a stream is cut after
+type interruptKey struct{}
+
+// Stats count the api requests by path, the ones that failed on purpose, and the usage of the replies
+type Stats struct {
+	Requests         map[string]int `json:"requests"`
+	Failed           map[string]int `json:"failed"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+}
+
+// Server is the mock, an http.Handler
+type Server struct {
+	options Options
+
+	mu     sync.Mutex
+	faults []Fault
+	stats  Stats
+	ids    int
+}
+
+// New returns a mock with the options
+func New(options Options) *Server {
+	if options.Dimensions <= 0 {
+		options.Dimensions = 8
+	}
+	s := &Server{options: options}
+	s.Reset()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/mock/") {
+		s.admin(w, r)
+		return
+	}
+	// the api is served with and without the /v1 of the OpenAI base url
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	var handler func(http.ResponseWriter, *http.Request)
+	switch path {
+	case "/chat/completions":
+		handler = s.chat
+	case "/embeddings":
+		handler = s.embeddings
+	case "/models":
+		handler = s.models
+	default:
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+	s.update(func(stats *Stats) { stats.Requests[path]++ })
+	if s.options.Name != "" {
+		w.Header().Set(ServerHeader, s.options.Name)
+	}
+
+	if s.options.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.options.APIKey {
+		writeError(w, http.StatusUnauthorized, "incorrect api key provided")
+		return
+	}
+	if !wait(r, s.options.Latency) {
+		return
+	}
+	if fault, ok := s.fault(path, r); ok {
+		s.update(func(stats *Stats) { stats.Failed[path]++ })
+		if fault.Interrupt > 0 {
+			if path != "/chat/completions" {
+				panic(http.ErrAbortHandler)
+			}
+			handler(w, r.WithContext(context.WithValue(r.Context(), interruptKey{}, fault.Interrupt)))
+			return
+		}
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		}
+		writeError(w, fault.Status, "injected "+http.StatusText(fault.Status))
+		return
+	}
+	handler(w, r)
+}
+
+// Inject queues faults, they are used up in order
+func (s *Server) Inject(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range faults {
+		if f.Count <= 0 {
+			f.Count = 1
+		}
+		s.faults = append(s.faults, f)
+	}
+}
+
+// Reset drops the pending faults and the stats
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+	s.stats = newStats()
+}
+
+func newStats() Stats {
+	return Stats{Requests: map[string]int{}, Failed: map[string]int{}}
+}
+
+// Stats returns a copy of the stats
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Requests = make(map[string]int, len(s.stats.Requests))
+	for k, v := range s.stats.Requests {
+		stats.Requests[k] = v
+	}
+	stats.Failed = make(map[string]int, len(s.stats.Failed))
+	for k, v := range s.stats.Failed {
+		stats.Failed[k] = v
+	}
+	return stats
+}
+
+func (s *Server) update(update func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(&s.stats)
+}
+
+// fault returns the fault the request fails with, the StatusHeader of the request comes first
+func (s *Server) fault(path string, r *http.Request) (Fault, bool) {
+	if status, err := strconv.Atoi(r.Header.Get(StatusHeader)); err == nil && status >= 400 {
+		return Fault{Status: status}, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if f.Path != "" && f.Path != path {
+			continue
+		}
+		if f.Count--; f.Count == 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		} else {
+			s.faults[i] = f
+		}
+		return f, true
+	}
+	return Fault{}, false
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids++
+	return prefix + "-mock-" + strconv.Itoa(s.ids)
+}
+
+// admin serves the control endpoints of the tests:
+//
+//	GET, DELETE /mock/stats    the stats, DELETE resets them
+//	GET, POST   /mock/faults   the pending faults, POST queues the Fault of the body
+//	DELETE      /mock/faults   drops the pending faults
+func (s *Server) admin(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/mock/stats" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Stats())
+	case r.URL.Path == "/mock/stats" && r.Method == http.MethodDelete:
+		s.update(func(stats *Stats) { *stats = newStats() })
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/mock/faults" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		faults := append([]Fault{}, s.faults...)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, faults)
+	case r.URL.Path == "/mock/faults" && r.Method == http.MethodPost:
+		var f Fault
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil || (f.Status < 400 && f.Interrupt <= 0) {
+			writeError(w, http.StatusBadRequest, "the body must be a fault with a status of 400 or more, or an interrupt")
+			return
+		}
+		s.Inject(f)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/mock/faults" && r.Method == http.MethodDelete:
+		s.mu.Lock()
+		s.faults = nil
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+	}
+}
+
+// wait waits for d, false when the client went away first
+func wait(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmmock
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testScript = Script{
+	Models: []string{"deepseek-chat", "mock-embedding"},
+	Rules: []Rule{
+		{Match: "1+1", Reply: "1+1 equals 2."},
+		{Model: "deepseek-chat", Match: "weather", Reply: "It is sunny in the mock city today."},
+	},
+}
+
+func newTestClient(t *testing.T, options Options) (*Server, openai.Client) {
+	mock := New(options)
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	return mock, NewClient(srv.URL+"/v1", "sk-test")
+}
+
+func userMessage(content string) openai.ChatCompletionNewParams {
+	return openai.ChatCompletionNewParams{
+		Model:    "deepseek-chat",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are a helpful assistant."), openai.UserMessage(content)},
+	}
+}
+
+func TestChat(t *testing.T) {
+	mock, client := newTestClient(t, Options{Script: testScript})
+
+	completion, err := client.Chat.Completions.New(context.Background(), userMessage("what is 1+1 ?"))
+	require.NoError(t, err)
+	require.Len(t, completion.Choices, 1)
+	assert.Equal(t, "1+1 equals 2.", completion.Choices[0].Message.Content)
+	assert.Equal(t, "stop", completion.Choices[0].FinishReason)
+	// a token per word: 5 words of the system message, 4 of the question, 3 of the reply
+	assert.Equal(t, int64(9), completion.Usage.PromptTokens)
+	assert.Equal(t, int64(3), completion.Usage.CompletionTokens)
+	assert.Equal(t, int64(12), completion.Usage.TotalTokens)
+
+	// without a matching rule the question is echoed
+	completion, err = client.Chat.Completions.New(context.Background(), userMessage("hello there"))
+	require.NoError(t, err)
+	assert.Equal(t, "mock reply to: hello there", completion.Choices[0].Message.Content)
+
+	stats := mock.Stats()
+	assert.Equal(t, 2, stats.Requests["/chat/completions"])
+	assert.Equal(t, 16, stats.PromptTokens)
+	assert.Equal(t, 8, stats.CompletionTokens)
+}
+
+func TestChatStream(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript, ChunkDelay: time.Millisecond})
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), userMessage("how is the weather ?"))
+	acc := openai.ChatCompletionAccumulator{}
+	chunks := 0
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+		chunks++
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "It is sunny in the mock city today.", acc.Choices[0].Message.Content)
+	assert.Equal(t, "stop", acc.Choices[0].FinishReason)
+	// the role, a chunk per word, and the finish reason with the usage
+	assert.Equal(t, 10, chunks)
+	assert.Equal(t, int64(8), acc.Usage.CompletionTokens)
+	assert.Equal(t, int64(10), acc.Usage.PromptTokens)
+}
+
+func TestChatMaxTokens(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript})
+	params := userMessage("how is the weather ?")
+	params.MaxTokens = openai.Int(3)
+
+	completion, err := client.Chat.Completions.New(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "It is sunny", completion.Choices[0].Message.Content)
+	assert.Equal(t, "length", completion.Choices[0].FinishReason)
+	assert.Equal(t, int64(3), completion.Usage.CompletionTokens)
+}
+
+func TestEmbeddings(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript, Dimensions: 16})
+	params := openai.EmbeddingNewParams{
+		Model: "mock-embedding",
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: []string{"the quick brown fox", "jumps", "the quick brown fox"}},
+	}
+
+	resp, err := client.Embeddings.New(context.Background(), params)
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 3)
+	assert.Equal(t, int64(9), resp.Usage.PromptTokens)
+	assert.Len(t, resp.Data[0].Embedding, 16)
+	assert.Equal(t, resp.Data[0].Embedding, resp.Data[2].Embedding)
+	assert.NotEqual(t, resp.Data[0].Embedding, resp.Data[1].Embedding)
+	norm := 0.0
+	for _, v := range resp.Data[1].Embedding {
+		norm += v * v
+	}
+	assert.InDelta(t, 1, math.Sqrt(norm), 1e-9)
+}
+
+func TestModels(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript})
+
+	page, err := client.Models.List(context.Background())
+	require.NoError(t, err)
+	ids := make([]string, 0, len(page.Data))
+	for _, m := range page.Data {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"deepseek-chat", "mock-embedding"}, ids)
+
+	params := userMessage("hello")
+	params.Model = "gpt-unknown"
+	_, err = client.Chat.Completions.New(context.Background(), params)
+	assertAPIError(t, err, http.StatusNotFound, "model_not_found")
+}
+
+func TestFaults(t *testing.T) {
+	mock, client := newTestClient(t, Options{Script: testScript})
+	mock.Inject(Fault{Status: http.StatusTooManyRequests, Count: 2, Path: "/chat/completions", RetryAfter: 1})
+
+	// the fault of another path leaves the models alone
+	_, err := client.Models.List(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = client.Chat.Completions.New(context.Background(), userMessage("hello"))
+		assertAPIError(t, err, http.StatusTooManyRequests, "rate_limit_exceeded")
+	}
+	_, err = client.Chat.Completions.New(context.Background(), userMessage("hello"))
+	require.NoError(t, err)
+
+	// the header fails a single request
+	_, err = client.Chat.Completions.New(context.Background(), userMessage("hello"), option.WithHeader(StatusHeader, "500"))
+	assertAPIError(t, err, http.StatusInternalServerError, "")
+
+	stats := mock.Stats()
+	assert.Equal(t, 4, stats.Requests["/chat/completions"])
+	assert.Equal(t, 3, stats.Failed["/chat/completions"])
+	assert.Equal(t, 1, stats.Requests["/models"])
+}
+
+func TestInterrupt(t *testing.T) {
+	mock, client := newTestClient(t, Options{Script: testScript})
+	mock.Inject(Fault{Interrupt: 3, Count: 2})
+
+	// the role and two words get through before the stream is cut
+	stream := client.Chat.Completions.NewStreaming(context.Background(), userMessage("how is the weather ?"))
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	require.Error(t, stream.Err())
+	assert.Equal(t, "It is ", acc.Choices[0].Message.Content)
+
+	// a request that does not stream is cut before its answer
+	_, err := client.Chat.Completions.New(context.Background(), userMessage("hello"))
+	require.Error(t, err)
+	var apiErr *openai.Error
+	assert.False(t, errors.As(err, &apiErr))
+
+	_, err = client.Chat.Completions.New(context.Background(), userMessage("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, mock.Stats().Failed["/chat/completions"])
+}
+
+func TestAPIKey(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript, APIKey: "sk-other"})
+	_, err := client.Chat.Completions.New(context.Background(), userMessage("hello"))
+	assertAPIError(t, err, http.StatusUnauthorized, "invalid_api_key")
+}
+
+func TestToolCall(t *testing.T) {
+	script := Script{Rules: []Rule{{Match: "time", Tool: "current_time", Arguments: `{"timezone":"UTC"}`, Reply: "It is {result} now."}}}
+	_, client := newTestClient(t, Options{Script: script})
+	params := openai.ChatCompletionNewParams{
+		Model:    "deepseek-chat",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("what time is it ?")},
+		Tools:    []openai.ChatCompletionToolParam{{Function: openai.FunctionDefinitionParam{Name: "current_time"}}},
+	}
+
+	completion, err := client.Chat.Completions.New(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "tool_calls", completion.Choices[0].FinishReason)
+	require.Len(t, completion.Choices[0].Message.ToolCalls, 1)
+	call := completion.Choices[0].Message.ToolCalls[0]
+	assert.Equal(t, "current_time", call.Function.Name)
+	assert.Equal(t, `{"timezone":"UTC"}`, call.Function.Arguments)
+
+	params.Messages = append(params.Messages, completion.Choices[0].Message.ToParam(), openai.ToolMessage("12:00", call.ID))
+	completion, err = client.Chat.Completions.New(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "It is 12:00 now.", completion.Choices[0].Message.Content)
+
+	// the stream sends the call in a chunk, a request without the tool gets the reply
+	params.Messages = params.Messages[:1]
+	stream := client.Chat.Completions.NewStreaming(context.Background(), params)
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	require.NoError(t, stream.Err())
+	require.Len(t, acc.Choices[0].Message.ToolCalls, 1)
+	assert.Equal(t, "current_time", acc.Choices[0].Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, `{"timezone":"UTC"}`, acc.Choices[0].Message.ToolCalls[0].Function.Arguments)
+
+	params.Tools = nil
+	completion, err = client.Chat.Completions.New(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "It is {result} now.", completion.Choices[0].Message.Content)
+}
+
+func TestName(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript, Name: "provider-a"})
+	var resp *http.Response
+	completion, err := client.Chat.Completions.New(context.Background(), userMessage("hello"), option.WithResponseInto(&resp))
+	require.NoError(t, err)
+	assert.Equal(t, "provider-a", completion.SystemFingerprint)
+	assert.Equal(t, "provider-a", resp.Header.Get(ServerHeader))
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), userMessage("hello"))
+	for stream.Next() {
+		assert.Equal(t, "provider-a", stream.Current().SystemFingerprint)
+	}
+	require.NoError(t, stream.Err())
+}
+
+func TestLatency(t *testing.T) {
+	_, client := newTestClient(t, Options{Script: testScript, Latency: 100 * time.Millisecond})
+	start := time.Now()
+	_, err := client.Chat.Completions.New(context.Background(), userMessage("hello"))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestClient(t *testing.T) {
+	mock, openaiClient := newTestClient(t, Options{Script: testScript})
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+	client := Client{URL: srv.URL}
+
+	require.NoError(t, client.Inject(Fault{Status: http.StatusServiceUnavailable}))
+	_, err := openaiClient.Chat.Completions.New(context.Background(), userMessage("hello"))
+	assertAPIError(t, err, http.StatusServiceUnavailable, "")
+
+	stats, err := client.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Failed["/chat/completions"])
+
+	require.NoError(t, client.Reset())
+	stats, err = client.Stats()
+	require.NoError(t, err)
+	assert.Empty(t, stats.Requests)
+	assert.Error(t, client.Inject(Fault{Status: http.StatusOK}))
+}
+
+func TestReset(t *testing.T) {
+	mock, openaiClient := newTestClient(t, Options{Script: testScript})
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	t.Run("test", func(t *testing.T) {
+		Reset(t, Client{URL: srv.URL})
+		mock.Inject(Fault{Status: http.StatusServiceUnavailable})
+		_, err := openaiClient.Chat.Completions.New(context.Background(), Question("deepseek-chat", "hello"))
+		assertAPIError(t, err, http.StatusServiceUnavailable, "")
+		mock.Inject(Fault{Status: http.StatusServiceUnavailable})
+	})
+	// the fault left over by the test is dropped with its stats
+	assert.Empty(t, mock.Stats().Requests)
+	_, err := openaiClient.Chat.Completions.New(context.Background(), Question("deepseek-chat", "hello"))
+	require.NoError(t, err)
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`models: [deepseek-chat]
+rules:
+  - match: hello
+    reply: hi from the script
+`), 0o644))
+	s, err := LoadScript(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deepseek-chat"}, s.Models)
+	assert.Equal(t, "hi from the script", s.reply("deepseek-chat", "hello mock"))
+	assert.True(t, Script{}.serves("deepseek-chat"))
+}
+
+func assertAPIError(t *testing.T, err error, status int, code string) {
+	t.Helper()
+	var apiErr *openai.Error
+	require.True(t, errors.As(err, &apiErr), "want an api error, got %v", err)
+	assert.Equal(t, status, apiErr.StatusCode)
+	assert.Equal(t, code, apiErr.Code)
+}
@@ -0,0 +1,298 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmmock
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type chatRequest struct {
+	Model     string    `json:"model"`
+	Messages  []message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	MaxTokens int       `json:"max_tokens"`
+	Tools     []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+// offers tells whether the request lets the model call the tool
+func (r chatRequest) offers(tool string) bool {
+	for _, t := range r.Tools {
+		if t.Function.Name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+type chatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+	Usage   *usage   `json:"usage,omitempty"`
+
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+}
+
+type choice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *delta       `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type delta struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	// Index is only sent in the chunks of a stream
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// chat answers with the scripted reply, cut to max_tokens words. A stream sends a chunk per word
+// and, like deepseek, the usage with the finish reason in the last chunk. A rule with a tool the
+// request offers answers with a call of the tool, and the result of the call with its reply.
+func (s *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "messages must not be empty")
+		return
+	}
+	if !s.options.Script.serves(req.Model) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("the model %q does not exist", req.Model))
+		return
+	}
+
+	last, promptTokens := prompt(req.Messages)
+	reply := s.options.Script.reply(req.Model, last)
+	var call *toolCall
+	if r := s.options.Script.rule(req.Model, last); r != nil && r.Tool != "" && req.offers(r.Tool) {
+		if result := req.Messages[len(req.Messages)-1]; result.Role == "tool" {
+			reply = strings.ReplaceAll(r.Reply, "{result}", result.text())
+		} else {
+			call = &toolCall{ID: s.nextID("call"), Type: "function"}
+			call.Function.Name, call.Function.Arguments = r.Tool, r.Arguments
+		}
+	}
+	words := strings.Fields(reply)
+	finish := "stop"
+	if call != nil {
+		words, finish = nil, "tool_calls"
+	} else if req.MaxTokens > 0 && len(words) > req.MaxTokens {
+		words, finish = words[:req.MaxTokens], "length"
+	}
+	completionTokens := len(words)
+	if call != nil {
+		completionTokens = CountTokens(call.Function.Name + " " + call.Function.Arguments)
+	}
+	u := &usage{PromptTokens: promptTokens, CompletionTokens: completionTokens, TotalTokens: promptTokens + completionTokens}
+	s.update(func(stats *Stats) {
+		stats.PromptTokens += u.PromptTokens
+		stats.CompletionTokens += u.CompletionTokens
+	})
+	completion := chatCompletion{ID: s.nextID("chatcmpl"), Object: "chat.completion", Created: time.Now().Unix(), Model: req.Model, SystemFingerprint: s.options.Name}
+	interrupt, _ := r.Context().Value(interruptKey{}).(int)
+
+	if !req.Stream {
+		if interrupt > 0 {
+			panic(http.ErrAbortHandler)
+		}
+		msg := &chatMessage{Role: "assistant", Content: strings.Join(words, " ")}
+		if call != nil {
+			msg.ToolCalls = []toolCall{*call}
+		}
+		completion.Choices = []choice{{Message: msg, FinishReason: &finish}}
+		completion.Usage = u
+		writeJSON(w, http.StatusOK, completion)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	completion.Object = "chat.completion.chunk"
+	sent := 0
+	send := func(d *delta, finish *string, u *usage) {
+		if interrupt > 0 && sent == interrupt {
+			// the chunked response ends without its last chunk, like a connection reset
+			panic(http.ErrAbortHandler)
+		}
+		sent++
+		completion.Choices = []choice{{Delta: d, FinishReason: finish}}
+		completion.Usage = u
+		data, _ := json.Marshal(completion)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	send(&delta{Role: "assistant"}, nil, nil)
+	if call != nil {
+		index := 0
+		call.Index = &index
+		send(&delta{ToolCalls: []toolCall{*call}}, nil, nil)
+	}
+	for i, word := range words {
+		if !wait(r, s.options.ChunkDelay) {
+			return
+		}
+		if i < len(words)-1 {
+			word += " "
+		}
+		send(&delta{Content: word}, nil, nil)
+	}
+	send(&delta{}, &finish, u)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+type embeddingRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+type embedding struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// embeddings answers with unit vectors derived from the hash of each input, the same input always
+// gets the same vector
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var req embeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	var inputs []string
+	var input string
+	if err := json.Unmarshal(req.Input, &input); err == nil {
+		inputs = []string{input}
+	} else if err = json.Unmarshal(req.Input, &inputs); err != nil || len(inputs) == 0 {
+		writeError(w, http.StatusBadRequest, "input must be a string or a list of strings")
+		return
+	}
+	if !s.options.Script.serves(req.Model) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("the model %q does not exist", req.Model))
+		return
+	}
+
+	data := make([]embedding, len(inputs))
+	tokens := 0
+	for i, in := range inputs {
+		data[i] = embedding{Object: "embedding", Index: i, Embedding: Embed(req.Model, in, s.options.Dimensions)}
+		tokens += CountTokens(in)
+	}
+	s.update(func(stats *Stats) { stats.PromptTokens += tokens })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]int{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+// Embed returns the embedding the mock serves for the input, a unit vector of dimensions
+func Embed(model, input string, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+	norm := 0.0
+	for i := range vector {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", model, input, i)))
+		vector[i] = float64(binary.BigEndian.Uint32(sum[:4]))/math.MaxUint32*2 - 1
+		norm += vector[i] * vector[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+func (s *Server) models(w http.ResponseWriter, _ *http.Request) {
+	models := s.options.Script.models()
+	data := make([]map[string]interface{}, len(models))
+	for i, m := range models {
+		data[i] = map[string]interface{}{"id": m, "object": "model", "created": 0, "owned_by": "llmmock"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the error body of the OpenAI api
+func writeError(w http.ResponseWriter, status int, message string) {
+	errType, code := "invalid_request_error", ""
+	switch {
+	case status == http.StatusUnauthorized:
+		errType, code = "authentication_error", "invalid_api_key"
+	case status == http.StatusNotFound && strings.HasPrefix(message, "the model"):
+		code = "model_not_found"
+	case status == http.StatusTooManyRequests:
+		errType, code = "rate_limit_error", "rate_limit_exceeded"
+	case status >= http.StatusInternalServerError:
+		errType = "server_error"
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"message": message, "type": errType, "code": code},
+	})
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmmock
+
+import (
+	"net/http"
+	"testing"
+)
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit"
+)
+
+// NewClient returns an openai client of the pixiu at baseURL, like http://localhost:8888. It does not retry,
+// the tests see every error of pixiu, and it dials the ports tools/igt moved the sample to
+func NewClient(baseURL, apiKey string, opts ...option.RequestOption) openai.Client {
+	return openai.NewClient(append([]option.RequestOption{
+		option.WithBaseURL(baseURL + "/"),
+		option.WithAPIKey(apiKey),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{Transport: testkit.Transport}),
+	}, opts...)...)
+}
+
+// Question returns the params of a chat completion asking model one question
+func Question(model, content string) openai.ChatCompletionNewParams {
+	return openai.ChatCompletionNewParams{
+		Model:    model,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(content)},
+	}
+}
+
+// Reset drops the faults and stats of the mocks before the test and again when it ends
+func Reset(t testing.TB, mocks ...Client) {
+	t.Helper()
+	reset := func() {
+		for _, m := range mocks {
+			require.NoError(t, m.Reset(), "reset %s", m.URL)
+		}
+	}
+	reset()
+	t.Cleanup(reset)
+}
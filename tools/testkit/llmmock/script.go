@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package llmmock
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultModels are served when the script names none
+var DefaultModels = []string{"deepseek-chat", "deepseek-reasoner", "text-embedding-3-small"}
+
+// Rule is a scripted reply
+type Rule struct {
+	// Match is a substring of the last user message, a rule without Match matches every message
+	Match string `yaml:"match" json:"match"`
+	// Model limits the rule to the requests of a model
+	Model string `yaml:"model" json:"model"`
+	Reply string `yaml:"reply" json:"reply"`
+	// Tool is called with Arguments when the request offers it, the result of the call is then
+	// answered with Reply, where {result} is replaced by the result
+	Tool      string `yaml:"tool" json:"tool"`
+	Arguments string `yaml:"arguments" json:"arguments"`
+}
+
+// Script is what the mock answers. The first rule that matches a request gives the reply,
+// a request no rule matches gets its last user message echoed.
+type Script struct {
+	// Models are listed by /models, requests for other models are refused with model_not_found
+	Models []string `yaml:"models" json:"models"`
+	Rules  []Rule   `yaml:"rules" json:"rules"`
+}
+
+// LoadScript reads a yaml script
+func LoadScript(path string) (Script, error) {
+	var s Script
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = yaml.Unmarshal(content, &s)
+	return s, err
+}
+
+func (s Script) models() []string {
+	if len(s.Models) == 0 {
+		return DefaultModels
+	}
+	return s.Models
+}
+
+func (s Script) serves(model string) bool {
+	for _, m := range s.models() {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Script) reply(model, prompt string) string {
+	if r := s.rule(model, prompt); r != nil {
+		return r.Reply
+	}
+	return "mock reply to: " + prompt
+}
+
+func (s Script) rule(model, prompt string) *Rule {
+	for i, r := range s.Rules {
+		if (r.Model == "" || r.Model == model) && strings.Contains(prompt, r.Match) {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
+
+// CountTokens is the tokenizer of the mock, a token per word, so the usage of a reply is known in advance
+func CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+// message is a chat message, its content is a string or a list of parts
+type message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+func (m message) text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// prompt returns the last user message and the tokens of every message
+func prompt(messages []message) (string, int) {
+	last, tokens := "", 0
+	for _, m := range messages {
+		text := m.text()
+		tokens += CountTokens(text)
+		if m.Role == "user" {
+			last = text
+		}
+	}
+	return last, tokens
+}
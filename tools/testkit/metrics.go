@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+import (
+	"github.com/stretchr/testify/require"
+)
+
+var labelPattern = regexp.MustCompile(`(\w+)="((?:[^"\\]|\\.)*)"`)
+
+// ScrapeMetric fetches the prometheus metrics at url and sums the samples of name that carry labels.
+// Inside assert.EventuallyWithT pass the *assert.CollectT, a failed scrape is then retried instead of ending the test.
+func ScrapeMetric(t require.TestingT, url, name string, labels map[string]string) float64 {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	resp, err := do("GET", url, "")
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode, "scrape %s", url)
+	return SumMetric(resp.String(), name, labels)
+}
+
+// SumMetric sums the samples of name in a prometheus text exposition whose labels include labels
+func SumMetric(text, name string, labels map[string]string) float64 {
+	sum := 0.0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		end := strings.IndexAny(line, "{ ")
+		if end < 0 || line[:end] != name {
+			continue
+		}
+		rest, sampleLabels := line[end:], map[string]string{}
+		if strings.HasPrefix(rest, "{") {
+			closing := strings.LastIndex(rest, "}")
+			if closing < 0 {
+				continue
+			}
+			for _, m := range labelPattern.FindAllStringSubmatch(rest[1:closing], -1) {
+				sampleLabels[m[1]] = m[2]
+			}
+			rest = rest[closing+1:]
+		}
+		if !hasLabels(sampleLabels, labels) {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			sum += v
+		}
+	}
+	return sum
+}
+
+func hasLabels(sample, want map[string]string) bool {
+	for k, v := range want {
+		if sample[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+const testMetrics = `# HELP pixiu_llm_prompt_tokens_total prompt tokens
+# TYPE pixiu_llm_prompt_tokens_total counter
+pixiu_llm_prompt_tokens_total{cluster_name="chat",model="deepseek-chat"} 12
+pixiu_llm_prompt_tokens_total{cluster_name="chat",model="deepseek-reasoner"} 3
+pixiu_llm_prompt_tokens_total{cluster_name="embed",model="text-embedding-3-small"} 5
+pixiu_llm_prompt_tokens_total_created{cluster_name="chat"} 1.7e+09
+pixiu_up 1
+`
+
+func TestSumMetric(t *testing.T) {
+	assert.Equal(t, 20.0, SumMetric(testMetrics, "pixiu_llm_prompt_tokens_total", nil))
+	assert.Equal(t, 15.0, SumMetric(testMetrics, "pixiu_llm_prompt_tokens_total", map[string]string{"cluster_name": "chat"}))
+	assert.Equal(t, 3.0, SumMetric(testMetrics, "pixiu_llm_prompt_tokens_total", map[string]string{"cluster_name": "chat", "model": "deepseek-reasoner"}))
+	assert.Equal(t, 1.0, SumMetric(testMetrics, "pixiu_up", nil))
+	assert.Zero(t, SumMetric(testMetrics, "pixiu_missing", nil))
+}
+
+func TestScrapeMetric(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, testMetrics)
+	}))
+	defer srv.Close()
+	assert.Equal(t, 5.0, ScrapeMetric(t, srv.URL, "pixiu_llm_prompt_tokens_total", map[string]string{"cluster_name": "embed"}))
+}
@@ -0,0 +1,163 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package portmap moves the ports of a sample: tools/igt starts every sample on free ports,
+// rewrites the configs and command lines of its processes with a Ports, and passes the Ports to the tests in EnvName.
+package portmap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// EnvName is the environment variable tools/igt passes the moved ports of a sample in, like "2222=41003,8888=41002"
+const EnvName = "IGT_PORTS"
+
+// Ports maps a port of the sample to the port used instead
+type Ports map[int]int
+
+// Free returns a local port nothing listens on
+func Free() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Parse reads the format of String
+func Parse(s string) (Ports, error) {
+	ports := Ports{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		f, err1 := strconv.Atoi(from)
+		t, err2 := strconv.Atoi(to)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%q is not a port=port pair", pair)
+		}
+		ports[f] = t
+	}
+	return ports, nil
+}
+
+// FromEnv reads the ports tools/igt moved, empty when the test is not run by it
+func FromEnv() (Ports, error) {
+	return Parse(os.Getenv(EnvName))
+}
+
+// String returns the ports as comma separated port=port pairs, sorted
+func (p Ports) String() string {
+	from := make([]int, 0, len(p))
+	for port := range p {
+		from = append(from, port)
+	}
+	sort.Ints(from)
+	pairs := make([]string, 0, len(from))
+	for _, port := range from {
+		pairs = append(pairs, fmt.Sprintf("%d=%d", port, p[port]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Port returns the port used in place of port
+func (p Ports) Port(port int) int {
+	if to, ok := p[port]; ok {
+		return to
+	}
+	return port
+}
+
+// Addr replaces the port of a host:port address, other strings are returned as they are
+func (p Ports) Addr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return addr
+	}
+	if to, ok := p[n]; ok {
+		return net.JoinHostPort(host, strconv.Itoa(to))
+	}
+	return addr
+}
+
+// portRef is a port in a command line argument: ":8090", "127.0.0.1:8090", "http://localhost:8090/models"
+var portRef = regexp.MustCompile(`:(\d{1,5})\b`)
+
+// Replace replaces the ports in a command line argument, an argument that is only a number is taken as a port
+func (p Ports) Replace(s string) string {
+	if n, err := strconv.Atoi(s); err == nil {
+		return strconv.Itoa(p.Port(n))
+	}
+	return portRef.ReplaceAllStringFunc(s, func(ref string) string {
+		n, _ := strconv.Atoi(ref[1:])
+		return ":" + strconv.Itoa(p.Port(n))
+	})
+}
+
+// Mentions tells if Replace would find port in s
+func Mentions(s string, port int) bool {
+	return Ports{port: port + 1}.Replace(s) != s
+}
+
+// RewriteYAML replaces the values of every port key, and the port of every host:port string, of a yaml document
+func (p Ports) RewriteYAML(content []byte) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(content, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return content, nil
+	}
+	p.RewriteNode(doc)
+	return yaml.Marshal(doc)
+}
+
+// RewriteNode is RewriteYAML on a decoded document
+func (p Ports) RewriteNode(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				continue
+			}
+			if n, err := strconv.Atoi(value.Value); err == nil && strings.HasSuffix(key.Value, "port") {
+				value.Value = strconv.Itoa(p.Port(n))
+				continue
+			}
+			value.Value = p.Addr(value.Value)
+		}
+	}
+	for _, child := range node.Content {
+		p.RewriteNode(child)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package portmap
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	ports, err := Parse("8888=41002, 2222=41003")
+	require.NoError(t, err)
+	assert.Equal(t, Ports{8888: 41002, 2222: 41003}, ports)
+	assert.Equal(t, "2222=41003,8888=41002", ports.String())
+
+	ports, err = Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, ports)
+
+	_, err = Parse("8888")
+	assert.Error(t, err)
+}
+
+func TestReplace(t *testing.T) {
+	ports := Ports{8090: 41000, 8888: 41001}
+
+	assert.Equal(t, ":41000", ports.Replace(":8090"))
+	assert.Equal(t, "41000", ports.Replace("8090"))
+	assert.Equal(t, "http://127.0.0.1:41000/models", ports.Replace("http://127.0.0.1:8090/models"))
+	assert.Equal(t, "127.0.0.1:1314", ports.Replace("127.0.0.1:1314"))
+	assert.Equal(t, "${SAMPLE_DIR}/script.yaml", ports.Replace("${SAMPLE_DIR}/script.yaml"))
+	assert.Equal(t, "127.0.0.1:41001", ports.Addr("127.0.0.1:8888"))
+	assert.Equal(t, "mock", ports.Addr("mock"))
+
+	assert.True(t, Mentions("-addr=:8090", 8090))
+	assert.False(t, Mentions(":80901", 8090))
+}
+
+func TestRewriteYAML(t *testing.T) {
+	content := []byte(`listeners:
+  - port: 8888
+clusters:
+  - port: 1314
+metric:
+  prometheus_port: 2222
+tracing:
+  endpoint: localhost:2222
+replicas: 8888
+`)
+	out, err := Ports{8888: 41001, 2222: 41002}.RewriteYAML(content)
+	require.NoError(t, err)
+	assert.Equal(t, `listeners:
+    - port: 41001
+clusters:
+    - port: 1314
+metric:
+    prometheus_port: 41002
+tracing:
+    endpoint: localhost:41002
+replicas: 8888
+`, string(out))
+}
@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+var (
+	// ports are the ports tools/igt moved the sample to, read by Setup
+	ports portmap.Ports
+
+	setupOnce sync.Once
+	setupErr  error
+
+	// Transport dials the moved port of a local address of the sample, the helpers send their
+	// requests with it. Give it to the other clients of a test, like an openai one.
+	Transport = movingTransport()
+)
+
+// Setup reads the ports tools/igt moved the sample to from portmap.EnvName. Tests keep the addresses
+// of the sample, like localhost:8888, and the helpers, Addr, URL and Transport give the moved port
+// instead. The tests of a sample call it once, see Main.
+func Setup() error {
+	setupOnce.Do(func() {
+		if ports, setupErr = portmap.FromEnv(); setupErr != nil {
+			setupErr = fmt.Errorf("%s: %w", portmap.EnvName, setupErr)
+		}
+	})
+	return setupErr
+}
+
+// movingTransport is a copy of the default transport that dials the address given by Addr
+func movingTransport() http.RoundTripper {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	transport := base.Clone()
+	var dialer net.Dialer
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, Addr(addr))
+	}
+	return transport
+}
+
+// Main is the TestMain of the tests of a sample, it runs them after Setup
+func Main(m *testing.M) {
+	if err := Setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	os.Exit(m.Run())
+}
+
+// Addr returns the address to dial for a local host:port of the sample, addr itself when igt did not move it
+func Addr(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || !isLocal(host) {
+		return addr
+	}
+	return ports.Addr(addr)
+}
+
+// URL is Addr for the host of an url
+func URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Port() == "" {
+		return rawURL
+	}
+	u.Host = Addr(u.Host)
+	return u.String()
+}
+
+func isLocal(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/tools/testkit/portmap"
+)
+
+func TestAddr(t *testing.T) {
+	saved := ports
+	defer func() { ports = saved }()
+	ports = portmap.Ports{8888: 41001}
+
+	assert.Equal(t, "localhost:41001", Addr("localhost:8888"))
+	assert.Equal(t, "127.0.0.1:41001", Addr("127.0.0.1:8888"))
+	assert.Equal(t, "nacos:8888", Addr("nacos:8888"))
+	assert.Equal(t, "localhost:1314", Addr("localhost:1314"))
+	assert.Equal(t, "http://localhost:41001/user?id=1", URL("http://localhost:8888/user?id=1"))
+	assert.Equal(t, "http://localhost/user", URL("http://localhost/user"))
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("moved"))
+	}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	moved, err := portmap.Parse("1=" + port)
+	require.NoError(t, err)
+
+	saved := ports
+	defer func() { ports = saved }()
+	ports = moved
+	resp, err := (&http.Client{Transport: Transport}).Get("http://127.0.0.1:1/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "moved", string(body))
+	assert.NotEqual(t, http.DefaultTransport, Transport, "the default transport is left alone")
+}
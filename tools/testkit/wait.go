@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testkit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// DefaultReadyTimeout is how long WaitForGateway waits for the gateway to accept connections
+	DefaultReadyTimeout = 30 * time.Second
+	// PollInterval is the pause between two attempts of the wait and eventually helpers
+	PollInterval = 200 * time.Millisecond
+)
+
+// WaitTCP waits until addr accepts tcp connections
+func WaitTCP(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", Addr(addr), time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is not ready after %s: %w", addr, timeout, err)
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+// WaitHTTP waits until a GET of url answers with one of the statuses, 200 when none is given
+func WaitHTTP(url string, timeout time.Duration, statuses ...int) error {
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusOK}
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := do(http.MethodGet, url, "")
+		if err == nil {
+			for _, status := range statuses {
+				if resp.StatusCode == status {
+					return nil
+				}
+			}
+			err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is not ready after %s: %w", url, timeout, err)
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+// WaitForGateway stops the test if the gateway does not listen on addr within DefaultReadyTimeout
+func WaitForGateway(t testing.TB, addr string) {
+	t.Helper()
+	require.NoError(t, WaitTCP(addr, DefaultReadyTimeout))
+}
@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func issueAll(t *testing.T) string {
+	dir := t.TempDir()
+	ca, err := NewCA(time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, ca.Save(dir))
+
+	loaded, err := LoadCA(dir)
+	require.NoError(t, err)
+	require.NoError(t, loaded.IssueServer(dir, []string{"localhost", "127.0.0.1"}, time.Hour))
+	require.NoError(t, loaded.IssueClient(dir, "test-id", []string{"pixiu"}, time.Hour))
+	return dir
+}
+
+func loadCert(t *testing.T, path string) *x509.Certificate {
+	pair, err := tls.LoadX509KeyPair(path, path[:len(path)-len(".pem")]+"-key.pem")
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	return cert
+}
+
+func TestIssue(t *testing.T) {
+	dir := issueAll(t)
+	ca := loadCert(t, filepath.Join(dir, "ca.pem"))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	server := loadCert(t, filepath.Join(dir, "server.pem"))
+	_, err := server.Verify(x509.VerifyOptions{Roots: roots, DNSName: "localhost"})
+	assert.NoError(t, err)
+	_, err = server.Verify(x509.VerifyOptions{Roots: roots, DNSName: "127.0.0.1"})
+	assert.NoError(t, err)
+
+	client := loadCert(t, filepath.Join(dir, "test-id.pem"))
+	assert.Equal(t, "test-id", client.Subject.CommonName)
+	assert.Equal(t, []string{"test-id", "pixiu"}, client.DNSNames)
+	_, err = client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.NoError(t, err)
+	_, err = client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	assert.Error(t, err, "a node certificate must not serve")
+
+	info, err := os.Stat(filepath.Join(dir, "test-id-key.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestTunnel(t *testing.T) {
+	dir := issueAll(t)
+
+	// the upstream requires a client certificate and answers with its common name
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(loadCert(t, filepath.Join(dir, "ca.pem")))
+	upstream, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		NextProtos:   []string{"h2"},
+	})
+	require.NoError(t, err)
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := conn.(*tls.Conn)
+				line, err := bufio.NewReader(tlsConn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				node := tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+				_, _ = conn.Write([]byte(node + " " + line))
+			}()
+		}
+	}()
+
+	tunnel, err := NewTunnel(dir, "test-id", upstream.Addr().String())
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() { _ = tunnel.Serve(lis) }()
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "test-id hello\n", reply)
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// xdsca is a local certificate authority for the xds samples. It issues the certificates of the control plane
+// and of the pixiu nodes, and runs the tunnel that connects pixiu, whose xds client dials plaintext, over mtls.
+//
+//	go run ./tools/xdsca ca -dir certs
+//	go run ./tools/xdsca server -dir certs -hosts localhost,127.0.0.1
+//	go run ./tools/xdsca client -dir certs -node test-id -groups pixiu
+//	go run ./tools/xdsca tunnel -dir certs -node test-id -listen 127.0.0.1:18010 -upstream localhost:18000
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `usage: xdsca <command> [flags]
+
+commands:
+  ca      create the certificate authority, ca.pem and ca-key.pem
+  server  issue the control plane certificate, server.pem and server-key.pem
+  client  issue the certificate of a node, <node>.pem and <node>-key.pem
+  tunnel  accept plaintext xds on a local port and forward it over mtls with the certificate of a node
+`
+
+func main() {
+	log.SetFlags(log.Ltime)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		log.Fatalf("[xdsca] %v", err)
+	}
+}
+
+func run(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	dir := fs.String("dir", "certs", "directory of the certificates and keys")
+	validity := fs.Duration("validity", 365*24*time.Hour, "how long the issued certificates are valid")
+	hosts := fs.String("hosts", "localhost,127.0.0.1", "comma separated names and addresses of the control plane")
+	node := fs.String("node", "", "node id of the pixiu the certificate is issued for")
+	groups := fs.String("groups", "", "comma separated node groups the certificate is issued for, like the cluster of the node")
+	listen := fs.String("listen", "127.0.0.1:18010", "plaintext address pixiu connects to")
+	upstream := fs.String("upstream", "localhost:18000", "mtls address of the control plane")
+	_ = fs.Parse(args)
+
+	switch command {
+	case "ca":
+		ca, err := NewCA(*validity)
+		if err != nil {
+			return err
+		}
+		return ca.Save(*dir)
+	case "server":
+		ca, err := LoadCA(*dir)
+		if err != nil {
+			return err
+		}
+		return ca.IssueServer(*dir, splitList(*hosts), *validity)
+	case "client":
+		if *node == "" {
+			return fmt.Errorf("client: -node is required")
+		}
+		ca, err := LoadCA(*dir)
+		if err != nil {
+			return err
+		}
+		return ca.IssueClient(*dir, *node, splitList(*groups), *validity)
+	case "tunnel":
+		if *node == "" {
+			return fmt.Errorf("tunnel: -node is required")
+		}
+		t, err := NewTunnel(*dir, *node, *upstream)
+		if err != nil {
+			return err
+		}
+		return t.ListenAndServe(*listen)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// splitList splits a comma separated flag, an empty flag is an empty list
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
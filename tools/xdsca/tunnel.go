@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Tunnel accepts plaintext connections on a local port and forwards them to the control plane over mtls,
+// with the certificate of a node. Pixiu's xds client dials the control plane without tls, the tunnel lets
+// it reach a control plane that requires client certificates.
+type Tunnel struct {
+	upstream string
+	config   *tls.Config
+}
+
+// NewTunnel loads ca.pem and the certificate of node from dir
+func NewTunnel(dir, node, upstream string) (*Tunnel, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, node+".pem"), filepath.Join(dir, node+"-key.pem"))
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s: no certificate in ca.pem", dir)
+	}
+	host, _, err := net.SplitHostPort(upstream)
+	if err != nil {
+		return nil, err
+	}
+	return &Tunnel{upstream: upstream, config: &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   host,
+		NextProtos:   []string{"h2"},
+		MinVersion:   tls.VersionTLS12,
+	}}, nil
+}
+
+// ListenAndServe forwards the connections accepted on addr
+func (t *Tunnel) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("[xdsca] tunnel %s -> %s", lis.Addr(), t.upstream)
+	return t.Serve(lis)
+}
+
+// Serve forwards the connections accepted by lis until it is closed
+func (t *Tunnel) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go t.forward(conn)
+	}
+}
+
+func (t *Tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+	upstream, err := tls.Dial("tcp", t.upstream, t.config)
+	if err != nil {
+		log.Printf("[xdsca] tunnel to %s: %v", t.upstream, err)
+		return
+	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(upstream, conn)
+		_ = upstream.CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(conn, upstream)
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+		}
+	}()
+	wg.Wait()
+}
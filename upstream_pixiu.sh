@@ -0,0 +1,30 @@
+#!/bin/bash
+#
+#  Licensed to the Apache Software Foundation (ASF) under one or more
+#  contributor license agreements.  See the NOTICE file distributed with
+#  this work for additional information regarding copyright ownership.
+#  The ASF licenses this file to You under the Apache License, Version 2.0
+#  (the "License"); you may not use this file except in compliance with
+#  the License.  You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+#  Unless required by applicable law or agreed to in writing, software
+#  distributed under the License is distributed on an "AS IS" BASIS,
+#  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+#  See the License for the specific language governing permissions and
+#  limitations under the License.
+#
+
+if [ -z "$PIXIU_SRC" ]; then
+  echo "Provide a dubbo-go-pixiu checkout please, like : PIXIU_SRC=pathto/dubbo-go-pixiu ./upstream_pixiu.sh gateway start -c llm/mock/pixiu/conf.yaml"
+  exit 1
+fi
+
+# the llm filters are not in the pixiu release of go.mod yet, so ./pixiu is run against the checkout
+# with a copy of go.mod that replaces pixiu, go.mod and go.sum are left as they are, like tools/igt does
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+{ cat go.mod; echo; echo "replace github.com/apache/dubbo-go-pixiu => $(cd "$PIXIU_SRC" && pwd)"; } > "$dir/go.mod"
+cp go.sum "$dir/go.sum"
+go run -modfile "$dir/go.mod" -mod mod ./pixiu "$@"
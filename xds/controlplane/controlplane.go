@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package controlplane is the xds control plane the pixiu samples build on: a grpc server of the
+// pixiu extension configs, node groups that share a snapshot, connected node tracking, tls, and
+// the sources that publish the snapshots.
+package controlplane
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+
+	"google.golang.org/grpc"
+)
+
+// Options configure a control plane
+type Options struct {
+	// Port of the xds server
+	Port uint
+	// NodeID is the node group the sources publish their default config to, DefaultGroup serves every
+	// node whose group has no config of its own. With GroupKey "id" it is the id of a single node.
+	NodeID string
+	// GroupKey names the group of a node, see NodeGroups
+	GroupKey string
+	// AdminAddr serves /status, /metrics and the handlers of the samples, nothing when empty
+	AdminAddr string
+	// TLSCert and TLSKey serve xds over tls, TLSClientCA also requires client certificates, see NodeIdentity
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+	// ShutdownTimeout is how long the streams may take to end before they are cut
+	ShutdownTimeout time.Duration
+}
+
+// DefaultOptions serves xds on 18000 and the admin endpoints on 18001 of the loopback only
+func DefaultOptions() Options {
+	return Options{
+		Port:            18000,
+		NodeID:          DefaultGroup,
+		GroupKey:        "cluster",
+		AdminAddr:       "127.0.0.1:18001",
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+// RegisterFlags registers the options as flags of fs, with their current values as defaults
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.UintVar(&o.Port, "port", o.Port, "port of the xds server")
+	fs.StringVar(&o.NodeID, "node-id", o.NodeID, "node group the default config is published to, "+DefaultGroup+" for every node")
+	fs.StringVar(&o.GroupKey, "group-key", o.GroupKey, "node metadata field that names the group of a node, cluster for the node cluster, id for the node id")
+	fs.StringVar(&o.AdminAddr, "admin", o.AdminAddr, "address of the /status and /metrics endpoints, and of the apis of the sample")
+	fs.StringVar(&o.TLSCert, "tls-cert", o.TLSCert, "certificate of the xds server, plaintext without it")
+	fs.StringVar(&o.TLSKey, "tls-key", o.TLSKey, "key of the xds server certificate")
+	fs.StringVar(&o.TLSClientCA, "tls-client-ca", o.TLSClientCA, "ca of the client certificates, requires mutual tls and nodes whose id and group the certificate is issued for")
+	fs.DurationVar(&o.ShutdownTimeout, "shutdown-timeout", o.ShutdownTimeout, "how long the xds streams may take to end on shutdown")
+}
+
+// Source publishes the snapshots of the node groups
+type Source interface {
+	// Run publishes to the snapshot cache of cp until ctx is done, an error stops the control plane
+	Run(ctx context.Context, cp *ControlPlane) error
+}
+
+// SourceFunc is a Source of a function
+type SourceFunc func(ctx context.Context, cp *ControlPlane) error
+
+// Run calls f
+func (f SourceFunc) Run(ctx context.Context, cp *ControlPlane) error {
+	return f(ctx, cp)
+}
+
+// Static is the source of a config built in go, published once as version 1 to the NodeID of the options
+func Static(build func() (*pixiupb.PixiuExtensionClusters, *pixiupb.PixiuExtensionListeners)) Source {
+	return SourceFunc(func(ctx context.Context, cp *ControlPlane) error {
+		cds, lds := build()
+		snap, err := NewSnapshot("1", cds, lds)
+		if err != nil {
+			return err
+		}
+		if err = cp.Publish(ctx, cp.Options.NodeID, snap); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	})
+}
+
+// ControlPlane serves the snapshots its sources publish to the pixiu nodes
+type ControlPlane struct {
+	Options   Options
+	Groups    *NodeGroups
+	Snapshots cache.SnapshotCache
+	Tracker   *Tracker
+	// Admin is served on the AdminAddr of the options, the samples add their apis to it
+	Admin *http.ServeMux
+}
+
+// New returns a control plane with an empty snapshot cache
+func New(options Options) *ControlPlane {
+	groups := &NodeGroups{Key: options.GroupKey}
+	cp := &ControlPlane{
+		Options:   options,
+		Groups:    groups,
+		Snapshots: cache.NewSnapshotCache(false, groups, l),
+		Tracker:   NewTracker(groups),
+		Admin:     http.NewServeMux(),
+	}
+	cp.Admin.HandleFunc("/status", cp.Tracker.ServeStatus)
+	cp.Admin.HandleFunc("/metrics", cp.Tracker.ServeMetrics)
+	return cp
+}
+
+// Publish sets the snapshot of a node group, a group other than DefaultGroup is added to the groups
+func (cp *ControlPlane) Publish(ctx context.Context, group string, snap *cache.Snapshot) error {
+	if err := cp.Snapshots.SetSnapshot(ctx, group, snap); err != nil {
+		return err
+	}
+	if group != DefaultGroup {
+		cp.Groups.Add(group)
+	}
+	return nil
+}
+
+// Run serves xds on the port of the options until ctx is done or a source fails
+func (cp *ControlPlane) Run(ctx context.Context, sources ...Source) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cp.Options.Port))
+	if err != nil {
+		return err
+	}
+	l.Infof("management server listening on %d", cp.Options.Port)
+	return cp.Serve(ctx, lis, sources...)
+}
+
+// Serve serves xds on lis until ctx is done or a source fails. The streams get the ShutdownTimeout
+// of the options to end before they are cut.
+func (cp *ControlPlane) Serve(ctx context.Context, lis net.Listener, sources ...Source) error {
+	var tlsConfig *tls.Config
+	var callbacks server.Callbacks = cp.Tracker
+	switch {
+	case cp.Options.TLSCert != "":
+		var err error
+		if tlsConfig, err = LoadTLS(cp.Options.TLSCert, cp.Options.TLSKey, cp.Options.TLSClientCA); err != nil {
+			_ = lis.Close()
+			return err
+		}
+		if cp.Options.TLSClientCA != "" {
+			callbacks = NewNodeIdentity(cp.Tracker, cp.Groups)
+		}
+	case cp.Options.TLSClientCA != "":
+		_ = lis.Close()
+		return errors.New("the client ca needs a certificate and key of the server")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	grpcServer := NewGRPCServer(tlsConfig)
+	RegisterServer(grpcServer, server.NewServer(ctx, cp.Snapshots, callbacks))
+
+	errs := make(chan error, len(sources)+2)
+	for _, source := range sources {
+		go func(source Source) {
+			errs <- source.Run(ctx, cp)
+		}(source)
+	}
+	var admin *http.Server
+	if cp.Options.AdminAddr != "" {
+		admin = &http.Server{Addr: cp.Options.AdminAddr, Handler: cp.Admin}
+		go func() {
+			l.Infof("admin listening on %s", cp.Options.AdminAddr)
+			if err := admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("admin: %w", err)
+			}
+		}()
+	}
+	go func() {
+		errs <- grpcServer.Serve(lis)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+		// a source that is done without error leaves the snapshots it published
+		for err == nil && ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case err = <-errs:
+			}
+		}
+	}
+	cancel()
+	cp.shutdown(grpcServer, admin)
+	return err
+}
+
+// shutdown stops the grpc server gracefully, the canceled context of the xds server has ended the streams
+func (cp *ControlPlane) shutdown(grpcServer *grpc.Server, admin *http.Server) {
+	l.Infof("shutting down, the streams have %s to end", cp.Options.ShutdownTimeout)
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(cp.Options.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		grpcServer.Stop()
+	}
+	if admin != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cp.Options.ShutdownTimeout)
+		defer cancel()
+		_ = admin.Shutdown(ctx)
+	}
+}
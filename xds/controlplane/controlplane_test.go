@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	extensionpb "github.com/envoyproxy/go-control-plane/envoy/service/extension/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testConfig() (*pixiupb.PixiuExtensionClusters, *pixiupb.PixiuExtensionListeners) {
+	return &pixiupb.PixiuExtensionClusters{Clusters: []*pixiupb.Cluster{{
+			Name:      "http_bin",
+			Endpoints: []*pixiupb.Endpoint{{Id: "backend", Address: &pixiupb.SocketAddress{Address: "127.0.0.1", Port: 8081}}},
+		}}},
+		&pixiupb.PixiuExtensionListeners{Listeners: []*pixiupb.Listener{{
+			Name:    "net/http",
+			Address: &pixiupb.Address{SocketAddress: &pixiupb.SocketAddress{Address: "0.0.0.0", Port: 8888}},
+		}}}
+}
+
+// testServer serves cp on an in-memory listener until the test ends, done receives what Serve returned
+type testServer struct {
+	lis    *bufconn.Listener
+	cancel context.CancelFunc
+	done   chan error
+}
+
+func serve(t *testing.T, cp *ControlPlane, sources ...Source) *testServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &testServer{lis: bufconn.Listen(1 << 20), cancel: cancel, done: make(chan error, 1)}
+	go func() { s.done <- cp.Serve(ctx, s.lis, sources...) }()
+	t.Cleanup(cancel)
+	return s
+}
+
+func (s *testServer) dial(t *testing.T, opts ...grpc.DialOption) extensionpb.ExtensionConfigDiscoveryServiceClient {
+	if len(opts) == 0 {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return s.lis.DialContext(ctx) }))
+	conn, err := grpc.Dial("bufnet", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return extensionpb.NewExtensionConfigDiscoveryServiceClient(conn)
+}
+
+func fetch(ctx context.Context, c extensionpb.ExtensionConfigDiscoveryServiceClient, node *core.Node) (*discovery.DiscoveryResponse, error) {
+	return c.FetchExtensionConfigs(ctx, &discovery.DiscoveryRequest{Node: node, TypeUrl: resource.ExtensionConfigType})
+}
+
+func testOptions() Options {
+	options := DefaultOptions()
+	options.AdminAddr = ""
+	options.ShutdownTimeout = time.Second
+	return options
+}
+
+func TestStaticSource(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := serve(t, New(testOptions()), Static(testConfig))
+	client := s.dial(t)
+
+	var resp *discovery.DiscoveryResponse
+	require.Eventually(t, func() bool {
+		var err error
+		resp, err = fetch(ctx, client, &core.Node{Id: "pixiu-1", Cluster: "pixiu"})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "1", resp.VersionInfo)
+	assert.Len(t, resp.Resources, 2)
+}
+
+func TestNodeIDWithIDGroups(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	options := testOptions()
+	options.GroupKey = "id"
+	options.NodeID = "pixiu-1"
+	cp := New(options)
+	s := serve(t, cp, Static(testConfig))
+	client := s.dial(t)
+
+	require.Eventually(t, func() bool {
+		_, err := fetch(ctx, client, &core.Node{Id: "pixiu-1"})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "pixiu-1", cp.Groups.ID(&core.Node{Id: "pixiu-1"}))
+	// the other nodes fall back to the default group, which has no snapshot
+	_, err := fetch(ctx, client, &core.Node{Id: "pixiu-2"})
+	assert.Error(t, err)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := serve(t, New(testOptions()), Static(testConfig))
+
+	// an open stream does not hold the shutdown back
+	stream, err := s.dial(t).StreamExtensionConfigs(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&discovery.DiscoveryRequest{Node: &core.Node{Id: "pixiu-1"}, TypeUrl: resource.ExtensionConfigType}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	s.cancel()
+	select {
+	case err := <-s.done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the control plane did not shut down")
+	}
+	_, err = stream.Recv()
+	assert.Error(t, err)
+}
+
+func TestFailingSourceStops(t *testing.T) {
+	broken := errors.New("broken source")
+	done := SourceFunc(func(context.Context, *ControlPlane) error { return nil })
+	s := serve(t, New(testOptions()), done, SourceFunc(func(context.Context, *ControlPlane) error { return broken }))
+	select {
+	case err := <-s.done:
+		assert.ErrorIs(t, err, broken)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the control plane did not stop")
+	}
+}
+
+func TestServeRejectsClientCAWithoutCertificate(t *testing.T) {
+	options := testOptions()
+	options.TLSClientCA = "ca.pem"
+	assert.Error(t, New(options).Serve(context.Background(), bufconn.Listen(1024)))
+}
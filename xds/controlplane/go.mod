@@ -0,0 +1,12 @@
+module github.com/dubbo-go-pixiu/samples/xds/controlplane
+
+go 1.16
+
+require (
+	github.com/dubbo-go-pixiu/pixiu-api v0.1.6-0.20220427143451-c0a68bf5b29a
+	github.com/envoyproxy/go-control-plane v0.11.1-0.20230524094728-9239064ad72f
+	github.com/stretchr/testify v1.8.3
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
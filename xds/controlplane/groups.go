@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"sync"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+// DefaultGroup is the group of the nodes no group is configured for
+const DefaultGroup = "default"
+
+// NodeGroups is the cache.NodeHash that shares a snapshot between the nodes of a group.
+// The group of a node is its cluster, or a field of its metadata, and nodes whose group
+// has no snapshot of its own get the one of DefaultGroup.
+type NodeGroups struct {
+	// Key is the node metadata field that names the group, the node cluster when empty or "cluster",
+	// and the node id for "id", which gives a node a snapshot of its own.
+	// The field is also looked up in the LABELS of istio style metadata.
+	Key string
+
+	mu     sync.RWMutex
+	groups map[string]bool
+}
+
+// ID returns the group of the node
+func (g *NodeGroups) ID(node *core.Node) string {
+	group := g.label(node)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.groups[group] {
+		return group
+	}
+	return DefaultGroup
+}
+
+// Add adds groups to the groups that have a snapshot of their own
+func (g *NodeGroups) Add(groups ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.groups == nil {
+		g.groups = make(map[string]bool, len(groups))
+	}
+	for _, group := range groups {
+		g.groups[group] = true
+	}
+}
+
+// Set replaces the groups that have a snapshot of their own
+func (g *NodeGroups) Set(groups ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.groups = make(map[string]bool, len(groups))
+	for _, group := range groups {
+		g.groups[group] = true
+	}
+}
+
+func (g *NodeGroups) label(node *core.Node) string {
+	if node == nil {
+		return ""
+	}
+	switch g.Key {
+	case "", "cluster":
+		return node.GetCluster()
+	case "id":
+		return node.GetId()
+	}
+	fields := node.GetMetadata().GetFields()
+	if v, ok := fields[g.Key]; ok {
+		return v.GetStringValue()
+	}
+	return fields["LABELS"].GetStructValue().GetFields()[g.Key].GetStringValue()
+}
@@ -15,12 +15,15 @@
  * limitations under the License.
  */
 
-package main
+package controlplane
 
 import (
 	"log"
 )
 
+// l is the logger of the control plane
+var l = Logger{Debug: true}
+
 // Logger An example of a logger that implements `pkg/log/Logger`.  Logs to
 // stdout.  If Debug == false then Debugf() and Infof() won't output
 // anything.
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controlplane
+
+import (
+	"encoding/json"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routeConfig is the part of the http connection manager config RouteClusters looks at
+type routeConfig struct {
+	RouteConfig struct {
+		Routes []struct {
+			Route struct {
+				Cluster string `json:"cluster" yaml:"cluster"`
+			} `json:"route" yaml:"route"`
+		} `json:"routes" yaml:"routes"`
+	} `json:"route_config" yaml:"route_config"`
+}
+
+// RouteClusters returns the cluster of every route of an http connection manager, whatever way its config is encoded
+func RouteClusters(f *pixiupb.NetworkFilter) ([]string, error) {
+	var conf routeConfig
+	switch {
+	case f.GetStruct() != nil:
+		content, err := json.Marshal(f.GetStruct().AsMap())
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(content, &conf); err != nil {
+			return nil, err
+		}
+	case f.GetJson() != nil:
+		if err := json.Unmarshal([]byte(f.GetJson().GetContent()), &conf); err != nil {
+			return nil, err
+		}
+	case f.GetYaml() != nil:
+		if err := yaml.Unmarshal([]byte(f.GetYaml().GetContent()), &conf); err != nil {
+			return nil, err
+		}
+	}
+	clusters := make([]string, 0, len(conf.RouteConfig.Routes))
+	for _, r := range conf.RouteConfig.Routes {
+		clusters = append(clusters, r.Route.Cluster)
+	}
+	return clusters, nil
+}
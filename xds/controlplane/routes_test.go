@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controlplane
+
+import (
+	"testing"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestRouteClusters(t *testing.T) {
+	conf, err := structpb.NewStruct(map[string]interface{}{
+		"route_config": map[string]interface{}{
+			"routes": []interface{}{
+				map[string]interface{}{"route": map[string]interface{}{"cluster": "a"}},
+				map[string]interface{}{"route": map[string]interface{}{"cluster": "b"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, f := range map[string]*pixiupb.NetworkFilter{
+		"struct": {Config: &pixiupb.NetworkFilter_Struct{Struct: conf}},
+		"json": {Config: &pixiupb.NetworkFilter_Json{Json: &pixiupb.Config{
+			Content: `{"route_config":{"routes":[{"route":{"cluster":"a"}},{"route":{"cluster":"b"}}]}}`,
+		}}},
+		"yaml": {Config: &pixiupb.NetworkFilter_Yaml{Yaml: &pixiupb.Config{
+			Content: "route_config:\n  routes:\n    - route: {cluster: a}\n    - route: {cluster: b}\n",
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clusters, err := RouteClusters(f)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, clusters)
+		})
+	}
+
+	_, err = RouteClusters(&pixiupb.NetworkFilter{Config: &pixiupb.NetworkFilter_Json{Json: &pixiupb.Config{Content: "{"}}})
+	assert.Error(t, err)
+}
@@ -14,14 +14,10 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
-package main
+package controlplane
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net"
+	"crypto/tls"
 	"time"
 )
 
@@ -37,6 +33,7 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -47,26 +44,25 @@ const (
 	grpcMaxConcurrentStreams = 1000000
 )
 
-func registerServer(grpcServer *grpc.Server, server server.Server) {
-	// register services
-	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
-	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, server)
-	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, server)
-	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, server)
-	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, server)
-	secretservice.RegisterSecretDiscoveryServiceServer(grpcServer, server)
-	runtimeservice.RegisterRuntimeDiscoveryServiceServer(grpcServer, server)
-	extensionpb.RegisterExtensionConfigDiscoveryServiceServer(grpcServer, server)
+// RegisterServer registers the xds services of srv, pixiu uses the extension config one
+func RegisterServer(grpcServer *grpc.Server, srv server.Server) {
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, srv)
+	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, srv)
+	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, srv)
+	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, srv)
+	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, srv)
+	secretservice.RegisterSecretDiscoveryServiceServer(grpcServer, srv)
+	runtimeservice.RegisterRuntimeDiscoveryServiceServer(grpcServer, srv)
+	extensionpb.RegisterExtensionConfigDiscoveryServiceServer(grpcServer, srv)
 }
 
-// RunServer starts an xDS server at the given port.
-func RunServer(ctx context.Context, srv server.Server, port uint) {
+// NewGRPCServer returns the grpc server of the xds services, over tls when tlsConfig is set
+func NewGRPCServer(tlsConfig *tls.Config) *grpc.Server {
 	// gRPC golang library sets a very small upper bound for the number gRPC/h2
 	// streams over a single TCP connection. If a proxy multiplexes requests over
 	// a single connection to the management server, then it might lead to
 	// availability problems. Keepalive timeouts based on connection_keepalive parameter https://www.envoyproxy.io/docs/envoy/latest/configuration/overview/examples#dynamic
-	var grpcOptions []grpc.ServerOption
-	grpcOptions = append(grpcOptions,
+	grpcOptions := []grpc.ServerOption{
 		grpc.MaxConcurrentStreams(grpcMaxConcurrentStreams),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			Time:    grpcKeepaliveTime,
@@ -76,18 +72,9 @@ func RunServer(ctx context.Context, srv server.Server, port uint) {
 			MinTime:             grpcKeepaliveMinTime,
 			PermitWithoutStream: true,
 		}),
-	)
-	grpcServer := grpc.NewServer(grpcOptions...)
-
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		log.Fatal(err)
 	}
-
-	registerServer(grpcServer, srv)
-
-	log.Printf("management server listening on %d\n", port)
-	if err = grpcServer.Serve(lis); err != nil {
-		log.Println(err)
+	if tlsConfig != nil {
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
+	return grpc.NewServer(grpcOptions...)
 }
@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ClusterPrefix and ListenerPrefix start the names of the resources of a split snapshot,
+// the name of the cluster or listener follows them
+const (
+	ClusterPrefix  = xds.ClusterType + "/"
+	ListenerPrefix = xds.ListenerType + "/"
+)
+
+// NewSnapshot wraps the clusters and listeners into the extension configs pixiu subscribes to
+func NewSnapshot(version string, cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners) (*cache.Snapshot, error) {
+	cdsResource, err := extensionConfig(xds.ClusterType, cds)
+	if err != nil {
+		return nil, err
+	}
+	ldsResource, err := extensionConfig(xds.ListenerType, lds)
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshot(version, []types.Resource{cdsResource, ldsResource})
+}
+
+// NewSplitSnapshot wraps each cluster and each listener into an extension config of its own, named
+// after it with ClusterPrefix or ListenerPrefix. Delta xDS clients then only receive the resources
+// that changed, instead of every cluster or listener when one of them changes.
+func NewSplitSnapshot(version string, cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners) (*cache.Snapshot, error) {
+	resources := make([]types.Resource, 0, len(cds.Clusters)+len(lds.Listeners))
+	for _, c := range cds.Clusters {
+		r, err := extensionConfig(ClusterPrefix+c.Name, &pixiupb.PixiuExtensionClusters{Clusters: []*pixiupb.Cluster{c}})
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	for _, listener := range lds.Listeners {
+		r, err := extensionConfig(ListenerPrefix+listener.Name, &pixiupb.PixiuExtensionListeners{Listeners: []*pixiupb.Listener{listener}})
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	return newSnapshot(version, resources)
+}
+
+// extensionConfig marshals m deterministically, delta xds versions a resource by the hash of its bytes
+// and the random order of a Struct map would resend unchanged resources
+func extensionConfig(name string, m proto.Message) (*core.TypedExtensionConfig, error) {
+	typed := &anypb.Any{}
+	if err := anypb.MarshalFrom(typed, m, proto.MarshalOptions{Deterministic: true}); err != nil {
+		return nil, err
+	}
+	return &core.TypedExtensionConfig{Name: name, TypedConfig: typed}, nil
+}
+
+func newSnapshot(version string, resources []types.Resource) (*cache.Snapshot, error) {
+	snap, err := cache.NewSnapshot(version, map[resource.Type][]types.Resource{
+		resource.ExtensionConfigType: resources,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = snap.Consistent(); err != nil {
+		return nil, fmt.Errorf("inconsistent snapshot: %w", err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	cds, lds := testConfig()
+	snap, err := NewSnapshot("1", cds, lds)
+	require.NoError(t, err)
+	resources := snap.GetResources(resource.ExtensionConfigType)
+	require.Len(t, resources, 2)
+	require.Contains(t, resources, xds.ClusterType)
+	require.Contains(t, resources, xds.ListenerType)
+}
+
+func TestNewSplitSnapshot(t *testing.T) {
+	cds, lds := testConfig()
+	snap, err := NewSplitSnapshot("1", cds, lds)
+	require.NoError(t, err)
+
+	resources := snap.GetResources(resource.ExtensionConfigType)
+	require.Len(t, resources, len(cds.Clusters)+len(lds.Listeners))
+	require.Contains(t, resources, ClusterPrefix+"http_bin")
+	require.Contains(t, resources, ListenerPrefix+"net/http")
+}
+
+func TestNodeGroups(t *testing.T) {
+	metadata, err := structpb.NewStruct(map[string]interface{}{
+		"zone":   "zone-a",
+		"LABELS": map[string]interface{}{"track": "canary"},
+	})
+	require.NoError(t, err)
+	node := &core.Node{Id: "pixiu-1", Cluster: "pixiu", Metadata: metadata}
+
+	groups := &NodeGroups{}
+	assert.Equal(t, DefaultGroup, groups.ID(node))
+	assert.Equal(t, DefaultGroup, groups.ID(nil))
+	groups.Set("pixiu", "zone-a", "canary")
+	assert.Equal(t, "pixiu", groups.ID(node))
+
+	groups.Key = "zone"
+	assert.Equal(t, "zone-a", groups.ID(node))
+	groups.Key = "track"
+	assert.Equal(t, "canary", groups.ID(node))
+	groups.Key = "missing"
+	assert.Equal(t, DefaultGroup, groups.ID(node))
+
+	groups.Key = "id"
+	groups.Add("pixiu-1")
+	assert.Equal(t, "pixiu-1", groups.ID(node))
+}
@@ -0,0 +1,314 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+)
+
+// Tracker is the server.Callbacks that records the streams of every node, and the versions each node
+// was sent, ACKed and NACKed. It serves them as json on /status and as prometheus metrics on /metrics.
+type Tracker struct {
+	hash cache.NodeHash
+
+	mu      sync.Mutex
+	streams map[int64]*stream
+	nodes   map[string]*NodeStatus
+}
+
+var _ server.Callbacks = &Tracker{}
+
+// NodeStatus is what the tracker knows about a node
+type NodeStatus struct {
+	ID        string                     `json:"id"`
+	Cluster   string                     `json:"cluster"`
+	Group     string                     `json:"group"`
+	Streams   int                        `json:"streams"`
+	LastSeen  time.Time                  `json:"last_seen"`
+	Resources map[string]*ResourceStatus `json:"resources"`
+
+	node *core.Node
+}
+
+// ResourceStatus is the state of a node for a type url
+type ResourceStatus struct {
+	// Sent is the last version pushed to the node
+	Sent string `json:"sent,omitempty"`
+	// Acked is the last version the node applied
+	Acked string `json:"acked,omitempty"`
+	// Nacked is the last version the node rejected, with the error it reported
+	Nacked   string    `json:"nacked,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	NackedAt time.Time `json:"nacked_at,omitempty"`
+	Acks     uint64    `json:"acks"`
+	Nacks    uint64    `json:"nacks"`
+}
+
+// stream is an open stream, nonces maps the nonce of every response to the version it carried
+type stream struct {
+	node   *core.Node
+	nonces map[string]string
+}
+
+// NewTracker returns a tracker that reports the group of the nodes with hash
+func NewTracker(hash cache.NodeHash) *Tracker {
+	return &Tracker{hash: hash, streams: map[int64]*stream{}, nodes: map[string]*NodeStatus{}}
+}
+
+// Nodes returns the status of every node that connected, sorted by id
+func (t *Tracker) Nodes() []NodeStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	nodes := make([]NodeStatus, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		node := *n
+		node.Group = t.hash.ID(n.node)
+		node.Resources = make(map[string]*ResourceStatus, len(n.Resources))
+		for typeURL, r := range n.Resources {
+			res := *r
+			node.Resources[typeURL] = &res
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+func (t *Tracker) OnStreamOpen(_ context.Context, id int64, _ string) error {
+	t.open(id)
+	return nil
+}
+
+func (t *Tracker) OnStreamClosed(id int64, _ *core.Node) {
+	t.close(id)
+}
+
+func (t *Tracker) OnDeltaStreamOpen(_ context.Context, id int64, _ string) error {
+	t.open(id)
+	return nil
+}
+
+func (t *Tracker) OnDeltaStreamClosed(id int64, _ *core.Node) {
+	t.close(id)
+}
+
+func (t *Tracker) OnStreamRequest(id int64, req *discovery.DiscoveryRequest) error {
+	t.request(id, req.GetNode(), req.GetTypeUrl(), req.GetResponseNonce(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+func (t *Tracker) OnStreamResponse(_ context.Context, id int64, req *discovery.DiscoveryRequest, res *discovery.DiscoveryResponse) {
+	t.response(id, req.GetTypeUrl(), res.GetNonce(), res.GetVersionInfo())
+}
+
+func (t *Tracker) OnStreamDeltaRequest(id int64, req *discovery.DeltaDiscoveryRequest) error {
+	t.request(id, req.GetNode(), req.GetTypeUrl(), req.GetResponseNonce(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+func (t *Tracker) OnStreamDeltaResponse(id int64, req *discovery.DeltaDiscoveryRequest, res *discovery.DeltaDiscoveryResponse) {
+	t.response(id, req.GetTypeUrl(), res.GetNonce(), res.GetSystemVersionInfo())
+}
+
+func (t *Tracker) OnFetchRequest(context.Context, *discovery.DiscoveryRequest) error {
+	return nil
+}
+
+func (t *Tracker) OnFetchResponse(*discovery.DiscoveryRequest, *discovery.DiscoveryResponse) {}
+
+func (t *Tracker) open(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streams[id] = &stream{nonces: map[string]string{}}
+}
+
+func (t *Tracker) close(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.streams[id]
+	if !ok {
+		return
+	}
+	delete(t.streams, id)
+	if s.node != nil {
+		if n, ok := t.nodes[s.node.GetId()]; ok {
+			n.Streams--
+			n.LastSeen = time.Now()
+		}
+	}
+}
+
+// request records the node of the stream, and the ACK or NACK of the response the request answers
+func (t *Tracker) request(id int64, node *core.Node, typeURL, nonce, errorDetail string, nack bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.streams[id]
+	if !ok {
+		return
+	}
+	// only the first request of a stream has to carry the node
+	if s.node == nil {
+		if node == nil {
+			return
+		}
+		s.node = node
+		n, ok := t.nodes[node.GetId()]
+		if !ok {
+			n = &NodeStatus{ID: node.GetId(), Resources: map[string]*ResourceStatus{}}
+			t.nodes[node.GetId()] = n
+		}
+		n.Cluster, n.node = node.GetCluster(), node
+		n.Streams++
+	}
+	n := t.nodes[s.node.GetId()]
+	n.LastSeen = time.Now()
+	if nonce == "" {
+		return
+	}
+
+	version, ok := s.nonces[nonce]
+	if !ok {
+		return
+	}
+	delete(s.nonces, nonce)
+	res := n.resource(typeURL)
+	if nack {
+		res.Nacked, res.Error, res.NackedAt = version, errorDetail, time.Now()
+		res.Nacks++
+		l.Warnf("node %s NACKed %s version %s: %s", n.ID, typeURL, version, errorDetail)
+		return
+	}
+	res.Acked = version
+	res.Acks++
+}
+
+func (t *Tracker) response(id int64, typeURL, nonce, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.streams[id]
+	if !ok || s.node == nil {
+		return
+	}
+	s.nonces[nonce] = version
+	t.nodes[s.node.GetId()].resource(typeURL).Sent = version
+}
+
+func (n *NodeStatus) resource(typeURL string) *ResourceStatus {
+	res, ok := n.Resources[typeURL]
+	if !ok {
+		res = &ResourceStatus{}
+		n.Resources[typeURL] = res
+	}
+	return res
+}
+
+// ServeStatus writes the status of the nodes as json
+func (t *Tracker) ServeStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(map[string]interface{}{"nodes": t.Nodes()})
+}
+
+// ServeMetrics writes the status of the nodes in the prometheus text format
+func (t *Tracker) ServeMetrics(w http.ResponseWriter, _ *http.Request) {
+	nodes := t.Nodes()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	connected, streams := 0, 0
+	for _, n := range nodes {
+		if n.Streams > 0 {
+			connected++
+		}
+		streams += n.Streams
+	}
+	metric(w, "xds_connected_nodes", "gauge", "Nodes with at least one open stream.")
+	fmt.Fprintf(w, "xds_connected_nodes %d\n", connected)
+	metric(w, "xds_open_streams", "gauge", "Open xds streams.")
+	fmt.Fprintf(w, "xds_open_streams %d\n", streams)
+
+	type sample struct {
+		labels string
+		res    *ResourceStatus
+	}
+	var samples []sample
+	for _, n := range nodes {
+		typeURLs := make([]string, 0, len(n.Resources))
+		for typeURL := range n.Resources {
+			typeURLs = append(typeURLs, typeURL)
+		}
+		sort.Strings(typeURLs)
+		for _, typeURL := range typeURLs {
+			samples = append(samples, sample{
+				labels: labels("node", n.ID, "group", n.Group, "type_url", typeURL),
+				res:    n.Resources[typeURL],
+			})
+		}
+	}
+	metric(w, "xds_acks_total", "counter", "Responses the node applied.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "xds_acks_total{%s} %d\n", s.labels, s.res.Acks)
+	}
+	metric(w, "xds_nacks_total", "counter", "Responses the node rejected.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "xds_nacks_total{%s} %d\n", s.labels, s.res.Nacks)
+	}
+	metric(w, "xds_node_acked_version", "gauge", "Version the node last applied, in the version label.")
+	for _, s := range samples {
+		if s.res.Acked != "" {
+			fmt.Fprintf(w, "xds_node_acked_version{%s,%s} 1\n", s.labels, labels("version", s.res.Acked))
+		}
+	}
+	metric(w, "xds_node_out_of_sync", "gauge", "1 when the node did not apply the last version it was sent.")
+	for _, s := range samples {
+		outOfSync := 0
+		if s.res.Sent != s.res.Acked {
+			outOfSync = 1
+		}
+		fmt.Fprintf(w, "xds_node_out_of_sync{%s} %d\n", s.labels, outOfSync)
+	}
+}
+
+func metric(w http.ResponseWriter, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// labels formats name value pairs as prometheus labels
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return strings.Join(parts, ",")
+}
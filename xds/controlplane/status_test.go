@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestTracker(t *testing.T) {
+	groups := &NodeGroups{}
+	groups.Set("canary")
+	tracker := NewTracker(groups)
+	ctx := context.Background()
+	node := &core.Node{Id: "pixiu-1", Cluster: "canary"}
+	typeURL := resource.ExtensionConfigType
+
+	require.NoError(t, tracker.OnStreamOpen(ctx, 1, typeURL))
+	require.NoError(t, tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{Node: node, TypeUrl: typeURL}))
+	req := &discovery.DiscoveryRequest{TypeUrl: typeURL}
+	tracker.OnStreamResponse(ctx, 1, req, &discovery.DiscoveryResponse{VersionInfo: "101", Nonce: "1"})
+	require.NoError(t, tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{TypeUrl: typeURL, VersionInfo: "101", ResponseNonce: "1"}))
+	tracker.OnStreamResponse(ctx, 1, req, &discovery.DiscoveryResponse{VersionInfo: "102", Nonce: "2"})
+	nack := &discovery.DiscoveryRequest{}
+	require.NoError(t, protojson.Unmarshal([]byte(`{"versionInfo": "101", "responseNonce": "2", "errorDetail": {"message": "unknown cluster http_bin2"}}`), nack))
+	nack.TypeUrl = typeURL
+	require.NoError(t, tracker.OnStreamRequest(1, nack))
+
+	nodes := tracker.Nodes()
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "pixiu-1", nodes[0].ID)
+	assert.Equal(t, "canary", nodes[0].Group)
+	assert.Equal(t, 1, nodes[0].Streams)
+	res := nodes[0].Resources[typeURL]
+	require.NotNil(t, res)
+	assert.Equal(t, "102", res.Sent)
+	assert.Equal(t, "101", res.Acked)
+	assert.Equal(t, "102", res.Nacked)
+	assert.Equal(t, "unknown cluster http_bin2", res.Error)
+	assert.Equal(t, uint64(1), res.Acks)
+	assert.Equal(t, uint64(1), res.Nacks)
+
+	rec := httptest.NewRecorder()
+	tracker.ServeMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := rec.Body.String()
+	labels := `node="pixiu-1",group="canary",type_url="` + typeURL + `"`
+	assert.Contains(t, metrics, "xds_connected_nodes 1\n")
+	assert.Contains(t, metrics, "xds_nacks_total{"+labels+"} 1\n")
+	assert.Contains(t, metrics, "xds_node_acked_version{"+labels+`,version="101"} 1`)
+	assert.Contains(t, metrics, "xds_node_out_of_sync{"+labels+"} 1\n")
+
+	tracker.OnStreamClosed(1, node)
+	rec = httptest.NewRecorder()
+	tracker.ServeStatus(rec, httptest.NewRequest("GET", "/status", nil))
+	var body struct {
+		Nodes []NodeStatus `json:"nodes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Nodes, 1)
+	assert.Equal(t, 0, body.Nodes[0].Streams)
+	assert.Equal(t, "101", body.Nodes[0].Resources[typeURL].Acked)
+}
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, `a="1",b="x\\y\"z\n"`, labels("a", "1", "b", "x\\y\"z\n"))
+}
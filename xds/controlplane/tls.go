@@ -0,0 +1,205 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LoadTLS returns the tls config of the xds server from the pem files of its certificate and key.
+// With clientCA the server requires client certificates issued by it, mutual tls.
+func LoadTLS(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCA == "" {
+		return config, nil
+	}
+	caPEM, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s: no certificate found", clientCA)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// NodeIdentity wraps the server.Callbacks of the xds server, and refuses the requests whose node id
+// is not the common name or a dns name of the client certificate, so a node can only fetch its own config.
+// The group a node names with the GroupKey of its metadata must be a name of the certificate too,
+// otherwise a node could read the snapshot of any group by changing its cluster.
+type NodeIdentity struct {
+	server.Callbacks
+	groups *NodeGroups
+
+	mu      sync.Mutex
+	streams map[int64]*identityStream
+}
+
+// identityStream is the certificate of a stream, and its node once a request carried one that passed the check
+type identityStream struct {
+	identities []string
+	node       *core.Node
+}
+
+// NewNodeIdentity checks the node ids and groups before passing the requests to next
+func NewNodeIdentity(next server.Callbacks, groups *NodeGroups) *NodeIdentity {
+	return &NodeIdentity{Callbacks: next, groups: groups, streams: map[int64]*identityStream{}}
+}
+
+func (n *NodeIdentity) OnStreamOpen(ctx context.Context, id int64, typeURL string) error {
+	n.open(ctx, id)
+	return n.Callbacks.OnStreamOpen(ctx, id, typeURL)
+}
+
+func (n *NodeIdentity) OnStreamClosed(id int64, node *core.Node) {
+	n.close(id)
+	n.Callbacks.OnStreamClosed(id, node)
+}
+
+func (n *NodeIdentity) OnDeltaStreamOpen(ctx context.Context, id int64, typeURL string) error {
+	n.open(ctx, id)
+	return n.Callbacks.OnDeltaStreamOpen(ctx, id, typeURL)
+}
+
+func (n *NodeIdentity) OnDeltaStreamClosed(id int64, node *core.Node) {
+	n.close(id)
+	n.Callbacks.OnDeltaStreamClosed(id, node)
+}
+
+func (n *NodeIdentity) OnStreamRequest(id int64, req *discovery.DiscoveryRequest) error {
+	if err := n.checkStream(id, req.GetNode()); err != nil {
+		return err
+	}
+	return n.Callbacks.OnStreamRequest(id, req)
+}
+
+// OnStreamDeltaRequest is called before the delta server fills in the node of the stream,
+// the requests after the first one carry no node and rely on the check of the first
+func (n *NodeIdentity) OnStreamDeltaRequest(id int64, req *discovery.DeltaDiscoveryRequest) error {
+	if err := n.checkStream(id, req.GetNode()); err != nil {
+		return err
+	}
+	return n.Callbacks.OnStreamDeltaRequest(id, req)
+}
+
+func (n *NodeIdentity) OnFetchRequest(ctx context.Context, req *discovery.DiscoveryRequest) error {
+	if req.GetNode() == nil {
+		return status.Error(codes.InvalidArgument, "the request carries no node")
+	}
+	if err := n.check(peerIdentities(ctx), req.GetNode()); err != nil {
+		return err
+	}
+	return n.Callbacks.OnFetchRequest(ctx, req)
+}
+
+func (n *NodeIdentity) open(ctx context.Context, id int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.streams[id] = &identityStream{identities: peerIdentities(ctx)}
+}
+
+func (n *NodeIdentity) close(id int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.streams, id)
+}
+
+// checkStream checks the node of a stream request, a request without node is only accepted
+// once an earlier request of the stream passed with one
+func (n *NodeIdentity) checkStream(id int64, node *core.Node) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	stream, ok := n.streams[id]
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown stream")
+	}
+	if node == nil {
+		if stream.node == nil {
+			return status.Error(codes.InvalidArgument, "the first request of the stream carries no node")
+		}
+		return nil
+	}
+	if err := n.check(stream.identities, node); err != nil {
+		return err
+	}
+	stream.node = node
+	return nil
+}
+
+// check refuses a node id or group the certificate was not issued for
+func (n *NodeIdentity) check(identities []string, node *core.Node) error {
+	if len(identities) == 0 {
+		return status.Errorf(codes.Unauthenticated, "node %q has no client certificate", node.GetId())
+	}
+	if !contains(identities, node.GetId()) {
+		l.Warnf("refused node %q, its certificate is issued for %v", node.GetId(), identities)
+		return status.Errorf(codes.PermissionDenied, "the client certificate is not issued for node %q", node.GetId())
+	}
+	if group := n.groups.label(node); group != "" && group != node.GetId() && !contains(identities, group) {
+		l.Warnf("refused node %q of group %q, its certificate is issued for %v", node.GetId(), group, identities)
+		return status.Errorf(codes.PermissionDenied, "the client certificate is not issued for group %q", group)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIdentities returns the common name and the dns names of the verified client certificate
+func peerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := info.State.VerifiedChains[0][0]
+	return append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+}
@@ -0,0 +1,179 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controlplane
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	extensionpb "github.com/envoyproxy/go-control-plane/envoy/service/extension/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA issues the certificates of the tls tests, like tools/xdsca does
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	ca := &testCA{t: t, dir: t.TempDir(), cert: cert, key: key}
+	ca.write("ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes <name>.pem and <name>-key.pem and returns the certificate
+func (ca *testCA) issue(name string, usage x509.ExtKeyUsage, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(ca.t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	require.NoError(ca.t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(ca.t, err)
+	ca.write(name+".pem", "CERTIFICATE", der)
+	ca.write(name+"-key.pem", "PRIVATE KEY", keyDER)
+	cert, err := tls.LoadX509KeyPair(ca.path(name+".pem"), ca.path(name+"-key.pem"))
+	require.NoError(ca.t, err)
+	return cert
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+func (ca *testCA) write(name, blockType string, der []byte) {
+	require.NoError(ca.t, os.WriteFile(ca.path(name), pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+func TestNodeIdentity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ca := newTestCA(t)
+	ca.issue("server", x509.ExtKeyUsageServerAuth, "xds.local")
+	pixiu1 := ca.issue("pixiu-1", x509.ExtKeyUsageClientAuth, "pixiu-1", "pixiu")
+	options := testOptions()
+	options.TLSCert, options.TLSKey, options.TLSClientCA = ca.path("server.pem"), ca.path("server-key.pem"), ca.path("ca.pem")
+	s := serve(t, New(options), Static(testConfig))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) extensionpb.ExtensionConfigDiscoveryServiceClient {
+		return s.dial(t, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "xds.local", Certificates: certs})))
+	}
+	fetchAs := func(c extensionpb.ExtensionConfigDiscoveryServiceClient, node *core.Node) error {
+		_, err := fetch(ctx, c, node)
+		return err
+	}
+	fetch := func(c extensionpb.ExtensionConfigDiscoveryServiceClient, node string) error {
+		return fetchAs(c, &core.Node{Id: node, Cluster: "pixiu"})
+	}
+
+	// the node the certificate is issued for gets its config
+	node1 := client(pixiu1)
+	require.Eventually(t, func() bool { return fetch(node1, "pixiu-1") == nil }, 5*time.Second, 10*time.Millisecond)
+
+	// another node id is refused, on fetch and on streams
+	assert.Equal(t, codes.PermissionDenied, status.Code(fetch(node1, "pixiu-2")))
+	stream, err := node1.StreamExtensionConfigs(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&discovery.DiscoveryRequest{Node: &core.Node{Id: "pixiu-2"}, TypeUrl: resource.ExtensionConfigType}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// so is a group the certificate is not issued for, and a node id standing in for a group
+	assert.Equal(t, codes.PermissionDenied, status.Code(fetchAs(node1, &core.Node{Id: "pixiu-1", Cluster: "payments"})))
+	assert.NoError(t, fetchAs(node1, &core.Node{Id: "pixiu-1"}))
+
+	// a request without node is refused, the delta server hands it over before it fills in the node
+	assert.Equal(t, codes.InvalidArgument, status.Code(fetchAs(node1, nil)))
+	delta, err := node1.DeltaExtensionConfigs(ctx)
+	require.NoError(t, err)
+	require.NoError(t, delta.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: resource.ExtensionConfigType}))
+	_, err = delta.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a client without certificate does not get past the handshake
+	assert.Error(t, fetch(client(), "pixiu-1"))
+}
+
+func TestLoadTLS(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("server", x509.ExtKeyUsageServerAuth, "xds.local")
+
+	config, err := LoadTLS(ca.path("server.pem"), ca.path("server-key.pem"), "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+
+	config, err = LoadTLS(ca.path("server.pem"), ca.path("server-key.pem"), ca.path("ca.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+
+	_, err = LoadTLS(ca.path("server.pem"), ca.path("server-key.pem"), ca.path("server-key.pem"))
+	assert.ErrorContains(t, err, "no certificate found")
+	_, err = LoadTLS(ca.path("missing.pem"), ca.path("server-key.pem"), "")
+	assert.Error(t, err)
+}
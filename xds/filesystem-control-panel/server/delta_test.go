@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	extensionpb "github.com/envoyproxy/go-control-plane/envoy/service/extension/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+// testServices is the number of clusters next to http_bin and orders in splitCDS
+const testServices = 50
+
+// splitCDS has http_bin, orders listening on ordersPort, and services clusters named service-<n>
+func splitCDS(services int, ordersPort int) string {
+	clusters := []string{
+		`{"name": "http_bin", "endpoints": [{"address": {"address": "127.0.0.1", "port": "8081"}}]}`,
+		fmt.Sprintf(`{"name": "orders", "endpoints": [{"address": {"address": "127.0.0.1", "port": "%d"}}]}`, ordersPort),
+	}
+	for i := 0; i < services; i++ {
+		clusters = append(clusters, fmt.Sprintf(`{"name": "service-%d", "endpoints": [
+  {"address": {"address": "10.0.0.%d", "port": "8080"}}, {"address": {"address": "10.0.1.%d", "port": "8080"}}
+]}`, i, i, i))
+	}
+	return fmt.Sprintf(`{"clusters": [%s]}`, strings.Join(clusters, ",\n"))
+}
+
+func TestDeltaSendsOnlyChangedResources(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p, stream := deltaClient(ctx, t, true)
+
+	initial, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Len(t, initial.Resources, testServices+3)
+
+	// one endpoint changes, only its cluster is sent
+	update := deltaUpdate(ctx, t, p, stream, initial, splitCDS(testServices, 8093))
+	require.Len(t, update.Resources, 1)
+	assert.Equal(t, controlplane.ClusterPrefix+"orders", update.Resources[0].Name)
+	assert.Empty(t, update.RemovedResources)
+
+	// the aggregate layout resends every cluster for the same change
+	aggregateP, aggregateStream := deltaClient(ctx, t, false)
+	aggregateInitial, err := aggregateStream.Recv()
+	require.NoError(t, err)
+	aggregate := deltaUpdate(ctx, t, aggregateP, aggregateStream, aggregateInitial, splitCDS(testServices, 8093))
+	require.Len(t, aggregate.Resources, 1)
+	assert.Equal(t, xds.ClusterType, aggregate.Resources[0].Name)
+	t.Logf("update of one endpoint: split %d bytes with %d bytes of resources, aggregate %d bytes with %d bytes of resources",
+		proto.Size(update), payload(update), proto.Size(aggregate), payload(aggregate))
+	assert.Less(t, payload(update)*5, payload(aggregate))
+	assert.Less(t, proto.Size(update), proto.Size(aggregate))
+
+	// a removed cluster is only named
+	removal := deltaUpdate(ctx, t, p, stream, update, splitCDS(testServices-1, 8093))
+	assert.Empty(t, removal.Resources)
+	assert.Equal(t, []string{fmt.Sprintf("%sservice-%d", controlplane.ClusterPrefix, testServices-1)}, removal.RemovedResources)
+}
+
+// deltaClient publishes splitCDS and subscribes to it with a delta stream
+func deltaClient(ctx context.Context, t *testing.T, split bool) (*publisher, extensionpb.ExtensionConfigDiscoveryService_DeltaExtensionConfigsClient) {
+	dir := t.TempDir()
+	groups := &controlplane.NodeGroups{}
+	snapshots := cache.NewSnapshotCache(false, groups, nil)
+	p := &publisher{dir: dir, nodeID: controlplane.DefaultGroup, snapshots: snapshots, version: 100, split: split}
+	writeConfig(t, dir, splitCDS(testServices, 8090), fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, p.publish(ctx))
+
+	stream := deltaStream(ctx, t, server.NewServer(ctx, snapshots, controlplane.NewTracker(groups)))
+	require.NoError(t, stream.Send(&discovery.DeltaDiscoveryRequest{
+		Node:    &core.Node{Id: "pixiu-1", Cluster: "pixiu"},
+		TypeUrl: resource.ExtensionConfigType,
+	}))
+	return p, stream
+}
+
+// deltaUpdate ACKs last, publishes cds and returns the response it causes
+func deltaUpdate(ctx context.Context, t *testing.T, p *publisher, stream extensionpb.ExtensionConfigDiscoveryService_DeltaExtensionConfigsClient,
+	last *discovery.DeltaDiscoveryResponse, cds string) *discovery.DeltaDiscoveryResponse {
+	require.NoError(t, stream.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: resource.ExtensionConfigType, ResponseNonce: last.Nonce}))
+	writeConfig(t, p.dir, cds, fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, p.publish(ctx))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	return resp
+}
+
+// payload is the size of the resources of resp, without the names and versions around them
+func payload(resp *discovery.DeltaDiscoveryResponse) int {
+	size := 0
+	for _, r := range resp.Resources {
+		size += proto.Size(r.Resource)
+	}
+	return size
+}
+
+// deltaStream serves srv over an in-memory connection and opens a delta extension config stream to it
+func deltaStream(ctx context.Context, t *testing.T, srv server.Server) extensionpb.ExtensionConfigDiscoveryService_DeltaExtensionConfigsClient {
+	stream, err := dial(ctx, t, srv).DeltaExtensionConfigs(ctx)
+	require.NoError(t, err)
+	return stream
+}
+
+// dial serves srv over an in-memory connection and returns an extension config client of it
+func dial(ctx context.Context, t *testing.T, srv server.Server) extensionpb.ExtensionConfigDiscoveryServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := controlplane.NewGRPCServer(nil)
+	controlplane.RegisterServer(grpcServer, srv)
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return extensionpb.NewExtensionConfigDiscoveryServiceClient(conn)
+}
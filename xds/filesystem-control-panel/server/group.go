@@ -0,0 +1,281 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+// ErrUnknownGroup is returned for a group the fleet does not serve
+var ErrUnknownGroup = errors.New("unknown group")
+
+// fleet publishes the fragments of dir to its root group, DefaultGroup unless the node id option names
+// another, and the fragments of each subdirectory of dir to the group named after it. A group is rejected on its own and keeps its last good snapshot.
+//
+// The watches of a node are kept under the group it had when it sent its request, so the nodes that
+// change group are told with a new version: the nodes of a removed group get the config of DefaultGroup
+// from it until their watches are gone, and DefaultGroup is published again for the nodes of a new
+// group. A delta watch is only answered when its resources change, so a delta stream of a new group
+// moves with the next change of DefaultGroup.
+type fleet struct {
+	dir       string
+	groups    *controlplane.NodeGroups
+	snapshots cache.SnapshotCache
+	options   fleetOptions
+
+	mu         sync.Mutex
+	publishers map[string]*publisher
+	// served are the groups set in groups, retired the removed ones that still have watches, with the
+	// version of DefaultGroup they serve
+	served  map[string]bool
+	retired map[string]string
+}
+
+// fleetOptions are the settings the publishers of the groups share
+type fleetOptions struct {
+	// root is the group the fragments of dir are published to, DefaultGroup when empty
+	root string
+	// registry has the endpoints of the clusters that name a service
+	registry *Registry
+	// split serves a resource per cluster and listener, see NewSplitSnapshot
+	split bool
+	// historySize revisions are retained per group, and persisted to historyDir/<group> when it is set
+	historySize int
+	historyDir  string
+}
+
+func newFleet(dir string, groups *controlplane.NodeGroups, snapshots cache.SnapshotCache, options fleetOptions) *fleet {
+	if options.root == "" {
+		options.root = controlplane.DefaultGroup
+	}
+	return &fleet{
+		dir:        dir,
+		groups:     groups,
+		snapshots:  snapshots,
+		options:    options,
+		publishers: map[string]*publisher{},
+		served:     map[string]bool{},
+		retired:    map[string]string{},
+	}
+}
+
+// reload publishes every group, the error lists the groups that were rejected
+func (f *fleet) reload(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	dirs := map[string]string{f.options.root: f.dir}
+	var rejected []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if e.Name() == f.options.root {
+			rejected = append(rejected, fmt.Sprintf("group %s: the directory name is reserved, put its fragments in %s", f.options.root, f.dir))
+			continue
+		}
+		dirs[e.Name()] = filepath.Join(f.dir, e.Name())
+	}
+
+	var removed []string
+	for group := range f.publishers {
+		if _, ok := dirs[group]; !ok {
+			delete(f.publishers, group)
+			removed = append(removed, group)
+			l.Debugf("group %s removed, its nodes get the %s group", group, controlplane.DefaultGroup)
+		}
+	}
+
+	var served []string
+	for group, dir := range dirs {
+		p, ok := f.publishers[group]
+		if !ok {
+			var err error
+			if p, err = f.newPublisher(group, dir); err != nil {
+				rejected = append(rejected, fmt.Sprintf("group %s: %v", group, err))
+				continue
+			}
+			f.publishers[group] = p
+		}
+		if err := p.publish(ctx); err != nil {
+			rejected = append(rejected, fmt.Sprintf("group %s, still serving version %s: %v", group, p.current(), err))
+		} else {
+			l.Debugf("serving group %s version %s", group, p.current())
+		}
+		if group != controlplane.DefaultGroup && p.current() != "none" {
+			served = append(served, group)
+		}
+	}
+	// the snapshots are set before the nodes are moved to their group
+	f.groups.Set(served...)
+	rejected = append(rejected, f.regroup(ctx, served, removed)...)
+
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return fmt.Errorf("rejected config:\n%s", strings.Join(rejected, "\n"))
+	}
+	return nil
+}
+
+// regroup tells the nodes that changed group with a new version, so their next request is for the
+// snapshot of their new group, and clears the removed groups once their watches are gone
+func (f *fleet) regroup(ctx context.Context, served, removed []string) []string {
+	var rejected, joined []string
+	now := make(map[string]bool, len(served))
+	for _, group := range served {
+		now[group] = true
+		delete(f.retired, group)
+		if !f.served[group] {
+			joined = append(joined, group)
+		}
+	}
+	for _, group := range removed {
+		if f.served[group] {
+			f.retired[group] = ""
+		}
+	}
+	f.served = now
+
+	def := f.publishers[controlplane.DefaultGroup]
+	if def != nil && len(joined) > 0 && f.watched(controlplane.DefaultGroup) {
+		sort.Strings(joined)
+		if err := def.republish(ctx, "nodes moved to "+strings.Join(joined, ", ")); err != nil {
+			rejected = append(rejected, fmt.Sprintf("group %s: %v", controlplane.DefaultGroup, err))
+		}
+	}
+
+	cds, lds := &pixiupb.PixiuExtensionClusters{}, &pixiupb.PixiuExtensionListeners{}
+	from := "none"
+	if def != nil {
+		from = def.current()
+		if c, ls := def.config(); c != nil {
+			cds, lds = c, ls
+		}
+	}
+	for group, serving := range f.retired {
+		if !f.watched(group) {
+			delete(f.retired, group)
+			f.snapshots.ClearSnapshot(group)
+			continue
+		}
+		if serving == from {
+			continue
+		}
+		build := controlplane.NewSnapshot
+		if f.options.split {
+			build = controlplane.NewSplitSnapshot
+		}
+		// the version is none of the group nor of DefaultGroup, the next request of a node is answered
+		// with the snapshot of DefaultGroup, even once it has a new version
+		snap, err := build(from+"+"+group, cds, lds)
+		if err == nil {
+			err = f.snapshots.SetSnapshot(ctx, group, snap)
+		}
+		if err != nil {
+			rejected = append(rejected, fmt.Sprintf("removed group %s: %v", group, err))
+			continue
+		}
+		f.retired[group] = from
+		l.Debugf("removed group %s serves version %s of the %s group to its watches", group, from, controlplane.DefaultGroup)
+	}
+	return rejected
+}
+
+// watched tells whether nodes wait for a new version of the snapshot of group
+func (f *fleet) watched(group string) bool {
+	for _, key := range f.snapshots.GetStatusKeys() {
+		if key == group {
+			info := f.snapshots.GetStatusInfo(group)
+			return info != nil && info.GetNumWatches()+info.GetNumDeltaWatches() > 0
+		}
+	}
+	return false
+}
+
+func (f *fleet) newPublisher(group, dir string) (*publisher, error) {
+	historyDir := ""
+	if f.options.historyDir != "" {
+		historyDir = filepath.Join(f.options.historyDir, group)
+	}
+	history, err := LoadHistory(f.options.historySize, historyDir)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	// the versions go on where the persisted history stops, so nodes never see a version twice
+	version := 100
+	if latest := history.Latest(); latest > version {
+		version = latest
+	}
+	return &publisher{
+		dir:       dir,
+		nodeID:    group,
+		snapshots: f.snapshots,
+		version:   version,
+		split:     f.options.split,
+		registry:  f.options.registry,
+		history:   history,
+	}, nil
+}
+
+// root returns the group the fragments of dir are published to
+func (f *fleet) root() string {
+	return f.options.root
+}
+
+// publisher returns the publisher of group
+func (f *fleet) publisher(group string) (*publisher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.publishers[group]
+	if !ok {
+		return nil, fmt.Errorf("group %s: %w", group, ErrUnknownGroup)
+	}
+	return p, nil
+}
+
+// current returns the version served to each group
+func (f *fleet) current() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := make(map[string]string, len(f.publishers))
+	for group, p := range f.publishers {
+		versions[group] = p.current()
+	}
+	return versions
+}
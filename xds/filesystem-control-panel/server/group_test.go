@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	extensionpb "github.com/envoyproxy/go-control-plane/envoy/service/extension/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+func TestFleet(t *testing.T) {
+	dir := t.TempDir()
+	canary := filepath.Join(dir, "canary")
+	require.NoError(t, os.Mkdir(canary, 0o755))
+	writeConfig(t, dir, testCDS, fmt.Sprintf(testLDS, "http_bin"))
+	writeConfig(t, canary, testCDS, fmt.Sprintf(testLDS, "http_bin"))
+
+	ctx := context.Background()
+	groups := &controlplane.NodeGroups{}
+	snapshots := cache.NewSnapshotCache(false, groups, nil)
+	f := newFleet(dir, groups, snapshots, fleetOptions{historySize: 1})
+	canaryNode := &core.Node{Id: "pixiu-1", Cluster: "canary"}
+
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, map[string]string{controlplane.DefaultGroup: "101", "canary": "101"}, f.current())
+	assert.Equal(t, "canary", groups.ID(canaryNode))
+
+	// nothing changed, nothing is pushed
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, map[string]string{controlplane.DefaultGroup: "101", "canary": "101"}, f.current())
+
+	// a broken canary keeps its last snapshot and does not hold back the other groups
+	writeConfig(t, canary, testCDS, fmt.Sprintf(testLDS, "missing"))
+	writeConfig(t, dir, `{"clusters": [{"name": "http_bin", "endpoints": [{"address": {"address": "127.0.0.2", "port": "8081"}}]}]}`, fmt.Sprintf(testLDS, "http_bin"))
+	err := f.reload(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "group canary, still serving version 101")
+	assert.Equal(t, map[string]string{controlplane.DefaultGroup: "102", "canary": "101"}, f.current())
+	assert.Equal(t, "canary", groups.ID(canaryNode))
+
+	// without its directory the canary group gets the default snapshot again
+	require.NoError(t, os.RemoveAll(canary))
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, map[string]string{controlplane.DefaultGroup: "102"}, f.current())
+	assert.Equal(t, controlplane.DefaultGroup, groups.ID(canaryNode))
+	_, err = snapshots.GetSnapshot("canary")
+	assert.Error(t, err)
+}
+
+func TestFleetRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, testCDS, fmt.Sprintf(testLDS, "http_bin"))
+
+	groups := &controlplane.NodeGroups{}
+	snapshots := cache.NewSnapshotCache(false, groups, nil)
+	f := newFleet(dir, groups, snapshots, fleetOptions{root: "pixiu", historySize: 1})
+
+	// the fragments of dir are only served to the nodes of the root group
+	require.NoError(t, f.reload(context.Background()))
+	assert.Equal(t, map[string]string{"pixiu": "101"}, f.current())
+	assert.Equal(t, "pixiu", groups.ID(&core.Node{Id: "pixiu-1", Cluster: "pixiu"}))
+	assert.Equal(t, controlplane.DefaultGroup, groups.ID(&core.Node{Id: "pixiu-2", Cluster: "other"}))
+	_, err := snapshots.GetSnapshot(controlplane.DefaultGroup)
+	assert.Error(t, err)
+}
+
+func TestFleetMovesConnectedNodes(t *testing.T) {
+	dir := t.TempDir()
+	canary := filepath.Join(dir, "canary")
+	require.NoError(t, os.Mkdir(canary, 0o755))
+	writeConfig(t, dir, endpointCDS("127.0.0.1"), fmt.Sprintf(testLDS, "http_bin"))
+	writeConfig(t, canary, endpointCDS("127.0.0.2"), fmt.Sprintf(testLDS, "http_bin"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	groups := &controlplane.NodeGroups{}
+	snapshots := cache.NewSnapshotCache(false, groups, nil)
+	f := newFleet(dir, groups, snapshots, fleetOptions{historySize: 1})
+	require.NoError(t, f.reload(ctx))
+
+	stream, err := dial(ctx, t, server.NewServer(ctx, snapshots, controlplane.NewTracker(groups))).StreamExtensionConfigs(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&discovery.DiscoveryRequest{
+		Node:    &core.Node{Id: "pixiu-1", Cluster: "canary"},
+		TypeUrl: resource.ExtensionConfigType,
+	}))
+	resp := ackNext(t, stream)
+	assert.Equal(t, "127.0.0.2", endpointAddress(t, resp))
+	require.Eventually(t, func() bool { return f.watched("canary") }, 5*time.Second, 10*time.Millisecond)
+
+	// the watch of the removed canary group gets the default config, the snapshot stays until it is gone
+	require.NoError(t, os.RemoveAll(canary))
+	require.NoError(t, f.reload(ctx))
+	resp = ackNext(t, stream)
+	assert.Equal(t, "101+canary", resp.VersionInfo)
+	assert.Equal(t, "127.0.0.1", endpointAddress(t, resp))
+	resp = ackNext(t, stream)
+	assert.Equal(t, "101", resp.VersionInfo, "the default group answers the version of the canary group")
+	require.Eventually(t, func() bool { return f.watched(controlplane.DefaultGroup) }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, f.reload(ctx))
+	_, err = snapshots.GetSnapshot("canary")
+	assert.Error(t, err, "the canary group has no watch left")
+
+	// a new canary group is told to the node by a new default version
+	require.NoError(t, os.Mkdir(canary, 0o755))
+	writeConfig(t, canary, endpointCDS("127.0.0.3"), fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, "102", f.current()[controlplane.DefaultGroup])
+	resp = ackNext(t, stream)
+	assert.Equal(t, "102", resp.VersionInfo)
+	resp = ackNext(t, stream)
+	assert.Equal(t, "127.0.0.3", endpointAddress(t, resp))
+}
+
+// endpointCDS has the http_bin cluster with an endpoint at address
+func endpointCDS(address string) string {
+	return fmt.Sprintf(`{"clusters": [{"name": "http_bin", "endpoints": [{"address": {"address": "%s", "port": "8081"}}]}]}`, address)
+}
+
+// ackNext receives the next response of stream and ACKs it
+func ackNext(t *testing.T, stream extensionpb.ExtensionConfigDiscoveryService_StreamExtensionConfigsClient) *discovery.DiscoveryResponse {
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&discovery.DiscoveryRequest{
+		TypeUrl:       resource.ExtensionConfigType,
+		VersionInfo:   resp.VersionInfo,
+		ResponseNonce: resp.Nonce,
+	}))
+	return resp
+}
+
+// endpointAddress is the address of the first endpoint of the clusters of resp
+func endpointAddress(t *testing.T, resp *discovery.DiscoveryResponse) string {
+	for _, r := range resp.Resources {
+		config := &core.TypedExtensionConfig{}
+		require.NoError(t, r.UnmarshalTo(config))
+		if config.Name != xds.ClusterType {
+			continue
+		}
+		clusters := &pixiupb.PixiuExtensionClusters{}
+		require.NoError(t, config.TypedConfig.UnmarshalTo(clusters))
+		return clusters.Clusters[0].Endpoints[0].Address.Address
+	}
+	return ""
+}
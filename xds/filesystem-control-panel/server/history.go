@@ -0,0 +1,375 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnknownRevision is returned for a version the history does not retain
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// Revision is a config a group was served
+type Revision struct {
+	Version string    `json:"version"`
+	Hash    string    `json:"hash"`
+	Time    time.Time `json:"time"`
+	// Note tells where the config came from, the files or a rollback
+	Note string `json:"note"`
+
+	clusters  *pixiupb.PixiuExtensionClusters
+	listeners *pixiupb.PixiuExtensionListeners
+}
+
+// History retains the last revisions of a group, and writes them to dir when it is set
+type History struct {
+	size int
+	dir  string
+
+	mu        sync.Mutex
+	revisions []*Revision
+}
+
+// LoadHistory returns the history of the revisions persisted in dir, an empty one without dir.
+// The size is at least 1, the served revision is always retained.
+func LoadHistory(size int, dir string) (*History, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("history size must be at least 1, got %d", size)
+	}
+	h := &History{size: size, dir: dir}
+	if dir == "" {
+		return h, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		rev, err := unmarshalRevision(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(dir, e.Name()), err)
+		}
+		h.revisions = append(h.revisions, rev)
+	}
+	sort.Slice(h.revisions, func(i, j int) bool {
+		return versionNumber(h.revisions[i].Version) < versionNumber(h.revisions[j].Version)
+	})
+	return h, h.prune()
+}
+
+// Add records a revision, the oldest ones beyond the size of the history are dropped.
+// A nil history records nothing.
+func (h *History) Add(rev *Revision) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.revisions = append(h.revisions, rev)
+	if h.dir != "" {
+		content, err := rev.MarshalConfig()
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(h.dir, rev.Version+".json"), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return h.prune()
+}
+
+// Get returns the revision of version
+func (h *History) Get(version string) (*Revision, error) {
+	if h == nil {
+		return nil, fmt.Errorf("version %s: %w", version, ErrUnknownRevision)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, rev := range h.revisions {
+		if rev.Version == version {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("version %s: %w", version, ErrUnknownRevision)
+}
+
+// List returns the retained revisions, the newest first
+func (h *History) List() []Revision {
+	if h == nil {
+		return []Revision{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]Revision, 0, len(h.revisions))
+	for i := len(h.revisions) - 1; i >= 0; i-- {
+		list = append(list, *h.revisions[i])
+	}
+	return list
+}
+
+// Latest returns the version of the newest revision, 0 for an empty history
+func (h *History) Latest() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.revisions) == 0 {
+		return 0
+	}
+	return versionNumber(h.revisions[len(h.revisions)-1].Version)
+}
+
+func (h *History) prune() error {
+	for len(h.revisions) > h.size {
+		if h.dir != "" {
+			err := os.Remove(filepath.Join(h.dir, h.revisions[0].Version+".json"))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		h.revisions = h.revisions[1:]
+	}
+	return nil
+}
+
+func newRevision(version int, cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners, note string) *Revision {
+	return &Revision{
+		Version:   strconv.Itoa(version),
+		Hash:      configHash(cds, lds),
+		Time:      time.Now().UTC(),
+		Note:      note,
+		clusters:  cds,
+		listeners: lds,
+	}
+}
+
+// configHash is the sha256 of the deterministic encoding of the config
+func configHash(cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners) string {
+	hash := sha256.New()
+	for _, m := range []proto.Message{cds, lds} {
+		b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		hash.Write(b)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func versionNumber(version string) int {
+	n, _ := strconv.Atoi(version)
+	return n
+}
+
+// revisionJSON is a revision with its config, the format of the api and of the persisted files
+type revisionJSON struct {
+	Revision
+	Clusters  json.RawMessage `json:"clusters"`
+	Listeners json.RawMessage `json:"listeners"`
+}
+
+// MarshalConfig returns the revision with its clusters and listeners as json
+func (r *Revision) MarshalConfig() ([]byte, error) {
+	clusters, err := protojson.Marshal(r.clusters)
+	if err != nil {
+		return nil, err
+	}
+	listeners, err := protojson.Marshal(r.listeners)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(revisionJSON{Revision: *r, Clusters: clusters, Listeners: listeners}, "", "  ")
+}
+
+func unmarshalRevision(content []byte) (*Revision, error) {
+	var r revisionJSON
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, err
+	}
+	rev := r.Revision
+	rev.clusters, rev.listeners = &pixiupb.PixiuExtensionClusters{}, &pixiupb.PixiuExtensionListeners{}
+	if err := protojson.Unmarshal(r.Clusters, rev.clusters); err != nil {
+		return nil, err
+	}
+	if err := protojson.Unmarshal(r.Listeners, rev.listeners); err != nil {
+		return nil, err
+	}
+	if rev.Version == "" || configHash(rev.clusters, rev.listeners) != rev.Hash {
+		return nil, errors.New("the revision is incomplete or does not match its hash")
+	}
+	return &rev, nil
+}
+
+// Diff is what changed between two revisions
+type Diff struct {
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Clusters  ResourceDiff `json:"clusters"`
+	Listeners ResourceDiff `json:"listeners"`
+}
+
+// ResourceDiff names the resources that were added, removed and changed
+type ResourceDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// DiffRevisions compares the clusters and listeners of from and to by name
+func DiffRevisions(from, to *Revision) Diff {
+	clusters := func(rev *Revision) map[string]proto.Message {
+		m := map[string]proto.Message{}
+		for _, c := range rev.clusters.GetClusters() {
+			m[c.GetName()] = c
+		}
+		return m
+	}
+	listeners := func(rev *Revision) map[string]proto.Message {
+		m := map[string]proto.Message{}
+		for _, l := range rev.listeners.GetListeners() {
+			m[l.GetName()] = l
+		}
+		return m
+	}
+	return Diff{
+		From:      from.Version,
+		To:        to.Version,
+		Clusters:  diffResources(clusters(from), clusters(to)),
+		Listeners: diffResources(listeners(from), listeners(to)),
+	}
+}
+
+func diffResources(from, to map[string]proto.Message) ResourceDiff {
+	d := ResourceDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for name, m := range to {
+		old, ok := from[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case !proto.Equal(old, m):
+			d.Changed = append(d.Changed, name)
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// HistoryAPI serves the history of the groups of a fleet and rolls them back:
+//
+//	GET  /history?group=                      the retained revisions, the newest first
+//	GET  /history/{version}?group=            a revision with its clusters and listeners
+//	GET  /history/diff?group=&from=&to=       what changed, to is the current version when left out
+//	POST /history/{version}/rollback?group=   serve the config of version again, as a new version
+type HistoryAPI struct {
+	fleet *fleet
+}
+
+func (a *HistoryAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = a.fleet.root()
+	}
+	p, err := a.fleet.publisher(group)
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/history"), "/"), "/")
+
+	switch {
+	case path[0] == "" && r.Method == http.MethodGet:
+		writeJSON(w, map[string]interface{}{"group": group, "current": p.current(), "revisions": p.history.List()})
+	case len(path) == 1 && path[0] == "diff" && r.Method == http.MethodGet:
+		to := r.URL.Query().Get("to")
+		if to == "" {
+			to = p.current()
+		}
+		from, err := p.history.Get(r.URL.Query().Get("from"))
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		toRev, err := p.history.Get(to)
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		writeJSON(w, DiffRevisions(from, toRev))
+	case len(path) == 1 && r.Method == http.MethodGet:
+		rev, err := p.history.Get(path[0])
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		content, err := rev.MarshalConfig()
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(content)
+	case len(path) == 2 && path[1] == "rollback" && r.Method == http.MethodPost:
+		rev, err := p.rollback(r.Context(), path[0])
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		l.Infof("group %s rolled back to version %s as version %s", group, path[0], rev.Version)
+		writeJSON(w, rev)
+	default:
+		http.Error(w, fmt.Sprintf("%s %s is not part of the history api", r.Method, r.URL.Path), http.StatusNotFound)
+	}
+}
+
+func historyError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrUnknownRevision) || errors.Is(err, ErrUnknownGroup) {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
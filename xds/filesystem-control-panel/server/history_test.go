@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+const testMovedCDS = `{"clusters": [{"name": "http_bin", "endpoints": [{"address": {"address": "127.0.0.2", "port": "8081"}}]}]}`
+
+type testHistory struct {
+	Current   string
+	Revisions []Revision
+}
+
+func historyCall(t *testing.T, srv *httptest.Server, method, path string, v interface{}) int {
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if resp.StatusCode == http.StatusOK && v != nil {
+		require.NoError(t, json.Unmarshal(body, v), string(body))
+	}
+	return resp.StatusCode
+}
+
+func TestHistoryRollback(t *testing.T) {
+	dir, historyDir := t.TempDir(), t.TempDir()
+	ctx := context.Background()
+	newTestFleet := func() *fleet {
+		groups := &controlplane.NodeGroups{}
+		return newFleet(dir, groups, cache.NewSnapshotCache(false, groups, nil), fleetOptions{historySize: 3, historyDir: historyDir})
+	}
+	f := newTestFleet()
+	srv := httptest.NewServer(&HistoryAPI{fleet: f})
+	defer srv.Close()
+
+	writeConfig(t, dir, testCDS, fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, f.reload(ctx))
+	writeConfig(t, dir, testMovedCDS, fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, f.reload(ctx))
+
+	var diff Diff
+	require.Equal(t, http.StatusOK, historyCall(t, srv, http.MethodGet, "/history/diff?from=101", &diff))
+	assert.Equal(t, Diff{
+		From:      "101",
+		To:        "102",
+		Clusters:  ResourceDiff{Added: []string{}, Removed: []string{}, Changed: []string{"http_bin"}},
+		Listeners: ResourceDiff{Added: []string{}, Removed: []string{}, Changed: []string{}},
+	}, diff)
+
+	// the rollback serves the config of 101 as 103, and sticks until the files change
+	var rev Revision
+	require.Equal(t, http.StatusOK, historyCall(t, srv, http.MethodPost, "/history/101/rollback", &rev))
+	assert.Equal(t, "103", rev.Version)
+	assert.Equal(t, "rollback to 101", rev.Note)
+	p, err := f.publisher(controlplane.DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", p.clusters.Clusters[0].Endpoints[0].Address.Address)
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, "103", p.current())
+
+	var history testHistory
+	require.Equal(t, http.StatusOK, historyCall(t, srv, http.MethodGet, "/history", &history))
+	assert.Equal(t, "103", history.Current)
+	require.Len(t, history.Revisions, 3)
+	assert.Equal(t, history.Revisions[2].Hash, history.Revisions[0].Hash)
+	assert.NotEqual(t, history.Revisions[1].Hash, history.Revisions[0].Hash)
+
+	writeConfig(t, dir, testMovedCDS, `{"listeners": []}`)
+	require.NoError(t, f.reload(ctx))
+	assert.Equal(t, "104", p.current())
+
+	// only the last 3 revisions are retained
+	assert.Equal(t, http.StatusNotFound, historyCall(t, srv, http.MethodGet, "/history/101", nil))
+	assert.Equal(t, http.StatusNotFound, historyCall(t, srv, http.MethodPost, "/history/101/rollback", nil))
+	assert.Equal(t, http.StatusNotFound, historyCall(t, srv, http.MethodGet, "/history?group=canary", nil))
+	files, err := filepath.Glob(filepath.Join(historyDir, controlplane.DefaultGroup, "*.json"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// a restarted control plane reads the history back and goes on with the versions
+	restarted := newTestFleet()
+	require.NoError(t, restarted.reload(ctx))
+	assert.Equal(t, map[string]string{controlplane.DefaultGroup: "105"}, restarted.current())
+	p, err = restarted.publisher(controlplane.DefaultGroup)
+	require.NoError(t, err)
+	_, err = p.rollback(ctx, "103")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", p.clusters.Clusters[0].Endpoints[0].Address.Address)
+	assert.Len(t, p.listeners.Listeners, 1)
+}
+
+func TestHistorySize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		_, err := LoadHistory(size, "")
+		assert.Error(t, err, "size %d", size)
+	}
+
+	defer func(size int) { historySize = size }(historySize)
+	historySize = -1
+	assert.Error(t, checkFlags())
+	historySize = 1
+	assert.NoError(t, checkFlags())
+
+	h, err := LoadHistory(1, "")
+	require.NoError(t, err)
+	cds, lds, err := loadResources("../json")
+	require.NoError(t, err)
+	require.NoError(t, h.Add(newRevision(101, cds, lds, "files")))
+	require.NoError(t, h.Add(newRevision(102, cds, lds, "files")))
+	assert.Equal(t, 102, h.Latest())
+	assert.Len(t, h.List(), 1)
+}
+
+func TestLoadHistoryRejectsTamperedRevision(t *testing.T) {
+	dir := t.TempDir()
+	h, err := LoadHistory(2, dir)
+	require.NoError(t, err)
+	cds, lds, err := loadResources("../json")
+	require.NoError(t, err)
+	require.NoError(t, h.Add(newRevision(101, cds, lds, "files")))
+
+	_, err = LoadHistory(2, dir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, "101.json"))
+	require.NoError(t, err)
+	var rev map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &rev))
+	rev["clusters"] = map[string]interface{}{}
+	content, err = json.Marshal(rev)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "101.json"), content, 0o644))
+	_, err = LoadHistory(2, dir)
+	assert.ErrorContains(t, err, "does not match its hash")
+}
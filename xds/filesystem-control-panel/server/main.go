@@ -20,149 +20,97 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 import (
-	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
-	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
-
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
-
-	"github.com/fsnotify/fsnotify"
-
-	"google.golang.org/protobuf/encoding/protojson"
-
-	"google.golang.org/protobuf/types/known/anypb"
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
 )
 
 var (
-	l       Logger
-	port    = uint(18000)
-	nodeID  = "test-id"
-	version = 100
+	l         = controlplane.Logger{Debug: true}
+	options   = controlplane.DefaultOptions()
+	configDir = "../config"
+	debounce  = 200 * time.Millisecond
+	split     bool
+
+	registryFile string
+	registryTTL  = 15 * time.Second
+
+	historySize = 10
+	historyDir  string
 )
 
 func init() {
-	l = Logger{}
-	l.Debug = true
+	options.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&configDir, "config", configDir, "directory of the json and yaml fragments of the config, each subdirectory configures the node group of its name")
+	flag.BoolVar(&split, "split", split, "serve a resource per cluster and listener to delta xds clients, instead of the two resources pixiu subscribes to")
+	flag.DurationVar(&debounce, "debounce", debounce, "how long the files must stay unchanged before they are loaded")
+	flag.StringVar(&registryFile, "registry", registryFile, "yaml file of persistent registry instances, the registry starts empty without it")
+	flag.DurationVar(&registryTTL, "registry-ttl", registryTTL, "how long an ephemeral registry instance stays healthy without heartbeat")
+	flag.IntVar(&historySize, "history", historySize, "how many served configs each group retains for rollbacks")
+	flag.StringVar(&historyDir, "history-dir", historyDir, "directory the retained configs are written to and read back from at start, memory only without it")
 }
 
 func main() {
 	flag.Parse()
+	if err := checkFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create a snaphost
-	snaphost := cache.NewSnapshotCache(false, cache.IDHash{}, l)
-
-	go func() {
-		// Create the config that we'll serve to Envoy
-		config := GenerateSnapshotPixiuFromFile()
-
-		if err := config.Consistent(); err != nil {
-			l.Errorf("config inconsistency: %+v\n%+v", config, err)
-			os.Exit(1)
+	registry := NewRegistry(registryTTL)
+	if registryFile != "" {
+		if err := registry.LoadFile(registryFile); err != nil {
+			log.Fatal(err)
 		}
-		l.Debugf("will serve config %+v", config)
-
-		// Add the config to the snaphost
-		if err := snaphost.SetSnapshot(context.Background(), nodeID, config); err != nil {
-			l.Errorf("config error %q for %+v", err, config)
-			os.Exit(1)
+	}
+	cp := controlplane.New(options)
+	f := newFleet(configDir, cp.Groups, cp.Snapshots, fleetOptions{
+		root:        options.NodeID,
+		registry:    registry,
+		split:       split,
+		historySize: historySize,
+		historyDir:  historyDir,
+	})
+	cp.Admin.Handle("/nacos/", NewNacosAPI(registry))
+	cp.Admin.Handle("/history", &HistoryAPI{fleet: f})
+	cp.Admin.Handle("/history/", &HistoryAPI{fleet: f})
+
+	reload := func() {
+		if err := f.reload(ctx); err != nil {
+			l.Errorf("%v", err)
 		}
-
-		go func() {
-			watcher, err := fsnotify.NewWatcher()
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer watcher.Close()
-			done := make(chan bool)
-			go func() {
-				for {
-					select {
-					case event, ok := <-watcher.Events:
-						if !ok {
-							return
-						}
-						if event.Op == fsnotify.Write {
-							log.Println("event:", event)
-							log.Println("modified file:", event.Name)
-							conf := GenerateSnapshotPixiuFromFile()
-							// Add the config to the snaphost
-							if err := snaphost.SetSnapshot(context.Background(), nodeID, conf); err != nil {
-								l.Errorf("config error %q for %+v", err, config)
-								os.Exit(1)
-							}
-						}
-					case err, ok := <-watcher.Errors:
-						if !ok {
-							return
-						}
-						log.Println("error:", err)
-					}
-				}
-			}()
-
-			err = watcher.Add("../pixiu")
-			if err != nil {
-				log.Fatal(err)
-			}
-			<-done
-		}()
-	}()
-
-	// Run the xDS server
-	ctx := context.Background()
-	cb := &test.Callbacks{Debug: l.Debug}
-	srv := server.NewServer(ctx, snaphost, cb)
-	RunServer(ctx, srv, port)
-}
-
-func GenerateSnapshotPixiuFromFile() *cache.Snapshot {
-	cdsStr, err := os.ReadFile("../pixiu/cds.json")
-	if err != nil {
-		l.Errorf("%s", err)
 	}
-	cds := &pixiupb.PixiuExtensionClusters{}
-	err = protojson.Unmarshal(cdsStr, cds)
-	if err != nil {
-		l.Errorf("%s", err)
+	// Registry churn is debounced like file events, so a restarting service is pushed once
+	registry.OnChange = newDebouncer(debounce, reload).trigger
+
+	// The files are the source of the snapshots, a broken group waits for the next edit
+	files := controlplane.SourceFunc(func(ctx context.Context, _ *controlplane.ControlPlane) error {
+		go registry.Run(ctx.Done())
+		reload()
+		return watch(ctx, configDir, debounce, reload)
+	})
+	if err := cp.Run(ctx, files); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	ldsStr, err := os.ReadFile("../pixiu/lds.json")
-	if err != nil {
-		l.Errorf("%s", err)
+// checkFlags rejects the values the registry and the history can not work with
+func checkFlags() error {
+	if registryTTL <= 0 {
+		return fmt.Errorf("-registry-ttl must be positive, got %s", registryTTL)
 	}
-
-	lds := &pixiupb.PixiuExtensionListeners{}
-	err = protojson.Unmarshal(ldsStr, lds)
-	if err != nil {
-		l.Errorf("%s", err)
+	if historySize < 1 {
+		return fmt.Errorf("-history must be at least 1, the served config is always retained, got %d", historySize)
 	}
-
-	version++
-	ldsResource, _ := anypb.New(lds)
-	cdsResource, _ := anypb.New(cds)
-	snap, _ := cache.NewSnapshot(strconv.Itoa(version),
-		map[resource.Type][]types.Resource{
-			resource.ExtensionConfigType: {
-				&core.TypedExtensionConfig{
-					Name:        xds.ClusterType,
-					TypedConfig: cdsResource,
-				},
-				&core.TypedExtensionConfig{
-					Name:        xds.ListenerType,
-					TypedConfig: ldsResource,
-				},
-			},
-		},
-	)
-	return snap
+	return nil
 }
@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// nacos naming answers a heartbeat of an instance it does not know with this code, the client registers it again
+const (
+	nacosOK              = 10200
+	nacosUnknownInstance = 20404
+)
+
+// NacosAPI serves the registry with the instance endpoints of the nacos naming open api v1,
+// so nacos clients and plain curl can register services:
+//
+//	POST   /nacos/v1/ns/instance        serviceName, ip, port, weight, ephemeral, metadata
+//	DELETE /nacos/v1/ns/instance        serviceName, ip, port
+//	PUT    /nacos/v1/ns/instance/beat   serviceName, ip, port
+//	GET    /nacos/v1/ns/instance/list   serviceName
+//	GET    /nacos/v1/ns/service/list
+type NacosAPI struct {
+	registry *Registry
+}
+
+// NewNacosAPI returns the nacos naming api of registry
+func NewNacosAPI(registry *Registry) *NacosAPI {
+	return &NacosAPI{registry: registry}
+}
+
+func (a *NacosAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/nacos/v1/ns/instance" && r.Method == http.MethodPost:
+		a.register(w, r)
+	case r.URL.Path == "/nacos/v1/ns/instance" && r.Method == http.MethodDelete:
+		service, ip, port, err := instanceParams(r)
+		if err == nil {
+			err = a.registry.Deregister(service, ip, port)
+		}
+		nacosReply(w, err)
+	case r.URL.Path == "/nacos/v1/ns/instance/beat" && r.Method == http.MethodPut:
+		a.beat(w, r)
+	case r.URL.Path == "/nacos/v1/ns/instance/list" && r.Method == http.MethodGet:
+		service := serviceName(r)
+		if service == "" {
+			nacosReply(w, errors.New("serviceName is missing"))
+			return
+		}
+		writeJSON(w, map[string]interface{}{"name": service, "hosts": a.registry.Instances(service)})
+	case r.URL.Path == "/nacos/v1/ns/service/list" && r.Method == http.MethodGet:
+		services := a.registry.Services()
+		writeJSON(w, map[string]interface{}{"count": len(services), "doms": services})
+	default:
+		http.Error(w, fmt.Sprintf("%s %s is not part of the nacos naming api", r.Method, r.URL.Path), http.StatusNotFound)
+	}
+}
+
+func (a *NacosAPI) register(w http.ResponseWriter, r *http.Request) {
+	service, ip, port, err := instanceParams(r)
+	if err != nil {
+		nacosReply(w, err)
+		return
+	}
+	inst := Instance{Service: service, IP: ip, Port: port, Weight: 1, Ephemeral: true}
+	if weight := r.FormValue("weight"); weight != "" {
+		if inst.Weight, err = strconv.ParseFloat(weight, 64); err != nil {
+			nacosReply(w, fmt.Errorf("invalid weight %q", weight))
+			return
+		}
+	}
+	if ephemeral := r.FormValue("ephemeral"); ephemeral != "" {
+		if inst.Ephemeral, err = strconv.ParseBool(ephemeral); err != nil {
+			nacosReply(w, fmt.Errorf("invalid ephemeral %q", ephemeral))
+			return
+		}
+	}
+	if metadata := r.FormValue("metadata"); metadata != "" {
+		if err = json.Unmarshal([]byte(metadata), &inst.Metadata); err != nil {
+			nacosReply(w, fmt.Errorf("invalid metadata: %v", err))
+			return
+		}
+	}
+	nacosReply(w, a.registry.Register(inst))
+}
+
+func (a *NacosAPI) beat(w http.ResponseWriter, r *http.Request) {
+	service, ip, port, err := instanceParams(r)
+	if err != nil {
+		nacosReply(w, err)
+		return
+	}
+	code := nacosOK
+	if err = a.registry.Beat(service, ip, port); errors.Is(err, ErrUnknownInstance) {
+		code = nacosUnknownInstance
+	} else if err != nil {
+		nacosReply(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"code": code, "clientBeatInterval": a.registry.ttl.Milliseconds() / 3})
+}
+
+// instanceParams reads the instance from the query or the form, like nacos does
+func instanceParams(r *http.Request) (service, ip string, port int64, err error) {
+	service, ip = serviceName(r), r.FormValue("ip")
+	if service == "" || ip == "" {
+		return "", "", 0, errors.New("serviceName, ip and port are required")
+	}
+	if port, err = strconv.ParseInt(r.FormValue("port"), 10, 64); err != nil {
+		return "", "", 0, fmt.Errorf("invalid port %q", r.FormValue("port"))
+	}
+	return service, ip, port, nil
+}
+
+// serviceName drops the group of a grouped nacos service name, the registry has a single group
+func serviceName(r *http.Request) string {
+	name := r.FormValue("serviceName")
+	if i := strings.Index(name, "@@"); i >= 0 {
+		name = name[i+2:]
+	}
+	return name
+}
+
+// nacosReply answers ok like nacos, or the error as plain text
+func nacosReply(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownInstance):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,316 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/proto"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownInstance is returned for a heartbeat or deregistration of an instance that is not registered
+var ErrUnknownInstance = errors.New("unknown instance")
+
+// Instance is a registered address of a service
+type Instance struct {
+	Service  string            `json:"serviceName" yaml:"-"`
+	IP       string            `json:"ip" yaml:"ip"`
+	Port     int64             `json:"port" yaml:"port"`
+	Weight   float64           `json:"weight" yaml:"weight"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata"`
+	// Ephemeral instances must send heartbeats, persistent ones stay until they are deregistered
+	Ephemeral bool `json:"ephemeral" yaml:"-"`
+	Healthy   bool `json:"healthy" yaml:"-"`
+
+	lastBeat time.Time
+}
+
+// ID is the address of the instance
+func (i *Instance) ID() string {
+	return net.JoinHostPort(i.IP, strconv.FormatInt(i.Port, 10))
+}
+
+// Registry is a service registry with the semantics of nacos naming: ephemeral instances turn unhealthy
+// when their heartbeats stop for ttl and are removed after twice ttl, persistent instances stay until
+// they are deregistered. The clusters of the config that name a service in eds_cluster_config get the
+// healthy instances of that service as endpoints.
+type Registry struct {
+	ttl time.Duration
+	// OnChange is called after the instances of a service changed, outside of the lock
+	OnChange func()
+
+	mu       sync.Mutex
+	services map[string]map[string]*Instance
+	now      func() time.Time
+}
+
+// NewRegistry returns an empty registry whose ephemeral instances expire after ttl
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl, services: map[string]map[string]*Instance{}, now: time.Now}
+}
+
+// Register adds the instance, or replaces the instance registered with the same address
+func (r *Registry) Register(inst Instance) error {
+	switch {
+	case inst.Service == "":
+		return errors.New("the service name is missing")
+	case inst.IP == "":
+		return errors.New("the ip is missing")
+	case inst.Port <= 0 || inst.Port > 65535:
+		return fmt.Errorf("invalid port %d", inst.Port)
+	case inst.Weight < 0:
+		return fmt.Errorf("invalid weight %v", inst.Weight)
+	}
+	r.mu.Lock()
+	instances := r.services[inst.Service]
+	if instances == nil {
+		instances = map[string]*Instance{}
+		r.services[inst.Service] = instances
+	}
+	inst.Healthy = true
+	inst.lastBeat = r.now()
+	changed := !instances[inst.ID()].equal(&inst)
+	instances[inst.ID()] = &inst
+	r.mu.Unlock()
+
+	if changed {
+		r.changed()
+	}
+	return nil
+}
+
+// Deregister removes the instance of service at ip and port
+func (r *Registry) Deregister(service, ip string, port int64) error {
+	r.mu.Lock()
+	inst, err := r.lookup(service, ip, port)
+	if err == nil {
+		delete(r.services[service], inst.ID())
+		if len(r.services[service]) == 0 {
+			delete(r.services, service)
+		}
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	r.changed()
+	return nil
+}
+
+// Beat renews the heartbeat of an ephemeral instance, an unhealthy instance turns healthy again
+func (r *Registry) Beat(service, ip string, port int64) error {
+	r.mu.Lock()
+	inst, err := r.lookup(service, ip, port)
+	revived := false
+	if err == nil {
+		inst.lastBeat = r.now()
+		revived = !inst.Healthy
+		inst.Healthy = true
+	}
+	r.mu.Unlock()
+
+	if revived {
+		r.changed()
+	}
+	return err
+}
+
+// Instances returns every instance of service, healthy or not, ordered by address
+func (r *Registry) Instances(service string) []Instance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	instances := make([]Instance, 0, len(r.services[service]))
+	for _, inst := range r.services[service] {
+		instances = append(instances, *inst)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID() < instances[j].ID() })
+	return instances
+}
+
+// Services returns the names of the services with instances
+func (r *Registry) Services() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	services := make([]string, 0, len(r.services))
+	for service := range r.services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// Expire marks the ephemeral instances whose heartbeats stopped for ttl unhealthy, and removes them after twice ttl
+func (r *Registry) Expire() {
+	r.mu.Lock()
+	now := r.now()
+	changed := false
+	for service, instances := range r.services {
+		for id, inst := range instances {
+			if !inst.Ephemeral {
+				continue
+			}
+			silent := now.Sub(inst.lastBeat)
+			switch {
+			case silent >= 2*r.ttl:
+				delete(instances, id)
+				changed = true
+				l.Debugf("instance %s of %s removed, no heartbeat for %s", id, service, silent)
+			case silent >= r.ttl && inst.Healthy:
+				inst.Healthy = false
+				changed = true
+				l.Debugf("instance %s of %s unhealthy, no heartbeat for %s", id, service, silent)
+			}
+		}
+		if len(instances) == 0 {
+			delete(r.services, service)
+		}
+	}
+	r.mu.Unlock()
+
+	if changed {
+		r.changed()
+	}
+}
+
+// Run expires the instances until stop is closed, a third of the ttl apart
+func (r *Registry) Run(stop <-chan struct{}) {
+	interval := r.ttl / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Expire()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Resolve returns clusters with the healthy instances of their eds_cluster_config service as endpoints.
+// The clusters without a service are returned as they are. A nil registry has no instances.
+func (r *Registry) Resolve(clusters []*pixiupb.Cluster) []*pixiupb.Cluster {
+	resolved := make([]*pixiupb.Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		service := c.GetEdsClusterConfig().GetServiceName()
+		if service == "" {
+			resolved = append(resolved, c)
+			continue
+		}
+		c = proto.Clone(c).(*pixiupb.Cluster)
+		c.Endpoints = nil
+		if r != nil {
+			for _, inst := range r.Instances(service) {
+				if inst.Healthy && inst.Weight > 0 {
+					c.Endpoints = append(c.Endpoints, inst.endpoint())
+				}
+			}
+		}
+		resolved = append(resolved, c)
+	}
+	return resolved
+}
+
+// LoadFile registers the persistent instances of a yaml file that lists them by service:
+//
+//	services:
+//	  orders:
+//	    - ip: 127.0.0.1
+//	      port: 8081
+func (r *Registry) LoadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Services map[string][]Instance `yaml:"services"`
+	}
+	if err = yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for service, instances := range file.Services {
+		for _, inst := range instances {
+			inst.Service = service
+			if inst.Weight == 0 {
+				inst.Weight = 1
+			}
+			if err = r.Register(inst); err != nil {
+				return fmt.Errorf("%s: service %s: %w", path, service, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Registry) lookup(service, ip string, port int64) (*Instance, error) {
+	inst := &Instance{IP: ip, Port: port}
+	found, ok := r.services[service][inst.ID()]
+	if !ok {
+		return nil, fmt.Errorf("%s of %s: %w", inst.ID(), service, ErrUnknownInstance)
+	}
+	return found, nil
+}
+
+func (r *Registry) changed() {
+	if r.OnChange != nil {
+		r.OnChange()
+	}
+}
+
+func (i *Instance) endpoint() *pixiupb.Endpoint {
+	metadata := make(map[string]string, len(i.Metadata)+1)
+	for k, v := range i.Metadata {
+		metadata[k] = v
+	}
+	metadata["weight"] = strconv.FormatFloat(i.Weight, 'f', -1, 64)
+	return &pixiupb.Endpoint{
+		Id:       i.ID(),
+		Name:     i.Service,
+		Address:  &pixiupb.SocketAddress{Address: i.IP, Port: i.Port},
+		Metadata: metadata,
+	}
+}
+
+// equal tells whether registering other changes the instance, a heartbeat alone does not
+func (i *Instance) equal(other *Instance) bool {
+	if i == nil || i.Weight != other.Weight || i.Ephemeral != other.Ephemeral || !i.Healthy || len(i.Metadata) != len(other.Metadata) {
+		return false
+	}
+	for k, v := range i.Metadata {
+		if other.Metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegistryCDS = `
+clusters:
+  - name: orders
+    type: EDS
+    lb_policy: RoundRobin
+    eds_cluster_config:
+      service_name: orders
+`
+
+func endpointIDs(cluster *pixiupb.Cluster) []string {
+	ids := []string{}
+	for _, e := range cluster.Endpoints {
+		ids = append(ids, e.Id)
+	}
+	return ids
+}
+
+func TestRegistryExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewRegistry(10 * time.Second)
+	r.now = func() time.Time { return now }
+	changes := 0
+	r.OnChange = func() { changes++ }
+	orders := []*pixiupb.Cluster{{Name: "orders", EdsClusterConfig: &pixiupb.EdsClusterConfig{ServiceName: "orders"}}}
+
+	require.NoError(t, r.Register(Instance{Service: "orders", IP: "10.0.0.1", Port: 8080, Weight: 1, Ephemeral: true}))
+	require.NoError(t, r.Register(Instance{Service: "orders", IP: "10.0.0.2", Port: 8080, Weight: 1}))
+	require.NoError(t, r.Register(Instance{Service: "orders", IP: "10.0.0.3", Port: 8080, Weight: 0}))
+	assert.Equal(t, 3, changes)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, endpointIDs(r.Resolve(orders)[0]))
+
+	// registering the same instance again is a heartbeat
+	require.NoError(t, r.Register(Instance{Service: "orders", IP: "10.0.0.1", Port: 8080, Weight: 1, Ephemeral: true}))
+	assert.Equal(t, 3, changes)
+
+	// without heartbeats the ephemeral instance turns unhealthy, a heartbeat revives it
+	now = now.Add(10 * time.Second)
+	r.Expire()
+	assert.Equal(t, 4, changes)
+	assert.Equal(t, []string{"10.0.0.2:8080"}, endpointIDs(r.Resolve(orders)[0]))
+	require.NoError(t, r.Beat("orders", "10.0.0.1", 8080))
+	assert.Equal(t, 5, changes)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, endpointIDs(r.Resolve(orders)[0]))
+
+	// twice the ttl removes it, the persistent instance stays
+	now = now.Add(20 * time.Second)
+	r.Expire()
+	assert.Len(t, r.Instances("orders"), 2)
+	assert.ErrorIs(t, r.Beat("orders", "10.0.0.1", 8080), ErrUnknownInstance)
+
+	require.NoError(t, r.Deregister("orders", "10.0.0.2", 8080))
+	require.NoError(t, r.Deregister("orders", "10.0.0.3", 8080))
+	assert.Empty(t, r.Services())
+	assert.Empty(t, r.Resolve(orders)[0].Endpoints)
+	assert.ErrorIs(t, r.Deregister("orders", "10.0.0.2", 8080), ErrUnknownInstance)
+}
+
+func TestRegistryTTL(t *testing.T) {
+	defer func(ttl time.Duration) { registryTTL = ttl }(registryTTL)
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		registryTTL = ttl
+		assert.Error(t, checkFlags(), "ttl %s", ttl)
+	}
+	registryTTL = time.Nanosecond
+	assert.NoError(t, checkFlags())
+
+	// a ttl shorter than three ticks of the clock still runs
+	stop := make(chan struct{})
+	close(stop)
+	NewRegistry(time.Nanosecond).Run(stop)
+}
+
+func TestRegistryLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  orders:
+    - ip: 127.0.0.1
+      port: 8081
+    - ip: 127.0.0.1
+      port: 8082
+      weight: 2
+      metadata:
+        zone: b
+`), 0o644))
+	r := NewRegistry(time.Minute)
+	require.NoError(t, r.LoadFile(path))
+
+	instances := r.Instances("orders")
+	require.Len(t, instances, 2)
+	assert.False(t, instances[0].Ephemeral)
+	assert.Equal(t, 1.0, instances[0].Weight)
+	resolved := r.Resolve([]*pixiupb.Cluster{{Name: "orders", EdsClusterConfig: &pixiupb.EdsClusterConfig{ServiceName: "orders"}}})
+	assert.Equal(t, map[string]string{"weight": "2", "zone": "b"}, resolved[0].Endpoints[1].Metadata)
+
+	require.NoError(t, os.WriteFile(path, []byte("services:\n  orders:\n    - ip: 127.0.0.1\n"), 0o644))
+	assert.ErrorContains(t, NewRegistry(time.Minute).LoadFile(path), "invalid port 0")
+}
+
+func TestNacosAPI(t *testing.T) {
+	r := NewRegistry(15 * time.Second)
+	srv := httptest.NewServer(NewNacosAPI(r))
+	defer srv.Close()
+
+	call := func(method, path string, params url.Values) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+path+"?"+params.Encode(), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := call(http.MethodPost, "/nacos/v1/ns/instance", url.Values{
+		"serviceName": {"DEFAULT_GROUP@@orders"}, "ip": {"10.0.0.1"}, "port": {"8080"}, "weight": {"2"}, "metadata": {`{"zone":"a"}`},
+	})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body)
+
+	code, body = call(http.MethodGet, "/nacos/v1/ns/instance/list", url.Values{"serviceName": {"orders"}})
+	require.Equal(t, http.StatusOK, code)
+	var list struct {
+		Name  string
+		Hosts []Instance
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	assert.Equal(t, "orders", list.Name)
+	require.Len(t, list.Hosts, 1)
+	assert.Equal(t, Instance{Service: "orders", IP: "10.0.0.1", Port: 8080, Weight: 2, Metadata: map[string]string{"zone": "a"}, Ephemeral: true, Healthy: true}, list.Hosts[0])
+
+	_, body = call(http.MethodPut, "/nacos/v1/ns/instance/beat", url.Values{"serviceName": {"orders"}, "ip": {"10.0.0.1"}, "port": {"8080"}})
+	assert.JSONEq(t, `{"code": 10200, "clientBeatInterval": 5000}`, body)
+	_, body = call(http.MethodPut, "/nacos/v1/ns/instance/beat", url.Values{"serviceName": {"orders"}, "ip": {"10.0.0.9"}, "port": {"8080"}})
+	assert.JSONEq(t, `{"code": 20404, "clientBeatInterval": 5000}`, body)
+
+	_, body = call(http.MethodGet, "/nacos/v1/ns/service/list", nil)
+	assert.JSONEq(t, `{"count": 1, "doms": ["orders"]}`, body)
+
+	code, _ = call(http.MethodPost, "/nacos/v1/ns/instance", url.Values{"serviceName": {"orders"}, "ip": {"10.0.0.1"}, "port": {"http"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = call(http.MethodDelete, "/nacos/v1/ns/instance", url.Values{"serviceName": {"orders"}, "ip": {"10.0.0.1"}, "port": {"8080"}})
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = call(http.MethodDelete, "/nacos/v1/ns/instance", url.Values{"serviceName": {"orders"}, "ip": {"10.0.0.1"}, "port": {"8080"}})
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPublisherFollowsRegistry(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cds.yaml"), []byte(testRegistryCDS), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lds.json"), []byte(fmt.Sprintf(testLDS, "orders")), 0o644))
+	registry := NewRegistry(time.Minute)
+	snapshots := cache.NewSnapshotCache(false, cache.IDHash{}, nil)
+	p := &publisher{dir: dir, nodeID: "node", snapshots: snapshots, version: 100, registry: registry}
+
+	// the cluster is served before the service has instances
+	require.NoError(t, p.publish(ctx))
+	assert.Equal(t, "101", p.current())
+	assert.Empty(t, p.clusters.Clusters[0].Endpoints)
+
+	require.NoError(t, registry.Register(Instance{Service: "orders", IP: "10.0.0.1", Port: 8080, Weight: 1, Ephemeral: true}))
+	require.NoError(t, p.publish(ctx))
+	assert.Equal(t, "102", p.current())
+	assert.Equal(t, []string{"10.0.0.1:8080"}, endpointIDs(p.clusters.Clusters[0]))
+	assert.Equal(t, "orders", p.clusters.Clusters[0].EdsClusterConfig.ServiceName)
+
+	// a heartbeat changes nothing pixiu sees
+	require.NoError(t, registry.Beat("orders", "10.0.0.1", 8080))
+	require.NoError(t, p.publish(ctx))
+	assert.Equal(t, "102", p.current())
+
+	// endpoints come either from the registry or from the file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cds.yaml"), []byte(testRegistryCDS+`    endpoints:
+      - socket_address:
+          address: 127.0.0.1
+          port: 8081
+`), 0o644))
+	err := p.publish(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `cds.yaml: cluster "orders" gets its endpoints from service "orders" and must not list endpoints`)
+}
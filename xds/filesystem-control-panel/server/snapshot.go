@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+// LoadSnapshot reads the fragments in dir, validates them and builds the snapshot of version
+func LoadSnapshot(dir, version string) (*cache.Snapshot, error) {
+	cds, lds, err := loadResources(dir)
+	if err != nil {
+		return nil, err
+	}
+	return controlplane.NewSnapshot(version, cds, lds)
+}
+
+func loadResources(dir string) (*pixiupb.PixiuExtensionClusters, *pixiupb.PixiuExtensionListeners, error) {
+	conf, err := LoadConfig(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = Validate(conf); err != nil {
+		return nil, nil, err
+	}
+	return &pixiupb.PixiuExtensionClusters{Clusters: conf.Clusters},
+		&pixiupb.PixiuExtensionListeners{Listeners: conf.Listeners}, nil
+}
+
+// publisher sets the snapshot loaded from dir for a node, a snapshot that fails to load is rejected
+// and the node keeps the last good one
+type publisher struct {
+	mu        sync.Mutex
+	dir       string
+	nodeID    string
+	snapshots cache.SnapshotCache
+	version   int
+	// split serves a resource per cluster and listener, see NewSplitSnapshot
+	split bool
+	// registry has the endpoints of the clusters that name a service
+	registry *Registry
+	// history retains the configs that were served, for rollbacks
+	history *History
+
+	clusters  *pixiupb.PixiuExtensionClusters
+	listeners *pixiupb.PixiuExtensionListeners
+	// files is the hash of the config last loaded from dir, pinned the one a rollback was done with.
+	// A pinned publisher keeps serving the rollback until the files change.
+	files  string
+	pinned string
+}
+
+// publish loads dir and sets it as the snapshot of the node, the error tells why it was rejected.
+// Nothing is pushed when the config did not change.
+func (p *publisher) publish(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cds, lds, err := loadResources(p.dir)
+	if err != nil {
+		return err
+	}
+	p.files = configHash(cds, lds)
+	if p.pinned != "" {
+		if p.pinned == p.files {
+			return nil
+		}
+		l.Debugf("the files of %s changed, the rollback of group %s is over", p.dir, p.nodeID)
+		p.pinned = ""
+	}
+	cds.Clusters = p.registry.Resolve(cds.Clusters)
+	if proto.Equal(cds, p.clusters) && proto.Equal(lds, p.listeners) {
+		return nil
+	}
+	_, err = p.set(ctx, cds, lds, "files")
+	return err
+}
+
+// rollback serves the config of version again as a new version, until the files change
+func (p *publisher) rollback(ctx context.Context, version string) (*Revision, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rev, err := p.history.Get(version)
+	if err != nil {
+		return nil, err
+	}
+	rolledBack, err := p.set(ctx, rev.clusters, rev.listeners, "rollback to "+version)
+	if err != nil {
+		return nil, err
+	}
+	p.pinned = p.files
+	return rolledBack, nil
+}
+
+// set pushes the config as the next version and records it in the history
+func (p *publisher) set(ctx context.Context, cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners, note string) (*Revision, error) {
+	build := controlplane.NewSnapshot
+	if p.split {
+		build = controlplane.NewSplitSnapshot
+	}
+	snap, err := build(strconv.Itoa(p.version+1), cds, lds)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.snapshots.SetSnapshot(ctx, p.nodeID, snap); err != nil {
+		return nil, err
+	}
+	p.version++
+	p.clusters, p.listeners = cds, lds
+	rev := newRevision(p.version, cds, lds, note)
+	if err = p.history.Add(rev); err != nil {
+		l.Errorf("group %s: version %s is served but not recorded: %v", p.nodeID, rev.Version, err)
+	}
+	return rev, nil
+}
+
+// republish pushes the config being served again as the next version, the watches of the nodes
+// that moved to another group are answered and their next request is for the new group
+func (p *publisher) republish(ctx context.Context, note string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clusters == nil {
+		return nil
+	}
+	_, err := p.set(ctx, p.clusters, p.listeners, note)
+	return err
+}
+
+// config returns the config being served, nil before the first one
+func (p *publisher) config() (*pixiupb.PixiuExtensionClusters, *pixiupb.PixiuExtensionListeners) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clusters, p.listeners
+}
+
+// current returns the version of the snapshot being served, or "none"
+func (p *publisher) current() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clusters == nil {
+		return "none"
+	}
+	return strconv.Itoa(p.version)
+}
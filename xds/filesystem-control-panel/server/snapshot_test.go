@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCDS = `{"clusters": [{"name": "http_bin", "endpoints": [{"address": {"address": "127.0.0.1", "port": "8081"}}]}]}`
+
+const testLDS = `{"listeners": [{
+  "name": "net/http",
+  "address": {"socketAddress": {"address": "0.0.0.0", "port": "8888"}},
+  "filterChain": {"filters": [{
+    "name": "dgp.filter.httpconnectionmanager",
+    "struct": {"route_config": {"routes": [{"match": {"prefix": "/"}, "route": {"cluster": "%s"}}]}}
+  }]}
+}]}`
+
+func writeConfig(t *testing.T, dir, cds, lds string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cds.json"), []byte(cds), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lds.json"), []byte(lds), 0o644))
+}
+
+func TestLoadSnapshotOfSample(t *testing.T) {
+	for _, dir := range []string{"../config", "../json"} {
+		snap, err := LoadSnapshot(dir, "1")
+		require.NoError(t, err, dir)
+		assert.Len(t, snap.GetResources(resource.ExtensionConfigType), 2, dir)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		cds      string
+		lds      string
+		problems []string
+	}{
+		{
+			name: "valid",
+			cds:  testCDS,
+			lds:  fmt.Sprintf(testLDS, "http_bin"),
+		},
+		{
+			name:     "malformed json",
+			cds:      `{"clusters": [`,
+			lds:      fmt.Sprintf(testLDS, "http_bin"),
+			problems: []string{"cds.json:"},
+		},
+		{
+			name:     "unknown cluster",
+			cds:      testCDS,
+			lds:      fmt.Sprintf(testLDS, "missing"),
+			problems: []string{`listener "net/http" routes[0] points at unknown cluster "missing"`},
+		},
+		{
+			name: "duplicate cluster and bad endpoint",
+			cds:  `{"clusters": [{"name": "http_bin"}, {"name": "http_bin", "endpoints": [{"address": {"port": "70000"}}]}]}`,
+			lds:  fmt.Sprintf(testLDS, "http_bin"),
+			problems: []string{
+				`cds.json: cluster "http_bin" is already defined in cds.json`,
+				`cluster "http_bin" endpoints[0] has no address`,
+				`cluster "http_bin" endpoints[0] has invalid port 70000`,
+			},
+		},
+		{
+			name: "duplicate listener port",
+			cds:  testCDS,
+			lds: `{"listeners": [
+  {"name": "a", "address": {"socketAddress": {"address": "0.0.0.0", "port": "8888"}}},
+  {"name": "b", "address": {"socketAddress": {"address": "127.0.0.1", "port": "8888"}}},
+  {"name": "b", "address": {"socketAddress": {"address": "0.0.0.0"}}}
+]}`,
+			problems: []string{
+				`listener "b" binds port 8888 already bound by listener "a"`,
+				`lds.json: listener "b" is already defined in lds.json`,
+				`listener "b" has invalid port 0`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfig(t, dir, tt.cds, tt.lds)
+			snap, err := LoadSnapshot(dir, "1")
+			if len(tt.problems) == 0 {
+				require.NoError(t, err)
+				assert.NoError(t, snap.Consistent())
+				return
+			}
+			require.Error(t, err)
+			for _, problem := range tt.problems {
+				assert.Contains(t, err.Error(), problem)
+			}
+		})
+	}
+}
+
+func TestPublisherKeepsLastGoodSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	snapshots := cache.NewSnapshotCache(false, cache.IDHash{}, nil)
+	p := &publisher{dir: dir, nodeID: "node", snapshots: snapshots, version: 100}
+
+	writeConfig(t, dir, "{", fmt.Sprintf(testLDS, "http_bin"))
+	assert.Error(t, p.publish(ctx))
+	assert.Equal(t, "none", p.current())
+
+	writeConfig(t, dir, testCDS, fmt.Sprintf(testLDS, "http_bin"))
+	require.NoError(t, p.publish(ctx))
+	assert.Equal(t, "101", p.current())
+
+	writeConfig(t, dir, testCDS, fmt.Sprintf(testLDS, "missing"))
+	err := p.publish(ctx)
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Problems, 1)
+
+	snap, err := snapshots.GetSnapshot("node")
+	require.NoError(t, err)
+	assert.Equal(t, "101", snap.GetVersion(resource.ExtensionConfigType))
+}
@@ -0,0 +1,263 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the clusters and listeners merged from the fragments of a directory
+type Config struct {
+	Clusters  []*pixiupb.Cluster
+	Listeners []*pixiupb.Listener
+
+	// the file every cluster and listener was read from, for the diagnostics
+	clusterFiles  []string
+	listenerFiles []string
+}
+
+// LoadConfig reads every json and yaml fragment of dir in name order and merges them.
+// Json fragments use the xds schema of cds.json and lds.json, yaml fragments the schema of
+// the static_resources of pixiu's conf.yaml, with or without the static_resources key.
+// Keys the xds api does not carry, like health_checks, are an error rather than dropped.
+func LoadConfig(dir string) (*Config, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && isFragment(e.Name()) {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+
+	conf := &Config{}
+	for _, name := range files {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Ext(name) == ".json" {
+			err = conf.addJSON(name, content)
+		} else {
+			err = conf.addYAML(name, content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return conf, nil
+}
+
+// isFragment tells the config files from hidden files and the backups editors leave around
+func isFragment(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func (c *Config) addCluster(file string, cluster *pixiupb.Cluster) {
+	c.Clusters = append(c.Clusters, cluster)
+	c.clusterFiles = append(c.clusterFiles, file)
+}
+
+func (c *Config) addListener(file string, listener *pixiupb.Listener) {
+	c.Listeners = append(c.Listeners, listener)
+	c.listenerFiles = append(c.listenerFiles, file)
+}
+
+func (c *Config) addJSON(file string, content []byte) error {
+	var parts map[string]json.RawMessage
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return err
+	}
+	for key, part := range parts {
+		switch key {
+		case "clusters":
+			cds := &pixiupb.PixiuExtensionClusters{}
+			if err := protojson.Unmarshal(wrap(key, part), cds); err != nil {
+				return err
+			}
+			for _, cluster := range cds.Clusters {
+				c.addCluster(file, cluster)
+			}
+		case "listeners":
+			lds := &pixiupb.PixiuExtensionListeners{}
+			if err := protojson.Unmarshal(wrap(key, part), lds); err != nil {
+				return err
+			}
+			for _, listener := range lds.Listeners {
+				c.addListener(file, listener)
+			}
+		default:
+			return fmt.Errorf("unknown key %q, expected clusters or listeners", key)
+		}
+	}
+	return nil
+}
+
+func wrap(key string, part json.RawMessage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "{%q:", key)
+	b.Write(part)
+	b.WriteString("}")
+	return b.Bytes()
+}
+
+// yamlResources is the static_resources of pixiu's conf.yaml, limited to what the xds api carries,
+// keys outside of it fail the fragment instead of being dropped
+type yamlResources struct {
+	StaticResources *yamlResources `yaml:"static_resources"`
+	Clusters        []yamlCluster  `yaml:"clusters"`
+	Listeners       []yamlListener `yaml:"listeners"`
+}
+
+type yamlCluster struct {
+	Name      string         `yaml:"name"`
+	Type      string         `yaml:"type"`
+	LbPolicy  string         `yaml:"lb_policy"`
+	Endpoints []yamlEndpoint `yaml:"endpoints"`
+	// EdsClusterConfig names the registry service the endpoints come from
+	EdsClusterConfig struct {
+		ServiceName string `yaml:"service_name"`
+	} `yaml:"eds_cluster_config"`
+}
+
+type yamlEndpoint struct {
+	ID            string            `yaml:"ID"`
+	Name          string            `yaml:"name"`
+	SocketAddress yamlSocketAddress `yaml:"socket_address"`
+	Meta          map[string]string `yaml:"meta"`
+}
+
+type yamlSocketAddress struct {
+	Address      string   `yaml:"address"`
+	Port         int64    `yaml:"port"`
+	ResolverName string   `yaml:"resolver_name"`
+	Domains      []string `yaml:"domains"`
+	CertsDir     string   `yaml:"certs_dir"`
+}
+
+type yamlListener struct {
+	Name         string `yaml:"name"`
+	ProtocolType string `yaml:"protocol_type"`
+	Address      struct {
+		Name          string            `yaml:"name"`
+		SocketAddress yamlSocketAddress `yaml:"socket_address"`
+	} `yaml:"address"`
+	FilterChains struct {
+		Filters []struct {
+			Name   string    `yaml:"name"`
+			Config yaml.Node `yaml:"config"`
+		} `yaml:"filters"`
+	} `yaml:"filter_chains"`
+}
+
+func (c *Config) addYAML(file string, content []byte) error {
+	var res yamlResources
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&res); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if res.StaticResources != nil {
+		res.Clusters = append(res.Clusters, res.StaticResources.Clusters...)
+		res.Listeners = append(res.Listeners, res.StaticResources.Listeners...)
+	}
+
+	for _, cl := range res.Clusters {
+		cluster := &pixiupb.Cluster{Name: cl.Name, TypeStr: cl.Type, LbStr: cl.LbPolicy}
+		if cl.EdsClusterConfig.ServiceName != "" {
+			cluster.EdsClusterConfig = &pixiupb.EdsClusterConfig{ServiceName: cl.EdsClusterConfig.ServiceName}
+		}
+		for _, e := range cl.Endpoints {
+			cluster.Endpoints = append(cluster.Endpoints, &pixiupb.Endpoint{
+				Id:       e.ID,
+				Name:     e.Name,
+				Address:  e.SocketAddress.proto(),
+				Metadata: e.Meta,
+			})
+		}
+		c.addCluster(file, cluster)
+	}
+
+	for _, l := range res.Listeners {
+		protocol := strings.ToUpper(l.ProtocolType)
+		if protocol == "" {
+			protocol = pixiupb.Listener_HTTP.String()
+		}
+		value, ok := pixiupb.Listener_Protocols_value[protocol]
+		if !ok {
+			return fmt.Errorf("listener %q has unknown protocol_type %q", l.Name, l.ProtocolType)
+		}
+		listener := &pixiupb.Listener{
+			Name:        l.Name,
+			Protocol:    pixiupb.Listener_Protocols(value),
+			Address:     &pixiupb.Address{Name: l.Address.Name, SocketAddress: l.Address.SocketAddress.proto()},
+			FilterChain: &pixiupb.FilterChain{},
+		}
+		for _, f := range l.FilterChains.Filters {
+			var config []byte
+			if f.Config.Kind != 0 {
+				var err error
+				if config, err = yaml.Marshal(&f.Config); err != nil {
+					return err
+				}
+			}
+			listener.FilterChain.Filters = append(listener.FilterChain.Filters, &pixiupb.NetworkFilter{
+				Name:   f.Name,
+				Config: &pixiupb.NetworkFilter_Yaml{Yaml: &pixiupb.Config{Content: string(config)}},
+			})
+		}
+		c.addListener(file, listener)
+	}
+	return nil
+}
+
+func (a yamlSocketAddress) proto() *pixiupb.SocketAddress {
+	return &pixiupb.SocketAddress{
+		Address:      a.Address,
+		Port:         a.Port,
+		ResolverName: a.ResolverName,
+		Domains:      a.Domains,
+		CertsDir:     a.CertsDir,
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+func TestLoadConfigYAMLMatchesJSON(t *testing.T) {
+	fromYAML, err := LoadConfig("../config")
+	require.NoError(t, err)
+	fromJSON, err := LoadConfig("../json")
+	require.NoError(t, err)
+
+	require.Len(t, fromYAML.Clusters, 1)
+	assert.True(t, proto.Equal(fromJSON.Clusters[0], fromYAML.Clusters[0]), "%v != %v", fromJSON.Clusters[0], fromYAML.Clusters[0])
+
+	require.Len(t, fromYAML.Listeners, 1)
+	listener := fromYAML.Listeners[0]
+	assert.Equal(t, fromJSON.Listeners[0].Name, listener.Name)
+	assert.Equal(t, pixiupb.Listener_HTTP2, listener.Protocol)
+	assert.True(t, proto.Equal(fromJSON.Listeners[0].Address, listener.Address))
+	routes, err := controlplane.RouteClusters(listener.FilterChain.Filters[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http_bin"}, routes)
+}
+
+func TestLoadConfigFragments(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("b-cluster.yml", `
+static_resources:
+  clusters:
+    - name: b
+      endpoints:
+        - socket_address: {address: 127.0.0.1, port: 8082}
+`)
+	write("a-cluster.yaml", `
+clusters:
+  - name: a
+    lb_policy: RoundRobin
+    endpoints:
+      - socket_address: {address: 127.0.0.1, port: 8081}
+        meta: {zone: a}
+`)
+	write("listener.json", `{"listeners": [{"name": "l", "address": {"socketAddress": {"port": "8888"}}}]}`)
+	write(".a-cluster.yaml.swp", "not yaml: [")
+	write("a-cluster.yaml~", "not yaml: [")
+	write("README.md", "# notes")
+
+	conf, err := LoadConfig(dir)
+	require.NoError(t, err)
+	require.Len(t, conf.Clusters, 2)
+	assert.Equal(t, "a", conf.Clusters[0].Name)
+	assert.Equal(t, "RoundRobin", conf.Clusters[0].LbStr)
+	assert.Equal(t, map[string]string{"zone": "a"}, conf.Clusters[0].Endpoints[0].Metadata)
+	assert.Equal(t, "b", conf.Clusters[1].Name)
+	assert.Equal(t, int64(8082), conf.Clusters[1].Endpoints[0].Address.Port)
+	require.Len(t, conf.Listeners, 1)
+	assert.Equal(t, "l", conf.Listeners[0].Name)
+	assert.Equal(t, []string{"a-cluster.yaml", "b-cluster.yml"}, conf.clusterFiles)
+
+	write("broken.yaml", "listeners:\n  - name: x\n    protocol_type: SMTP\n")
+	_, err = LoadConfig(dir)
+	assert.EqualError(t, err, `broken.yaml: listener "x" has unknown protocol_type "SMTP"`)
+
+	write("broken.yaml", `
+clusters:
+  - name: c
+    health_checks:
+      - protocol: tcp
+`)
+	_, err = LoadConfig(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken.yaml")
+	assert.Contains(t, err.Error(), "field health_checks not found")
+
+	write("broken.yaml", `{"routes": []}`)
+	write("broken.json", `{"routes": []}`)
+	_, err = LoadConfig(dir)
+	assert.EqualError(t, err, `broken.json: unknown key "routes", expected clusters or listeners`)
+}
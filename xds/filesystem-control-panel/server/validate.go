@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+const httpConnectionManager = "dgp.filter.httpconnectionmanager"
+
+// ValidationError lists every problem found in a snapshot
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid snapshot:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the rules pixiu relies on and the xds cache does not know about: names are set and unique,
+// endpoints and listeners have valid addresses, no two listeners bind the same port,
+// and every route points at a cluster that exists.
+func Validate(conf *Config) error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	clusters := map[string]string{}
+	for i, c := range conf.Clusters {
+		file := conf.clusterFiles[i]
+		switch {
+		case c.GetName() == "":
+			report("%s: a cluster has no name", file)
+		case clusters[c.GetName()] != "":
+			report("%s: cluster %q is already defined in %s", file, c.GetName(), clusters[c.GetName()])
+		default:
+			clusters[c.GetName()] = file
+		}
+		if service := c.GetEdsClusterConfig().GetServiceName(); service != "" && len(c.GetEndpoints()) > 0 {
+			report("%s: cluster %q gets its endpoints from service %q and must not list endpoints", file, c.GetName(), service)
+		}
+		for j, e := range c.GetEndpoints() {
+			if e.GetAddress().GetAddress() == "" {
+				report("%s: cluster %q endpoints[%d] has no address", file, c.GetName(), j)
+			}
+			if port := e.GetAddress().GetPort(); port <= 0 || port > 65535 {
+				report("%s: cluster %q endpoints[%d] has invalid port %d", file, c.GetName(), j, port)
+			}
+		}
+	}
+
+	names := map[string]string{}
+	ports := map[int64]string{}
+	for i, l := range conf.Listeners {
+		file := conf.listenerFiles[i]
+		switch {
+		case l.GetName() == "":
+			report("%s: a listener has no name", file)
+		case names[l.GetName()] != "":
+			report("%s: listener %q is already defined in %s", file, l.GetName(), names[l.GetName()])
+		default:
+			names[l.GetName()] = file
+		}
+
+		port := l.GetAddress().GetSocketAddress().GetPort()
+		if port <= 0 || port > 65535 {
+			report("%s: listener %q has invalid port %d", file, l.GetName(), port)
+		} else if other, ok := ports[port]; ok {
+			report("%s: listener %q binds port %d already bound by listener %q", file, l.GetName(), port, other)
+		} else {
+			ports[port] = l.GetName()
+		}
+
+		for _, f := range l.GetFilterChain().GetFilters() {
+			if f.GetName() != httpConnectionManager {
+				continue
+			}
+			routes, err := controlplane.RouteClusters(f)
+			if err != nil {
+				report("%s: listener %q filter %s: %v", file, l.GetName(), f.GetName(), err)
+				continue
+			}
+			for j, cluster := range routes {
+				if cluster == "" {
+					report("%s: listener %q routes[%d] has no cluster", file, l.GetName(), j)
+				} else if clusters[cluster] == "" {
+					report("%s: listener %q routes[%d] points at unknown cluster %q", file, l.GetName(), j, cluster)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
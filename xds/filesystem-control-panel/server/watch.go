@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/fsnotify/fsnotify"
+)
+
+// debouncer runs fn once triggers stopped arriving for delay
+type debouncer struct {
+	delay time.Duration
+	fn    func()
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func newDebouncer(delay time.Duration, fn func()) *debouncer {
+	return &debouncer{delay: delay, fn: fn}
+}
+
+func (d *debouncer) trigger() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.delay, d.fn)
+}
+
+// watch calls fn when the fragments of dir or of its group subdirectories change. The events are debounced,
+// so the write, rename and chmod an editor does to save a file end up in a single call. It returns when ctx is done.
+func watch(ctx context.Context, dir string, delay time.Duration, fn func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	dir = filepath.Clean(dir)
+	if err = watcher.Add(dir); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if err = watcher.Add(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	d := newDebouncer(delay, fn)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			name := filepath.Base(event.Name)
+			group := filepath.Dir(event.Name) == dir && !strings.HasPrefix(name, ".") && filepath.Ext(name) == ""
+			if !group && !isFragment(name) {
+				continue
+			}
+			if group && event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = watcher.Add(event.Name); err != nil {
+						log.Println("error:", err)
+					}
+				}
+			}
+			log.Println("event:", event)
+			d.trigger()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("error:", err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+const apiPrefix = "/api/v1/"
+
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// API is the management api of the store. Resources are read and written in the protojson
+// of the pixiu xds api, names are path escaped, so listener net/http is listeners/net%2Fhttp.
+//
+//	GET                /api/v1/clusters
+//	GET, PUT, DELETE   /api/v1/clusters/{name}
+//	GET, PUT, DELETE   /api/v1/clusters/{name}/endpoints/{id}
+//	GET                /api/v1/listeners
+//	GET, PUT, DELETE   /api/v1/listeners/{name}
+//
+// The group query parameter selects the node group, default otherwise. Every response carries the
+// version of what it returns as ETag, a change is only applied when its If-Match matches the current
+// version, and If-None-Match: * only creates a resource that does not exist yet.
+type API struct {
+	store *Store
+}
+
+// NewAPI returns the management api of store
+func NewAPI(store *Store) *API {
+	return &API{store: store}
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.EscapedPath(), apiPrefix) {
+		writeError(w, fmt.Errorf("%s: %w", r.URL.Path, ErrNotFound))
+		return
+	}
+	var path []string
+	for _, segment := range strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), apiPrefix), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", ErrInvalid, err))
+			return
+		}
+		path = append(path, unescaped)
+	}
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = controlplane.DefaultGroup
+	}
+
+	switch {
+	case len(path) == 1 && path[0] == "clusters" && r.Method == http.MethodGet:
+		clusters, version, err := a.store.Clusters(group)
+		a.reply(w, http.StatusOK, &pixiupb.PixiuExtensionClusters{Clusters: clusters}, version, err)
+	case len(path) == 1 && path[0] == "listeners" && r.Method == http.MethodGet:
+		listeners, version, err := a.store.Listeners(group)
+		a.reply(w, http.StatusOK, &pixiupb.PixiuExtensionListeners{Listeners: listeners}, version, err)
+	case len(path) == 2 && path[0] == "clusters":
+		a.cluster(w, r, group, path[1])
+	case len(path) == 4 && path[0] == "clusters" && path[2] == "endpoints":
+		a.endpoint(w, r, group, path[1], path[3])
+	case len(path) == 2 && path[0] == "listeners":
+		a.listener(w, r, group, path[1])
+	default:
+		writeError(w, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, ErrNotFound))
+	}
+}
+
+func (a *API) cluster(w http.ResponseWriter, r *http.Request, group, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		cluster, version, err := a.store.Cluster(group, name)
+		a.reply(w, http.StatusOK, cluster, version, err)
+	case http.MethodPut:
+		cluster := &pixiupb.Cluster{}
+		if err := readBody(r, cluster, &cluster.Name, name); err != nil {
+			writeError(w, err)
+			return
+		}
+		version, created, err := a.store.PutCluster(r.Context(), group, cluster, precondition(r))
+		a.reply(w, putStatus(created), cluster, version, err)
+	case http.MethodDelete:
+		a.reply(w, http.StatusNoContent, nil, 0, a.store.DeleteCluster(r.Context(), group, name, precondition(r)))
+	default:
+		writeError(w, errMethod(r))
+	}
+}
+
+func (a *API) endpoint(w http.ResponseWriter, r *http.Request, group, clusterName, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		cluster, version, err := a.store.Cluster(group, clusterName)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, e := range cluster.GetEndpoints() {
+			if e.GetId() == id {
+				a.reply(w, http.StatusOK, e, version, nil)
+				return
+			}
+		}
+		writeError(w, fmt.Errorf("endpoint %s of cluster %s: %w", id, clusterName, ErrNotFound))
+	case http.MethodPut:
+		endpoint := &pixiupb.Endpoint{}
+		if err := readBody(r, endpoint, &endpoint.Id, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		version, created, err := a.store.PutEndpoint(r.Context(), group, clusterName, endpoint, precondition(r))
+		a.reply(w, putStatus(created), endpoint, version, err)
+	case http.MethodDelete:
+		version, err := a.store.DeleteEndpoint(r.Context(), group, clusterName, id, precondition(r))
+		a.reply(w, http.StatusNoContent, nil, version, err)
+	default:
+		writeError(w, errMethod(r))
+	}
+}
+
+func (a *API) listener(w http.ResponseWriter, r *http.Request, group, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		listener, version, err := a.store.Listener(group, name)
+		a.reply(w, http.StatusOK, listener, version, err)
+	case http.MethodPut:
+		listener := &pixiupb.Listener{}
+		if err := readBody(r, listener, &listener.Name, name); err != nil {
+			writeError(w, err)
+			return
+		}
+		version, created, err := a.store.PutListener(r.Context(), group, listener, precondition(r))
+		a.reply(w, putStatus(created), listener, version, err)
+	case http.MethodDelete:
+		a.reply(w, http.StatusNoContent, nil, 0, a.store.DeleteListener(r.Context(), group, name, precondition(r)))
+	default:
+		writeError(w, errMethod(r))
+	}
+}
+
+func (a *API) reply(w http.ResponseWriter, status int, m proto.Message, version int, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if version > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+	}
+	if m == nil {
+		w.WriteHeader(status)
+		return
+	}
+	body, err := protojson.Marshal(m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// readBody decodes the resource of the request, whose name must be empty or the one of the path
+func readBody(r *http.Request, m proto.Message, name *string, pathName string) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err = protojson.Unmarshal(body, m); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if *name == "" {
+		*name = pathName
+	}
+	if *name != pathName {
+		return fmt.Errorf("%w: the body names %q, the path %q", ErrInvalid, *name, pathName)
+	}
+	return nil
+}
+
+// precondition returns the version the request expects the resource in
+func precondition(r *http.Request) string {
+	if r.Header.Get("If-None-Match") == "*" {
+		return Missing
+	}
+	match := strings.TrimPrefix(r.Header.Get("If-Match"), "W/")
+	if match == "" || match == "*" {
+		return AnyVersion
+	}
+	return strings.Trim(match, `"`)
+}
+
+func putStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+func errMethod(r *http.Request) error {
+	return fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, errMethodNotAllowed)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrVersionMismatch):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, errMethodNotAllowed):
+		status = http.StatusMethodNotAllowed
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
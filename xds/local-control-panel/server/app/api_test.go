@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+func newTestAPI(t *testing.T) (*httptest.Server, *Store, cache.SnapshotCache) {
+	groups := &controlplane.NodeGroups{}
+	snapshots := cache.NewSnapshotCache(false, groups, nil)
+	store := NewStore(groups, snapshots)
+	require.NoError(t, store.Load(context.Background(), controlplane.DefaultGroup, makeClusters(), makeListeners()))
+	srv := httptest.NewServer(NewAPI(store))
+	t.Cleanup(srv.Close)
+	return srv, store, snapshots
+}
+
+func call(t *testing.T, method, url, body string, headers ...string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(content)
+}
+
+func TestAPICluster(t *testing.T) {
+	srv, _, snapshots := newTestAPI(t)
+	url := srv.URL + "/api/v1/clusters/http_bin"
+
+	resp, body := call(t, http.MethodGet, url, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	assert.Contains(t, body, "httpbin.org")
+
+	cluster := `{"typeStr": "http", "endpoints": [{"id": "backend", "address": {"address": "127.0.0.1", "port": "8080"}}]}`
+	resp, body = call(t, http.MethodPut, url, cluster, "If-Match", `"1"`)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	snap, err := snapshots.GetSnapshot(controlplane.DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, "2", snap.GetVersion(resource.ExtensionConfigType))
+
+	// the cluster changed since version 1
+	resp, body = call(t, http.MethodPut, url, cluster, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, body)
+
+	resp, body = call(t, http.MethodPut, srv.URL+"/api/v1/clusters/other", cluster, "If-None-Match", "*")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	assert.Contains(t, body, `"name":"other"`)
+	resp, _ = call(t, http.MethodPut, srv.URL+"/api/v1/clusters/other", cluster, "If-None-Match", "*")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, body = call(t, http.MethodDelete, url, "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Contains(t, body, "routes to unknown cluster http_bin")
+	resp, _ = call(t, http.MethodDelete, srv.URL+"/api/v1/clusters/other", "", "If-Match", `"3"`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = call(t, http.MethodGet, srv.URL+"/api/v1/clusters", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+	assert.NotContains(t, body, "other")
+
+	resp, _ = call(t, http.MethodPut, url, `{"name": "renamed"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = call(t, http.MethodPut, url, `{"endpoints": 1}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = call(t, http.MethodGet, url+"?group=missing", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = call(t, http.MethodPost, url, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	srv, store, _ := newTestAPI(t)
+	url := srv.URL + "/api/v1/clusters/http_bin/endpoints/"
+
+	resp, body := call(t, http.MethodPut, url+"local", `{"address": {"address": "127.0.0.1", "port": "8080"}}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	resp, body = call(t, http.MethodGet, url+"local", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "8080")
+
+	resp, _ = call(t, http.MethodDelete, url+"backend", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	cluster, version, err := store.Cluster(controlplane.DefaultGroup, "http_bin")
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	require.Len(t, cluster.Endpoints, 1)
+	assert.Equal(t, "local", cluster.Endpoints[0].Id)
+
+	resp, _ = call(t, http.MethodDelete, url+"backend", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPIListener(t *testing.T) {
+	srv, _, _ := newTestAPI(t)
+
+	resp, body := call(t, http.MethodGet, srv.URL+"/api/v1/listeners/net%2Fhttp", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	listener := `{"address": {"socketAddress": {"address": "0.0.0.0", "port": "8888"}}}`
+	resp, body = call(t, http.MethodPut, srv.URL+"/api/v1/listeners/other", listener)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Contains(t, body, "port 8888")
+
+	listener = `{"address": {"socketAddress": {"address": "0.0.0.0", "port": "8889"}}}`
+	resp, body = call(t, http.MethodPut, srv.URL+"/api/v1/listeners/other", listener)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	resp, _ = call(t, http.MethodDelete, srv.URL+"/api/v1/listeners/net%2Fhttp", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = call(t, http.MethodGet, srv.URL+"/api/v1/listeners", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"name":"other"`)
+	assert.NotContains(t, body, "net/http")
+}
+
+func TestStoreCopy(t *testing.T) {
+	_, store, snapshots := newTestAPI(t)
+	ctx := context.Background()
+	groups := store.groups
+	node := &core.Node{Id: "pixiu-1", Cluster: "canary"}
+	assert.Equal(t, controlplane.DefaultGroup, groups.ID(node))
+
+	require.NoError(t, store.Copy(ctx, controlplane.DefaultGroup, "canary"))
+	assert.Equal(t, "canary", groups.ID(node))
+	require.NoError(t, store.Load(ctx, "canary", makeClusters(), makeListeners2()))
+
+	require.NoError(t, store.Copy(ctx, "canary", controlplane.DefaultGroup))
+	listeners, version, err := store.Listeners(controlplane.DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	require.Len(t, listeners, 1)
+	assert.Equal(t, "net/http889", listeners[0].Name)
+	snap, err := snapshots.GetSnapshot(controlplane.DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, "2", snap.GetVersion(resource.ExtensionConfigType))
+
+	_, version, err = store.Listener("canary", "net/http889")
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+}
@@ -5,7 +5,12 @@ go 1.16
 require (
 	github.com/apache/dubbo-go-pixiu v0.5.0-rc01.0.20221008085317-b71ac6ee18d6
 	github.com/dubbo-go-pixiu/pixiu-api v0.1.6-0.20220612115254-d9a176b25b99
+	github.com/dubbo-go-pixiu/samples/xds/controlplane v0.0.0
 	github.com/envoyproxy/go-control-plane v0.11.1-0.20230524094728-9239064ad72f
+	github.com/stretchr/testify v1.8.3
 	google.golang.org/grpc v1.56.3
 	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+replace github.com/dubbo-go-pixiu/samples/xds/controlplane => ../../../controlplane
@@ -20,62 +20,102 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 import (
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
 )
 
 var (
-	l      Logger
-	port   = uint(18000)
-	nodeID = "test-id"
+	l            = controlplane.Logger{Debug: true}
+	options      = controlplane.DefaultOptions()
+	canary       string
+	canaryDelay  = 30 * time.Second
+	promoteDelay time.Duration
 )
 
 func init() {
-	l = Logger{}
-	l.Debug = true
+	options.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&canary, "canary", canary, "group that gets the second listener config first")
+	flag.DurationVar(&canaryDelay, "canary-delay", canaryDelay, "when the canary group gets the second listener config")
+	flag.DurationVar(&promoteDelay, "promote-delay", promoteDelay, "when every group gets the config of the canary, never when 0")
 }
 
 func main() {
 	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create a snaphost
-	snaphost := cache.NewSnapshotCache(false, cache.IDHash{}, l)
+	// The nodes of a group share a snapshot, the management api edits them
+	cp := controlplane.New(options)
+	store := NewStore(cp.Groups, cp.Snapshots)
+	cp.Admin.Handle("/api/", NewAPI(store))
 
-	go func() {
-		// Create the config that we'll serve to Envoy
-		config := GenerateSnapshotPixiu()
-		if err := config.Consistent(); err != nil {
-			l.Errorf("config inconsistency: %+v\n%+v", config, err)
-			os.Exit(1)
+	// Serve the config built in go, and roll the second listener config out to the canary group
+	config := controlplane.SourceFunc(func(ctx context.Context, _ *controlplane.ControlPlane) error {
+		if err := store.Load(ctx, options.NodeID, makeClusters(), makeListeners()); err != nil {
+			return fmt.Errorf("config error: %w", err)
 		}
-		l.Debugf("will serve config %+v", config)
-
-		// Add the config to the snaphost
-		if err := snaphost.SetSnapshot(context.Background(), nodeID, config); err != nil {
-			l.Errorf("config error %q for %+v", err, config)
-			os.Exit(1)
+		if canary != "" {
+			rollout(ctx, store, options.NodeID)
 		}
+		return nil
+	})
+	if err := cp.Run(ctx, config); err != nil {
+		log.Fatal(err)
+	}
+}
 
-		//time.Sleep(30 * time.Second)
-		//config2 := GenerateSnapshotPixiu2()
-		//if err := config2.Consistent(); err != nil {
-		//	l.Errorf("config inconsistency: %+v\n%+v", config, err)
-		//	os.Exit(1)
-		//}
-		//if err := snaphost.SetSnapshot(context.Background(), nodeID, config2); err != nil {
-		//	l.Errorf("config error %q for %+v", err, config)
-		//	os.Exit(1)
-		//}
-	}()
+// rollout gives the canary group the second listener config first, and promotes it to every group later
+func rollout(ctx context.Context, store *Store, base string) {
+	// the canary group starts with the same config
+	if err := store.Copy(ctx, base, canary); err != nil {
+		l.Errorf("canary error: %v", err)
+		return
+	}
+	if !sleep(ctx, canaryDelay) {
+		return
+	}
+	clusters, _, err := store.Clusters(canary)
+	if err == nil {
+		err = store.Load(ctx, canary, &pixiupb.PixiuExtensionClusters{Clusters: clusters}, makeListeners2())
+	}
+	if err != nil {
+		l.Errorf("canary error: %v", err)
+		return
+	}
+	l.Debugf("group %s got the second listener config", canary)
+	if promoteDelay <= 0 {
+		return
+	}
+	if !sleep(ctx, promoteDelay) {
+		return
+	}
+	if err = store.Copy(ctx, canary, base); err != nil {
+		l.Errorf("promote error: %v", err)
+		return
+	}
+	l.Debugf("group %s promoted to every group", canary)
+}
 
-	// Run the xDS server
-	ctx := context.Background()
-	cb := &test.Callbacks{Debug: l.Debug}
-	srv := server.NewServer(ctx, snaphost, cb)
-	RunServer(ctx, srv, port)
+// sleep waits for d, false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
@@ -18,15 +18,7 @@
 package main
 
 import (
-	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
 	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
-
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-
-	"google.golang.org/protobuf/types/known/anypb"
 )
 
 func makeListeners2() *pixiupb.PixiuExtensionListeners {
@@ -44,23 +36,3 @@ func makeListeners2() *pixiupb.PixiuExtensionListeners {
 		},
 	}}
 }
-
-func GenerateSnapshotPixiu2() *cache.Snapshot {
-	ldsResource2, _ := anypb.New(makeListeners2())
-	cdsResource, _ := anypb.New(makeClusters())
-	snap, _ := cache.NewSnapshot("2",
-		map[resource.Type][]types.Resource{
-			resource.ExtensionConfigType: {
-				&core.TypedExtensionConfig{
-					Name:        xds.ClusterType,
-					TypedConfig: cdsResource,
-				},
-				&core.TypedExtensionConfig{
-					Name:        xds.ListenerType,
-					TypedConfig: ldsResource2,
-				},
-			},
-		},
-	)
-	return snap
-}
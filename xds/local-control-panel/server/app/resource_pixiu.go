@@ -20,15 +20,8 @@ package main
 import (
 	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
 
-	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
 	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
 
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
-
-	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
 	structpb2 "google.golang.org/protobuf/types/known/structpb"
 )
@@ -125,23 +118,3 @@ func makeClusters() *pixiupb.PixiuExtensionClusters {
 		},
 	}
 }
-
-func GenerateSnapshotPixiu() *cache.Snapshot {
-	ldsResource, _ := anypb.New(makeListeners())
-	cdsResource, _ := anypb.New(makeClusters())
-	snap, _ := cache.NewSnapshot("2",
-		map[resource.Type][]types.Resource{
-			resource.ExtensionConfigType: {
-				&core.TypedExtensionConfig{
-					Name:        xds.ClusterType,
-					TypedConfig: cdsResource,
-				},
-				&core.TypedExtensionConfig{
-					Name:        xds.ListenerType,
-					TypedConfig: ldsResource,
-				},
-			},
-		},
-	)
-	return snap
-}
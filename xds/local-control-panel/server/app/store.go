@@ -0,0 +1,408 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+import (
+	"github.com/apache/dubbo-go-pixiu/pkg/common/constant"
+
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/xds/controlplane"
+)
+
+var (
+	// ErrNotFound is returned for a resource the group does not have
+	ErrNotFound = errors.New("not found")
+	// ErrVersionMismatch is returned when the resource changed since the version the caller expects
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrConflict is returned when the change would break the config, like deleting a cluster a route uses
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid is returned for a resource that cannot be applied at all
+	ErrInvalid = errors.New("invalid")
+)
+
+const (
+	// AnyVersion disables the version check of a change
+	AnyVersion = ""
+	// Missing only lets a change create a resource that does not exist yet
+	Missing = "0"
+)
+
+// Store holds the clusters and listeners of every node group. Each change bumps the version of
+// the group and pushes its new snapshot to the nodes of the group.
+type Store struct {
+	groups    *controlplane.NodeGroups
+	snapshots cache.SnapshotCache
+
+	mu      sync.Mutex
+	configs map[string]*groupConfig
+}
+
+// groupConfig is the config of a group, with the version every resource was last changed in
+type groupConfig struct {
+	version   int
+	clusters  map[string]*versioned
+	listeners map[string]*versioned
+}
+
+type versioned struct {
+	version int
+	message proto.Message
+}
+
+// NewStore returns an empty store that publishes to snapshots
+func NewStore(groups *controlplane.NodeGroups, snapshots cache.SnapshotCache) *Store {
+	return &Store{groups: groups, snapshots: snapshots, configs: map[string]*groupConfig{}}
+}
+
+// Load replaces the config of a group
+func (s *Store) Load(ctx context.Context, group string, cds *pixiupb.PixiuExtensionClusters, lds *pixiupb.PixiuExtensionListeners) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := &groupConfig{clusters: map[string]*versioned{}, listeners: map[string]*versioned{}}
+	if current, ok := s.configs[group]; ok {
+		next.version = current.version
+	}
+	next.version++
+	for _, c := range cds.GetClusters() {
+		next.clusters[c.GetName()] = &versioned{version: next.version, message: c}
+	}
+	for _, l := range lds.GetListeners() {
+		next.listeners[l.GetName()] = &versioned{version: next.version, message: l}
+	}
+	return s.publish(ctx, group, next)
+}
+
+// Copy replaces the config of group to with the one of group from, like to promote a canary
+func (s *Store) Copy(ctx context.Context, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, ok := s.configs[from]
+	if !ok {
+		return fmt.Errorf("group %s: %w", from, ErrNotFound)
+	}
+	next := &groupConfig{version: 1, clusters: map[string]*versioned{}, listeners: map[string]*versioned{}}
+	if current, ok := s.configs[to]; ok {
+		next.version = current.version + 1
+	}
+	for name, v := range source.clusters {
+		next.clusters[name] = &versioned{version: next.version, message: v.message}
+	}
+	for name, v := range source.listeners {
+		next.listeners[name] = &versioned{version: next.version, message: v.message}
+	}
+	return s.publish(ctx, to, next)
+}
+
+// Version returns the version of a group
+func (s *Store) Version(group string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conf, ok := s.configs[group]
+	if !ok {
+		return 0, fmt.Errorf("group %s: %w", group, ErrNotFound)
+	}
+	return conf.version, nil
+}
+
+// Clusters returns the clusters of a group sorted by name, and the version of the group
+func (s *Store) Clusters(group string) ([]*pixiupb.Cluster, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conf, ok := s.configs[group]
+	if !ok {
+		return nil, 0, fmt.Errorf("group %s: %w", group, ErrNotFound)
+	}
+	return conf.clusterList(), conf.version, nil
+}
+
+// Listeners returns the listeners of a group sorted by name, and the version of the group
+func (s *Store) Listeners(group string) ([]*pixiupb.Listener, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conf, ok := s.configs[group]
+	if !ok {
+		return nil, 0, fmt.Errorf("group %s: %w", group, ErrNotFound)
+	}
+	return conf.listenerList(), conf.version, nil
+}
+
+// Cluster returns a cluster and the version it was last changed in
+func (s *Store) Cluster(group, name string) (*pixiupb.Cluster, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookup(group, name, (*groupConfig).clusterMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v.message.(*pixiupb.Cluster), v.version, nil
+}
+
+// Listener returns a listener and the version it was last changed in
+func (s *Store) Listener(group, name string) (*pixiupb.Listener, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookup(group, name, (*groupConfig).listenerMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v.message.(*pixiupb.Listener), v.version, nil
+}
+
+// PutCluster creates or replaces a cluster, match is the version the caller last saw it in, or AnyVersion.
+// It returns the new version of the cluster and whether it was created.
+func (s *Store) PutCluster(ctx context.Context, group string, cluster *pixiupb.Cluster, match string) (int, bool, error) {
+	return s.put(ctx, group, cluster.GetName(), cluster, match, (*groupConfig).clusterMap)
+}
+
+// PutListener creates or replaces a listener, like PutCluster
+func (s *Store) PutListener(ctx context.Context, group string, listener *pixiupb.Listener, match string) (int, bool, error) {
+	return s.put(ctx, group, listener.GetName(), listener, match, (*groupConfig).listenerMap)
+}
+
+// DeleteCluster removes a cluster no route uses, match is the version the caller last saw it in, or AnyVersion
+func (s *Store) DeleteCluster(ctx context.Context, group, name, match string) error {
+	return s.delete(ctx, group, name, match, (*groupConfig).clusterMap)
+}
+
+// DeleteListener removes a listener, like DeleteCluster
+func (s *Store) DeleteListener(ctx context.Context, group, name, match string) error {
+	return s.delete(ctx, group, name, match, (*groupConfig).listenerMap)
+}
+
+// PutEndpoint creates or replaces the endpoint of a cluster with the id of the endpoint,
+// match is the version the caller last saw the cluster in, or AnyVersion.
+func (s *Store) PutEndpoint(ctx context.Context, group, clusterName string, endpoint *pixiupb.Endpoint, match string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookup(group, clusterName, (*groupConfig).clusterMap)
+	if err != nil {
+		return 0, false, err
+	}
+	cluster := proto.Clone(v.message).(*pixiupb.Cluster)
+	created := true
+	for i, e := range cluster.Endpoints {
+		if e.GetId() == endpoint.GetId() {
+			cluster.Endpoints[i] = endpoint
+			created = false
+		}
+	}
+	if created {
+		cluster.Endpoints = append(cluster.Endpoints, endpoint)
+	}
+	version, _, err := s.change(ctx, group, clusterName, cluster, match, (*groupConfig).clusterMap)
+	return version, created, err
+}
+
+// DeleteEndpoint removes the endpoint of a cluster, match is the version the caller last saw the cluster in, or AnyVersion
+func (s *Store) DeleteEndpoint(ctx context.Context, group, clusterName, id, match string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookup(group, clusterName, (*groupConfig).clusterMap)
+	if err != nil {
+		return 0, err
+	}
+	cluster := proto.Clone(v.message).(*pixiupb.Cluster)
+	endpoints := cluster.Endpoints[:0]
+	for _, e := range cluster.Endpoints {
+		if e.GetId() != id {
+			endpoints = append(endpoints, e)
+		}
+	}
+	if len(endpoints) == len(cluster.Endpoints) {
+		return 0, fmt.Errorf("endpoint %s of cluster %s: %w", id, clusterName, ErrNotFound)
+	}
+	cluster.Endpoints = endpoints
+	version, _, err := s.change(ctx, group, clusterName, cluster, match, (*groupConfig).clusterMap)
+	return version, err
+}
+
+func (s *Store) lookup(group, name string, resources func(*groupConfig) map[string]*versioned) (*versioned, error) {
+	conf, ok := s.configs[group]
+	if !ok {
+		return nil, fmt.Errorf("group %s: %w", group, ErrNotFound)
+	}
+	v, ok := resources(conf)[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return v, nil
+}
+
+func (s *Store) put(ctx context.Context, group, name string, m proto.Message, match string, resources func(*groupConfig) map[string]*versioned) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.change(ctx, group, name, m, match, resources)
+}
+
+func (s *Store) delete(ctx context.Context, group, name, match string, resources func(*groupConfig) map[string]*versioned) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.lookup(group, name, resources); err != nil {
+		return err
+	}
+	_, _, err := s.change(ctx, group, name, nil, match, resources)
+	return err
+}
+
+// change sets, or removes when m is nil, a resource of a group and publishes the group
+func (s *Store) change(ctx context.Context, group, name string, m proto.Message, match string, resources func(*groupConfig) map[string]*versioned) (int, bool, error) {
+	if name == "" {
+		return 0, false, fmt.Errorf("%w: the resource has no name", ErrInvalid)
+	}
+	conf, ok := s.configs[group]
+	if !ok {
+		return 0, false, fmt.Errorf("group %s: %w", group, ErrNotFound)
+	}
+	current, exists := resources(conf)[name]
+	switch {
+	case match == AnyVersion:
+	case match == Missing && exists:
+		return 0, false, fmt.Errorf("%s: %w, it already exists", name, ErrVersionMismatch)
+	case match != Missing && (!exists || strconv.Itoa(current.version) != match):
+		return 0, false, fmt.Errorf("%s: %w", name, ErrVersionMismatch)
+	}
+
+	next := conf.clone()
+	next.version++
+	if m == nil {
+		delete(resources(next), name)
+	} else {
+		resources(next)[name] = &versioned{version: next.version, message: m}
+	}
+	if err := next.validate(); err != nil {
+		return 0, false, err
+	}
+	if err := s.publish(ctx, group, next); err != nil {
+		return 0, false, err
+	}
+	return next.version, !exists, nil
+}
+
+// publish sets the snapshot of a group and makes it the config of the group
+func (s *Store) publish(ctx context.Context, group string, conf *groupConfig) error {
+	snap, err := conf.snapshot()
+	if err != nil {
+		return err
+	}
+	if err = s.snapshots.SetSnapshot(ctx, group, snap); err != nil {
+		return err
+	}
+	s.configs[group] = conf
+
+	groups := make([]string, 0, len(s.configs))
+	for g := range s.configs {
+		if g != controlplane.DefaultGroup {
+			groups = append(groups, g)
+		}
+	}
+	s.groups.Set(groups...)
+	return nil
+}
+
+func (c *groupConfig) clusterMap() map[string]*versioned {
+	return c.clusters
+}
+
+func (c *groupConfig) listenerMap() map[string]*versioned {
+	return c.listeners
+}
+
+func (c *groupConfig) clone() *groupConfig {
+	next := &groupConfig{version: c.version, clusters: map[string]*versioned{}, listeners: map[string]*versioned{}}
+	for name, v := range c.clusters {
+		next.clusters[name] = v
+	}
+	for name, v := range c.listeners {
+		next.listeners[name] = v
+	}
+	return next
+}
+
+func (c *groupConfig) clusterList() []*pixiupb.Cluster {
+	clusters := make([]*pixiupb.Cluster, 0, len(c.clusters))
+	for _, name := range sortedNames(c.clusters) {
+		clusters = append(clusters, c.clusters[name].message.(*pixiupb.Cluster))
+	}
+	return clusters
+}
+
+func (c *groupConfig) listenerList() []*pixiupb.Listener {
+	listeners := make([]*pixiupb.Listener, 0, len(c.listeners))
+	for _, name := range sortedNames(c.listeners) {
+		listeners = append(listeners, c.listeners[name].message.(*pixiupb.Listener))
+	}
+	return listeners
+}
+
+func sortedNames(resources map[string]*versioned) []string {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate rejects the configs pixiu cannot apply: listeners sharing a port, and routes to unknown clusters
+func (c *groupConfig) validate() error {
+	ports := map[int64]string{}
+	for _, l := range c.listenerList() {
+		port := l.GetAddress().GetSocketAddress().GetPort()
+		if other, ok := ports[port]; ok {
+			return fmt.Errorf("%w: listener %s binds port %d already bound by listener %s", ErrConflict, l.GetName(), port, other)
+		}
+		ports[port] = l.GetName()
+		for _, f := range l.GetFilterChain().GetFilters() {
+			if f.GetName() != constant.HTTPConnectManagerFilter {
+				continue
+			}
+			clusters, err := controlplane.RouteClusters(f)
+			if err != nil {
+				return fmt.Errorf("%w: listener %s: %v", ErrInvalid, l.GetName(), err)
+			}
+			for _, cluster := range clusters {
+				if _, ok := c.clusters[cluster]; !ok {
+					return fmt.Errorf("%w: listener %s routes to unknown cluster %s", ErrConflict, l.GetName(), cluster)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *groupConfig) snapshot() (*cache.Snapshot, error) {
+	return controlplane.NewSnapshot(strconv.Itoa(c.version),
+		&pixiupb.PixiuExtensionClusters{Clusters: c.clusterList()},
+		&pixiupb.PixiuExtensionListeners{Listeners: c.listenerList()})
+}